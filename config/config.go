@@ -15,30 +15,84 @@ type Config struct {
 	// Ollama settings
 	OllamaURL      string // Ollama API URL (e.g., http://localhost:11434)
 	EmbeddingModel string // Embedding model name (e.g., qwen3-embedding:8b)
+	ModelKeepAlive int    // Interval in seconds for pings that keep the model loaded (0 = disabled)
+
+	NormalizeEmbeddings bool   // L2-normalize vectors returned by the model (search uses cosine distance either way)
+	EmbedTemplate       string // Embedding input template with {language}, {type}, {name}, {signature}, {content} (empty = built-in)
+
+	ReembedOnModelChange bool // Re-embed stored chunks after switching to another model of the same dimension, instead of blocking
+
+	// Local embedding backend (no Ollama)
+	EmbeddingBackend string // "ollama" or "local" (llama.cpp server started for LocalModelPath)
+	LocalModelPath   string // GGUF embedding model file used by the local backend
+	LlamaServerBin   string // llama.cpp server binary used by the local backend
 
 	// Web UI settings
-	WebUIEnabled bool // Enable web UI HTTP server
-	WebUIPort    int  // Port for web UI server
-	AutoOpenUI   bool // Auto-open browser when server starts
-	MaxPortRetry int  // Max ports to try if default is busy
+	WebUIEnabled   bool   // Enable web UI HTTP server
+	WebUIPort      int    // Port for web UI server
+	WebUIStaticDir string // Directory overriding embedded static files (empty = embedded only)
+	WebUIHost      string // Interface the web UI listens on (empty = all interfaces)
+	AutoOpenUI     bool   // Auto-open browser when server starts
+	MaxPortRetry   int    // Max ports to try if default is busy
 
 	// Indexing settings
-	AutoIndex        bool  // Auto-index current folder on startup
-	WatchEnabled     bool  // Enable file watching for auto-updates
-	DebounceMs       int   // Debounce delay for file watcher in ms
-	MaxFileSize      int64 // Maximum file size to index in bytes
-	MaxChunkSize     int   // Maximum chunk size for line-based fallback
-	ChunkOverlap     int   // Overlap lines for line-based chunking
-	EmbeddingWorkers int   // Number of parallel embedding workers (1-8)
+	AutoIndex          bool            // Auto-index current folder on startup
+	WatchEnabled       bool            // Enable file watching for auto-updates
+	DebounceMs         int             // Debounce delay for file watcher in ms
+	WatchIdleMin       int             // Stop a project's watcher after this many minutes without file changes or searches (0 = never)
+	MaxFileSize        int64           // Maximum file size to index in bytes (unless MaxReadBytes is set)
+	MaxReadBytes       int64           // Maximum bytes read per file; larger files, even over MaxFileSize, are truncated (0 = no limit)
+	MaxLineLength      int             // Files with a longer line or almost no whitespace (minified, base64) are skipped (0 = off)
+	InvalidUTF8        string          // Policy for files with invalid UTF-8: "sanitize" or "skip"
+	MaxChunkSize       int             // Maximum chunk size for line-based fallback
+	ChunkOverlap       int             // Overlap lines for line-based chunking
+	MaxSymbolParts     int             // Symbols needing more parts than this are stored as one truncated chunk (0 = no cap)
+	EmbeddingWorkers   int             // Max concurrent embedding requests to Ollama (1-8)
+	EmbedRatePerSec    float64         // Max embedding requests started per second, across all workers (0 = unlimited)
+	EmbeddingBatchSize int             // Texts sent per embedding request when indexing (1 = one request per text)
+	FileWorkers        int             // Number of files embedded and stored in parallel during indexing (1-8)
+	ParseWorkers       int             // Number of files read and parsed in parallel, ahead of embedding (1-8)
+	IndexComments      bool            // Index comment blocks and docstrings as separate "doc" chunks
+	IndexTests         bool            // Index test files; when false they are skipped entirely (not_tested is then not reported)
+	ExtractReferences  bool            // Extract the types/variables each symbol references ("Used By"); costs a walk of every symbol's subtree
+	ReferenceLanguages map[string]bool // Per-language override of ExtractReferences, keyed by language
+	MergeSmall         bool            // Merge runs of tiny sibling functions/methods into one chunk
+	StoreFullFiles     bool            // Also store each indexed file's content, so definitions are exact without the file on disk
+
+	// Access settings
+	AllowedRoots []string // If set, only folders under these roots may be scanned or indexed
+	SafeMode     bool     // Headless/CI defaults: no auto-index, browser, or applied updates; web UI on localhost
+
+	// Search settings
+	SearchCandidateMultiplier int     // Vector candidates fetched per requested result, scaled up per active filter
+	MaxSearchLimit            int     // Ceiling on the number of results one search may request (1-1000)
+	DedupChunks               bool    // Collapse content-identical chunks in search results
+	BoostBand                 float32 // Keyword boost only applies within this similarity of the top match (0 = all candidates)
+	QueryExpansion            bool    // Also search abbreviation/stem variants of every query
+	CollectFeedback           bool    // Record which search results were useful (feedback tool, /api/feedback)
+	MaxCallerNodes            int     // Max callers/referencers collected per result across all levels (0 = no cap)
+	MaxListResults            int     // Max rows returned per call by list-style tools such as api_surface (0 = no cap)
+	SymbolCaseSensitive       bool    // Caller, reference and definition lookups match symbol names case-sensitively
+
+	// Usage analysis settings
+	EntryPointPatterns []string // Extra symbol name patterns (path.Match syntax) never flagged as unused
+	GraphMinSimilarity float32  // Only results at or above this similarity contribute to the usage graph (0 = all)
 
 	// File filtering
-	ExcludeDirs []string // Directories to always exclude
-	ExcludeExts []string // File extensions to exclude (binary files)
-	IncludeExts []string // If set, only include these extensions
+	ExcludeDirs     []string // Directories to always exclude
+	HomeExcludeDirs []string // Directories excluded only directly under the home directory
+	ExcludeExts     []string // File extensions to exclude (binary files)
+	IncludeExts     []string // If set, only include these extensions
+
+	IndexHiddenFiles bool     // Index dotfiles/dot-directories even if gitignored (still subject to ExcludeDirs/ExcludeExts)
+	SkipDotfiles     bool     // Skip every dotfile/dot-directory not in DotfileAllowlist (overrides IndexHiddenFiles)
+	DotfileAllowlist []string // Dotfile/dot-directory names kept when SkipDotfiles is set
+	IndexCompressed  bool     // Decompress .gz files and index them by their inner extension (schema.sql.gz -> .sql)
 
 	// Auto-update settings
 	AutoUpdateEnabled bool // Enable automatic update checking
 	AutoUpdateApply   bool // Automatically apply updates (requires restart)
+	ReindexOnUpgrade  bool // Reindex all folders on startup if the index was built by an older chunker
 }
 
 // DefaultConfig returns the default configuration
@@ -47,20 +101,47 @@ func DefaultConfig() *Config {
 	dbPath := filepath.Join(homeDir, ".ssss-claude-plugin")
 
 	return &Config{
-		DBPath:           dbPath,
-		OllamaURL:        "http://localhost:11434",
-		EmbeddingModel:   "qwen3-embedding:8b",
-		WebUIEnabled:     true,
-		WebUIPort:        9420,
-		AutoOpenUI:       true, // Auto-open browser by default
-		MaxPortRetry:     10,   // Try up to 10 ports if busy
-		AutoIndex:        true, // Auto-index current folder by default
-		WatchEnabled:     true,
-		DebounceMs:       500,
-		MaxFileSize:      1024 * 1024, // 1MB
-		MaxChunkSize:     500,         // 500 lines per chunk
-		ChunkOverlap:     20,          // 20 lines overlap
-		EmbeddingWorkers: 4,           // 4 parallel embedding workers
+		DBPath:             dbPath,
+		OllamaURL:          "http://localhost:11434",
+		EmbeddingModel:     "qwen3-embedding:8b",
+		ModelKeepAlive:     0, // Keep-alive pings disabled by default
+		WebUIEnabled:       true,
+		WebUIPort:          9420,
+		AutoOpenUI:         true, // Auto-open browser by default
+		MaxPortRetry:       10,   // Try up to 10 ports if busy
+		AutoIndex:          true, // Auto-index current folder by default
+		WatchEnabled:       true,
+		DebounceMs:         500,
+		WatchIdleMin:       0,           // Watchers stay up while the server runs
+		MaxFileSize:        1024 * 1024, // 1MB
+		MaxReadBytes:       0,           // Read whole file (larger files are excluded by MaxFileSize)
+		MaxLineLength:      10000,       // 10KB; minified bundles are usually far longer
+		InvalidUTF8:        "sanitize",  // Replace invalid byte sequences
+		MaxChunkSize:       500,         // 500 lines per chunk
+		ChunkOverlap:       20,          // 20 lines overlap
+		MaxSymbolParts:     20,          // ~10k lines with the default chunk size
+		EmbeddingWorkers:   4,           // 4 parallel embedding workers
+		EmbedRatePerSec:    0,           // No throttling
+		EmbeddingBatchSize: 1,           // One text per request
+		FileWorkers:        2,           // 2 files embedded in parallel
+		ParseWorkers:       2,           // 2 files parsed ahead of embedding
+		IndexComments:      false,       // Comments stay part of their code chunks only
+		IndexTests:         true,        // Tests are searchable and count as callers
+		ExtractReferences:  true,        // Types report who uses them
+		MergeSmall:         false,       // One chunk per symbol
+		StoreFullFiles:     false,       // Chunk contents only
+
+		NormalizeEmbeddings: true,
+
+		EmbeddingBackend: "ollama",
+		LlamaServerBin:   "llama-server", // Looked up on PATH
+
+		SearchCandidateMultiplier: 5,   // limit*5 candidates with no filters
+		MaxSearchLimit:            50,  // Raise via MCP_MAX_SEARCH_LIMIT for batch tooling
+		BoostBand:                 0.1, // Name matches only reorder near-ties
+		MaxCallerNodes:            100, // Stop deep caller traversal after 100 nodes
+		MaxListResults:            200, // api_surface pages of 200 symbols
+		SymbolCaseSensitive:       true,
 
 		ExcludeDirs: []string{
 			".git",
@@ -81,7 +162,17 @@ func DefaultConfig() *Config {
 			"coverage",
 			".pytest_cache",
 			".mypy_cache",
+			// Caches and system dirs found when a home directory is indexed
+			".cache",
+			".ollama",
+			".npm",
+			".cargo",
+			".rustup",
+			".gradle",
+			".m2",
+			".Trash",
 		},
+		HomeExcludeDirs: []string{"Library", "AppData"}, // OS app data, not code, but common project dir names elsewhere
 
 		ExcludeExts: []string{
 			// Binary/compiled
@@ -105,6 +196,8 @@ func DefaultConfig() *Config {
 
 		IncludeExts: []string{}, // Empty means include all text files
 
+		DotfileAllowlist: []string{".github", ".gitlab-ci.yml", ".circleci"}, // CI config stays indexed
+
 		AutoUpdateEnabled: true, // Check for updates by default
 		AutoUpdateApply:   true, // Auto-apply updates by default
 	}
@@ -126,6 +219,40 @@ func LoadFromEnv() *Config {
 		cfg.EmbeddingModel = v
 	}
 
+	if v := os.Getenv("MCP_MODEL_KEEP_ALIVE"); v != "" {
+		if sec, err := strconv.Atoi(v); err == nil && sec >= 0 {
+			cfg.ModelKeepAlive = sec
+		}
+	}
+
+	if v := os.Getenv("MCP_NORMALIZE_EMBEDDINGS"); v != "" {
+		cfg.NormalizeEmbeddings = strings.ToLower(v) == "true" || v == "1"
+	}
+
+	if v := os.Getenv("MCP_REEMBED_ON_MODEL_CHANGE"); v != "" {
+		cfg.ReembedOnModelChange = strings.ToLower(v) == "true" || v == "1"
+	}
+
+	if v := os.Getenv("MCP_EMBED_TEMPLATE"); v != "" {
+		// Allow "\n" escapes, since newlines are awkward in env vars
+		cfg.EmbedTemplate = strings.ReplaceAll(v, `\n`, "\n")
+	}
+
+	if v := os.Getenv("MCP_EMBEDDING_BACKEND"); v != "" {
+		switch backend := strings.ToLower(v); backend {
+		case "ollama", "local":
+			cfg.EmbeddingBackend = backend
+		}
+	}
+
+	if v := os.Getenv("MCP_LOCAL_MODEL_PATH"); v != "" {
+		cfg.LocalModelPath = v
+	}
+
+	if v := os.Getenv("MCP_LLAMA_SERVER_BIN"); v != "" {
+		cfg.LlamaServerBin = v
+	}
+
 	if v := os.Getenv("MCP_WATCH_ENABLED"); v != "" {
 		cfg.WatchEnabled = strings.ToLower(v) == "true" || v == "1"
 	}
@@ -136,12 +263,54 @@ func LoadFromEnv() *Config {
 		}
 	}
 
+	if v := os.Getenv("MCP_WATCH_IDLE_MIN"); v != "" {
+		if minutes, err := strconv.Atoi(v); err == nil && minutes >= 0 {
+			cfg.WatchIdleMin = minutes
+		}
+	}
+
 	if v := os.Getenv("MCP_MAX_FILE_SIZE"); v != "" {
 		if size, err := strconv.ParseInt(v, 10, 64); err == nil {
 			cfg.MaxFileSize = size
 		}
 	}
 
+	if v := os.Getenv("MCP_MAX_SYMBOL_PARTS"); v != "" {
+		if parts, err := strconv.Atoi(v); err == nil && parts >= 0 {
+			cfg.MaxSymbolParts = parts
+		}
+	}
+
+	if v := os.Getenv("MCP_MAX_READ_BYTES"); v != "" {
+		if size, err := strconv.ParseInt(v, 10, 64); err == nil && size >= 0 {
+			cfg.MaxReadBytes = size
+		}
+	}
+
+	if v := os.Getenv("MCP_MAX_LINE_LENGTH"); v != "" {
+		if length, err := strconv.Atoi(v); err == nil && length >= 0 {
+			cfg.MaxLineLength = length
+		}
+	}
+
+	if v := os.Getenv("MCP_INVALID_UTF8"); v != "" {
+		switch policy := strings.ToLower(v); policy {
+		case "sanitize", "skip":
+			cfg.InvalidUTF8 = policy
+		}
+	}
+
+	if v := os.Getenv("MCP_ALLOWED_ROOTS"); v != "" {
+		for _, root := range filepath.SplitList(v) {
+			if root = strings.TrimSpace(root); root == "" {
+				continue
+			}
+			if abs, err := filepath.Abs(expandPath(root)); err == nil {
+				cfg.AllowedRoots = append(cfg.AllowedRoots, abs)
+			}
+		}
+	}
+
 	if v := os.Getenv("MCP_WEBUI_ENABLED"); v != "" {
 		cfg.WebUIEnabled = strings.ToLower(v) == "true" || v == "1"
 	}
@@ -152,6 +321,14 @@ func LoadFromEnv() *Config {
 		}
 	}
 
+	if v := os.Getenv("MCP_WEBUI_STATIC_DIR"); v != "" {
+		cfg.WebUIStaticDir = expandPath(v)
+	}
+
+	if v := os.Getenv("MCP_WEBUI_HOST"); v != "" {
+		cfg.WebUIHost = v
+	}
+
 	if v := os.Getenv("MCP_AUTO_OPEN_UI"); v != "" {
 		cfg.AutoOpenUI = strings.ToLower(v) == "true" || v == "1"
 	}
@@ -178,6 +355,156 @@ func LoadFromEnv() *Config {
 		}
 	}
 
+	if v := os.Getenv("MCP_EMBEDDING_BATCH_SIZE"); v != "" {
+		if size, err := strconv.Atoi(v); err == nil && size >= 1 {
+			cfg.EmbeddingBatchSize = size
+		}
+	}
+
+	if v := os.Getenv("MCP_EMBED_RATE_PER_SEC"); v != "" {
+		if rate, err := strconv.ParseFloat(v, 64); err == nil && rate >= 0 {
+			cfg.EmbedRatePerSec = rate
+		}
+	}
+
+	if v := os.Getenv("MCP_FILE_WORKERS"); v != "" {
+		if workers, err := strconv.Atoi(v); err == nil {
+			if workers < 1 {
+				workers = 1
+			}
+			if workers > 8 {
+				workers = 8
+			}
+			cfg.FileWorkers = workers
+		}
+	}
+
+	if v := os.Getenv("MCP_PARSE_WORKERS"); v != "" {
+		if workers, err := strconv.Atoi(v); err == nil {
+			if workers < 1 {
+				workers = 1
+			}
+			if workers > 8 {
+				workers = 8
+			}
+			cfg.ParseWorkers = workers
+		}
+	}
+
+	if v := os.Getenv("MCP_INDEX_COMMENTS"); v != "" {
+		cfg.IndexComments = strings.ToLower(v) == "true" || v == "1"
+	}
+
+	if v := os.Getenv("MCP_INDEX_TESTS"); v != "" {
+		cfg.IndexTests = strings.ToLower(v) == "true" || v == "1"
+	}
+
+	if v := os.Getenv("MCP_EXTRACT_REFERENCES"); v != "" {
+		cfg.ExtractReferences = strings.ToLower(v) == "true" || v == "1"
+	}
+
+	// Comma-separated language=bool pairs; a bare language means true
+	if v := os.Getenv("MCP_REFERENCE_LANGUAGES"); v != "" {
+		cfg.ReferenceLanguages = make(map[string]bool)
+		for _, item := range strings.Split(v, ",") {
+			lang, value, hasValue := strings.Cut(strings.TrimSpace(item), "=")
+			if lang = strings.ToLower(strings.TrimSpace(lang)); lang == "" {
+				continue
+			}
+			value = strings.TrimSpace(value)
+			cfg.ReferenceLanguages[lang] = !hasValue || strings.ToLower(value) == "true" || value == "1"
+		}
+	}
+
+	if v := os.Getenv("MCP_MERGE_SMALL_CHUNKS"); v != "" {
+		cfg.MergeSmall = strings.ToLower(v) == "true" || v == "1"
+	}
+
+	if v := os.Getenv("MCP_STORE_FULL_FILES"); v != "" {
+		cfg.StoreFullFiles = strings.ToLower(v) == "true" || v == "1"
+	}
+
+	if v := os.Getenv("MCP_SEARCH_CANDIDATE_MULTIPLIER"); v != "" {
+		if mult, err := strconv.Atoi(v); err == nil && mult > 0 {
+			cfg.SearchCandidateMultiplier = mult
+		}
+	}
+
+	if v := os.Getenv("MCP_MAX_SEARCH_LIMIT"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			cfg.MaxSearchLimit = min(n, maxSearchLimitCeiling)
+		}
+	}
+
+	if v := os.Getenv("MCP_QUERY_EXPANSION"); v != "" {
+		cfg.QueryExpansion = strings.ToLower(v) == "true" || v == "1"
+	}
+
+	if v := os.Getenv("MCP_COLLECT_FEEDBACK"); v != "" {
+		cfg.CollectFeedback = strings.ToLower(v) == "true" || v == "1"
+	}
+
+	if v := os.Getenv("MCP_DEDUP_CHUNKS"); v != "" {
+		cfg.DedupChunks = strings.ToLower(v) == "true" || v == "1"
+	}
+
+	if v := os.Getenv("MCP_BOOST_BAND"); v != "" {
+		if band, err := strconv.ParseFloat(v, 32); err == nil && band >= 0 && band <= 1 {
+			cfg.BoostBand = float32(band)
+		}
+	}
+
+	if v := os.Getenv("MCP_MAX_CALLER_NODES"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n >= 0 {
+			cfg.MaxCallerNodes = n
+		}
+	}
+
+	if v := os.Getenv("MCP_MAX_LIST_RESULTS"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n >= 0 {
+			cfg.MaxListResults = n
+		}
+	}
+
+	if v := os.Getenv("MCP_SYMBOL_CASE_SENSITIVE"); v != "" {
+		cfg.SymbolCaseSensitive = strings.ToLower(v) == "true" || v == "1"
+	}
+
+	if v := os.Getenv("MCP_INDEX_HIDDEN_FILES"); v != "" {
+		cfg.IndexHiddenFiles = strings.ToLower(v) == "true" || v == "1"
+	}
+
+	if v := os.Getenv("MCP_SKIP_DOTFILES"); v != "" {
+		cfg.SkipDotfiles = strings.ToLower(v) == "true" || v == "1"
+	}
+
+	if v := os.Getenv("MCP_INDEX_COMPRESSED"); v != "" {
+		cfg.IndexCompressed = strings.ToLower(v) == "true" || v == "1"
+	}
+
+	if v, ok := os.LookupEnv("MCP_DOTFILE_ALLOWLIST"); ok {
+		cfg.DotfileAllowlist = nil
+		for _, name := range strings.Split(v, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				cfg.DotfileAllowlist = append(cfg.DotfileAllowlist, name)
+			}
+		}
+	}
+
+	if v := os.Getenv("MCP_ENTRY_POINTS"); v != "" {
+		for _, pattern := range strings.Split(v, ",") {
+			if pattern = strings.TrimSpace(pattern); pattern != "" {
+				cfg.EntryPointPatterns = append(cfg.EntryPointPatterns, pattern)
+			}
+		}
+	}
+
+	if v := os.Getenv("MCP_GRAPH_MIN_SIMILARITY"); v != "" {
+		if sim, err := strconv.ParseFloat(v, 32); err == nil && sim >= 0 && sim <= 1 {
+			cfg.GraphMinSimilarity = float32(sim)
+		}
+	}
+
 	if v := os.Getenv("MCP_AUTO_UPDATE"); v != "" {
 		cfg.AutoUpdateEnabled = strings.ToLower(v) == "true" || v == "1"
 	}
@@ -186,9 +513,40 @@ func LoadFromEnv() *Config {
 		cfg.AutoUpdateApply = strings.ToLower(v) == "true" || v == "1"
 	}
 
+	if v := os.Getenv("MCP_REINDEX_ON_UPGRADE"); v != "" {
+		cfg.ReindexOnUpgrade = strings.ToLower(v) == "true" || v == "1"
+	}
+
+	if v := os.Getenv("MCP_SAFE_MODE"); v != "" {
+		cfg.SafeMode = strings.ToLower(v) == "true" || v == "1"
+	}
+
+	// Applied last, but only to settings not explicitly set via their own variable
+	if cfg.SafeMode {
+		cfg.applySafeMode()
+	}
+
 	return cfg
 }
 
+// applySafeMode switches off behavior unsuitable for CI/servers (auto-index,
+// browser open, auto-applied updates) and binds the web UI to localhost.
+// Settings whose environment variable is set explicitly are left alone.
+func (c *Config) applySafeMode() {
+	if _, ok := os.LookupEnv("MCP_AUTO_OPEN_UI"); !ok {
+		c.AutoOpenUI = false
+	}
+	if _, ok := os.LookupEnv("MCP_AUTO_INDEX"); !ok {
+		c.AutoIndex = false
+	}
+	if _, ok := os.LookupEnv("MCP_AUTO_UPDATE_APPLY"); !ok {
+		c.AutoUpdateApply = false
+	}
+	if _, ok := os.LookupEnv("MCP_WEBUI_HOST"); !ok {
+		c.WebUIHost = "127.0.0.1"
+	}
+}
+
 // expandPath expands ~ to home directory
 func expandPath(path string) string {
 	if strings.HasPrefix(path, "~") {
@@ -208,6 +566,34 @@ func (c *Config) MetadataPath() string {
 	return filepath.Join(c.DBPath, "projects.json")
 }
 
+// maxSearchLimitCeiling bounds MCP_MAX_SEARCH_LIMIT; past this, result
+// formatting and usage analysis dominate and the vector query hits its k limit
+const maxSearchLimitCeiling = 1000
+
+// ExtractReferencesFor reports whether type/variable references are extracted
+// for language: its MCP_REFERENCE_LANGUAGES override, else ExtractReferences
+func (c *Config) ExtractReferencesFor(language string) bool {
+	if extract, ok := c.ReferenceLanguages[strings.ToLower(language)]; ok {
+		return extract
+	}
+	return c.ExtractReferences
+}
+
+// ClampSearchLimit caps a requested search limit at MaxSearchLimit.
+// Limits <= 0 are returned unchanged so callers can apply their default.
+func (c *Config) ClampSearchLimit(limit int) int {
+	if c.MaxSearchLimit > 0 && limit > c.MaxSearchLimit {
+		return c.MaxSearchLimit
+	}
+	return limit
+}
+
+// ExceedsMaxFileSize checks if a file is too large to index. With
+// MaxReadBytes set, large files are not excluded; only their head is read.
+func (c *Config) ExceedsMaxFileSize(size int64) bool {
+	return c.MaxReadBytes <= 0 && size > c.MaxFileSize
+}
+
 // IsExcludedDir checks if a directory should be excluded
 func (c *Config) IsExcludedDir(name string) bool {
 	for _, excluded := range c.ExcludeDirs {
@@ -218,6 +604,72 @@ func (c *Config) IsExcludedDir(name string) bool {
 	return false
 }
 
+// IsExcludedHomeDir checks if a directory is one of HomeExcludeDirs directly
+// under the user's home directory (~/Library, not ./src/Library)
+func (c *Config) IsExcludedHomeDir(path string) bool {
+	home, err := os.UserHomeDir()
+	if err != nil || home == "" || filepath.Dir(filepath.Clean(path)) != filepath.Clean(home) {
+		return false
+	}
+	name := filepath.Base(path)
+	for _, excluded := range c.HomeExcludeDirs {
+		if name == excluded {
+			return true
+		}
+	}
+	return false
+}
+
+// isDotfile reports whether a file or directory name is hidden (starts with ".")
+func isDotfile(name string) bool {
+	return strings.HasPrefix(name, ".") && name != "." && name != ".."
+}
+
+// SkipDotfile checks if a dotfile/dot-directory must be skipped regardless of
+// gitignore (SkipDotfiles is set and the name is not in DotfileAllowlist)
+func (c *Config) SkipDotfile(name string) bool {
+	if !c.SkipDotfiles || !isDotfile(name) {
+		return false
+	}
+	for _, allowed := range c.DotfileAllowlist {
+		if name == allowed {
+			return false
+		}
+	}
+	return true
+}
+
+// ForceDotfilePath checks if a path under root is, or is inside, a dotfile or
+// dot-directory that should be indexed even when gitignored (IndexHiddenFiles)
+func (c *Config) ForceDotfilePath(root, path string) bool {
+	if !c.IndexHiddenFiles {
+		return false
+	}
+	rel, err := filepath.Rel(root, path)
+	if err != nil {
+		return false
+	}
+	for _, part := range strings.Split(rel, string(filepath.Separator)) {
+		if isDotfile(part) && !c.SkipDotfile(part) {
+			return true
+		}
+	}
+	return false
+}
+
+// FilterExt returns the extension a file is filtered by: its own, or for
+// .gz files with IndexCompressed set, the extension under .gz. A .gz without
+// an inner extension keeps ".gz".
+func (c *Config) FilterExt(name string) string {
+	ext := strings.ToLower(filepath.Ext(name))
+	if ext == ".gz" && c.IndexCompressed {
+		if inner := strings.ToLower(filepath.Ext(strings.TrimSuffix(name, filepath.Ext(name)))); inner != "" {
+			return inner
+		}
+	}
+	return ext
+}
+
 // IsExcludedExt checks if a file extension should be excluded
 func (c *Config) IsExcludedExt(ext string) bool {
 	ext = strings.ToLower(ext)
@@ -0,0 +1,61 @@
+package config
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestIsExcludedHomeDirOnlyMatchesDirectlyUnderHome(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	cfg := DefaultConfig()
+
+	for path, want := range map[string]bool{
+		filepath.Join(home, "Library"):               true,
+		filepath.Join(home, "AppData"):               true,
+		filepath.Join(home, "src", "app", "Library"): false,
+		filepath.Join(home, "Library", "Library"):    false,
+		filepath.Join(home, "Documents"):             false,
+		filepath.Join(t.TempDir(), "Library"):        false,
+	} {
+		if got := cfg.IsExcludedHomeDir(path); got != want {
+			t.Errorf("IsExcludedHomeDir(%s) = %v, want %v", path, got, want)
+		}
+	}
+	if cfg.IsExcludedDir("Library") {
+		t.Error("Library must not be excluded at any depth")
+	}
+}
+
+func TestSafeModeKeepsExplicitlySetVariables(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("MCP_SAFE_MODE", "true")
+	t.Setenv("MCP_AUTO_INDEX", "true")
+
+	cfg := LoadFromEnv()
+	if cfg.AutoOpenUI || cfg.AutoUpdateApply || cfg.WebUIHost != "127.0.0.1" {
+		t.Errorf("safe mode left AutoOpenUI=%v AutoUpdateApply=%v WebUIHost=%q", cfg.AutoOpenUI, cfg.AutoUpdateApply, cfg.WebUIHost)
+	}
+	if !cfg.AutoIndex {
+		t.Error("safe mode overrode MCP_AUTO_INDEX=true")
+	}
+
+	t.Setenv("MCP_SAFE_MODE", "false")
+	if cfg := LoadFromEnv(); !cfg.AutoOpenUI || cfg.WebUIHost != "" {
+		t.Errorf("without safe mode AutoOpenUI=%v WebUIHost=%q, want the defaults", cfg.AutoOpenUI, cfg.WebUIHost)
+	}
+}
+
+func TestMaxSearchLimitFromEnvIsBounded(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	for env, want := range map[string]int{"200": 200, "1000000": 1000, "0": 50, "-5": 50, "many": 50} {
+		t.Setenv("MCP_MAX_SEARCH_LIMIT", env)
+		cfg := LoadFromEnv()
+		if cfg.MaxSearchLimit != want {
+			t.Errorf("MCP_MAX_SEARCH_LIMIT=%s: MaxSearchLimit = %d, want %d", env, cfg.MaxSearchLimit, want)
+		}
+		if got := cfg.ClampSearchLimit(want + 1); got != want {
+			t.Errorf("ClampSearchLimit(%d) = %d, want %d", want+1, got, want)
+		}
+	}
+}
@@ -2,45 +2,119 @@ package indexer
 
 import (
 	"context"
+	"fmt"
 	"go/ast"
 	"go/parser"
 	"go/token"
+	"log"
 	"path/filepath"
 	"regexp"
+	"strconv"
 	"strings"
 
 	"mcp-semantic-search/types"
 )
 
+// ChunkerVersion identifies the parser/chunker output. Bump it whenever a change
+// produces different chunks for the same source, so indexes built by older
+// binaries are detected on startup (see Indexer.IndexOutdated).
+const ChunkerVersion = 10
+
 // Chunker parses source files into semantic chunks
 type Chunker struct {
-	maxChunkSize int
-	overlapLines int
-	tsParser     *Parser // Tree-sitter parser for multi-language support
+	maxChunkSize   int
+	overlapLines   int
+	maxSymbolParts int     // Oversized symbols needing more parts are stored truncated as one chunk (0 = no cap)
+	indexComments  bool    // Emit comment/docstring blocks as separate doc chunks
+	mergeSmall     bool    // Merge runs of small sibling symbol chunks (see mergeSmallChunks)
+	indexTests     bool    // Chunk test files; when false they produce no chunks
+	tsParser       *Parser // Tree-sitter parser for multi-language support
 }
 
 // NewChunker creates a new Chunker
-func NewChunker(maxChunkSize, overlapLines int) *Chunker {
+func NewChunker(maxChunkSize, overlapLines, maxSymbolParts int, indexComments, mergeSmall, indexTests bool) *Chunker {
 	return &Chunker{
-		maxChunkSize: maxChunkSize,
-		overlapLines: overlapLines,
-		tsParser:     NewParser(), // Initialize tree-sitter parser
+		maxChunkSize:   maxChunkSize,
+		overlapLines:   overlapLines,
+		maxSymbolParts: maxSymbolParts,
+		indexComments:  indexComments,
+		mergeSmall:     mergeSmall,
+		indexTests:     indexTests,
+		tsParser:       NewParser(), // Initialize tree-sitter parser
 	}
 }
 
-// ChunkFile parses a file into chunks based on its language
-func (c *Chunker) ChunkFile(content, filePath, language string) []types.Chunk {
+// SetExtractReferences limits reference extraction ("Used By" data) to the
+// languages for which extract returns true; nil extracts them for all.
+// Call before the chunker is used.
+func (c *Chunker) SetExtractReferences(extract func(language string) bool) {
+	c.tsParser.references = extract
+}
+
+// ChunkFile parses a file into chunks based on its language.
+// The returned reason is non-empty when tree-sitter supports the language but
+// could not chunk the file, or parts of it, so a fallback parser was used.
+// Test files (by path or content) yield no chunks when tests are not indexed.
+func (c *Chunker) ChunkFile(content, filePath, language string) ([]types.Chunk, string) {
+	if !c.indexTests && isTestFilePath(filePath) {
+		return nil, ""
+	}
+
 	// Try tree-sitter first for supported languages
+	var docChunks []types.Chunk
+	var imports []string
+	fallbackReason := ""
 	if c.tsParser.IsSupported(language) {
-		chunks := c.chunkWithTreeSitter(content, filePath, language)
+		var chunks []types.Chunk
+		var isTest bool
+		chunks, docChunks, imports, isTest, fallbackReason = c.chunkWithTreeSitter(content, filePath, language)
+		if isTest && !c.indexTests {
+			return nil, ""
+		}
+		if c.mergeSmall {
+			chunks = c.mergeSmallChunks(chunks, content)
+		}
 		if len(chunks) > 0 {
-			return chunks
+			return withImports(append(chunks, docChunks...), imports), fallbackReason
 		}
 	}
 
-	// Fall back to legacy parsers
+	chunks := c.legacyChunks(content, filePath, language)
+
+	// Ensure all chunks have proper metadata
+	for i := range chunks {
+		chunks[i].Language = language
+		chunks[i].FilePath = filePath
+	}
+
+	return withImports(append(chunks, docChunks...), imports), fallbackReason
+}
+
+// withImports sets the file's import paths on every chunk
+func withImports(chunks []types.Chunk, imports []string) []types.Chunk {
+	for i := range chunks {
+		chunks[i].Imports = imports
+	}
+	return chunks
+}
+
+// legacyChunks chunks content with the regex/go-parser chunkers, falling back
+// to line-based chunking when they find nothing
+func (c *Chunker) legacyChunks(content, filePath, language string) []types.Chunk {
 	var chunks []types.Chunk
 
+	switch {
+	case isDotenvFile(filePath):
+		chunks = c.chunkConfigKeys(content, dotenvKeyPattern, nil)
+	case language == "yaml":
+		chunks = c.chunkConfigKeys(content, yamlKeyPattern, nil)
+	case isBazelBuildFile(filePath):
+		chunks = c.chunkConfigKeys(content, starlarkCallPattern, starlarkRuleName)
+	}
+	if len(chunks) > 0 {
+		return chunks
+	}
+
 	switch language {
 	case "go":
 		chunks = c.chunkGo(content, filePath)
@@ -61,25 +135,26 @@ func (c *Chunker) ChunkFile(content, filePath, language string) []types.Chunk {
 		chunks = c.chunkByLines(content, filePath, language)
 	}
 
-	// Ensure all chunks have proper metadata
-	for i := range chunks {
-		chunks[i].Language = language
-		chunks[i].FilePath = filePath
-	}
-
 	return chunks
 }
 
-// chunkWithTreeSitter uses tree-sitter for parsing and reference extraction
-func (c *Chunker) chunkWithTreeSitter(content, filePath, language string) []types.Chunk {
+// chunkWithTreeSitter uses tree-sitter for parsing and reference extraction.
+// It returns symbol chunks and, if enabled, comment/docstring chunks separately
+// so that files without symbols can still fall back to line-based chunking.
+// The file's import paths and whether it is a test file are returned too, and
+// the last value explains a parse failure that left no symbol chunks.
+func (c *Chunker) chunkWithTreeSitter(content, filePath, language string) ([]types.Chunk, []types.Chunk, []string, bool, string) {
 	ctx := context.Background()
 	result, err := c.tsParser.Parse(ctx, []byte(content), language)
-	if err != nil || result == nil {
-		return nil
+	if err != nil {
+		return nil, nil, nil, false, fmt.Sprintf("parse error: %v", err)
+	}
+	if result == nil {
+		return nil, nil, nil, false, ""
 	}
 
 	// Detect if this is a test file
-	isTestFile := result.IsTest || c.isTestFilePath(filePath)
+	isTestFile := result.IsTest || isTestFilePath(filePath)
 
 	chunks := make([]types.Chunk, 0, len(result.Symbols))
 
@@ -102,23 +177,237 @@ func (c *Chunker) chunkWithTreeSitter(content, filePath, language string) []type
 			StartLine:  sym.StartLine,
 			EndLine:    sym.EndLine,
 			Calls:      sym.Calls,
+			CallCounts: sym.CallCounts,
 			References: sym.References,
 			IsExported: sym.IsExported,
 			IsTest:     isTestFile || strings.HasPrefix(strings.ToLower(sym.Name), "test"),
 			Parent:     sym.Parent,
+			Signature:  sym.Signature,
+			Metadata:   symbolMetadata(sym),
 		}
 
 		chunks = append(chunks, chunk)
 	}
 
-	return chunks
+	var docChunks []types.Chunk
+	if c.indexComments {
+		for _, doc := range result.Comments {
+			docChunks = append(docChunks, types.Chunk{
+				Content:   doc.Content,
+				Type:      types.ChunkTypeBlock,
+				Name:      types.DocChunkName,
+				Language:  language,
+				FilePath:  filePath,
+				StartLine: doc.StartLine,
+				EndLine:   doc.EndLine,
+				IsTest:    isTestFile,
+				Parent:    doc.Parent,
+			})
+		}
+	}
+
+	// A clean parse without symbols (e.g. a config file) is not a failure
+	reason := ""
+	if len(chunks) == 0 && result.HasError {
+		reason = "syntax errors, no symbols extracted"
+	}
+
+	// Code swallowed by syntax errors (e.g. functions after a half-typed one)
+	// is chunked by the fallback parsers so it stays searchable
+	if len(chunks) > 0 && len(result.ErrorRanges) > 0 {
+		recovered := c.recoverErrorRegions(content, filePath, language, result.ErrorRanges, chunks, isTestFile)
+		if len(recovered) > 0 {
+			chunks = append(chunks, recovered...)
+			reason = fmt.Sprintf("syntax errors, %d chunks recovered by fallback chunking", len(recovered))
+		}
+	}
+
+	return chunks, docChunks, result.Imports, isTestFile, reason
 }
 
-// splitLargeSymbol splits an oversized symbol into smaller chunks
+// smallChunkLines is the size below which a symbol chunk may be merged with
+// its neighbors (MCP_MERGE_SMALL_CHUNKS)
+const smallChunkLines = 10
+
+// mergeSmallChunks combines runs of consecutive small chunks of the same type
+// and parent (e.g. a class's one-line methods) into one chunk spanning them,
+// up to maxChunkSize lines. The merged chunk keeps the first symbol's name and
+// signature, lists all merged names in its metadata and carries the union of
+// their calls and references.
+func (c *Chunker) mergeSmallChunks(chunks []types.Chunk, content string) []types.Chunk {
+	lines := strings.Split(content, "\n")
+	small := func(ch types.Chunk) bool {
+		return ch.EndLine-ch.StartLine+1 < smallChunkLines && ch.Type != types.ChunkTypeBlock
+	}
+
+	merged := make([]types.Chunk, 0, len(chunks))
+	for i := 0; i < len(chunks); {
+		run := i + 1
+		if small(chunks[i]) {
+			for run < len(chunks) {
+				next := chunks[run]
+				if !small(next) || next.Type != chunks[i].Type || next.Parent != chunks[i].Parent ||
+					next.StartLine <= chunks[run-1].EndLine || next.EndLine-chunks[i].StartLine+1 > c.maxChunkSize {
+					break
+				}
+				run++
+			}
+		}
+
+		if run-i == 1 {
+			merged = append(merged, chunks[i])
+		} else {
+			merged = append(merged, mergeChunks(chunks[i:run], lines))
+		}
+		i = run
+	}
+	return merged
+}
+
+// mergeChunks combines adjacent chunks into one spanning their lines
+func mergeChunks(run []types.Chunk, lines []string) types.Chunk {
+	first, last := run[0], run[len(run)-1]
+	merged := types.Chunk{
+		Content:   getLines(lines, first.StartLine, last.EndLine),
+		Type:      first.Type,
+		Language:  first.Language,
+		FilePath:  first.FilePath,
+		StartLine: first.StartLine,
+		EndLine:   last.EndLine,
+		Name:      first.Name,
+		Signature: first.Signature,
+		Parent:    first.Parent,
+		Metadata:  make(map[string]string, len(first.Metadata)+1),
+	}
+	for k, v := range first.Metadata {
+		merged.Metadata[k] = v
+	}
+
+	names := make([]string, 0, len(run))
+	callSites := make(map[string]int)
+	seenRefs := make(map[string]bool)
+	complexity := 0
+	for _, ch := range run {
+		names = append(names, ch.Name)
+		if c, _ := strconv.Atoi(ch.Metadata[types.MetadataComplexity]); c > complexity {
+			complexity = c
+		}
+		for _, call := range ch.Calls {
+			if callSites[call] == 0 {
+				merged.Calls = append(merged.Calls, call)
+			}
+			callSites[call] += max(ch.CallCounts[call], 1)
+		}
+		for _, ref := range ch.References {
+			if !seenRefs[ref] {
+				seenRefs[ref] = true
+				merged.References = append(merged.References, ref)
+			}
+		}
+		merged.IsExported = merged.IsExported || ch.IsExported
+		merged.IsTest = merged.IsTest || ch.IsTest
+	}
+	merged.Metadata[types.MetadataMerged] = strings.Join(names, ",")
+	if complexity > 0 {
+		merged.Metadata[types.MetadataComplexity] = strconv.Itoa(complexity) // The most complex member
+	}
+	for call, count := range callSites {
+		if count > 1 {
+			if merged.CallCounts == nil {
+				merged.CallCounts = make(map[string]int)
+			}
+			merged.CallCounts[call] = count
+		}
+	}
+
+	return merged
+}
+
+// recoverErrorRegions chunks the lines of syntax error regions that no symbol
+// chunk covers. Each uncovered run of lines goes through the legacy chunkers;
+// runs they cannot split into symbols become nameless block chunks.
+func (c *Chunker) recoverErrorRegions(content, filePath, language string, regions [][2]int, symbols []types.Chunk, isTestFile bool) []types.Chunk {
+	lines := strings.Split(content, "\n")
+	covered := make([]bool, len(lines)+2)
+	for _, chunk := range symbols {
+		for l := chunk.StartLine; l <= chunk.EndLine && l < len(covered); l++ {
+			covered[l] = true
+		}
+	}
+
+	var recovered []types.Chunk
+	for _, region := range regions {
+		end := region[1]
+		if end > len(lines) {
+			end = len(lines)
+		}
+		for start := region[0]; start <= end; start++ {
+			if covered[start] {
+				continue
+			}
+			runEnd := start
+			for runEnd < end && !covered[runEnd+1] {
+				runEnd++
+			}
+			for l := start; l <= runEnd; l++ {
+				covered[l] = true // Overlapping regions are recovered once
+			}
+
+			text := getLines(lines, start, runEnd)
+			if strings.TrimSpace(text) != "" {
+				for _, chunk := range c.legacyChunks(text, filePath, language) {
+					if chunk.Type == types.ChunkTypeFile {
+						chunk.Type = types.ChunkTypeBlock
+						chunk.Name = ""
+					}
+					chunk.StartLine += start - 1
+					chunk.EndLine += start - 1
+					chunk.Language = language
+					chunk.FilePath = filePath
+					chunk.IsTest = isTestFile
+					recovered = append(recovered, chunk)
+				}
+			}
+			start = runEnd
+		}
+	}
+
+	return recovered
+}
+
+// splitLargeSymbol splits an oversized symbol into smaller chunks. A symbol
+// that would need more than maxSymbolParts parts (typically generated code)
+// becomes a single chunk of its first lines instead.
 func (c *Chunker) splitLargeSymbol(sym SymbolInfo, language string, isTestFile bool) []types.Chunk {
 	lines := strings.Split(sym.Content, "\n")
 	var chunks []types.Chunk
 
+	step := c.maxChunkSize - c.overlapLines
+	if parts := (len(lines) - c.overlapLines + step - 1) / step; c.maxSymbolParts > 0 && parts > c.maxSymbolParts {
+		log.Printf("Truncating %s (%d lines): would need %d parts, more than MCP_MAX_SYMBOL_PARTS (%d)", sym.Name, len(lines), parts, c.maxSymbolParts)
+		metadata := symbolMetadata(sym)
+		if metadata == nil {
+			metadata = make(map[string]string)
+		}
+		metadata[types.MetadataTruncatedLines] = strconv.Itoa(len(lines) - c.maxChunkSize)
+		return []types.Chunk{{
+			Content:    strings.Join(lines[:c.maxChunkSize], "\n"),
+			Type:       sym.Type,
+			Name:       sym.Name,
+			Language:   language,
+			StartLine:  sym.StartLine,
+			EndLine:    sym.EndLine, // The chunk still stands for the whole symbol
+			Calls:      sym.Calls,
+			CallCounts: sym.CallCounts,
+			References: sym.References,
+			IsExported: sym.IsExported,
+			IsTest:     isTestFile,
+			Parent:     sym.Parent,
+			Signature:  sym.Signature,
+			Metadata:   metadata,
+		}}
+	}
+
 	for i := 0; i < len(lines); i += c.maxChunkSize - c.overlapLines {
 		endLine := i + c.maxChunkSize
 		if endLine > len(lines) {
@@ -135,16 +424,19 @@ func (c *Chunker) splitLargeSymbol(sym SymbolInfo, language string, isTestFile b
 			Language:   language,
 			StartLine:  sym.StartLine + i,
 			EndLine:    sym.StartLine + endLine - 1,
-			Calls:      sym.Calls,      // Keep calls from full symbol
+			Calls:      sym.Calls, // Keep calls from full symbol
+			CallCounts: sym.CallCounts,
 			References: sym.References, // Keep references
 			IsExported: sym.IsExported,
 			IsTest:     isTestFile,
 			Parent:     sym.Parent,
+			Signature:  sym.Signature,
+			Metadata:   symbolMetadata(sym), // Parts report the whole symbol's complexity
 		}
 
 		// Mark as part if split
 		if partNum > 1 || endLine < len(lines) {
-			chunk.Name = sym.Name + " (part " + string(rune('0'+partNum)) + ")"
+			chunk.Name = sym.Name + " (part " + strconv.Itoa(partNum) + ")"
 		}
 
 		chunks = append(chunks, chunk)
@@ -157,8 +449,16 @@ func (c *Chunker) splitLargeSymbol(sym SymbolInfo, language string, isTestFile b
 	return chunks
 }
 
+// symbolMetadata returns the chunk metadata derived from a parsed symbol
+func symbolMetadata(sym SymbolInfo) map[string]string {
+	if sym.Complexity == 0 {
+		return nil
+	}
+	return map[string]string{types.MetadataComplexity: strconv.Itoa(sym.Complexity)}
+}
+
 // isTestFilePath checks if the file path indicates a test file
-func (c *Chunker) isTestFilePath(filePath string) bool {
+func isTestFilePath(filePath string) bool {
 	base := strings.ToLower(filepath.Base(filePath))
 
 	// Common test file patterns
@@ -182,8 +482,8 @@ func (c *Chunker) isTestFilePath(filePath string) bool {
 		}
 	}
 
-	// Check directory name
-	dir := strings.ToLower(filepath.Dir(filePath))
+	// Check directory name; the trailing separator matches a file directly in tests/
+	dir := strings.ToLower(filepath.Dir(filePath)) + string(filepath.Separator)
 	if strings.Contains(dir, "/test/") || strings.Contains(dir, "/tests/") ||
 		strings.Contains(dir, "\\test\\") || strings.Contains(dir, "\\tests\\") {
 		return true
@@ -603,6 +903,107 @@ func (c *Chunker) chunkRust(content, filePath string) []types.Chunk {
 	return chunks
 }
 
+var (
+	dotenvKeyPattern = regexp.MustCompile(`^\s*(?:export\s+)?([A-Za-z_][A-Za-z0-9_.]*)\s*=`)
+	yamlKeyPattern   = regexp.MustCompile(`^([A-Za-z_][\w.\-]*|"[^"]+"|'[^']+')\s*:(?:\s|$)`)
+
+	// starlarkCallPattern matches a top-level rule or macro call (go_library(, native.genrule()
+	starlarkCallPattern = regexp.MustCompile(`^([A-Za-z_][\w.]*)\s*\(`)
+	starlarkNamePattern = regexp.MustCompile(`^\s*name\s*=\s*["']([^"']+)["']`)
+)
+
+// isBazelBuildFile reports whether filePath is a Bazel BUILD, WORKSPACE or
+// MODULE file, which the Python chunkers find no definitions in
+func isBazelBuildFile(filePath string) bool {
+	switch filepath.Base(filePath) {
+	case "BUILD", "BUILD.bazel", "WORKSPACE", "WORKSPACE.bazel", "MODULE.bazel":
+		return true
+	}
+	return false
+}
+
+// starlarkRuleName names a rule call block by its name attribute (the target
+// name), falling back to the called rule or function (load, package)
+func starlarkRuleName(rule string, block []string) string {
+	for _, line := range block {
+		if m := starlarkNamePattern.FindStringSubmatch(line); m != nil {
+			return m[1]
+		}
+	}
+	return rule
+}
+
+// isDotenvFile reports whether filePath is a .env file (.env, .env.local, prod.env)
+func isDotenvFile(filePath string) bool {
+	base := filepath.Base(filePath)
+	return base == ".env" || strings.HasPrefix(base, ".env.") || strings.HasSuffix(base, ".env")
+}
+
+// chunkConfigKeys emits one block chunk per config key (.env variable,
+// top-level YAML key or Starlark rule call), named by the key, so "where is
+// DATABASE_URL set" finds the key by name. A chunk runs from the key's leading
+// comments to the line before the next key. blockName, when set, names a chunk
+// from its key and lines instead. Returns nil when no keys are found.
+func (c *Chunker) chunkConfigKeys(content string, keyPattern *regexp.Regexp, blockName func(key string, block []string) string) []types.Chunk {
+	lines := strings.Split(content, "\n")
+
+	type key struct {
+		name string
+		line int // 0-based
+	}
+	var keys []key
+	for i, line := range lines {
+		if m := keyPattern.FindStringSubmatch(line); m != nil {
+			keys = append(keys, key{name: strings.Trim(m[1], `"'`), line: i})
+		}
+	}
+	if len(keys) == 0 {
+		return nil
+	}
+
+	var chunks []types.Chunk
+	for k, ky := range keys {
+		// Pull in the comment block directly above the key
+		start := ky.line
+		for start > 0 && strings.HasPrefix(strings.TrimSpace(lines[start-1]), "#") {
+			start--
+		}
+		if k == 0 {
+			start = 0
+		}
+
+		end := len(lines) - 1
+		if k+1 < len(keys) {
+			end = keys[k+1].line - 1
+			for end > ky.line && strings.HasPrefix(strings.TrimSpace(lines[end]), "#") {
+				end--
+			}
+		}
+		for end > ky.line && strings.TrimSpace(lines[end]) == "" {
+			end--
+		}
+
+		name := ky.name
+		if blockName != nil {
+			name = blockName(ky.name, lines[ky.line:end+1])
+		}
+
+		// Long YAML sections are split, each part keeping the key name
+		for from := start; from <= end; from += c.maxChunkSize {
+			to := min(from+c.maxChunkSize-1, end)
+			chunks = append(chunks, types.Chunk{
+				Content:   strings.Join(lines[from:to+1], "\n"),
+				Type:      types.ChunkTypeBlock,
+				Name:      name,
+				StartLine: from + 1,
+				EndLine:   to + 1,
+			})
+		}
+	}
+
+	return chunks
+}
+
 // chunkByLines splits content into line-based chunks with overlap
 func (c *Chunker) chunkByLines(content, filePath, language string) []types.Chunk {
 	var chunks []types.Chunk
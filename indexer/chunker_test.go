@@ -0,0 +1,167 @@
+package indexer
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"mcp-semantic-search/types"
+)
+
+const smallMethodsSource = `class Greeter:
+    def hello(self):
+        return self.say("hello")
+
+    def bye(self):
+        if self.polite:
+            return self.say("bye")
+        return None
+
+    def wave(self):
+        return self.say("wave")
+`
+
+func TestMergeSmallChunksKeepsFirstNameAndListsMembers(t *testing.T) {
+	chunker := NewChunker(500, 20, 20, false, true, true)
+	chunks, _ := chunker.ChunkFile(smallMethodsSource, "/p/greeter.py", "python")
+
+	var merged *types.Chunk
+	for i := range chunks {
+		if chunks[i].Metadata[types.MetadataMerged] != "" {
+			merged = &chunks[i]
+		}
+	}
+	if merged == nil {
+		t.Fatalf("no merged chunk in %+v", chunks)
+	}
+
+	if merged.Name != "Greeter.hello" {
+		t.Errorf("Name = %q, want the first member", merged.Name)
+	}
+	if got := merged.Metadata[types.MetadataMerged]; got != "Greeter.hello,Greeter.bye,Greeter.wave" {
+		t.Errorf("merged = %q, want all three members", got)
+	}
+	if merged.Signature == "" {
+		t.Error("merged chunk lost the first member's signature")
+	}
+	if got := merged.Metadata[types.MetadataComplexity]; got != "2" {
+		t.Errorf("complexity = %q, want the most complex member's 2", got)
+	}
+	if got := merged.CallCounts["self.say"]; got != 3 {
+		t.Errorf("CallCounts[self.say] = %d, want the sum 3", got)
+	}
+}
+
+// brokenSource has an unterminated function body; tree-sitter wraps the rest
+// of the file in one ERROR node
+const brokenSource = `package main
+
+func First() int {
+	return 1
+}
+
+func Broken() {
+	for {
+		x := 1 +
+
+func Third() string {
+	return "three"
+}
+`
+
+func TestSyntaxErrorRegionIsRecoveredByFallbackChunking(t *testing.T) {
+	chunker := NewChunker(500, 20, 0, false, false, true)
+	chunks, reason := chunker.ChunkFile(brokenSource, "/p/main.go", "go")
+
+	var first, fallback *types.Chunk
+	for i := range chunks {
+		switch {
+		case chunks[i].Name == "First":
+			first = &chunks[i]
+		case chunks[i].Type == types.ChunkTypeBlock && strings.Contains(chunks[i].Content, "three"):
+			fallback = &chunks[i]
+		}
+	}
+	if first == nil || first.Type != types.ChunkTypeFunction {
+		t.Fatalf("valid function First not indexed: %+v", chunks)
+	}
+	if fallback == nil {
+		t.Fatalf("code after the syntax error not recovered: %+v", chunks)
+	}
+	if fallback.StartLine > 11 || fallback.EndLine < 13 || fallback.Name != "" {
+		t.Errorf("fallback chunk %q covers %d-%d, want a nameless block over Third (11-13)", fallback.Name, fallback.StartLine, fallback.EndLine)
+	}
+	if !strings.Contains(reason, "recovered") {
+		t.Errorf("reason = %q, want the recovery reported", reason)
+	}
+}
+
+func TestOversizedSymbolIsCappedAtMaxSymbolParts(t *testing.T) {
+	var src strings.Builder
+	src.WriteString("package gen\n\nfunc Table(n int) int {\n")
+	for i := 0; i < 300; i++ {
+		fmt.Fprintf(&src, "\tn += %d\n", i)
+	}
+	src.WriteString("\treturn n\n}\n")
+
+	functionChunks := func(maxParts int) []types.Chunk {
+		chunks, _ := NewChunker(50, 5, maxParts, false, false, true).ChunkFile(src.String(), "/p/gen.go", "go")
+		var fns []types.Chunk
+		for _, c := range chunks {
+			if c.Type == types.ChunkTypeFunction {
+				fns = append(fns, c)
+			}
+		}
+		return fns
+	}
+
+	if parts := functionChunks(10); len(parts) < 4 || len(parts) > 10 || parts[0].Name != "Table (part 1)" {
+		t.Fatalf("cap 10: %d chunks starting with %q, want the symbol split into at most 10 parts", len(parts), parts[0].Name)
+	}
+
+	capped := functionChunks(3)
+	if len(capped) != 1 {
+		t.Fatalf("cap 3: %d chunks, want one truncated chunk", len(capped))
+	}
+	c := capped[0]
+	if c.Name != "Table" || c.StartLine != 3 || c.EndLine != 305 || strings.Count(c.Content, "\n") != 49 {
+		t.Errorf("truncated chunk %q lines %d-%d with %d content lines, want Table 3-305 holding the first 50", c.Name, c.StartLine, c.EndLine, strings.Count(c.Content, "\n")+1)
+	}
+	if got := c.Metadata[types.MetadataTruncatedLines]; got != "253" {
+		t.Errorf("truncated_lines = %q, want 253", got)
+	}
+}
+
+const bazelBuildSource = `load("@io_bazel_rules_go//go:def.bzl", "go_library", "go_test")
+
+# The server library
+go_library(
+    name = "server",
+    srcs = ["server.go"],
+    deps = ["//store"],
+)
+
+go_test(
+    name = "server_test",
+    srcs = ["server_test.go"],
+)
+`
+
+func TestBazelRulesBecomeChunksNamedByTarget(t *testing.T) {
+	chunks, _ := NewChunker(500, 20, 0, false, false, true).ChunkFile(bazelBuildSource, "/p/server/BUILD.bazel", "python")
+
+	got := make(map[string][2]int)
+	for _, c := range chunks {
+		got[c.Name] = [2]int{c.StartLine, c.EndLine}
+	}
+	want := map[string][2]int{"load": {1, 1}, "server": {3, 8}, "server_test": {10, 13}}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("chunks = %v, want %v", got, want)
+	}
+	for _, c := range chunks {
+		if c.Name == "server" && !strings.Contains(c.Content, "# The server library") {
+			t.Errorf("go_library chunk lost its leading comment: %q", c.Content)
+		}
+	}
+}
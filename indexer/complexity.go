@@ -0,0 +1,81 @@
+package indexer
+
+import (
+	sitter "github.com/smacker/go-tree-sitter"
+)
+
+// decisionNodeTypes are the tree-sitter node types, across the supported
+// grammars, that add a path through a function: branches, loops, case arms,
+// exception handlers and conditional expressions. Default/else arms are not
+// listed because they do not add a decision.
+var decisionNodeTypes = map[string]bool{
+	// Branches
+	"if_statement":           true,
+	"if_expression":          true,
+	"elif_clause":            true,
+	"else_if_clause":         true,
+	"conditional_expression": true, // Python, C, C# ternaries
+	"ternary_expression":     true, // JavaScript, Java
+
+	// Loops
+	"for_statement":          true,
+	"for_in_statement":       true,
+	"enhanced_for_statement": true,
+	"for_range_loop":         true,
+	"foreach_statement":      true,
+	"for_expression":         true,
+	"while_statement":        true,
+	"while_expression":       true,
+	"do_statement":           true,
+
+	// Case arms
+	"expression_case":              true, // Go
+	"type_case":                    true,
+	"communication_case":           true,
+	"switch_case":                  true, // JavaScript/TypeScript
+	"switch_block_statement_group": true, // Java
+	"switch_section":               true, // C#
+	"case_statement":               true, // C/C++
+	"case_clause":                  true, // Python match
+	"match_arm":                    true, // Rust
+	"when_entry":                   true, // Kotlin
+
+	// Exception handlers
+	"catch_clause":  true,
+	"except_clause": true,
+	"rescue":        true,
+
+	// Short-circuit operators (Python; other grammars use binary_expression)
+	"boolean_operator": true,
+}
+
+// cyclomaticComplexity estimates the cyclomatic complexity of a function node:
+// 1 plus the number of decision points (if/for/while/case/catch, ternaries and
+// && / || operators) in its body. Nested closures count toward the enclosing
+// function. It is a cheap syntactic estimate, not a control-flow analysis.
+func cyclomaticComplexity(node *sitter.Node) int {
+	return 1 + countDecisions(node)
+}
+
+func countDecisions(node *sitter.Node) int {
+	if node == nil {
+		return 0
+	}
+
+	count := 0
+	nodeType := node.Type()
+	if decisionNodeTypes[nodeType] {
+		count++
+	} else if nodeType == "binary_expression" {
+		if op := node.ChildByFieldName("operator"); op != nil {
+			if t := op.Type(); t == "&&" || t == "||" {
+				count++
+			}
+		}
+	}
+
+	for i := 0; i < int(node.ChildCount()); i++ {
+		count += countDecisions(node.Child(i))
+	}
+	return count
+}
@@ -4,11 +4,15 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"log"
 	"math"
 	"net/http"
+	"strconv"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"mcp-semantic-search/types"
@@ -19,36 +23,183 @@ type Embedder struct {
 	baseURL    string
 	model      string
 	httpClient *http.Client
+	transport  *http.Transport // Owned by httpClient; kept to drain idle connections on Close
+	inFlight   chan struct{}   // Global cap on outstanding embedding requests
+	normalize  bool            // L2-normalize returned vectors (see SetNormalize)
+	server     *llamaServer    // Child llama-server for the local backend (nil with Ollama)
+	limiter    *rateLimiter    // Caps sustained embed requests per second (nil = unlimited, see SetRateLimit)
+	batchSize  int             // Max texts per request in EmbedBatchParallel (see SetBatchSize)
+
+	batchFallbackLogged atomic.Bool // A failed batch request was logged (logged once)
+
+	// Keep-alive pinging to keep the model resident in Ollama
+	lastUsed      atomic.Int64 // Unix nanos of the last embed request
+	keepAliveStop chan struct{}
+	keepAliveMu   sync.Mutex
 }
 
 // EmbedRequest represents the request to Ollama's embed API
 type EmbedRequest struct {
 	Model string `json:"model"`
-	Input string `json:"input"`
+	Input any    `json:"input"` // One text, or a []string embedded in one request
 }
 
-// EmbedResponse represents the response from Ollama's embed API
+// EmbedResponse represents the response from Ollama's embed API, or from an
+// OpenAI-compatible embeddings endpoint (llama-server), which uses Data
 type EmbedResponse struct {
 	Model      string      `json:"model"`
 	Embeddings [][]float32 `json:"embeddings"`
+	Data       []struct {
+		Index     int       `json:"index"`
+		Embedding []float32 `json:"embedding"`
+	} `json:"data"`
 }
 
-// NewEmbedder creates a new Embedder instance
-func NewEmbedder(baseURL, model string) *Embedder {
+// Backoff bounds for EmbedWithRetry
+const (
+	retryBaseBackoff = 100 * time.Millisecond // Connection errors and other failures
+	busyBaseBackoff  = time.Second            // Ollama busy or unavailable (429/503)
+	maxRetryBackoff  = 30 * time.Second
+)
+
+// Request timeouts: embedding can take time for large texts, and a request
+// with several texts gets more time per extra text
+const (
+	requestTimeout        = 60 * time.Second
+	requestTimeoutPerText = 10 * time.Second
+)
+
+// batchRetries is how many times embedRange sends a batch that Ollama
+// rejected as busy (429/503) before embedding its texts one by one
+const batchRetries = 3
+
+// OllamaError is returned by Embed (wrapped in a types.Error) when Ollama
+// responds with a non-200 status
+type OllamaError struct {
+	StatusCode int
+	Body       string
+	RetryAfter time.Duration // Parsed Retry-After header (0 if absent)
+}
+
+func (e *OllamaError) Error() string {
+	return fmt.Sprintf("ollama error (status %d): %s", e.StatusCode, e.Body)
+}
+
+// Busy reports whether Ollama is overloaded or temporarily unavailable
+func (e *OllamaError) Busy() bool {
+	return e.StatusCode == http.StatusTooManyRequests || e.StatusCode == http.StatusServiceUnavailable
+}
+
+// NewEmbedder creates a new Embedder instance.
+// maxConcurrent caps the number of embedding requests in flight to Ollama
+// across all callers (indexing, watcher updates, searches).
+func NewEmbedder(baseURL, model string, maxConcurrent int) *Embedder {
+	if maxConcurrent < 1 {
+		maxConcurrent = 1
+	}
+
+	// Keep one idle connection per possible in-flight request so concurrent
+	// embeds reuse connections instead of reconnecting (the default keeps 2)
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.MaxIdleConns = maxConcurrent * 2
+	transport.MaxIdleConnsPerHost = maxConcurrent
+	transport.IdleConnTimeout = 90 * time.Second
+
 	return &Embedder{
 		baseURL: baseURL,
 		model:   model,
 		httpClient: &http.Client{
-			Timeout: 60 * time.Second, // Embedding can take time for large texts
+			Transport: transport, // Timed out per request (see requestTimeout)
 		},
+		transport: transport,
+		inFlight:  make(chan struct{}, maxConcurrent),
+		normalize: true,
+		batchSize: 1,
 	}
 }
 
+// SetNormalize controls whether Embed L2-normalizes the model's vectors.
+// The vector index compares by cosine distance, which does not depend on
+// vector length, so this only saves work for models that already return
+// unit vectors. Call before the embedder is used.
+func (e *Embedder) SetNormalize(normalize bool) {
+	e.normalize = normalize
+}
+
+// SetRateLimit caps the embedding requests started per second, independent of
+// maxConcurrent, so a bulk reindex cannot flood the embedding server. 0 (or
+// less) removes the limit. Call before the embedder is used.
+func (e *Embedder) SetRateLimit(perSec float64) {
+	e.limiter = newRateLimiter(perSec)
+}
+
+// SetBatchSize sets how many texts EmbedBatchParallel sends per request
+// (minimum 1, one request per text). Each request counts once against the
+// concurrency cap and rate limit. Call before the embedder is used.
+func (e *Embedder) SetBatchSize(size int) {
+	e.batchSize = max(size, 1)
+}
+
 // Embed generates an embedding for the given text
 func (e *Embedder) Embed(ctx context.Context, text string) ([]float32, error) {
+	return e.embed(ctx, e.model, text)
+}
+
+// EmbedWithModel embeds text with another Ollama model, for diagnostics only
+// (see Indexer.TestModel): vectors from a different model must never be
+// stored next to those of the configured one. Not supported by the local
+// backend, whose llama-server serves a single model.
+func (e *Embedder) EmbedWithModel(ctx context.Context, model, text string) ([]float32, error) {
+	if e.server != nil {
+		return nil, types.NewError(types.ErrCodeInvalidRequest, "the local embedding backend serves a single model; set MCP_LOCAL_MODEL_PATH to try another", nil)
+	}
+	return e.embed(ctx, model, text)
+}
+
+// embed requests an embedding of text from model
+func (e *Embedder) embed(ctx context.Context, model, text string) ([]float32, error) {
+	embeddings, err := e.request(ctx, model, text)
+	if err != nil {
+		return nil, err
+	}
+	return embeddings[0], nil
+}
+
+// EmbedMany embeds several texts with a single request. The embeddings are
+// returned in the order of texts.
+func (e *Embedder) EmbedMany(ctx context.Context, texts []string) ([][]float32, error) {
+	embeddings, err := e.request(ctx, e.model, texts)
+	if err != nil {
+		return nil, err
+	}
+	if len(embeddings) != len(texts) {
+		return nil, fmt.Errorf("requested %d embeddings, got %d", len(texts), len(embeddings))
+	}
+	return embeddings, nil
+}
+
+// request sends one embed request for input (a string or []string) to model
+func (e *Embedder) request(ctx context.Context, model string, input any) ([][]float32, error) {
+	// Searches are not throttled behind indexing
+	if !types.IsQueryEmbedding(ctx) {
+		if err := e.limiter.wait(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	// Acquire a global in-flight slot
+	select {
+	case e.inFlight <- struct{}{}:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	defer func() { <-e.inFlight }()
+
+	e.lastUsed.Store(time.Now().UnixNano())
+
 	reqBody := EmbedRequest{
-		Model: e.model,
-		Input: text,
+		Model: model,
+		Input: input,
 	}
 
 	jsonData, err := json.Marshal(reqBody)
@@ -57,7 +208,18 @@ func (e *Embedder) Embed(ctx context.Context, text string) ([]float32, error) {
 	}
 
 	url := fmt.Sprintf("%s/api/embed", e.baseURL)
-	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(jsonData))
+	if e.server != nil {
+		url = e.baseURL + "/v1/embeddings" // Same request body, OpenAI-style response
+	}
+
+	timeout := requestTimeout
+	if texts, ok := input.([]string); ok && len(texts) > 1 {
+		timeout += time.Duration(len(texts)-1) * requestTimeoutPerText
+	}
+	reqCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(reqCtx, "POST", url, bytes.NewBuffer(jsonData))
 	if err != nil {
 		return nil, fmt.Errorf("request creation error: %w", err)
 	}
@@ -65,13 +227,20 @@ func (e *Embedder) Embed(ctx context.Context, text string) ([]float32, error) {
 
 	resp, err := e.httpClient.Do(req)
 	if err != nil {
-		return nil, fmt.Errorf("request error: %w", err)
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, types.NewError(types.ErrCodeOllamaDown, "request error", err)
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("ollama error (status %d): %s", resp.StatusCode, string(body))
+		return nil, types.NewError(types.ErrCodeEmbeddingUnavailable, "", &OllamaError{
+			StatusCode: resp.StatusCode,
+			Body:       string(body),
+			RetryAfter: parseRetryAfter(resp.Header.Get("Retry-After")),
+		})
 	}
 
 	body, err := io.ReadAll(resp.Body)
@@ -84,15 +253,26 @@ func (e *Embedder) Embed(ctx context.Context, text string) ([]float32, error) {
 		return nil, fmt.Errorf("unmarshal error: %w", err)
 	}
 
+	// OpenAI-style entries carry their input's index
+	if len(embedResp.Data) > 0 {
+		embedResp.Embeddings = make([][]float32, len(embedResp.Data))
+		for i, d := range embedResp.Data {
+			if d.Index < 0 || d.Index >= len(embedResp.Data) || embedResp.Embeddings[d.Index] != nil {
+				return nil, fmt.Errorf("invalid embedding index %d in response", d.Index)
+			}
+			embedResp.Embeddings[d.Index] = embedResp.Data[i].Embedding
+		}
+	}
 	if len(embedResp.Embeddings) == 0 {
 		return nil, fmt.Errorf("no embeddings returned")
 	}
 
-	// Normalize the embedding vector
-	embedding := embedResp.Embeddings[0]
-	normalized := normalizeVector(embedding)
-
-	return normalized, nil
+	if e.normalize {
+		for i, embedding := range embedResp.Embeddings {
+			embedResp.Embeddings[i] = normalizeVector(embedding)
+		}
+	}
+	return embedResp.Embeddings, nil
 }
 
 // EmbedBatch generates embeddings for multiple texts (sequential, for compatibility)
@@ -100,7 +280,8 @@ func (e *Embedder) EmbedBatch(ctx context.Context, texts []string) ([][]float32,
 	return e.EmbedBatchParallel(ctx, texts, 1)
 }
 
-// EmbedBatchParallel generates embeddings with concurrent workers
+// EmbedBatchParallel generates embeddings with concurrent workers, each
+// sending up to the batch size texts per request (see SetBatchSize)
 func (e *Embedder) EmbedBatchParallel(ctx context.Context, texts []string, workers int) ([][]float32, error) {
 	if len(texts) == 0 {
 		return [][]float32{}, nil
@@ -120,7 +301,9 @@ func (e *Embedder) EmbedBatchParallel(ctx context.Context, texts []string, worke
 	sem := make(chan struct{}, workers)
 	var wg sync.WaitGroup
 
-	for i, text := range texts {
+	for start := 0; start < len(texts); start += e.batchSize {
+		end := min(start+e.batchSize, len(texts))
+
 		select {
 		case <-ctx.Done():
 			return nil, ctx.Err()
@@ -128,7 +311,7 @@ func (e *Embedder) EmbedBatchParallel(ctx context.Context, texts []string, worke
 		}
 
 		wg.Add(1)
-		go func(idx int, txt string) {
+		go func(start, end int) {
 			defer wg.Done()
 
 			// Acquire semaphore
@@ -138,18 +321,13 @@ func (e *Embedder) EmbedBatchParallel(ctx context.Context, texts []string, worke
 			// Check context again inside goroutine
 			select {
 			case <-ctx.Done():
-				errors[idx] = ctx.Err()
+				errors[start] = ctx.Err()
 				return
 			default:
 			}
 
-			emb, err := e.EmbedWithRetry(ctx, txt, 3)
-			if err != nil {
-				errors[idx] = fmt.Errorf("embedding text %d: %w", idx, err)
-				return
-			}
-			embeddings[idx] = emb
-		}(i, text)
+			e.embedRange(ctx, texts, start, end, embeddings, errors)
+		}(start, end)
 	}
 
 	wg.Wait()
@@ -164,7 +342,47 @@ func (e *Embedder) EmbedBatchParallel(ctx context.Context, texts []string, worke
 	return embeddings, nil
 }
 
-// EmbedWithRetry attempts embedding with exponential backoff
+// embedRange embeds texts[start:end] into embeddings with one request, retried
+// while Ollama is busy, falling back to one request per text if that fails
+// (e.g. a server that does not accept several inputs). Failures are recorded
+// in errs.
+func (e *Embedder) embedRange(ctx context.Context, texts []string, start, end int, embeddings [][]float32, errs []error) {
+	if end-start > 1 {
+		batch, err := e.EmbedMany(ctx, texts[start:end])
+		for attempt := 0; err != nil && isBusy(err) && attempt < batchRetries-1; attempt++ {
+			select {
+			case <-ctx.Done():
+				errs[start] = ctx.Err()
+				return
+			case <-time.After(retryBackoff(err, attempt)):
+			}
+			batch, err = e.EmbedMany(ctx, texts[start:end])
+		}
+		if err == nil {
+			copy(embeddings[start:end], batch)
+			return
+		}
+		if ctx.Err() != nil {
+			errs[start] = ctx.Err()
+			return
+		}
+		if e.batchFallbackLogged.CompareAndSwap(false, true) {
+			log.Printf("Warning: embedding %d texts in one request failed, embedding them one by one: %v", end-start, err)
+		}
+	}
+
+	for i := start; i < end; i++ {
+		emb, err := e.EmbedWithRetry(ctx, texts[i], 3)
+		if err != nil {
+			errs[i] = fmt.Errorf("embedding text %d: %w", i, err)
+			return
+		}
+		embeddings[i] = emb
+	}
+}
+
+// EmbedWithRetry attempts embedding with exponential backoff.
+// Busy responses (429/503) back off longer and honor Retry-After.
 func (e *Embedder) EmbedWithRetry(ctx context.Context, text string, maxRetries int) ([]float32, error) {
 	var lastErr error
 
@@ -182,9 +400,8 @@ func (e *Embedder) EmbedWithRetry(ctx context.Context, text string, maxRetries i
 
 		lastErr = err
 
-		// Exponential backoff: 100ms, 200ms, 400ms...
 		if attempt < maxRetries-1 {
-			backoff := time.Duration(100*(1<<attempt)) * time.Millisecond
+			backoff := retryBackoff(err, attempt)
 			select {
 			case <-ctx.Done():
 				return nil, ctx.Err()
@@ -196,6 +413,44 @@ func (e *Embedder) EmbedWithRetry(ctx context.Context, text string, maxRetries i
 	return nil, fmt.Errorf("after %d retries: %w", maxRetries, lastErr)
 }
 
+// isBusy reports whether err is a busy (429/503) response from Ollama
+func isBusy(err error) bool {
+	var ollamaErr *OllamaError
+	return errors.As(err, &ollamaErr) && ollamaErr.Busy()
+}
+
+// retryBackoff returns the wait before the next attempt: 100ms, 200ms, 400ms...
+// for connection errors, 1s, 2s, 4s... (or Retry-After) when Ollama is busy
+func retryBackoff(err error, attempt int) time.Duration {
+	base := retryBaseBackoff
+
+	var ollamaErr *OllamaError
+	if errors.As(err, &ollamaErr) && ollamaErr.Busy() {
+		if ollamaErr.RetryAfter > 0 {
+			return min(ollamaErr.RetryAfter, maxRetryBackoff)
+		}
+		base = busyBaseBackoff
+	}
+
+	return min(base*time.Duration(1<<attempt), maxRetryBackoff)
+}
+
+// parseRetryAfter parses a Retry-After header given in seconds or as an HTTP date
+func parseRetryAfter(v string) time.Duration {
+	if v == "" {
+		return 0
+	}
+	if secs, err := strconv.Atoi(v); err == nil && secs > 0 {
+		return time.Duration(secs) * time.Second
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		if d := time.Until(t); d > 0 {
+			return d
+		}
+	}
+	return 0
+}
+
 // TestConnection tests the connection to Ollama
 func (e *Embedder) TestConnection(ctx context.Context) error {
 	// Try to embed a simple text
@@ -206,6 +461,68 @@ func (e *Embedder) TestConnection(ctx context.Context) error {
 	return nil
 }
 
+// StartKeepAlive periodically sends a tiny embed request so Ollama keeps the
+// model loaded between indexing bursts. Pings are skipped if the embedder was
+// used within the interval. Calling it again replaces the running keep-alive.
+func (e *Embedder) StartKeepAlive(interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+
+	e.keepAliveMu.Lock()
+	defer e.keepAliveMu.Unlock()
+
+	if e.keepAliveStop != nil {
+		close(e.keepAliveStop)
+	}
+	stop := make(chan struct{})
+	e.keepAliveStop = stop
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				idle := time.Since(time.Unix(0, e.lastUsed.Load()))
+				if idle < interval {
+					continue
+				}
+				ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+				if _, err := e.Embed(ctx, "keep-alive"); err != nil {
+					log.Printf("Model keep-alive ping failed: %v", err)
+				}
+				cancel()
+			}
+		}
+	}()
+}
+
+// StopKeepAlive stops the keep-alive pings if running
+func (e *Embedder) StopKeepAlive() {
+	e.keepAliveMu.Lock()
+	defer e.keepAliveMu.Unlock()
+
+	if e.keepAliveStop != nil {
+		close(e.keepAliveStop)
+		e.keepAliveStop = nil
+	}
+}
+
+// Close stops keep-alive pings and closes idle connections to Ollama.
+// Requests still in flight are not interrupted, except with the local
+// backend, whose llama-server is stopped.
+func (e *Embedder) Close() {
+	e.StopKeepAlive()
+	e.transport.CloseIdleConnections()
+	if e.server != nil {
+		e.server.stop()
+	}
+}
+
 // GetModel returns the configured model name
 func (e *Embedder) GetModel() string {
 	return e.model
@@ -218,6 +535,14 @@ func (e *Embedder) EmbeddingFunc() types.EmbeddingFunc {
 	}
 }
 
+// BatchEmbeddingFunc returns a function compatible with types.BatchEmbeddingFunc
+// for the store, sending up to the batch size texts per request
+func (e *Embedder) BatchEmbeddingFunc() types.BatchEmbeddingFunc {
+	return func(ctx context.Context, texts []string) ([][]float32, error) {
+		return e.EmbedBatchParallel(ctx, texts, cap(e.inFlight))
+	}
+}
+
 // normalizeVector normalizes a vector to unit length (L2 normalization)
 func normalizeVector(v []float32) []float32 {
 	var sum float64
@@ -0,0 +1,266 @@
+package indexer
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"mcp-semantic-search/types"
+)
+
+// fakeOllama serves /api/embed with vectors derived from each input's length
+// and counts the requests it receives
+func fakeOllama(t *testing.T, handle func(w http.ResponseWriter, inputs []string) bool) (*httptest.Server, *atomic.Int64) {
+	t.Helper()
+	requests := new(atomic.Int64)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		var req struct {
+			Input json.RawMessage `json:"input"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		var inputs []string
+		if err := json.Unmarshal(req.Input, &inputs); err != nil {
+			var one string
+			_ = json.Unmarshal(req.Input, &one)
+			inputs = []string{one}
+		}
+		if handle != nil && !handle(w, inputs) {
+			return
+		}
+
+		var resp EmbedResponse
+		for _, in := range inputs {
+			v, _ := fakeEmbed(r.Context(), in)
+			resp.Embeddings = append(resp.Embeddings, v)
+		}
+		_ = json.NewEncoder(w).Encode(resp)
+	}))
+	t.Cleanup(srv.Close)
+	return srv, requests
+}
+
+func TestQueryEmbeddingsBypassRateLimit(t *testing.T) {
+	srv, _ := fakeOllama(t, nil)
+	e := NewEmbedder(srv.URL, "test-model", 2)
+	e.SetRateLimit(0.5) // One request per 2s
+	ctx := context.Background()
+
+	if _, err := e.Embed(ctx, "index this"); err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Now()
+	if _, err := e.Embed(types.WithQueryEmbedding(ctx), "search query"); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("query embedding waited %s for the rate limit", elapsed)
+	}
+}
+
+func TestEmbedBatchParallelMatchesPerTextEmbeddings(t *testing.T) {
+	srv, requests := fakeOllama(t, nil)
+	e := NewEmbedder(srv.URL, "test-model", 4)
+	e.SetBatchSize(3)
+	ctx := context.Background()
+
+	texts := []string{"a", "bb", "ccc", "dddd", "eeeee", "ffffff", "ggggggg"}
+	batch, err := e.EmbedBatchParallel(ctx, texts, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := requests.Load(); got != 3 {
+		t.Errorf("sent %d requests for 7 texts in batches of 3, want 3", got)
+	}
+
+	for i, text := range texts {
+		want, err := e.Embed(ctx, text)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(batch[i], want) {
+			t.Errorf("batch embedding %d (%q) differs from its own embedding", i, text)
+		}
+	}
+}
+
+func TestEmbedBatchRetriesBusyBatchBeforeFallingBack(t *testing.T) {
+	var busy atomic.Bool
+	busy.Store(true)
+	srv, requests := fakeOllama(t, func(w http.ResponseWriter, inputs []string) bool {
+		if len(inputs) > 1 && busy.CompareAndSwap(true, false) {
+			w.Header().Set("Retry-After", "0")
+			http.Error(w, "busy", http.StatusServiceUnavailable)
+			return false
+		}
+		return true
+	})
+	e := NewEmbedder(srv.URL, "test-model", 1)
+	e.SetBatchSize(4)
+
+	if _, err := e.EmbedBatchParallel(context.Background(), []string{"a", "bb", "ccc", "dddd"}, 1); err != nil {
+		t.Fatal(err)
+	}
+	if got := requests.Load(); got != 2 {
+		t.Errorf("sent %d requests, want the busy batch retried once (2) rather than split per text", got)
+	}
+}
+
+func TestEmbedderCapsRequestsInFlight(t *testing.T) {
+	var inFlight, peak atomic.Int64
+	srv, requests := fakeOllama(t, func(w http.ResponseWriter, inputs []string) bool {
+		n := inFlight.Add(1)
+		defer inFlight.Add(-1)
+		for {
+			p := peak.Load()
+			if n <= p || peak.CompareAndSwap(p, n) {
+				break
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+		return true
+	})
+	e := NewEmbedder(srv.URL, "test-model", 2)
+
+	errs := make(chan error, 8)
+	for i := 0; i < 8; i++ {
+		go func() {
+			_, err := e.Embed(context.Background(), "text")
+			errs <- err
+		}()
+	}
+	for i := 0; i < 8; i++ {
+		if err := <-errs; err != nil {
+			t.Fatal(err)
+		}
+	}
+	if requests.Load() != 8 || peak.Load() > 2 {
+		t.Fatalf("%d requests with %d in flight at once, want 8 with at most 2", requests.Load(), peak.Load())
+	}
+}
+
+func TestEmbedErrorsCarryOllamaErrorCodes(t *testing.T) {
+	failing, _ := fakeOllama(t, func(w http.ResponseWriter, inputs []string) bool {
+		http.Error(w, "model not found", http.StatusNotFound)
+		return false
+	})
+	_, err := NewEmbedder(failing.URL, "test-model", 1).Embed(context.Background(), "text")
+	if code := types.ErrorCodeOf(err); code != types.ErrCodeEmbeddingUnavailable {
+		t.Errorf("Ollama error %v has code %s, want %s", err, code, types.ErrCodeEmbeddingUnavailable)
+	}
+
+	down, _ := fakeOllama(t, nil)
+	down.Close()
+	_, err = NewEmbedder(down.URL, "test-model", 1).Embed(context.Background(), "text")
+	if code := types.ErrorCodeOf(err); code != types.ErrCodeOllamaDown {
+		t.Errorf("unreachable Ollama %v has code %s, want %s", err, code, types.ErrCodeOllamaDown)
+	}
+}
+
+func TestEmbedderReusesConnectionsAndCloseDrainsThem(t *testing.T) {
+	var opened, closed atomic.Int64
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(10 * time.Millisecond)
+		v, _ := fakeEmbed(r.Context(), "text")
+		_ = json.NewEncoder(w).Encode(EmbedResponse{Embeddings: [][]float32{v}})
+	}))
+	srv.Config.ConnState = func(c net.Conn, state http.ConnState) {
+		switch state {
+		case http.StateNew:
+			opened.Add(1)
+		case http.StateClosed:
+			closed.Add(1)
+		}
+	}
+	srv.Start()
+	defer srv.Close()
+
+	e := NewEmbedder(srv.URL, "test-model", 4)
+	for round := 0; round < 3; round++ {
+		errs := make(chan error, 4)
+		for i := 0; i < 4; i++ {
+			go func() {
+				_, err := e.Embed(context.Background(), "text")
+				errs <- err
+			}()
+		}
+		for i := 0; i < 4; i++ {
+			if err := <-errs; err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if n := opened.Load(); n > 4 {
+		t.Errorf("opened %d connections for 3 rounds of 4 requests, want at most 4", n)
+	}
+
+	e.Close()
+	deadline := time.Now().Add(2 * time.Second)
+	for closed.Load() < opened.Load() && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if closed.Load() != opened.Load() {
+		t.Errorf("closed %d of %d connections after Close", closed.Load(), opened.Load())
+	}
+}
+
+func TestModelOverrideAppliesOnlyToTheDiagnosticEmbed(t *testing.T) {
+	var mu sync.Mutex
+	var models []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req EmbedRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		mu.Lock()
+		models = append(models, req.Model)
+		mu.Unlock()
+		dim := 16
+		if req.Model == "wide-model" {
+			dim = 24
+		}
+		_ = json.NewEncoder(w).Encode(EmbedResponse{Embeddings: [][]float32{make([]float32, dim)}})
+	}))
+	defer srv.Close()
+
+	e := NewEmbedder(srv.URL, "test-model", 1)
+	idx, _ := newTestIndexer(t, nil)
+	idx.embedder = e
+	ctx := context.Background()
+
+	result, err := idx.TestModel(ctx, " wide-model ", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Model != "wide-model" || result.Dimension != 24 || result.IndexModel != "test-model" || result.IndexDimension != 16 || result.SameDimension {
+		t.Fatalf("result = %+v, want wide-model at 24 dimensions next to the 16-dimensional index", result)
+	}
+
+	if _, err := e.Embed(ctx, "index this"); err != nil {
+		t.Fatal(err)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if want := []string{"wide-model", "test-model"}; !reflect.DeepEqual(models, want) {
+		t.Fatalf("models requested = %v, want %v", models, want)
+	}
+	if e.GetModel() != "test-model" {
+		t.Fatalf("embedder model = %q after the diagnostic embed", e.GetModel())
+	}
+
+	if _, err := idx.TestModel(ctx, "  ", ""); types.ErrorCodeOf(err) != types.ErrCodeInvalidRequest {
+		t.Fatalf("empty model: err = %v, want invalid_request", err)
+	}
+}
@@ -0,0 +1,178 @@
+package indexer
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"unicode/utf8"
+
+	"mcp-semantic-search/config"
+	"mcp-semantic-search/types"
+)
+
+// FileStatus diagnoses a single file: whether it is indexed and with how many
+// chunks, whether its content changed since indexing, and why indexing would
+// skip it (exclusion rules of the indexed folder containing it, or content
+// that is binary, minified or invalid UTF-8).
+func (idx *Indexer) FileStatus(ctx context.Context, filePath string) (*types.FileStatus, error) {
+	absFile, err := filepath.Abs(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve path: %w", err)
+	}
+	if err := idx.CheckAllowedPath(absFile); err != nil {
+		return nil, err
+	}
+
+	status := &types.FileStatus{
+		Path:     absFile,
+		Language: detectLanguage(absFile),
+		Chunks:   idx.store.CountFileChunks(ctx, absFile),
+	}
+	status.Indexed = status.Chunks > 0
+	status.Project = idx.containingProject(absFile)
+	if status.Project != "" {
+		status.StoredHash = idx.hashStore.GetFileHash(status.Project, absFile)
+	}
+
+	info, err := os.Stat(absFile)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			return nil, fmt.Errorf("failed to stat file: %w", err)
+		}
+		status.Stale = status.Indexed || status.StoredHash != ""
+		status.Excluded = "file does not exist"
+		return status, nil
+	}
+	if info.IsDir() {
+		return nil, types.NewError(types.ErrCodeInvalidRequest, absFile+" is a directory", nil)
+	}
+	status.Exists = true
+	status.Size = info.Size()
+
+	scanner, err := idx.fileScanner(absFile, status.Project)
+	if err != nil {
+		return nil, err
+	}
+
+	if status.CurrentHash, err = scanner.hashFile(absFile); err != nil {
+		return nil, fmt.Errorf("failed to hash file: %w", err)
+	}
+	status.Stale = status.StoredHash != "" && status.StoredHash != status.CurrentHash
+
+	status.Excluded = scanner.ExcludeReason(absFile, info)
+	if status.Excluded == "" {
+		status.Excluded = contentSkipReason(absFile, idx.cfg)
+	}
+	if status.Excluded == "" && status.Project == "" {
+		status.Excluded = "not inside an indexed folder"
+	}
+	return status, nil
+}
+
+// ExplainIndex reports every rule deciding whether a file is indexed: the
+// scanner's directory, size, extension and .gitignore rules (with the
+// matching file and rule), the content checks (binary, minified, UTF-8) and
+// whether it lies in an indexed folder, together with its detected language
+// and whether it is in the index now.
+func (idx *Indexer) ExplainIndex(ctx context.Context, filePath string) (*types.IndexExplanation, error) {
+	absFile, err := filepath.Abs(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve path: %w", err)
+	}
+	if err := idx.CheckAllowedPath(absFile); err != nil {
+		return nil, err
+	}
+
+	explanation := &types.IndexExplanation{
+		Path:     absFile,
+		Project:  idx.containingProject(absFile),
+		Language: detectLanguage(absFile),
+		Chunks:   idx.store.CountFileChunks(ctx, absFile),
+	}
+	explanation.Indexed = explanation.Chunks > 0
+
+	info, err := os.Stat(absFile)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			return nil, fmt.Errorf("failed to stat file: %w", err)
+		}
+		explanation.Checks = []types.IndexCheck{{Check: "exists", Detail: "file does not exist"}}
+		explanation.Excluded = "file does not exist"
+		return explanation, nil
+	}
+	if info.IsDir() {
+		return nil, types.NewError(types.ErrCodeInvalidRequest, absFile+" is a directory", nil)
+	}
+	explanation.Exists = true
+	explanation.Size = info.Size()
+
+	scanner, err := idx.fileScanner(absFile, explanation.Project)
+	if err != nil {
+		return nil, err
+	}
+	explanation.Checks = scanner.Explain(absFile, info)
+	explanation.Checks = append(explanation.Checks, contentChecks(absFile, idx.cfg, true)...)
+	explanation.Checks = append(explanation.Checks,
+		indexCheck("indexed_folder", explanation.Project == "", "not inside an indexed folder"))
+	explanation.Excluded = failedCheck(explanation.Checks)
+	return explanation, nil
+}
+
+// containingProject returns the innermost indexed folder containing absFile,
+// which holds its hash and exclusion rules, or "" if there is none
+func (idx *Indexer) containingProject(absFile string) string {
+	project := ""
+	for _, folder := range idx.hashStore.ListIndexedFolders() {
+		if hasPrefix(absFile, folder) && len(folder) > len(project) {
+			project = folder
+		}
+	}
+	return project
+}
+
+// fileScanner returns a scanner applying the exclusion rules of project to
+// absFile. Outside indexed folders, .gitignore rules are evaluated as if the
+// file's repository (or directory) were indexed.
+func (idx *Indexer) fileScanner(absFile, project string) (*Scanner, error) {
+	root := project
+	if root == "" {
+		if gitRoot, ok := FindGitRoot(filepath.Dir(absFile)); ok {
+			root = gitRoot
+		} else {
+			root = filepath.Dir(absFile)
+		}
+	}
+	scanner, err := NewScanner(idx.cfg, root)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create scanner: %w", err)
+	}
+	return scanner, nil
+}
+
+// contentSkipReason explains why ReadFileContent would return no content for
+// a file that passed the scanner's rules, or returns "" if it is indexable
+func contentSkipReason(path string, cfg *config.Config) string {
+	return failedCheck(contentChecks(path, cfg, false))
+}
+
+// contentChecks evaluates the content rules of ReadFileContent in order.
+// Unless all is set it stops at the first failed check; an unreadable file
+// ends the checks either way.
+func contentChecks(path string, cfg *config.Config, all bool) []types.IndexCheck {
+	content, err := readFileBytes(path, cfg.MaxReadBytes)
+	if err != nil {
+		return []types.IndexCheck{{Check: "readable", Detail: fmt.Sprintf("cannot be read: %v", err)}}
+	}
+	checks := []types.IndexCheck{indexCheck("binary", content == nil, "binary file")}
+	if content == nil {
+		return checks
+	}
+
+	reason := nonTextReason(content, cfg.MaxLineLength)
+	checks = append(checks, indexCheck("text", reason != "", reason+" (MCP_MAX_LINE_LENGTH)"))
+	if reason != "" && !all {
+		return checks
+	}
+	return append(checks, indexCheck("utf8", cfg.InvalidUTF8 == "skip" && !utf8.Valid(content), "invalid UTF-8 (MCP_INVALID_UTF8=skip)"))
+}
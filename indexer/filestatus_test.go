@@ -0,0 +1,142 @@
+package indexer
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"mcp-semantic-search/config"
+	"mcp-semantic-search/types"
+)
+
+// failed returns the named check if it failed, or nil
+func failed(checks []types.IndexCheck, name string) *types.IndexCheck {
+	for i := range checks {
+		if checks[i].Check == name && !checks[i].Passed {
+			return &checks[i]
+		}
+	}
+	return nil
+}
+
+func TestExplainIndexReportsEachExclusionReason(t *testing.T) {
+	idx, _ := newTestIndexer(t, func(cfg *config.Config) { cfg.MaxFileSize = 200 })
+	dir := writeFiles(t, map[string]string{
+		"main.go":                 "package main\n\nfunc main() {}\n",
+		".gitignore":              "# generated\nsecret.go\n",
+		"secret.go":               "package main\n",
+		"sub/.gitignore":          "gen/\n",
+		"sub/gen/out.go":          "package gen\n",
+		"node_modules/lib/lib.js": "module.exports = 1\n",
+		"tool.exe":                "MZ",
+		"big.go":                  "package main\n\n// " + strings.Repeat("x", 300) + "\n",
+		"blob.go":                 "package main\x00\x01\x02\n",
+	})
+	ctx := context.Background()
+	if _, err := idx.IndexProject(ctx, dir, false, false); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		file, check, detail string
+	}{
+		{"secret.go", "gitignore", "ignored by .gitignore (.gitignore:2: secret.go)"},
+		{"sub/gen/out.go", "directory", "directory gen is ignored by .gitignore (sub/.gitignore:1: gen/)"},
+		{"node_modules/lib/lib.js", "directory", "directory node_modules is always excluded"},
+		{"tool.exe", "excluded_ext", "extension .exe is excluded"},
+		{"big.go", "max_file_size", "larger than MCP_MAX_FILE_SIZE"},
+		{"blob.go", "binary", "binary file"},
+	}
+	for _, tt := range tests {
+		explanation, err := idx.ExplainIndex(ctx, filepath.Join(dir, tt.file))
+		if err != nil {
+			t.Fatal(err)
+		}
+		check := failed(explanation.Checks, tt.check)
+		if check == nil || !strings.HasPrefix(check.Detail, tt.detail) {
+			t.Errorf("%s: checks %+v, want %s to fail with %q", tt.file, explanation.Checks, tt.check, tt.detail)
+			continue
+		}
+		if explanation.Indexed {
+			t.Errorf("%s: excluded file reported as indexed", tt.file)
+		}
+	}
+
+	explanation, err := idx.ExplainIndex(ctx, filepath.Join(dir, "main.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if explanation.Excluded != "" || !explanation.Indexed || explanation.Language != "go" || explanation.Project != dir {
+		t.Errorf("main.go: %+v, want an indexed Go file in %s", explanation, dir)
+	}
+	for _, check := range explanation.Checks {
+		if !check.Passed {
+			t.Errorf("main.go: check %+v failed", check)
+		}
+	}
+}
+
+func TestExplainIndexReportsEveryFailedRule(t *testing.T) {
+	idx, _ := newTestIndexer(t, func(cfg *config.Config) { cfg.MaxFileSize = 10 })
+	dir := writeFiles(t, map[string]string{
+		".gitignore": "*.exe\n",
+		"tool.exe":   "MZ this is larger than ten bytes",
+	})
+	if _, err := idx.IndexProject(context.Background(), dir, false, false); err != nil {
+		t.Fatal(err)
+	}
+
+	explanation, err := idx.ExplainIndex(context.Background(), filepath.Join(dir, "tool.exe"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"max_file_size", "excluded_ext", "gitignore"} {
+		if failed(explanation.Checks, name) == nil {
+			t.Errorf("check %s did not fail: %+v", name, explanation.Checks)
+		}
+	}
+	if !strings.HasPrefix(explanation.Excluded, "larger than MCP_MAX_FILE_SIZE") {
+		t.Errorf("Excluded = %q, want the first failed rule", explanation.Excluded)
+	}
+}
+
+func TestFileStatusReportsIndexedStaleAndExcludedFiles(t *testing.T) {
+	idx, _ := newTestIndexer(t, nil)
+	dir := writeFiles(t, map[string]string{
+		"main.go":    "package main\n\nfunc main() {}\n",
+		".gitignore": "secret.go\n",
+		"secret.go":  "package main\n",
+	})
+	ctx := context.Background()
+	if _, err := idx.IndexProject(ctx, dir, false, false); err != nil {
+		t.Fatal(err)
+	}
+
+	main := filepath.Join(dir, "main.go")
+	status, err := idx.FileStatus(ctx, main)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !status.Indexed || status.Chunks == 0 || status.Stale || status.Project != dir || status.Language != "go" {
+		t.Fatalf("main.go status = %+v, want indexed and current in %s", status, dir)
+	}
+
+	if err := os.WriteFile(main, []byte("package main\n\nfunc main() { run() }\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if status, err = idx.FileStatus(ctx, main); err != nil {
+		t.Fatal(err)
+	}
+	if !status.Stale || status.StoredHash == status.CurrentHash {
+		t.Fatalf("edited main.go status = %+v, want stale", status)
+	}
+
+	if status, err = idx.FileStatus(ctx, filepath.Join(dir, "secret.go")); err != nil {
+		t.Fatal(err)
+	}
+	if status.Indexed || !strings.Contains(status.Excluded, ".gitignore:1: secret.go") {
+		t.Fatalf("secret.go status = %+v, want excluded by .gitignore:1", status)
+	}
+}
@@ -0,0 +1,59 @@
+package indexer
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// changedSince returns the absolute paths under absPath that differ from git
+// ref ref: files modified, added or deleted since ref (committed or not) and
+// untracked files that are not ignored. Requires git on PATH and absPath to be
+// inside a git repository.
+func changedSince(ctx context.Context, absPath, ref string) (map[string]bool, error) {
+	if strings.HasPrefix(ref, "-") {
+		return nil, fmt.Errorf("invalid git ref %q", ref)
+	}
+
+	gitRoot, ok := FindGitRoot(absPath)
+	if !ok {
+		return nil, fmt.Errorf("%s is not inside a git repository", absPath)
+	}
+
+	// Renames are reported as a deletion plus an addition, so the old path is dropped
+	diff, err := runGit(ctx, gitRoot, "diff", "--name-only", "--no-renames", "-z", ref, "--")
+	if err != nil {
+		return nil, err
+	}
+	untracked, err := runGit(ctx, gitRoot, "ls-files", "--others", "--exclude-standard", "-z")
+	if err != nil {
+		return nil, err
+	}
+
+	changed := make(map[string]bool)
+	for _, rel := range strings.Split(diff+untracked, "\x00") {
+		if rel == "" {
+			continue
+		}
+		path := filepath.Join(gitRoot, filepath.FromSlash(rel))
+		if hasPrefix(path, absPath) {
+			changed[path] = true
+		}
+	}
+	return changed, nil
+}
+
+// runGit runs a git subcommand in dir and returns its stdout
+func runGit(ctx context.Context, dir string, args ...string) (string, error) {
+	cmd := exec.CommandContext(ctx, "git", append([]string{"-C", dir}, args...)...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("git %s failed: %v: %s", args[0], err, strings.TrimSpace(stderr.String()))
+	}
+	return string(out), nil
+}
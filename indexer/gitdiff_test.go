@@ -0,0 +1,69 @@
+package indexer
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+// git runs a git command in dir or fails the test
+func git(t *testing.T, dir string, args ...string) {
+	t.Helper()
+	cmd := exec.Command("git", append([]string{"-C", dir, "-c", "user.name=test", "-c", "user.email=test@example.com"}, args...)...)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("git %v: %v\n%s", args, err, out)
+	}
+}
+
+func TestIndexProjectSinceProcessesOnlyChangedFiles(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not available")
+	}
+	idx, st := newTestIndexer(t, nil)
+	dir := writeFiles(t, map[string]string{
+		"a.go": "package main\n\nfunc A() {}\n",
+		"b.go": "package main\n\nfunc B() {}\n",
+		"c.go": "package main\n\nfunc C() {}\n",
+	})
+	git(t, dir, "init", "-q")
+	git(t, dir, "add", "-A")
+	git(t, dir, "commit", "-q", "-m", "base")
+	git(t, dir, "tag", "base")
+
+	ctx := context.Background()
+	if _, err := idx.IndexProject(ctx, dir, false, false); err != nil {
+		t.Fatal(err)
+	}
+
+	// a.go changes in a commit, c.go is deleted and d.go is new and untracked
+	if err := os.WriteFile(filepath.Join(dir, "a.go"), []byte("package main\n\nfunc A2() {}\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	git(t, dir, "commit", "-q", "-am", "change a")
+	if err := os.Remove(filepath.Join(dir, "c.go")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "d.go"), []byte("package main\n\nfunc D() {}\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := idx.IndexProjectSince(ctx, dir, "base", false, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// b.go is not even hashed, so it is not counted as skipped
+	if result.FilesIndexed != 2 || result.Deleted != 1 || result.Skipped != 0 || result.SinceRef != "base" {
+		t.Fatalf("result = %+v, want a.go and d.go indexed, c.go deleted, b.go untouched", result)
+	}
+	for name, want := range map[string]bool{"a.go": true, "b.go": true, "c.go": false, "d.go": true} {
+		if got := st.CountFileChunks(ctx, filepath.Join(dir, name)) > 0; got != want {
+			t.Errorf("%s indexed = %v, want %v", name, got, want)
+		}
+	}
+
+	if _, err := idx.IndexProjectSince(ctx, dir, "--output=x", false, false); err == nil {
+		t.Error("a ref starting with - was passed to git")
+	}
+}
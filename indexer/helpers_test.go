@@ -0,0 +1,56 @@
+package indexer
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"mcp-semantic-search/config"
+	"mcp-semantic-search/store"
+)
+
+// fakeEmbed is a deterministic embedding: texts of equal length get equal vectors
+func fakeEmbed(ctx context.Context, text string) ([]float32, error) {
+	v := make([]float32, 16)
+	v[0] = 1
+	v[len(text)%16] += 1
+	return v, nil
+}
+
+// newTestIndexer returns an indexer over a fresh store in a temp directory,
+// after applying configure to the default config
+func newTestIndexer(t *testing.T, configure func(*config.Config)) (*Indexer, *store.Store) {
+	t.Helper()
+	cfg := config.DefaultConfig()
+	cfg.DBPath = t.TempDir()
+	cfg.WatchEnabled = false
+	if configure != nil {
+		configure(cfg)
+	}
+
+	st, err := store.NewStore(cfg, fakeEmbed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { st.Close() })
+
+	return NewIndexer(cfg, st, st.NewFileHashStore(), nil), st
+}
+
+// writeFiles creates files (relative path -> content) under a temp project
+// directory and returns the directory
+func writeFiles(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for rel, content := range files {
+		path := filepath.Join(dir, rel)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
@@ -0,0 +1,100 @@
+package indexer
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"mcp-semantic-search/types"
+)
+
+// commitMarker prefixes the header line git prints for each commit
+const commitMarker = "__SSSS_COMMIT__"
+
+// SymbolHistory returns recent commits that changed the line range of an indexed symbol.
+// This is best-effort: it requires git on PATH and the symbol's file to be inside a git repo.
+func (idx *Indexer) SymbolHistory(ctx context.Context, symbol string, maxCommits int) (*types.SymbolHistory, error) {
+	meta, err := idx.store.GetChunkMetadata(ctx, symbol)
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up symbol: %w", err)
+	}
+	if meta == nil {
+		return nil, fmt.Errorf("symbol %q not found in index", symbol)
+	}
+
+	absFile := meta["absolute_path"]
+	gitRoot, ok := FindGitRoot(filepath.Dir(absFile))
+	if !ok {
+		return nil, fmt.Errorf("%s is not inside a git repository", absFile)
+	}
+
+	relFile, err := filepath.Rel(gitRoot, absFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve path: %w", err)
+	}
+	relFile = filepath.ToSlash(relFile)
+
+	if maxCommits < 1 {
+		maxCommits = 5
+	}
+
+	lineRange := fmt.Sprintf("%s,%s:%s", meta["start_line"], meta["end_line"], relFile)
+	cmd := exec.CommandContext(ctx, "git", "-C", gitRoot, "log",
+		"-n", fmt.Sprint(maxCommits),
+		"--no-color",
+		"--date=short",
+		"--format="+commitMarker+"%H%x09%an%x09%ad%x09%s",
+		"-L", lineRange,
+	)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("git log failed: %v: %s", err, strings.TrimSpace(stderr.String()))
+	}
+
+	return &types.SymbolHistory{
+		Symbol:   symbol,
+		FilePath: relFile,
+		Lines:    fmt.Sprintf("%s-%s", meta["start_line"], meta["end_line"]),
+		Commits:  parseLineLog(string(out)),
+	}, nil
+}
+
+// parseLineLog splits `git log -L` output into commits using commitMarker header lines
+func parseLineLog(out string) []types.SymbolCommit {
+	var commits []types.SymbolCommit
+	var diff strings.Builder
+
+	flush := func() {
+		if len(commits) > 0 {
+			commits[len(commits)-1].Diff = strings.TrimSpace(diff.String())
+		}
+		diff.Reset()
+	}
+
+	for _, line := range strings.Split(out, "\n") {
+		if strings.HasPrefix(line, commitMarker) {
+			flush()
+			fields := strings.SplitN(strings.TrimPrefix(line, commitMarker), "\t", 4)
+			for len(fields) < 4 {
+				fields = append(fields, "")
+			}
+			commits = append(commits, types.SymbolCommit{
+				SHA:     fields[0],
+				Author:  fields[1],
+				Date:    fields[2],
+				Subject: fields[3],
+			})
+			continue
+		}
+		diff.WriteString(line)
+		diff.WriteString("\n")
+	}
+	flush()
+
+	return commits
+}
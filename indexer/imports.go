@@ -0,0 +1,269 @@
+package indexer
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"mcp-semantic-search/types"
+)
+
+var (
+	quotedImportPattern = regexp.MustCompile("[\"'`]([^\"'`]+)[\"'`]")
+	pythonImportPattern = regexp.MustCompile(`^\s*import\s+(.+)$`)
+	pythonFromPattern   = regexp.MustCompile(`^\s*from\s+(\S+)\s+import\b`)
+	dottedImportPattern = regexp.MustCompile(`^\s*(?:import|using)\s+(?:static\s+)?(?:\w+\s*=\s*)?([\w.]+?)(?:\.\*)?\s*;?\s*$`)
+	rustUsePattern      = regexp.MustCompile(`^\s*(?:pub(?:\([^)]*\))?\s+)?use\s+([\w:]+?)(?:::\{.*\}|::\*)?(?:\s+as\s+\w+)?\s*;?\s*$`)
+	phpUsePattern       = regexp.MustCompile(`^\s*use\s+(?:function\s+|const\s+)?\\?([\w\\]+?)(?:\\\{.*\})?(?:\s+as\s+\w+)?\s*;?\s*$`)
+)
+
+// importPaths extracts the imported module/package paths from the source of
+// an import node, as written (e.g. "fmt", "./util", "os.path", "crate::store")
+func importPaths(text, language string) []string {
+	text = strings.Join(strings.Fields(text), " ")
+
+	switch language {
+	case "go", "javascript", "typescript", "ruby":
+		var paths []string
+		for _, m := range quotedImportPattern.FindAllStringSubmatch(text, -1) {
+			paths = append(paths, m[1])
+		}
+		return paths
+	case "python":
+		if m := pythonFromPattern.FindStringSubmatch(text); m != nil {
+			return []string{m[1]}
+		}
+		if m := pythonImportPattern.FindStringSubmatch(text); m != nil {
+			var paths []string
+			for _, part := range strings.Split(m[1], ",") {
+				if fields := strings.Fields(part); len(fields) > 0 {
+					paths = append(paths, fields[0])
+				}
+			}
+			return paths
+		}
+	case "java", "csharp":
+		if m := dottedImportPattern.FindStringSubmatch(text); m != nil {
+			return []string{m[1]}
+		}
+	case "rust":
+		if m := rustUsePattern.FindStringSubmatch(text); m != nil {
+			return []string{m[1]}
+		}
+	case "php":
+		if m := phpUsePattern.FindStringSubmatch(text); m != nil {
+			return []string{m[1]}
+		}
+	}
+	return nil
+}
+
+// DependencyGraph returns the import graph of an indexed folder: every indexed
+// file with its import paths, and an edge for each import that resolves to
+// another file of the folder. Paths are relative to the folder.
+func (idx *Indexer) DependencyGraph(ctx context.Context, folderPath string) (*types.DependencyGraph, error) {
+	absPath, err := filepath.Abs(folderPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve path: %w", err)
+	}
+
+	nodes, err := idx.store.FileImports(ctx, absPath)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range nodes {
+		if rel, err := filepath.Rel(absPath, nodes[i].Path); err == nil {
+			nodes[i].Path = filepath.ToSlash(rel)
+		}
+	}
+
+	resolver := newImportResolver(nodes, goModulePath(absPath))
+	edges := make([]types.DependencyEdge, 0)
+	for _, node := range nodes {
+		seen := make(map[string]bool)
+		for _, imp := range node.Imports {
+			for _, target := range resolver.resolve(node, imp) {
+				if target == node.Path || seen[target] {
+					continue
+				}
+				seen[target] = true
+				edges = append(edges, types.DependencyEdge{From: node.Path, To: target, Import: imp})
+			}
+		}
+	}
+
+	return &types.DependencyGraph{Root: absPath, Nodes: nodes, Edges: edges}, nil
+}
+
+// goModulePath returns the Go import path of root: the module path declared
+// in the nearest go.mod at or above root, joined with root's directory within
+// that module. Returns "" outside a Go module.
+func goModulePath(root string) string {
+	for dir := root; ; dir = filepath.Dir(dir) {
+		if module := goModuleDirective(filepath.Join(dir, "go.mod")); module != "" {
+			rel, err := filepath.Rel(dir, root)
+			if err != nil || rel == "." {
+				return module
+			}
+			return module + "/" + filepath.ToSlash(rel)
+		}
+		if filepath.Dir(dir) == dir {
+			return ""
+		}
+	}
+}
+
+// goModuleDirective returns the module path declared in a go.mod file, if any
+func goModuleDirective(goMod string) string {
+	f, err := os.Open(goMod)
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if fields := strings.Fields(scanner.Text()); len(fields) == 2 && fields[0] == "module" {
+			return strings.Trim(fields[1], `"`)
+		}
+	}
+	return ""
+}
+
+// importResolver maps import paths to the project files they refer to
+type importResolver struct {
+	goModule string
+	byStem   map[string][]string // Path without extension -> files
+	byDir    map[string][]string // Directory -> files
+}
+
+func newImportResolver(nodes []types.DependencyNode, goModule string) *importResolver {
+	r := &importResolver{
+		goModule: goModule,
+		byStem:   make(map[string][]string),
+		byDir:    make(map[string][]string),
+	}
+	for _, node := range nodes {
+		stem := strings.TrimSuffix(node.Path, path.Ext(node.Path))
+		r.byStem[stem] = append(r.byStem[stem], node.Path)
+		dir := path.Dir(node.Path)
+		r.byDir[dir] = append(r.byDir[dir], node.Path)
+	}
+	return r
+}
+
+// resolve returns the project files an import of from refers to: a module
+// file, or every file of a package directory. Relative imports resolve against
+// the importing file; other imports match project paths ending with the import.
+func (r *importResolver) resolve(from types.DependencyNode, imp string) []string {
+	fromDir := path.Dir(from.Path)
+
+	switch from.Language {
+	case "javascript", "typescript", "ruby":
+		if !strings.HasPrefix(imp, ".") {
+			if from.Language == "ruby" {
+				return r.match(imp, false)
+			}
+			return nil
+		}
+		target := path.Join(fromDir, imp)
+		if files := r.byStem[strings.TrimSuffix(target, path.Ext(target))]; len(files) > 0 {
+			return files
+		}
+		return r.byStem[path.Join(target, "index")]
+	case "python":
+		rel := strings.TrimLeft(imp, ".")
+		if dots := len(imp) - len(rel); dots > 0 {
+			dir := fromDir
+			for i := 1; i < dots; i++ {
+				dir = path.Dir(dir)
+			}
+			return r.lookup(path.Join(dir, strings.ReplaceAll(rel, ".", "/")), true)
+		}
+		return r.match(strings.ReplaceAll(imp, ".", "/"), false)
+	case "go":
+		if r.goModule == "" || (imp != r.goModule && !strings.HasPrefix(imp, r.goModule+"/")) {
+			return nil
+		}
+		dir := strings.TrimPrefix(strings.TrimPrefix(imp, r.goModule), "/")
+		if dir == "" {
+			dir = "."
+		}
+		var files []string
+		for _, file := range r.byDir[dir] {
+			if !strings.HasSuffix(file, "_test.go") {
+				files = append(files, file)
+			}
+		}
+		return files
+	case "java", "csharp":
+		return r.match(strings.ReplaceAll(imp, ".", "/"), true)
+	case "rust":
+		key := strings.ReplaceAll(imp, "::", "/")
+		if strings.HasPrefix(key, "self/") || strings.HasPrefix(key, "super/") {
+			// Approximate: self and the first super are the importing file's directory
+			dir := fromDir
+			key = strings.TrimPrefix(key, "self/")
+			for strings.HasPrefix(key, "super/") {
+				key = strings.TrimPrefix(key, "super/")
+				if strings.HasPrefix(key, "super/") {
+					dir = path.Dir(dir)
+				}
+			}
+			return r.lookup(path.Join(dir, key), true)
+		}
+		return r.match(strings.TrimPrefix(key, "crate/"), true)
+	case "php":
+		return r.match(strings.ReplaceAll(imp, `\`, "/"), true)
+	}
+	return nil
+}
+
+// lookup returns the files of a module path (file stem) or package directory.
+// With symbolFallback, an unresolved path is retried without its last segment,
+// for languages whose imports name a symbol inside a module.
+func (r *importResolver) lookup(key string, symbolFallback bool) []string {
+	if files := r.byStem[key]; len(files) > 0 {
+		return files
+	}
+	if files := r.byDir[key]; len(files) > 0 {
+		return files
+	}
+	if symbolFallback && strings.Contains(key, "/") {
+		return r.lookup(path.Dir(key), false)
+	}
+	return nil
+}
+
+// match resolves a non-relative import key against every project path ending
+// with it, so source roots (src/, src/main/java/) need not be configured
+func (r *importResolver) match(key string, symbolFallback bool) []string {
+	if key == "" {
+		return nil
+	}
+	var files []string
+	for stem, stemFiles := range r.byStem {
+		if stem == key || strings.HasSuffix(stem, "/"+key) {
+			files = append(files, stemFiles...)
+		}
+	}
+	if len(files) == 0 {
+		for dir, dirFiles := range r.byDir {
+			if dir == key || strings.HasSuffix(dir, "/"+key) {
+				files = append(files, dirFiles...)
+			}
+		}
+	}
+	if len(files) == 0 && symbolFallback && strings.Contains(key, "/") {
+		return r.match(path.Dir(key), false)
+	}
+	sort.Strings(files)
+	return files
+}
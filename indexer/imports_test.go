@@ -0,0 +1,51 @@
+package indexer
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"mcp-semantic-search/types"
+)
+
+func TestDependencyGraphResolvesGoImportsFromParentModule(t *testing.T) {
+	idx, _ := newTestIndexer(t, nil)
+	dir := writeFiles(t, map[string]string{
+		"go.mod":          "module example.com/m\n\ngo 1.24\n",
+		"pkg/a/a.go":      "package a\n\nimport \"example.com/m/pkg/b\"\n\nfunc A() { b.B() }\n",
+		"pkg/b/b.go":      "package b\n\nfunc B() {}\n",
+		"pkg/b/b_test.go": "package b\n\nimport \"testing\"\n\nfunc TestB(t *testing.T) { B() }\n",
+	})
+	pkg := filepath.Join(dir, "pkg")
+	ctx := context.Background()
+	if _, err := idx.IndexProject(ctx, pkg, false, false); err != nil {
+		t.Fatal(err)
+	}
+
+	graph, err := idx.DependencyGraph(ctx, pkg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := types.DependencyEdge{From: "a/a.go", To: "b/b.go", Import: "example.com/m/pkg/b"}
+	if len(graph.Edges) != 1 || graph.Edges[0] != want {
+		t.Fatalf("edges = %+v, want [%+v]", graph.Edges, want)
+	}
+}
+
+func TestGoModulePathWalksUpToNearestGoMod(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"go.mod":         "module example.com/m\n",
+		"tools/go.mod":   "module example.com/tools\n",
+		"pkg/sub/x.go":   "package sub\n",
+		"tools/gen/x.go": "package gen\n",
+	})
+	for rel, want := range map[string]string{
+		".":         "example.com/m",
+		"pkg/sub":   "example.com/m/pkg/sub",
+		"tools/gen": "example.com/tools/gen",
+	} {
+		if got := goModulePath(filepath.Join(dir, rel)); got != want {
+			t.Errorf("goModulePath(%s) = %q, want %q", rel, got, want)
+		}
+	}
+}
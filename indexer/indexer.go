@@ -6,7 +6,10 @@ import (
 	"encoding/hex"
 	"fmt"
 	"log"
+	"os"
+	"path"
 	"path/filepath"
+	"sort"
 	"strings"
 	"sync"
 	"time"
@@ -59,17 +62,22 @@ type Indexer struct {
 	opQueue   map[string]FileOperation // keyed by absolute path for deduplication
 	opQueueMu sync.Mutex
 	isBusy    bool // true when indexing is in progress
+	pending   map[string]bool // Folders with an IndexProject call running or waiting (guarded by opQueueMu)
 }
 
 // NewIndexer creates a new Indexer instance
 func NewIndexer(cfg *config.Config, st *store.Store, hashStore *store.FileHashStore, embedder *Embedder) *Indexer {
+	chunker := NewChunker(cfg.MaxChunkSize, cfg.ChunkOverlap, cfg.MaxSymbolParts, cfg.IndexComments, cfg.MergeSmall, cfg.IndexTests)
+	chunker.SetExtractReferences(cfg.ExtractReferencesFor)
+
 	return &Indexer{
 		cfg:       cfg,
 		store:     st,
 		hashStore: hashStore,
 		embedder:  embedder,
-		chunker:   NewChunker(cfg.MaxChunkSize, cfg.ChunkOverlap),
+		chunker:   chunker,
 		opQueue:   make(map[string]FileOperation),
+		pending:   make(map[string]bool),
 	}
 }
 
@@ -104,6 +112,10 @@ func (idx *Indexer) ScanProject(ctx context.Context, projectPath string) (*types
 		return nil, fmt.Errorf("failed to resolve path: %w", err)
 	}
 
+	if err := idx.checkFolder(absPath); err != nil {
+		return nil, err
+	}
+
 	idx.sendProgress(types.ProgressEvent{
 		Type:    "scanning",
 		Project: filepath.Base(absPath),
@@ -162,7 +174,15 @@ func (idx *Indexer) ScanProject(ctx context.Context, projectPath string) (*types
 }
 
 // IndexFolder indexes a folder with incremental support using global collection
-func (idx *Indexer) IndexProject(ctx context.Context, folderPath string, enableWatch bool) (*types.IndexResult, error) {
+func (idx *Indexer) IndexProject(ctx context.Context, folderPath string, enableWatch, force bool) (*types.IndexResult, error) {
+	return idx.IndexProjectSince(ctx, folderPath, "", enableWatch, force)
+}
+
+// IndexProjectSince is IndexProject limited to the files that changed since
+// git ref sinceRef (see changedSince), for fast scoped CI runs: only those
+// files are scanned, reindexed when their content changed, or removed when
+// deleted. An empty sinceRef indexes the whole folder.
+func (idx *Indexer) IndexProjectSince(ctx context.Context, folderPath, sinceRef string, enableWatch, force bool) (*types.IndexResult, error) {
 	startTime := time.Now()
 
 	// Resolve absolute path
@@ -171,6 +191,30 @@ func (idx *Indexer) IndexProject(ctx context.Context, folderPath string, enableW
 		return nil, fmt.Errorf("failed to resolve path: %w", err)
 	}
 
+	if err := idx.checkFolder(absPath); err != nil {
+		return nil, err
+	}
+	if !force {
+		if err := CheckBroadRoot(absPath); err != nil {
+			return nil, err
+		}
+	}
+
+	// Reject a second request for a folder that is already being indexed
+	if !idx.beginIndexing(absPath) {
+		return nil, types.NewError(types.ErrCodeBusy, fmt.Sprintf("%s is already being indexed", absPath), nil)
+	}
+	defer idx.endIndexing(absPath)
+
+	// Refuse to index if the model's output dimension changed since startup;
+	// other probe failures surface per file as before
+	if err := idx.store.CheckEmbeddingDimension(ctx); types.ErrorCodeOf(err) == types.ErrCodeDimensionMismatch {
+		return nil, err
+	}
+	if err := idx.store.ModelError(); err != nil {
+		return nil, err
+	}
+
 	folderName := filepath.Base(absPath)
 
 	// Prevent concurrent indexing
@@ -213,6 +257,22 @@ func (idx *Indexer) IndexProject(ctx context.Context, folderPath string, enableW
 		return nil, fmt.Errorf("failed to create scanner: %w", err)
 	}
 
+	// Limit the scan to files changed since the ref
+	var changed map[string]bool
+	if sinceRef != "" {
+		changed, err = changedSince(ctx, absPath, sinceRef)
+		if err != nil {
+			idx.sendProgress(types.ProgressEvent{
+				Type:    "error",
+				Project: folderName,
+				Message: "Failed to list files changed since " + sinceRef,
+				Error:   err.Error(),
+			})
+			return nil, types.NewError(types.ErrCodeInvalidRequest, fmt.Sprintf("cannot list changes since %q", sinceRef), err)
+		}
+		scanner.Restrict(changed)
+	}
+
 	// Scan for files
 	files, err := scanner.Scan()
 	if err != nil {
@@ -235,6 +295,16 @@ func (idx *Indexer) IndexProject(ctx context.Context, folderPath string, enableW
 
 	// Get changed files (incremental indexing)
 	added, modified, deleted := idx.hashStore.GetChangedFiles(absPath, currentFiles)
+	if changed != nil {
+		// Files outside the change set were not scanned, so only deletions within it are real
+		kept := deleted[:0]
+		for _, absFilePath := range deleted {
+			if changed[absFilePath] {
+				kept = append(kept, absFilePath)
+			}
+		}
+		deleted = kept
+	}
 
 	idx.sendProgress(types.ProgressEvent{
 		Type:    "scan_complete",
@@ -265,50 +335,134 @@ func (idx *Indexer) IndexProject(ctx context.Context, folderPath string, enableW
 	filesToProcess := append(added, modified...)
 	totalToProcess := len(filesToProcess)
 
-	for i, absFilePath := range filesToProcess {
+	// Process files in a two-stage pipeline: parse workers read and chunk
+	// files ahead of the embed workers, so parsing (CPU) overlaps with
+	// embedding (Ollama). Embedding concurrency is capped separately by the
+	// embedder's global in-flight limit.
+	parseWorkers := idx.cfg.ParseWorkers
+	if parseWorkers < 1 {
+		parseWorkers = 1
+	}
+	embedWorkers := idx.cfg.FileWorkers
+	if embedWorkers < 1 {
+		embedWorkers = 1
+	}
+
+	var (
+		progressMu     sync.Mutex
+		started        int
+		parseWg        sync.WaitGroup
+		embedWg        sync.WaitGroup
+		byLanguage     = make(map[string]int)
+		bySymbolType   = make(map[string]int)
+		parseFallbacks int
+		failed         []string // "relPath: reason" per file that could not be indexed
+	)
+	recordFailure := func(absFilePath string, err error) {
+		relPath, _ := filepath.Rel(absPath, absFilePath)
+		idx.reportFileError(folderName, relPath, err)
+		progressMu.Lock()
+		failed = append(failed, fmt.Sprintf("%s: %v", relPath, err))
+		progressMu.Unlock()
+	}
+	jobs := make(chan string)
+	parsed := make(chan parsedFile, parseWorkers*2)
+
+	// Parse stage
+	for w := 0; w < parseWorkers; w++ {
+		parseWg.Add(1)
+		go func() {
+			defer parseWg.Done()
+			for absFilePath := range jobs {
+				file := fileInfoMap[absFilePath]
+				chunks, fallbackReason, err := idx.processFile(ctx, file)
+				if err != nil {
+					log.Printf("Warning: failed to process %s: %v", absFilePath, err)
+					recordFailure(absFilePath, err)
+					continue
+				}
+
+				relPath, _ := filepath.Rel(absPath, absFilePath)
+				if fallbackReason != "" {
+					idx.reportParseFallback(folderName, relPath, file.Language, fallbackReason)
+					progressMu.Lock()
+					parseFallbacks++
+					progressMu.Unlock()
+				}
+
+				parsed <- parsedFile{file: file, relPath: relPath, chunks: chunks}
+			}
+		}()
+	}
+
+	// Embed/store stage
+	for w := 0; w < embedWorkers; w++ {
+		embedWg.Add(1)
+		go func() {
+			defer embedWg.Done()
+			for pf := range parsed {
+				progressMu.Lock()
+				started++
+				current := started
+				progressMu.Unlock()
+				idx.sendProgress(types.ProgressEvent{
+					Type:    "embedding",
+					Project: folderName,
+					Message: fmt.Sprintf("Embedding file %d/%d", current, totalToProcess),
+					Current: current,
+					Total:   totalToProcess,
+					Percent: float64(current) / float64(totalToProcess) * 100,
+					File:    pf.relPath,
+				})
+
+				if len(pf.chunks) > 0 {
+					if err := idx.addChunksWithRetry(ctx, pf.chunks); err != nil {
+						log.Printf("Warning: failed to add chunks for %s: %v", pf.file.Path, err)
+						if ctx.Err() == nil {
+							recordFailure(pf.file.Path, err)
+						}
+						continue
+					}
+				}
+
+				// Update file hash
+				idx.hashStore.SetFileHash(absPath, pf.file.Path, pf.file.Hash)
+
+				progressMu.Lock()
+				totalChunks += len(pf.chunks)
+				filesProcessed++
+				for _, chunk := range pf.chunks {
+					byLanguage[chunk.Language]++
+					bySymbolType[string(chunk.Type)]++
+				}
+				progressMu.Unlock()
+			}
+		}()
+	}
+
+	cancelled := false
+feed:
+	for _, absFilePath := range filesToProcess {
 		select {
 		case <-ctx.Done():
-			idx.sendProgress(types.ProgressEvent{
-				Type:    "error",
-				Project: folderName,
-				Message: "Indexing cancelled",
-				Error:   "cancelled",
-			})
-			return nil, ctx.Err()
-		default:
+			cancelled = true
+			break feed
+		case jobs <- absFilePath:
 		}
+	}
+	close(jobs)
+	parseWg.Wait()
+	close(parsed)
+	embedWg.Wait()
 
-		// Send progress with relative path for display
-		relPath, _ := filepath.Rel(absPath, absFilePath)
-		percent := float64(i+1) / float64(totalToProcess) * 100
+	if cancelled {
 		idx.sendProgress(types.ProgressEvent{
-			Type:    "embedding",
+			Type:    "error",
 			Project: folderName,
-			Message: fmt.Sprintf("Embedding file %d/%d", i+1, totalToProcess),
-			Current: i + 1,
-			Total:   totalToProcess,
-			Percent: percent,
-			File:    relPath,
+			Message: "Indexing cancelled",
+			Error:   "cancelled",
 		})
-
-		file := fileInfoMap[absFilePath]
-		chunks, err := idx.processFile(ctx, file)
-		if err != nil {
-			log.Printf("Warning: failed to process %s: %v", absFilePath, err)
-			continue
-		}
-
-		if len(chunks) > 0 {
-			if err := idx.store.AddChunks(ctx, chunks); err != nil {
-				log.Printf("Warning: failed to add chunks for %s: %v", absFilePath, err)
-				continue
-			}
-			totalChunks += len(chunks)
-		}
-
-		// Update file hash
-		idx.hashStore.SetFileHash(absPath, absFilePath, file.Hash)
-		filesProcessed++
+		return nil, ctx.Err()
 	}
 
 	// Save file hashes
@@ -323,59 +477,290 @@ func (idx *Indexer) IndexProject(ctx context.Context, folderPath string, enableW
 
 	elapsed := time.Since(startTime)
 
+	status := "success"
+	if len(failed) > 0 {
+		status = "partial"
+		sort.Strings(failed)
+	}
+
+	result := &types.IndexResult{
+		Status:         status,
+		Project:        folderName,
+		FilesIndexed:   filesProcessed,
+		ChunksStored:   totalChunks,
+		TimeTakenMs:    elapsed.Milliseconds(),
+		Skipped:        len(files) - filesProcessed - len(failed),
+		Deleted:        len(deleted),
+		ParseFallbacks: parseFallbacks,
+		Failed:         failed,
+		SinceRef:       sinceRef,
+		ByLanguage:     byLanguage,
+		BySymbolType:   bySymbolType,
+	}
+
+	message := fmt.Sprintf("Indexing complete: %d files, %d chunks in %dms", filesProcessed, totalChunks, elapsed.Milliseconds())
+	if parseFallbacks > 0 {
+		message += fmt.Sprintf(" (%d files used fallback chunking)", parseFallbacks)
+	}
+	if len(failed) > 0 {
+		message += fmt.Sprintf(" (%d files failed)", len(failed))
+	}
+
 	idx.sendProgress(types.ProgressEvent{
 		Type:    "complete",
 		Project: folderName,
-		Message: fmt.Sprintf("Indexing complete: %d files, %d chunks in %dms", filesProcessed, totalChunks, elapsed.Milliseconds()),
+		Message: message,
 		Current: totalToProcess,
 		Total:   totalToProcess,
 		Percent: 100,
+		Result:  result,
 	})
 
-	return &types.IndexResult{
-		Status:       "success",
-		Project:      folderName,
-		FilesIndexed: filesProcessed,
-		ChunksStored: totalChunks,
-		TimeTakenMs:  elapsed.Milliseconds(),
-		Skipped:      len(files) - filesProcessed,
-		Deleted:      len(deleted),
-	}, nil
+	return result, nil
+}
+
+// parsedFile is a chunked file waiting for the embed stage of IndexProject
+type parsedFile struct {
+	file    types.FileInfo
+	relPath string
+	chunks  []types.Chunk
 }
 
-// processFile reads and chunks a single file
-func (idx *Indexer) processFile(ctx context.Context, file types.FileInfo) ([]types.Chunk, error) {
+// processFile reads and chunks a single file. The returned reason is non-empty
+// when tree-sitter could not chunk the file and a fallback chunker was used.
+func (idx *Indexer) processFile(ctx context.Context, file types.FileInfo) ([]types.Chunk, string, error) {
 	// Read file content
-	content, err := ReadFileContent(file.Path)
+	content, err := ReadFileContent(file.Path, idx.cfg)
 	if err != nil {
-		return nil, err
+		return nil, "", err
 	}
 
 	// Skip empty or binary files
 	if content == "" {
-		return nil, nil
+		return nil, "", nil
 	}
 
 	// Chunk the file
-	chunks := idx.chunker.ChunkFile(content, file.RelativePath, file.Language)
+	chunks, fallbackReason := idx.chunker.ChunkFile(content, file.RelativePath, file.Language)
 
 	// Assign IDs and absolute paths to chunks
 	for i := range chunks {
 		chunks[i].ID = store.GenerateChunkID(file.Path, i) // Use absolute path for ID
 		chunks[i].FilePath = file.Path                     // Store absolute path
 		chunks[i].Language = file.Language
+		chunks[i].ModTime = file.ModTime.Unix()
+		if idx.cfg.StoreFullFiles {
+			chunks[i].FileContent = content
+		}
 	}
 
-	return chunks, nil
+	return chunks, fallbackReason, nil
+}
+
+// reportParseFallback logs and emits a parse_fallback event for a file that
+// tree-sitter could not chunk
+func (idx *Indexer) reportParseFallback(project, relPath, language, reason string) {
+	log.Printf("Parse fallback for %s (%s): %s", relPath, language, reason)
+	idx.sendProgress(types.ProgressEvent{
+		Type:    "parse_fallback",
+		Project: project,
+		Message: fmt.Sprintf("Tree-sitter could not chunk %s (%s), used fallback chunking: %s", relPath, language, reason),
+		File:    relPath,
+		Error:   reason,
+	})
+}
+
+// reportFileError notifies listeners that a file could not be indexed
+func (idx *Indexer) reportFileError(project, relPath string, err error) {
+	idx.sendProgress(types.ProgressEvent{
+		Type:    "file_error",
+		Project: project,
+		Message: fmt.Sprintf("Failed to index %s", relPath),
+		File:    relPath,
+		Error:   err.Error(),
+	})
 }
 
-// ReindexProject forces a complete reindex of a folder
-func (idx *Indexer) ReindexProject(ctx context.Context, folderPath string) (*types.IndexResult, error) {
+// addChunksWithRetry stores a file's chunks, retrying once after a short pause
+// when embedding failed for a possibly transient reason (Ollama down or busy)
+func (idx *Indexer) addChunksWithRetry(ctx context.Context, chunks []types.Chunk) error {
+	err := idx.store.AddChunks(ctx, chunks)
+	if err == nil {
+		return nil
+	}
+	switch types.ErrorCodeOf(err) {
+	case types.ErrCodeOllamaDown, types.ErrCodeEmbeddingUnavailable:
+	default:
+		return err
+	}
+
+	select {
+	case <-ctx.Done():
+		return err
+	case <-time.After(busyBaseBackoff):
+	}
+	return idx.store.AddChunks(ctx, chunks)
+}
+
+// IndexContent chunks and stores content that does not exist on disk (generated
+// or fetched code, snippets) under virtualPath. A relative virtualPath is
+// resolved against the working directory. virtualPath must not exist on disk
+// or lie inside an indexed folder, whose scans would replace or drop its
+// chunks. Indexing the same path again replaces its chunks; RemoveContent or
+// ClearIndex deletes them. language is detected from the path's extension
+// when empty.
+func (idx *Indexer) IndexContent(ctx context.Context, virtualPath, content, language string) (*types.IndexResult, error) {
+	startTime := time.Now()
+
+	if strings.TrimSpace(virtualPath) == "" {
+		return nil, types.NewError(types.ErrCodeInvalidRequest, "path cannot be empty", nil)
+	}
+	if content == "" {
+		return nil, types.NewError(types.ErrCodeInvalidRequest, "content cannot be empty", nil)
+	}
+	if idx.cfg.MaxFileSize > 0 && int64(len(content)) > idx.cfg.MaxFileSize {
+		return nil, types.NewError(types.ErrCodeInvalidRequest, fmt.Sprintf("content is %d bytes, more than MCP_MAX_FILE_SIZE (%d)", len(content), idx.cfg.MaxFileSize), nil)
+	}
+
+	absPath, err := filepath.Abs(virtualPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve path: %w", err)
+	}
+	if err := idx.CheckAllowedPath(absPath); err != nil {
+		return nil, err
+	}
+	if err := idx.checkVirtualPath(absPath); err != nil {
+		return nil, err
+	}
+	if language == "" {
+		language = detectLanguage(absPath)
+	}
+	content = strings.ToValidUTF8(content, "\uFFFD")
+
+	if !idx.beginIndexing(absPath) {
+		return nil, types.NewError(types.ErrCodeBusy, fmt.Sprintf("%s is already being indexed", absPath), nil)
+	}
+	defer idx.endIndexing(absPath)
+
+	// Wait for running indexing, and keep ClearIndex from running while chunks are re-added
+	idx.indexingMu.Lock()
+	defer idx.indexingMu.Unlock()
+
+	if err := idx.store.CheckEmbeddingDimension(ctx); types.ErrorCodeOf(err) == types.ErrCodeDimensionMismatch {
+		return nil, err
+	}
+	if err := idx.store.ModelError(); err != nil {
+		return nil, err
+	}
+
+	chunks, fallbackReason := idx.chunker.ChunkFile(content, virtualPath, language)
+	if fallbackReason != "" {
+		idx.reportParseFallback(filepath.Base(filepath.Dir(absPath)), virtualPath, language, fallbackReason)
+	}
+
+	modTime := time.Now().Unix()
+	for i := range chunks {
+		chunks[i].ID = store.GenerateChunkID(absPath, i)
+		chunks[i].FilePath = absPath
+		chunks[i].Language = language
+		chunks[i].ModTime = modTime
+		if idx.cfg.StoreFullFiles {
+			chunks[i].FileContent = content
+		}
+	}
+
+	if err := idx.store.DeleteFileChunks(ctx, absPath); err != nil {
+		return nil, fmt.Errorf("failed to replace existing chunks: %w", err)
+	}
+	if len(chunks) > 0 {
+		if err := idx.addChunksWithRetry(ctx, chunks); err != nil {
+			return nil, err
+		}
+	}
+
+	result := &types.IndexResult{
+		Status:       "success",
+		Project:      absPath,
+		FilesIndexed: 1,
+		ChunksStored: len(chunks),
+		TimeTakenMs:  time.Since(startTime).Milliseconds(),
+		ByLanguage:   map[string]int{},
+		BySymbolType: map[string]int{},
+	}
+	if fallbackReason != "" {
+		result.ParseFallbacks = 1
+	}
+	for _, chunk := range chunks {
+		result.ByLanguage[chunk.Language]++
+		result.BySymbolType[string(chunk.Type)]++
+	}
+	return result, nil
+}
+
+// RemoveContent deletes the chunks IndexContent stored under virtualPath.
+// Virtual paths belong to no indexed folder, so RemoveProject and
+// PruneProjects never remove them.
+func (idx *Indexer) RemoveContent(ctx context.Context, virtualPath string) (*types.RemoveResult, error) {
+	if strings.TrimSpace(virtualPath) == "" {
+		return nil, types.NewError(types.ErrCodeInvalidRequest, "path cannot be empty", nil)
+	}
+	absPath, err := filepath.Abs(virtualPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve path: %w", err)
+	}
+	if err := idx.CheckAllowedPath(absPath); err != nil {
+		return nil, err
+	}
+	if err := idx.checkVirtualPath(absPath); err != nil {
+		return nil, err
+	}
+
+	idx.indexingMu.Lock()
+	defer idx.indexingMu.Unlock()
+
+	chunks := idx.store.CountFileChunks(ctx, absPath)
+	if chunks == 0 {
+		return nil, types.NewError(types.ErrCodeNotIndexed, fmt.Sprintf("no content is indexed under %s", absPath), nil)
+	}
+	if err := idx.store.DeleteFileChunks(ctx, absPath); err != nil {
+		return nil, fmt.Errorf("failed to delete chunks: %w", err)
+	}
+	return &types.RemoveResult{Path: absPath, FilesRemoved: 1, ChunksRemoved: chunks}, nil
+}
+
+// checkVirtualPath rejects an IndexContent path that would collide with
+// indexed files: one that exists on disk or lies inside an indexed folder
+func (idx *Indexer) checkVirtualPath(absPath string) error {
+	if _, err := os.Stat(absPath); err == nil {
+		return types.NewError(types.ErrCodeInvalidRequest, fmt.Sprintf("%s exists on disk; index its folder instead, or pick a path that does not exist", absPath), nil)
+	}
+	for _, folder := range idx.hashStore.ListIndexedFolders() {
+		rel, err := filepath.Rel(folder, absPath)
+		if err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return types.NewError(types.ErrCodeInvalidRequest, fmt.Sprintf("%s lies inside the indexed folder %s, whose next scan would remove it; pick a path outside indexed folders", absPath, folder), nil)
+		}
+	}
+	return nil
+}
+
+// ReindexProject forces a complete reindex of a folder. force allows the
+// filesystem root and home directory (see CheckBroadRoot).
+func (idx *Indexer) ReindexProject(ctx context.Context, folderPath string, force bool) (*types.IndexResult, error) {
 	absPath, err := filepath.Abs(folderPath)
 	if err != nil {
 		return nil, fmt.Errorf("failed to resolve path: %w", err)
 	}
 
+	if !force {
+		if err := CheckBroadRoot(absPath); err != nil {
+			return nil, err
+		}
+	}
+
+	if idx.IsIndexing(absPath) {
+		return nil, types.NewError(types.ErrCodeBusy, fmt.Sprintf("%s is already being indexed", absPath), nil)
+	}
+
 	// Stop existing watcher
 	idx.stopWatcher(absPath)
 
@@ -385,26 +770,97 @@ func (idx *Indexer) ReindexProject(ctx context.Context, folderPath string) (*typ
 	}
 
 	// Reindex
-	return idx.IndexProject(ctx, folderPath, true)
+	return idx.IndexProject(ctx, folderPath, true, true)
+}
+
+// IndexOutdated reports whether the index was built by an older parser/chunker
+// than this binary's ChunkerVersion. An empty index is simply stamped current.
+func (idx *Indexer) IndexOutdated() (stored int, outdated bool) {
+	stored = idx.store.IndexVersion()
+	if stored >= ChunkerVersion {
+		return stored, false
+	}
+
+	if idx.store.GetTotalChunkCount() == 0 {
+		if err := idx.store.SetIndexVersion(ChunkerVersion); err != nil {
+			log.Printf("Warning: failed to record index version: %v", err)
+		}
+		return stored, false
+	}
+
+	return stored, true
 }
 
-// RemoveProject removes all indexed files from a folder
-func (idx *Indexer) RemoveProject(ctx context.Context, folderPath string) error {
+// ReindexAll forces a full reindex of every indexed folder, then records the
+// current ChunkerVersion. Folders that no longer exist or are outside the
+// allowed roots are skipped; the version is only recorded if all others succeed.
+func (idx *Indexer) ReindexAll(ctx context.Context) error {
+	var failed int
+	for _, folder := range idx.hashStore.ListIndexedFolders() {
+		if _, err := os.Stat(folder); err != nil {
+			log.Printf("Skipping reindex of %s: %v", folder, err)
+			continue
+		}
+		if err := idx.CheckAllowedPath(folder); err != nil {
+			log.Printf("Skipping reindex: %v", err)
+			continue
+		}
+
+		if err := idx.hashStore.DeleteProjectHashes(folder); err != nil {
+			log.Printf("Warning: failed to delete file hashes: %v", err)
+		}
+
+		// Already indexed once, so a broad root was forced back then
+		result, err := idx.IndexProject(ctx, folder, idx.cfg.WatchEnabled, true)
+		if err != nil {
+			log.Printf("Reindex of %s failed: %v", folder, err)
+			failed++
+			continue
+		}
+		log.Printf("Reindexed %s: %d files, %d chunks", folder, result.FilesIndexed, result.ChunksStored)
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("failed to reindex %d folders", failed)
+	}
+
+	if err := idx.store.SetIndexVersion(ChunkerVersion); err != nil {
+		return fmt.Errorf("failed to record index version: %w", err)
+	}
+	return nil
+}
+
+// RemoveProject removes all indexed files from a folder.
+// With dryRun, it only reports what would be removed without changing the index.
+func (idx *Indexer) RemoveProject(ctx context.Context, folderPath string, dryRun bool) (*types.RemoveResult, error) {
 	absPath, err := filepath.Abs(folderPath)
 	if err != nil {
-		return fmt.Errorf("failed to resolve path: %w", err)
+		return nil, fmt.Errorf("failed to resolve path: %w", err)
 	}
 
-	// Stop watcher
-	idx.stopWatcher(absPath)
-
 	// Load file hashes to get list of indexed files
 	if err := idx.hashStore.LoadProjectHashes(absPath); err != nil {
 		log.Printf("Warning: failed to load file hashes: %v", err)
 	}
 
-	// Get all indexed files and delete their chunks
 	indexedFiles := idx.hashStore.GetAllFilePaths(absPath)
+	result := &types.RemoveResult{
+		Path:         absPath,
+		FilesRemoved: len(indexedFiles),
+		DryRun:       dryRun,
+	}
+	for _, filePath := range indexedFiles {
+		result.ChunksRemoved += idx.store.CountFileChunks(ctx, filePath)
+	}
+
+	if dryRun {
+		return result, nil
+	}
+
+	// Stop watcher
+	idx.stopWatcher(absPath)
+
+	// Delete chunks of all indexed files
 	for _, filePath := range indexedFiles {
 		if err := idx.store.DeleteFileChunks(ctx, filePath); err != nil {
 			log.Printf("Warning: failed to delete chunks for %s: %v", filePath, err)
@@ -416,21 +872,444 @@ func (idx *Indexer) RemoveProject(ctx context.Context, folderPath string) error
 		log.Printf("Warning: failed to delete file hashes: %v", err)
 	}
 
+	return result, nil
+}
+
+// PruneProjects checks that every indexed or watched folder still exists on disk.
+// Watchers of missing folders are stopped; with removeChunks their chunks and
+// file hashes are deleted as well.
+func (idx *Indexer) PruneProjects(ctx context.Context, removeChunks bool) (*types.PruneResult, error) {
+	folders := make(map[string]bool)
+	for _, folder := range idx.hashStore.ListIndexedFolders() {
+		folders[folder] = true
+	}
+	if idx.watcherMgr != nil {
+		for _, folder := range idx.watcherMgr.ListWatched() {
+			folders[folder] = true
+		}
+	}
+
+	paths := make([]string, 0, len(folders))
+	for folder := range folders {
+		paths = append(paths, folder)
+	}
+	sort.Strings(paths)
+
+	result := &types.PruneResult{
+		Checked:       len(paths),
+		Pruned:        []types.PrunedProject{},
+		ChunksRemoved: removeChunks,
+	}
+
+	for _, folder := range paths {
+		if _, err := os.Stat(folder); !os.IsNotExist(err) {
+			continue
+		}
+
+		pruned := types.PrunedProject{Path: folder}
+		if idx.watcherMgr != nil && idx.watcherMgr.IsWatching(folder) {
+			idx.stopWatcher(folder)
+			pruned.WatcherStopped = true
+		}
+
+		if removeChunks {
+			removed, err := idx.RemoveProject(ctx, folder, false)
+			if err != nil {
+				log.Printf("Warning: failed to remove missing project %s: %v", folder, err)
+			} else {
+				pruned.FilesRemoved = removed.FilesRemoved
+				pruned.ChunksRemoved = removed.ChunksRemoved
+			}
+		}
+
+		log.Printf("Pruned missing project: %s", folder)
+		result.Pruned = append(result.Pruned, pruned)
+	}
+
+	return result, nil
+}
+
+// ClearIndex stops all file watchers and removes every indexed project
+func (idx *Indexer) ClearIndex(ctx context.Context) error {
+	// Wait for any running indexing to finish
+	idx.indexingMu.Lock()
+	defer idx.indexingMu.Unlock()
+
+	if idx.watcherMgr != nil {
+		idx.watcherMgr.StopAll()
+	}
+
+	// Drop watcher changes queued before the clear so they can't re-add chunks
+	idx.opQueueMu.Lock()
+	dropped := len(idx.opQueue)
+	idx.opQueue = make(map[string]FileOperation)
+	idx.opQueueMu.Unlock()
+	if dropped > 0 {
+		log.Printf("Discarded %d queued file operations", dropped)
+	}
+
+	if err := idx.store.ClearAll(ctx); err != nil {
+		return fmt.Errorf("failed to clear index: %w", err)
+	}
+
+	// Project metadata would otherwise still list the cleared projects
+	if meta, err := store.NewMetadata(idx.cfg); err != nil {
+		log.Printf("Warning: failed to load project metadata: %v", err)
+	} else if err := meta.Clear(); err != nil {
+		log.Printf("Warning: failed to clear project metadata: %v", err)
+	}
+
 	return nil
 }
 
+// APISurface lists a page of the exported symbols of an indexed folder, with
+// paths relative to cwd. limit is capped at MCP_MAX_LIST_RESULTS.
+func (idx *Indexer) APISurface(ctx context.Context, folderPath string, limit, offset int) (*types.APISurfaceResult, error) {
+	absPath, err := filepath.Abs(folderPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve path: %w", err)
+	}
+
+	if maxResults := idx.cfg.MaxListResults; maxResults > 0 && (limit <= 0 || limit > maxResults) {
+		limit = maxResults
+	}
+
+	symbols, total, err := idx.store.ListExportedSymbols(ctx, absPath, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+
+	cwd, _ := filepath.Abs(".")
+	for i := range symbols {
+		if rel, err := filepath.Rel(cwd, symbols[i].FilePath); err == nil {
+			symbols[i].FilePath = "./" + filepath.ToSlash(rel)
+		}
+	}
+
+	return &types.APISurfaceResult{Symbols: symbols, Total: total, Offset: max(offset, 0)}, nil
+}
+
+// ComplexFunctions lists the functions and methods of a folder with the highest
+// estimated cyclomatic complexity, paths relative to cwd. limit is capped at
+// MCP_MAX_LIST_RESULTS.
+func (idx *Indexer) ComplexFunctions(ctx context.Context, folderPath string, limit int) ([]types.ComplexSymbol, error) {
+	absPath, err := filepath.Abs(folderPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve path: %w", err)
+	}
+
+	if maxResults := idx.cfg.MaxListResults; maxResults > 0 && (limit <= 0 || limit > maxResults) {
+		limit = maxResults
+	}
+
+	symbols, err := idx.store.ListComplexFunctions(ctx, absPath, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	cwd, _ := filepath.Abs(".")
+	for i := range symbols {
+		if rel, err := filepath.Rel(cwd, symbols[i].FilePath); err == nil {
+			symbols[i].FilePath = "./" + filepath.ToSlash(rel)
+		}
+	}
+
+	return symbols, nil
+}
+
+// FindImplementations lists types implementing an interface within a folder, with paths relative to cwd
+func (idx *Indexer) FindImplementations(ctx context.Context, interfaceName, folderPath string) ([]types.Implementor, error) {
+	absPath, err := filepath.Abs(folderPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve path: %w", err)
+	}
+
+	implementors, err := idx.store.FindImplementors(ctx, interfaceName, absPath)
+	if err != nil {
+		return nil, err
+	}
+
+	cwd, _ := filepath.Abs(".")
+	for i := range implementors {
+		if rel, err := filepath.Rel(cwd, implementors[i].FilePath); err == nil {
+			implementors[i].FilePath = "./" + filepath.ToSlash(rel)
+		}
+	}
+
+	return implementors, nil
+}
+
+// findTestsDepth is how many caller levels FindTests walks looking for tests
+const findTestsDepth = 5
+
+// FindTests lists the tests that call symbol directly or transitively within
+// a folder, nearest first, with paths relative to cwd
+func (idx *Indexer) FindTests(ctx context.Context, symbol, folderPath string) ([]types.TestCaller, bool, error) {
+	symbol = strings.TrimSuffix(strings.TrimSpace(symbol), "()")
+	if symbol == "" {
+		return nil, false, types.NewError(types.ErrCodeInvalidRequest, "symbol cannot be empty", nil)
+	}
+
+	absPath, err := filepath.Abs(folderPath)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to resolve path: %w", err)
+	}
+
+	callersByLevel, truncated := idx.store.FindCallersDeepSplitTests(ctx, symbol, findTestsDepth, 20, absPath)
+
+	cwd, _ := filepath.Abs(".")
+	var tests []types.TestCaller
+	for level := 1; level <= findTestsDepth; level++ {
+		for _, caller := range callersByLevel[level] {
+			if !caller.IsTest {
+				continue
+			}
+			path := caller.FilePath
+			if rel, err := filepath.Rel(cwd, path); err == nil {
+				path = "./" + filepath.ToSlash(rel)
+			}
+			tests = append(tests, types.TestCaller{
+				Name:     caller.Name,
+				FilePath: path,
+				Line:     caller.Line,
+				Language: caller.Language,
+				Depth:    level,
+			})
+		}
+	}
+
+	sort.SliceStable(tests, func(i, j int) bool {
+		if tests[i].Depth != tests[j].Depth {
+			return tests[i].Depth < tests[j].Depth
+		}
+		if tests[i].FilePath != tests[j].FilePath {
+			return tests[i].FilePath < tests[j].FilePath
+		}
+		return tests[i].Line < tests[j].Line
+	})
+
+	return tests, truncated, nil
+}
+
+// duplicateSeedLimit bounds how many chunks FindDuplicates checks per request
+const duplicateSeedLimit = 200
+
+// DefaultDuplicateSimilarity is the similarity above which FindDuplicates
+// reports two chunks as copies
+const DefaultDuplicateSimilarity = 0.97
+
+// FindDuplicates lists chunks in other files that are near-identical (at least
+// minSimilarity) to the chunks of file, or to symbol when file is empty,
+// within a folder. Paths are relative to cwd. The returned bool reports whether
+// only the first duplicateSeedLimit chunks were checked.
+func (idx *Indexer) FindDuplicates(ctx context.Context, file, symbol, folderPath string, minSimilarity float32) ([]types.DuplicatePair, bool, error) {
+	file = strings.TrimSpace(file)
+	symbol = strings.TrimSuffix(strings.TrimSpace(symbol), "()")
+	if file == "" && symbol == "" {
+		return nil, false, types.NewError(types.ErrCodeInvalidRequest, "file or symbol is required", nil)
+	}
+	if minSimilarity <= 0 || minSimilarity > 1 {
+		minSimilarity = DefaultDuplicateSimilarity
+	}
+
+	absPath, err := filepath.Abs(folderPath)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to resolve path: %w", err)
+	}
+	absFile := ""
+	if file != "" {
+		if absFile, err = filepath.Abs(file); err != nil {
+			return nil, false, fmt.Errorf("failed to resolve file: %w", err)
+		}
+	}
+
+	pairs, truncated, err := idx.store.FindDuplicates(ctx, absFile, symbol, absPath, minSimilarity, duplicateSeedLimit)
+	if err != nil {
+		return nil, false, err
+	}
+
+	cwd, _ := filepath.Abs(".")
+	relative := func(path string) string {
+		if rel, err := filepath.Rel(cwd, path); err == nil {
+			return "./" + filepath.ToSlash(rel)
+		}
+		return path
+	}
+	for i := range pairs {
+		pairs[i].Chunk.FilePath = relative(pairs[i].Chunk.FilePath)
+		pairs[i].Duplicate.FilePath = relative(pairs[i].Duplicate.FilePath)
+	}
+
+	return pairs, truncated, nil
+}
+
+// GotoDefinition finds where a called symbol is defined. symbol may be written
+// as it appears in code ("doThing()", "client.Fetch"): a qualified name is
+// tried first, then its last segment. Definitions closest to callerFile (if
+// given) come first. Paths are relative to cwd.
+func (idx *Indexer) GotoDefinition(ctx context.Context, symbol, callerFile string) ([]types.Definition, error) {
+	symbol = strings.TrimSuffix(strings.TrimSpace(symbol), "()")
+	if symbol == "" {
+		return nil, types.NewError(types.ErrCodeInvalidRequest, "symbol cannot be empty", nil)
+	}
+
+	cwd, _ := filepath.Abs(".")
+
+	var callerPath string
+	if callerFile != "" {
+		callerPath = callerFile
+		if !filepath.IsAbs(callerPath) {
+			callerPath = filepath.Join(cwd, callerPath)
+		}
+		callerPath = filepath.Clean(callerPath)
+	}
+
+	definitions, err := idx.store.FindSymbolLocation(ctx, symbol, callerPath)
+	if err != nil {
+		return nil, err
+	}
+	if len(definitions) == 0 {
+		if i := strings.LastIndex(symbol, "."); i >= 0 && i < len(symbol)-1 {
+			definitions, err = idx.store.FindSymbolLocation(ctx, symbol[i+1:], callerPath)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	for i := range definitions {
+		if rel, err := filepath.Rel(cwd, definitions[i].FilePath); err == nil {
+			definitions[i].FilePath = "./" + filepath.ToSlash(rel)
+		}
+	}
+
+	return definitions, nil
+}
+
 // Search performs semantic search across the global index
 func (idx *Indexer) Search(ctx context.Context, query string, opts types.SearchOptions) ([]types.SearchResult, error) {
 	// Get current working directory for relative path computation
-	cwd, _ := filepath.Abs(".")
+	cwd, err := searchCwd(opts)
+	if err != nil {
+		return nil, err
+	}
+	idx.touchSearchScope(cwd, opts)
 
 	return idx.store.Search(ctx, query, cwd, opts)
 }
 
+// searchCwd returns the directory search paths are relative to: opts.BasePath
+// when the client runs elsewhere than the server, else the server's cwd
+func searchCwd(opts types.SearchOptions) (string, error) {
+	if opts.BasePath == "" {
+		cwd, _ := filepath.Abs(".")
+		return cwd, nil
+	}
+
+	base, err := filepath.Abs(opts.BasePath)
+	if err != nil {
+		return "", types.NewError(types.ErrCodeInvalidRequest, "invalid base_path", err)
+	}
+	if info, err := os.Stat(base); err != nil || !info.IsDir() {
+		return "", types.NewError(types.ErrCodePathNotFound, fmt.Sprintf("base_path %s is not a directory", base), err)
+	}
+	return base, nil
+}
+
+// touchSearchScope tells the watcher manager which projects a search covers,
+// so idle watchers there restart (MCP_WATCH_IDLE_MIN)
+func (idx *Indexer) touchSearchScope(cwd string, opts types.SearchOptions) {
+	if idx.watcherMgr == nil {
+		return
+	}
+
+	scopes := append([]string{}, opts.Paths...)
+	if opts.Path != "" {
+		// A glob is scoped to the directories before its first wildcard
+		path := opts.Path
+		for strings.ContainsAny(path, "*?") {
+			path = filepath.Dir(path)
+		}
+		scopes = append(scopes, path)
+	}
+	if len(scopes) == 0 {
+		scopes = append(scopes, cwd)
+	}
+
+	for _, scope := range scopes {
+		if !filepath.IsAbs(scope) {
+			scope = filepath.Join(cwd, scope)
+		}
+		idx.watcherMgr.Touch(scope)
+	}
+}
+
+// ReloadIndex reopens the database so changes written by another process
+// (a CLI run, a second server) become visible. Refused while indexing.
+func (idx *Indexer) ReloadIndex() error {
+	if idx.IsBusy() {
+		return types.NewError(types.ErrCodeBusy, "cannot reload the index while indexing is running", nil)
+	}
+	return idx.store.Reload()
+}
+
+// FeedbackEnabled reports whether search feedback is collected (MCP_COLLECT_FEEDBACK)
+func (idx *Indexer) FeedbackEnabled() bool {
+	return idx.cfg.CollectFeedback
+}
+
+// TestsIndexed reports whether test files are indexed (MCP_INDEX_TESTS)
+func (idx *Indexer) TestsIndexed() bool {
+	return idx.cfg.IndexTests
+}
+
+// RecordFeedback records whether the result at filePath:line was useful for query.
+// filePath may be relative to the current directory.
+func (idx *Indexer) RecordFeedback(ctx context.Context, query, filePath string, line int, useful bool) (*types.FeedbackEntry, error) {
+	if !idx.cfg.CollectFeedback {
+		return nil, types.NewError(types.ErrCodeInvalidRequest, "feedback collection is disabled (set MCP_COLLECT_FEEDBACK=true)", nil)
+	}
+
+	absPath, err := filepath.Abs(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve path: %w", err)
+	}
+
+	return idx.store.RecordFeedback(ctx, query, absPath, line, useful)
+}
+
+// ExportFeedback returns all recorded search feedback
+func (idx *Indexer) ExportFeedback(ctx context.Context) ([]types.FeedbackEntry, error) {
+	return idx.store.ExportFeedback(ctx)
+}
+
+// MaxSearchLimit returns the most results a single search may request
+func (idx *Indexer) MaxSearchLimit() int {
+	return idx.cfg.MaxSearchLimit
+}
+
 // SearchWithUsage performs semantic search and includes usage information
 func (idx *Indexer) SearchWithUsage(ctx context.Context, query string, opts types.SearchOptions) (*types.SearchResponse, error) {
+	return idx.searchWithUsage(ctx, query, opts, nil)
+}
+
+// SearchWithUsageStream is SearchWithUsage that also hands each result to
+// onResult as soon as its usage information is computed. Results arrive in
+// completion order; the returned response holds the final ranking and graph.
+// onResult is never called concurrently.
+func (idx *Indexer) SearchWithUsageStream(ctx context.Context, query string, opts types.SearchOptions, onResult func(types.SearchResult)) (*types.SearchResponse, error) {
+	return idx.searchWithUsage(ctx, query, opts, onResult)
+}
+
+func (idx *Indexer) searchWithUsage(ctx context.Context, query string, opts types.SearchOptions, onResult func(types.SearchResult)) (*types.SearchResponse, error) {
 	// Get current working directory for relative path computation
-	cwd, _ := filepath.Abs(".")
+	cwd, err := searchCwd(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	opts.Limit = idx.cfg.ClampSearchLimit(opts.Limit)
+	idx.touchSearchScope(cwd, opts)
 
 	// Get base search results with filtering
 	results, err := idx.store.Search(ctx, query, cwd, opts)
@@ -438,6 +1317,9 @@ func (idx *Indexer) SearchWithUsage(ctx context.Context, query string, opts type
 		return nil, err
 	}
 
+	// Symbols removed from the index, to flag calls that still point at them
+	staleSymbols := idx.store.StaleSymbols(ctx)
+
 	// Process results in parallel for faster response
 	var wg sync.WaitGroup
 	var graphMu sync.Mutex
@@ -445,14 +1327,28 @@ func (idx *Indexer) SearchWithUsage(ctx context.Context, query string, opts type
 	graphEdges := make([]types.GraphEdge, 0)
 	seenNodes := make(map[string]bool)
 
+	// Emit each result once its enrichment finishes, one at a time
+	var emitMu sync.Mutex
+	emit := func(result *types.SearchResult) {
+		if onResult == nil {
+			return
+		}
+		emitMu.Lock()
+		defer emitMu.Unlock()
+		onResult(*result)
+	}
+
 	for i := range results {
-		if results[i].Name == "" {
+		// Callers of a merged chunk's first name say nothing about the other members
+		if results[i].Name == "" || results[i].Metadata[types.MetadataMerged] != "" {
+			emit(&results[i])
 			continue
 		}
 
 		wg.Add(1)
 		go func(result *types.SearchResult) {
 			defer wg.Done()
+			defer emit(result)
 
 			// Get metadata for this result
 			metadata, err := idx.store.GetChunkMetadata(ctx, result.Name)
@@ -460,17 +1356,20 @@ func (idx *Indexer) SearchWithUsage(ctx context.Context, query string, opts type
 				return
 			}
 
-			// Parse references
-			var references []string
+			// Parse references, and flag calls to symbols that were removed or renamed
+			var references, staleCalls []string
 			if metadata != nil {
 				if refsStr := metadata["references"]; refsStr != "" {
 					references = splitAndTrim(refsStr)
 				}
+				if callsStr := metadata["calls"]; callsStr != "" {
+					staleCalls = store.StaleCalls(splitAndTrim(callsStr), metadata["parent"], staleSymbols)
+				}
 			}
 
 			// Find callers (3 levels deep) using the chunks table directly
 			// Scope to current working directory to avoid cross-project matches
-			callersByLevel := idx.store.FindCallersDeep(ctx, result.Name, 3, 10, cwd)
+			callersByLevel, truncated := idx.store.FindCallersDeep(ctx, result.Name, 3, 10, cwd)
 
 			// Flatten callers for the result
 			allCallers := make([]types.CallerInfo, 0)
@@ -503,7 +1402,8 @@ func (idx *Indexer) SearchWithUsage(ctx context.Context, query string, opts type
 
 			if isTypeOrClass || len(allCallers) == 0 {
 				// Get type referencers (who uses this type in their code)
-				refsByLevel := idx.store.FindReferencersDeep(ctx, result.Name, 3, 10, cwd)
+				refsByLevel, refsTruncated := idx.store.FindReferencersDeep(ctx, result.Name, 3, 10, cwd)
+				truncated = truncated || refsTruncated
 				for level := 1; level <= 3; level++ {
 					if refs, ok := refsByLevel[level]; ok {
 						for _, ref := range refs {
@@ -528,14 +1428,24 @@ func (idx *Indexer) SearchWithUsage(ctx context.Context, query string, opts type
 			isExported := metadata != nil && metadata["is_exported"] == "true"
 			isTest := metadata != nil && metadata["is_test"] == "true"
 
-			// Check if this is an entry point (shouldn't be marked as unused)
-			isEntryPoint := isEntryPointFunction(result.Name, result.Language)
-
 			// For types: unused if no callers AND no referencers
 			// For functions: unused if no callers
 			totalUsage := len(allCallers) + len(allReferencers)
-			isUnused := isExported && totalUsage == 0 && !isEntryPoint && !isTest
-			notTested := isExported && !isTest && !hasTestCaller
+			isUnused := false
+			unusedReason := ""
+			if isExported && totalUsage == 0 && !isTest {
+				unusedReason = idx.unusedExemption(ctx, result.Name, result.Language, cwd)
+				// Types are used through references, which may not be extracted
+				if unusedReason == "" && isTypeOrClass && !idx.cfg.ExtractReferencesFor(result.Language) {
+					unusedReason = "references are not extracted for " + result.Language + " (MCP_EXTRACT_REFERENCES)"
+				}
+				isUnused = unusedReason == ""
+				if isUnused {
+					unusedReason = "no callers or references in indexed code"
+				}
+			}
+			// Without indexed tests there are no test callers to find
+			notTested := idx.cfg.IndexTests && isExported && !isTest && !hasTestCaller
 
 			result.Usage = &types.UsageInfo{
 				CalledBy:     allCallers,
@@ -545,6 +1455,14 @@ func (idx *Indexer) SearchWithUsage(ctx context.Context, query string, opts type
 				IsTest:       isTest,
 				IsUnused:     isUnused,
 				NotTested:    notTested,
+				Truncated:    truncated,
+				UnusedReason: unusedReason,
+				StaleCalls:   staleCalls,
+			}
+
+			// Low-relevance results keep their usage info but stay out of the graph
+			if result.Similarity < idx.cfg.GraphMinSimilarity {
+				return
 			}
 
 			// Build graph nodes and edges (thread-safe)
@@ -577,7 +1495,7 @@ func (idx *Indexer) SearchWithUsage(ctx context.Context, query string, opts type
 				graphEdges = append(graphEdges, types.GraphEdge{
 					From:  caller.Name,
 					To:    result.Name,
-					Count: 1,
+					Count: max(caller.Calls, 1),
 				})
 			}
 
@@ -611,6 +1529,7 @@ func (idx *Indexer) SearchWithUsage(ctx context.Context, query string, opts type
 			Nodes: graphNodes,
 			Edges: graphEdges,
 		},
+		Warning: idx.store.ModelDrift(),
 	}, nil
 }
 
@@ -630,6 +1549,42 @@ func splitAndTrim(s string) []string {
 	return result
 }
 
+// modelTestSample is embedded by TestModel when no sample text is given
+const modelTestSample = "func add(a, b int) int { return a + b } // adds two numbers"
+
+// TestModel embeds a sample with model, without touching the index, and
+// reports the vector dimension and latency so models can be compared before
+// reindexing with one. Only this call uses model; indexing and search keep
+// the configured one.
+func (idx *Indexer) TestModel(ctx context.Context, model, sample string) (*types.ModelTestResult, error) {
+	model = strings.TrimSpace(model)
+	if model == "" {
+		return nil, types.NewError(types.ErrCodeInvalidRequest, "model cannot be empty", nil)
+	}
+	if idx.embedder == nil {
+		return nil, types.NewError(types.ErrCodeEmbeddingUnavailable, "no embedder configured", nil)
+	}
+	if strings.TrimSpace(sample) == "" {
+		sample = modelTestSample
+	}
+
+	start := time.Now()
+	vector, err := idx.embedder.EmbedWithModel(ctx, model, sample)
+	if err != nil {
+		return nil, err
+	}
+
+	indexDim := idx.store.EmbeddingDimension()
+	return &types.ModelTestResult{
+		Model:          model,
+		Dimension:      len(vector),
+		LatencyMs:      time.Since(start).Milliseconds(),
+		IndexModel:     idx.embedder.GetModel(),
+		IndexDimension: indexDim,
+		SameDimension:  len(vector) == indexDim,
+	}, nil
+}
+
 // GetStatus returns the status of the global index
 func (idx *Indexer) GetStatus(ctx context.Context) (*types.StatusResult, error) {
 	// Get total chunk count from the global collection
@@ -644,14 +1599,34 @@ func (idx *Indexer) GetStatus(ctx context.Context) (*types.StatusResult, error)
 	// Get current working directory
 	cwd, _ := filepath.Abs(".")
 
+	embeddingError := ""
+	if err := idx.store.DimensionError(); err != nil {
+		embeddingError = err.Error()
+	} else if err := idx.store.ModelError(); err != nil {
+		embeddingError = err.Error()
+	}
+
 	return &types.StatusResult{
-		TotalChunks:   totalChunks,
-		OllamaStatus:  ollamaStatus,
-		DBPath:        idx.cfg.DBPath,
-		CurrentFolder: cwd,
+		TotalChunks:      totalChunks,
+		OllamaStatus:     ollamaStatus,
+		EmbeddingError:   embeddingError,
+		EmbeddingWarning: idx.store.ModelDrift(),
+		DBPath:           idx.cfg.DBPath,
+		CurrentFolder:    cwd,
+		ByLanguage:       idx.store.CountChunksBy("language"),
+		ByType:           idx.store.CountChunksBy("chunk_type"),
+		Watchers:         idx.WatcherStats(),
 	}, nil
 }
 
+// WatcherStats returns statistics for every active file watcher
+func (idx *Indexer) WatcherStats() []types.WatcherStats {
+	if idx.watcherMgr == nil {
+		return nil
+	}
+	return idx.watcherMgr.Stats()
+}
+
 // UpdateFile updates the index for a single file (called by watcher)
 // If indexing is in progress, the operation is queued for later
 func (idx *Indexer) UpdateFile(ctx context.Context, folderPath, filePath string) error {
@@ -682,6 +1657,12 @@ func (idx *Indexer) UpdateFile(ctx context.Context, folderPath, filePath string)
 // doUpdateFile performs the actual file update
 func (idx *Indexer) doUpdateFile(ctx context.Context, absFolderPath, absFilePath string) error {
 	relPath, _ := filepath.Rel(absFolderPath, absFilePath)
+
+	// Keep the file's old chunks rather than replacing them with vectors of another model
+	if err := idx.store.ModelError(); err != nil {
+		log.Printf("Watcher: Not re-indexing %s: %v", relPath, err)
+		return err
+	}
 	log.Printf("Watcher: Re-indexing file: %s", relPath)
 
 	// Send progress event for UI
@@ -698,7 +1679,7 @@ func (idx *Indexer) doUpdateFile(ctx context.Context, absFolderPath, absFilePath
 	}
 
 	// Read and reindex file
-	content, err := ReadFileContent(absFilePath)
+	content, err := ReadFileContent(absFilePath, idx.cfg)
 	if err != nil {
 		log.Printf("Watcher: Failed to read file %s: %v", relPath, err)
 		return err
@@ -711,14 +1692,26 @@ func (idx *Indexer) doUpdateFile(ctx context.Context, absFolderPath, absFilePath
 	// Calculate file hash
 	hash := computeFileHash(content)
 
+	var modTime int64
+	if info, err := os.Stat(absFilePath); err == nil {
+		modTime = info.ModTime().Unix()
+	}
+
 	language := detectLanguage(absFilePath)
-	chunks := idx.chunker.ChunkFile(content, relPath, language)
+	chunks, fallbackReason := idx.chunker.ChunkFile(content, relPath, language)
+	if fallbackReason != "" {
+		idx.reportParseFallback(filepath.Base(absFolderPath), relPath, language, fallbackReason)
+	}
 	log.Printf("Watcher: Created %d chunks for %s", len(chunks), relPath)
 
 	for i := range chunks {
 		chunks[i].ID = store.GenerateChunkID(absFilePath, i)
 		chunks[i].FilePath = absFilePath // Store absolute path
 		chunks[i].Language = language
+		chunks[i].ModTime = modTime
+		if idx.cfg.StoreFullFiles {
+			chunks[i].FileContent = content
+		}
 	}
 
 	if len(chunks) > 0 {
@@ -886,13 +1879,76 @@ func hasPrefix(path, prefix string) bool {
 	if path[:len(prefix)] != prefix {
 		return false
 	}
-	// Ensure it's a proper path prefix (not partial match)
-	if len(path) > len(prefix) && path[len(prefix)] != filepath.Separator {
+	// Ensure it's a proper path prefix (not partial match); a filesystem
+	// root such as "/" already ends in a separator
+	if len(path) > len(prefix) && path[len(prefix)] != filepath.Separator &&
+		!strings.HasSuffix(prefix, string(filepath.Separator)) {
 		return false
 	}
 	return true
 }
 
+// CheckAllowedPath rejects paths outside cfg.AllowedRoots (MCP_ALLOWED_ROOTS).
+// Symlinks are resolved so a link inside a root cannot point outside it.
+func (idx *Indexer) CheckAllowedPath(absPath string) error {
+	if len(idx.cfg.AllowedRoots) == 0 {
+		return nil
+	}
+
+	resolved := absPath
+	if real, err := filepath.EvalSymlinks(absPath); err == nil {
+		resolved = real
+	}
+
+	for _, root := range idx.cfg.AllowedRoots {
+		realRoot := root
+		if r, err := filepath.EvalSymlinks(root); err == nil {
+			realRoot = r
+		}
+		if hasPrefix(resolved, realRoot) {
+			return nil
+		}
+	}
+
+	return types.NewError(types.ErrCodePathNotAllowed, fmt.Sprintf("path %s is outside the allowed roots (MCP_ALLOWED_ROOTS=%s)",
+		absPath, strings.Join(idx.cfg.AllowedRoots, string(filepath.ListSeparator))), nil)
+}
+
+// CheckBroadRoot rejects the filesystem root and the user's home directory.
+// Indexing either is almost always accidental and walks huge caches, so
+// callers must pass force to do it.
+func CheckBroadRoot(absPath string) error {
+	absPath = filepath.Clean(absPath)
+
+	what := ""
+	if filepath.Dir(absPath) == absPath {
+		what = "the filesystem root"
+	} else if home, err := os.UserHomeDir(); err == nil && home != "" && absPath == filepath.Clean(home) {
+		what = "your home directory"
+	}
+	if what == "" {
+		return nil
+	}
+
+	return types.NewError(types.ErrCodePathNotAllowed, fmt.Sprintf("refusing to index %s (%s): this is usually accidental and very slow; index a project folder instead, or retry with force", absPath, what), nil)
+}
+
+// checkFolder verifies that absPath is an existing directory under the allowed roots
+func (idx *Indexer) checkFolder(absPath string) error {
+	info, err := os.Stat(absPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return types.NewError(types.ErrCodePathNotFound, fmt.Sprintf("folder %s does not exist", absPath), nil)
+		}
+		return fmt.Errorf("failed to access %s: %w", absPath, err)
+	}
+	if !info.IsDir() {
+		return types.NewError(types.ErrCodeInvalidRequest, fmt.Sprintf("%s is not a folder", absPath), nil)
+	}
+
+	return idx.CheckAllowedPath(absPath)
+}
+
 // startWatcher starts a file watcher for a project
 func (idx *Indexer) startWatcher(projectPath string) {
 	if idx.watcherMgr == nil {
@@ -907,6 +1963,14 @@ func (idx *Indexer) startWatcher(projectPath string) {
 	}
 }
 
+// RescanFolder incrementally indexes a folder whose watcher was not running
+// (called by the watcher manager after restarting it)
+func (idx *Indexer) RescanFolder(ctx context.Context, folderPath string) error {
+	// Already indexed once, so a broad root was forced back then
+	_, err := idx.IndexProject(ctx, folderPath, false, true)
+	return err
+}
+
 // stopWatcher stops the file watcher for a project
 func (idx *Indexer) stopWatcher(projectPath string) {
 	if idx.watcherMgr == nil {
@@ -937,6 +2001,35 @@ func (idx *Indexer) IsBusy() bool {
 	return idx.isBusy
 }
 
+// beginIndexing marks a folder as being indexed; false if it already is
+func (idx *Indexer) beginIndexing(absPath string) bool {
+	idx.opQueueMu.Lock()
+	defer idx.opQueueMu.Unlock()
+	if idx.pending[absPath] {
+		return false
+	}
+	idx.pending[absPath] = true
+	return true
+}
+
+// endIndexing clears the mark set by beginIndexing
+func (idx *Indexer) endIndexing(absPath string) {
+	idx.opQueueMu.Lock()
+	defer idx.opQueueMu.Unlock()
+	delete(idx.pending, absPath)
+}
+
+// IsIndexing returns true if an IndexProject call for the folder is running or waiting
+func (idx *Indexer) IsIndexing(folderPath string) bool {
+	absPath, err := filepath.Abs(folderPath)
+	if err != nil {
+		return false
+	}
+	idx.opQueueMu.Lock()
+	defer idx.opQueueMu.Unlock()
+	return idx.pending[absPath]
+}
+
 // queueOperation adds a file operation to the queue
 // Returns true if queued, false if should process immediately
 func (idx *Indexer) queueOperation(op FileOperation) bool {
@@ -1039,6 +2132,32 @@ func opTypeName(t FileOpType) string {
 	}
 }
 
+// unusedExemption returns why a symbol without callers should not be flagged as
+// unused, or "" if none of the heuristics apply
+func (idx *Indexer) unusedExemption(ctx context.Context, name, language, cwd string) string {
+	// Methods are stored as Parent.Name; heuristics apply to the bare name
+	bareName := name
+	if i := strings.LastIndex(bareName, "."); i >= 0 {
+		bareName = bareName[i+1:]
+	}
+
+	if isEntryPointFunction(bareName, language) {
+		return "entry point (main, init, test, handler or framework hook)"
+	}
+
+	for _, pattern := range idx.cfg.EntryPointPatterns {
+		if matched, _ := path.Match(pattern, bareName); matched {
+			return fmt.Sprintf("matches entry point pattern %q (MCP_ENTRY_POINTS)", pattern)
+		}
+	}
+
+	if idx.store.NameInStringLiteral(ctx, bareName, cwd) {
+		return "name appears in a string literal (possible reflection or route registration)"
+	}
+
+	return ""
+}
+
 // isEntryPointFunction checks if a function is an entry point that shouldn't be marked as unused.
 // This is language-aware to handle different conventions across languages.
 func isEntryPointFunction(name, language string) bool {
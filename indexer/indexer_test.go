@@ -0,0 +1,596 @@
+package indexer
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"mcp-semantic-search/config"
+	"mcp-semantic-search/store"
+	"mcp-semantic-search/types"
+)
+
+func TestSearchSkipsUsageAnalysisOfMergedChunks(t *testing.T) {
+	idx, _ := newTestIndexer(t, func(cfg *config.Config) { cfg.MergeSmall = true })
+	dir := writeFiles(t, map[string]string{"greeter.py": smallMethodsSource})
+	ctx := context.Background()
+
+	if _, err := idx.IndexProject(ctx, dir, false, false); err != nil {
+		t.Fatal(err)
+	}
+	resp, err := idx.SearchWithUsage(ctx, "greeter methods", types.SearchOptions{Limit: 10, BasePath: dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	found := false
+	for _, r := range resp.Results {
+		if r.Metadata[types.MetadataMerged] == "" {
+			continue
+		}
+		found = true
+		if r.Usage != nil {
+			t.Errorf("merged chunk %s has usage %+v, want none", r.Name, r.Usage)
+		}
+	}
+	if !found {
+		t.Fatalf("no merged chunk in results %+v", resp.Results)
+	}
+}
+
+func TestSearchFlagsCallersOfRenamedFunctionAsStale(t *testing.T) {
+	idx, _ := newTestIndexer(t, nil)
+	dir := writeFiles(t, map[string]string{
+		"greet.go": "package main\n\nfunc Hello() string {\n\treturn \"hello\"\n}\n",
+		"main.go":  "package main\n\nfunc main() {\n\tprintln(Hello())\n}\n",
+	})
+	ctx := context.Background()
+	if _, err := idx.IndexProject(ctx, dir, false, false); err != nil {
+		t.Fatal(err)
+	}
+
+	greet := filepath.Join(dir, "greet.go")
+	if err := os.WriteFile(greet, []byte("package main\n\nfunc Greet() string {\n\treturn \"hello\"\n}\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := idx.UpdateFile(ctx, dir, greet); err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err := idx.SearchWithUsage(ctx, "main", types.SearchOptions{Limit: 10, BasePath: dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var found bool
+	for _, r := range resp.Results {
+		switch r.Name {
+		case "main":
+			found = true
+			if r.Usage == nil || !reflect.DeepEqual(r.Usage.StaleCalls, []string{"Hello"}) {
+				t.Errorf("main usage = %+v, want stale call Hello", r.Usage)
+			}
+		case "Greet":
+			if r.Usage != nil && len(r.Usage.StaleCalls) != 0 {
+				t.Errorf("Greet stale calls = %v, want none", r.Usage.StaleCalls)
+			}
+		}
+	}
+	if !found {
+		t.Fatalf("main not in results %+v", resp.Results)
+	}
+}
+
+func TestIndexContentRejectsPathsThatCollideWithIndexedFiles(t *testing.T) {
+	idx, _ := newTestIndexer(t, nil)
+	dir := writeFiles(t, map[string]string{"main.go": "package main\n\nfunc main() {}\n"})
+	ctx := context.Background()
+	if _, err := idx.IndexProject(ctx, dir, false, false); err != nil {
+		t.Fatal(err)
+	}
+
+	src := "package gen\n\nfunc Generated() int { return 1 }\n"
+	for name, path := range map[string]string{
+		"existing file":         filepath.Join(dir, "main.go"),
+		"inside indexed folder": filepath.Join(dir, "gen", "gen.go"),
+	} {
+		if _, err := idx.IndexContent(ctx, path, src, ""); types.ErrorCodeOf(err) != types.ErrCodeInvalidRequest {
+			t.Errorf("%s: err = %v, want an invalid request", name, err)
+		}
+	}
+
+	virtual := filepath.Join(t.TempDir(), "gen.go")
+	result, err := idx.IndexContent(ctx, virtual, src, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.ChunksStored == 0 {
+		t.Error("no chunks stored for virtual content")
+	}
+
+	resp, err := idx.SearchWithUsage(ctx, "Generated", types.SearchOptions{Limit: 10, BasePath: filepath.Dir(virtual)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	found := false
+	for _, r := range resp.Results {
+		if r.Name == "Generated" && strings.Contains(r.Content, "return 1") {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("virtual snippet not found under its directory: %+v", resp.Results)
+	}
+}
+
+func TestRemoveContentDeletesVirtualChunks(t *testing.T) {
+	idx, st := newTestIndexer(t, nil)
+	dir := writeFiles(t, map[string]string{"main.go": "package main\n\nfunc main() {}\n"})
+	ctx := context.Background()
+	if _, err := idx.IndexProject(ctx, dir, false, false); err != nil {
+		t.Fatal(err)
+	}
+
+	virtual := filepath.Join(t.TempDir(), "gen.go")
+	if _, err := idx.IndexContent(ctx, virtual, "package gen\n\nfunc Generated() int { return 1 }\n", ""); err != nil {
+		t.Fatal(err)
+	}
+
+	// Removing projects leaves virtual content alone
+	if _, err := idx.RemoveProject(ctx, dir, false); err != nil {
+		t.Fatal(err)
+	}
+	if st.CountFileChunks(ctx, virtual) == 0 {
+		t.Fatal("RemoveProject deleted virtual content")
+	}
+
+	result, err := idx.RemoveContent(ctx, virtual)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.ChunksRemoved == 0 || st.CountFileChunks(ctx, virtual) != 0 {
+		t.Errorf("RemoveContent = %+v, %d chunks left", result, st.CountFileChunks(ctx, virtual))
+	}
+	if _, err := idx.RemoveContent(ctx, virtual); types.ErrorCodeOf(err) != types.ErrCodeNotIndexed {
+		t.Errorf("second RemoveContent err = %v, want not indexed", err)
+	}
+}
+
+func TestIndexContentWaitsForRunningIndexing(t *testing.T) {
+	idx, _ := newTestIndexer(t, nil)
+	ctx := context.Background()
+
+	idx.indexingMu.Lock()
+	done := make(chan error, 1)
+	go func() {
+		_, err := idx.IndexContent(ctx, filepath.Join(t.TempDir(), "gen.go"), "package gen\n\nfunc Generated() {}\n", "")
+		done <- err
+	}()
+
+	select {
+	case err := <-done:
+		idx.indexingMu.Unlock()
+		t.Fatalf("IndexContent finished (err %v) while indexing was running", err)
+	case <-time.After(100 * time.Millisecond):
+	}
+	idx.indexingMu.Unlock()
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestFindTestsIsNotCrowdedOutByNonTestCallers(t *testing.T) {
+	idx, st := newTestIndexer(t, nil)
+	ctx := context.Background()
+
+	var chunks []types.Chunk
+	for i := 0; i < 30; i++ {
+		path := fmt.Sprintf("/p/pkg/user%d.go", i)
+		chunks = append(chunks, types.Chunk{
+			ID: store.GenerateChunkID(path, 0), FilePath: path, Language: "go",
+			Type: types.ChunkTypeFunction, Name: fmt.Sprintf("User%d", i),
+			Content: "Target()", StartLine: 1, EndLine: 3, Calls: []string{"Target"},
+		})
+	}
+	chunks = append(chunks, types.Chunk{
+		ID: store.GenerateChunkID("/p/pkg/target_test.go", 0), FilePath: "/p/pkg/target_test.go", Language: "go",
+		Type: types.ChunkTypeFunction, Name: "TestTarget", IsTest: true,
+		Content: "Target()", StartLine: 1, EndLine: 3, Calls: []string{"Target"},
+	})
+	if err := st.AddChunks(ctx, chunks); err != nil {
+		t.Fatal(err)
+	}
+
+	tests, _, err := idx.FindTests(ctx, "Target", "/p")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tests) != 1 || tests[0].Name != "TestTarget" || tests[0].Depth != 1 {
+		t.Fatalf("FindTests = %+v, want TestTarget at depth 1", tests)
+	}
+}
+
+func TestIndexProjectWithFileWorkersIndexesEveryFile(t *testing.T) {
+	idx, _ := newTestIndexer(t, func(cfg *config.Config) { cfg.FileWorkers = 4 })
+	files := make(map[string]string)
+	for i := 0; i < 12; i++ {
+		files[fmt.Sprintf("f%d.go", i)] = fmt.Sprintf("package main\n\nfunc F%d() {}\n", i)
+	}
+	dir := writeFiles(t, files)
+
+	result, err := idx.IndexProject(context.Background(), dir, false, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.FilesIndexed != 12 || result.ChunksStored < 12 || len(result.Failed) != 0 {
+		t.Fatalf("result = %+v, want all 12 files indexed", result)
+	}
+}
+
+func TestGotoDefinitionPrefersDefinitionsNearTheCaller(t *testing.T) {
+	idx, _ := newTestIndexer(t, nil)
+	dir := writeFiles(t, map[string]string{
+		"a/client.go": "package a\n\nfunc Fetch(url string) error {\n\treturn nil\n}\n",
+		"b/client.go": "package b\n\nfunc Fetch(url string) error {\n\treturn nil\n}\n",
+		"b/main.go":   "package b\n\nfunc run() { client.Fetch(\"x\") }\n",
+	})
+	ctx := context.Background()
+	if _, err := idx.IndexProject(ctx, dir, false, false); err != nil {
+		t.Fatal(err)
+	}
+
+	// The qualified call has no definition of its own; its last segment does
+	defs, err := idx.GotoDefinition(ctx, "client.Fetch()", filepath.Join(dir, "b", "main.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(defs) != 2 || !strings.HasSuffix(defs[0].FilePath, "b/client.go") || defs[0].Line != 3 {
+		t.Fatalf("definitions = %+v, want b/client.go:3 first of two", defs)
+	}
+	if !strings.Contains(defs[0].Content, "func Fetch(url string) error") {
+		t.Errorf("content = %q, want the definition's code", defs[0].Content)
+	}
+
+	if _, err := idx.GotoDefinition(ctx, " () ", ""); types.ErrorCodeOf(err) != types.ErrCodeInvalidRequest {
+		t.Errorf("empty symbol: err = %v, want invalid_request", err)
+	}
+}
+
+func TestAPISurfaceIsCappedAndPaged(t *testing.T) {
+	idx, _ := newTestIndexer(t, func(cfg *config.Config) { cfg.MaxListResults = 3 })
+	src := "package lib\n\n"
+	for _, name := range []string{"A", "B", "C", "D", "E"} {
+		src += "func " + name + "() {}\n\n"
+	}
+	src += "func hidden() {}\n"
+	dir := writeFiles(t, map[string]string{"lib.go": src})
+	ctx := context.Background()
+	if _, err := idx.IndexProject(ctx, dir, false, false); err != nil {
+		t.Fatal(err)
+	}
+
+	page := func(limit, offset int) (names []string, total int) {
+		result, err := idx.APISurface(ctx, dir, limit, offset)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, s := range result.Symbols {
+			names = append(names, s.Name)
+		}
+		return names, result.Total
+	}
+	if names, total := page(0, 0); strings.Join(names, ",") != "A,B,C" || total != 5 {
+		t.Errorf("first page = %v of %d, want A,B,C of 5 exported", names, total)
+	}
+	if names, _ := page(10, 3); strings.Join(names, ",") != "D,E" {
+		t.Errorf("second page = %v, want D,E", names)
+	}
+}
+
+func TestConfigKeysAreIndexedAsNamedChunks(t *testing.T) {
+	idx, st := newTestIndexer(t, nil)
+	dir := writeFiles(t, map[string]string{
+		".env":        "# Primary database\nDATABASE_URL=postgres://localhost/app\nexport REDIS_URL=redis://localhost\n",
+		"config.yaml": "server:\n  port: 8080\n# Log settings\nlogging:\n  level: debug\n",
+	})
+	ctx := context.Background()
+	if _, err := idx.IndexProject(ctx, dir, false, false); err != nil {
+		t.Fatal(err)
+	}
+
+	for symbol, want := range map[string]string{
+		"DATABASE_URL": filepath.Join(dir, ".env") + ":1",
+		"REDIS_URL":    filepath.Join(dir, ".env") + ":3",
+		"server":       filepath.Join(dir, "config.yaml") + ":1",
+		"logging":      filepath.Join(dir, "config.yaml") + ":3",
+	} {
+		meta, err := st.GetChunkMetadata(ctx, symbol)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := meta["absolute_path"] + ":" + meta["start_line"]; got != want {
+			t.Errorf("%s: chunk at %s, want %s", symbol, got, want)
+		}
+	}
+}
+
+func TestGzippedSQLIsDecompressedAndChunkedAsSQL(t *testing.T) {
+	var gz bytes.Buffer
+	zw := gzip.NewWriter(&gz)
+	zw.Write([]byte("CREATE TABLE users (\n  id INTEGER PRIMARY KEY,\n  email TEXT NOT NULL\n);\n"))
+	zw.Close()
+	files := map[string]string{"schema.sql.gz": gz.String()}
+
+	// Off by default: .gz is not a source extension
+	idx, _ := newTestIndexer(t, nil)
+	result, err := idx.IndexProject(context.Background(), writeFiles(t, files), false, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.FilesIndexed != 0 {
+		t.Fatalf("indexed %d compressed files with MCP_INDEX_COMPRESSED off", result.FilesIndexed)
+	}
+
+	idx, st := newTestIndexer(t, func(cfg *config.Config) { cfg.IndexCompressed = true })
+	dir := writeFiles(t, files)
+	if _, err := idx.IndexProject(context.Background(), dir, false, false); err != nil {
+		t.Fatal(err)
+	}
+	if got := st.CountChunksBy("language"); got["sql"] == 0 {
+		t.Fatalf("chunks by language = %v, want sql chunks", got)
+	}
+	results, err := idx.Search(context.Background(), "users table", types.SearchOptions{BasePath: dir, Limit: 5})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) == 0 || results[0].Language != "sql" || !strings.Contains(results[0].Content, "CREATE TABLE users") {
+		t.Fatalf("results = %+v, want the decompressed sql", results)
+	}
+}
+
+func TestIndexingHomeRequiresForce(t *testing.T) {
+	home := writeFiles(t, map[string]string{"notes/main.go": "package main\n\nfunc main() {}\n"})
+	t.Setenv("HOME", home)
+	idx, _ := newTestIndexer(t, nil)
+	ctx := context.Background()
+
+	_, err := idx.IndexProject(ctx, home, false, false)
+	if types.ErrorCodeOf(err) != types.ErrCodePathNotAllowed || !strings.Contains(err.Error(), "home directory") || !strings.Contains(err.Error(), "force") {
+		t.Fatalf("err = %v, want path_not_allowed naming the home directory and force", err)
+	}
+	if _, err := idx.ReindexProject(ctx, home, false); types.ErrorCodeOf(err) != types.ErrCodePathNotAllowed {
+		t.Fatalf("reindex err = %v, want path_not_allowed", err)
+	}
+	if err := CheckBroadRoot(string(filepath.Separator)); types.ErrorCodeOf(err) != types.ErrCodePathNotAllowed {
+		t.Fatalf("CheckBroadRoot(/) = %v, want path_not_allowed", err)
+	}
+
+	result, err := idx.IndexProject(ctx, home, false, true)
+	if err != nil || result.FilesIndexed != 1 {
+		t.Fatalf("forced index: result %+v, err %v, want the home directory indexed", result, err)
+	}
+	if err := CheckBroadRoot(filepath.Join(home, "notes")); err != nil {
+		t.Fatalf("CheckBroadRoot(project under home) = %v", err)
+	}
+}
+
+func TestSearchLimitAboveDefaultCeilingHonoredWhenRaised(t *testing.T) {
+	var src strings.Builder
+	src.WriteString("package lib\n")
+	for i := 0; i < 80; i++ {
+		fmt.Fprintf(&src, "\nfunc Handler%d(n int) int {\n\treturn n + %d\n}\n", i, i)
+	}
+	files := map[string]string{"lib.go": src.String()}
+
+	for _, tt := range []struct {
+		maxLimit int
+		want     int
+	}{
+		{50, 50},
+		{100, 70},
+	} {
+		idx, _ := newTestIndexer(t, func(cfg *config.Config) { cfg.MaxSearchLimit = tt.maxLimit })
+		dir := writeFiles(t, files)
+		if _, err := idx.IndexProject(context.Background(), dir, false, false); err != nil {
+			t.Fatal(err)
+		}
+
+		resp, err := idx.SearchWithUsage(context.Background(), "handler", types.SearchOptions{BasePath: dir, Limit: 70})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(resp.Results) != tt.want {
+			t.Errorf("MaxSearchLimit %d: %d results for limit 70, want %d", tt.maxLimit, len(resp.Results), tt.want)
+		}
+	}
+}
+
+func TestComplexityFilterAndRanking(t *testing.T) {
+	idx, _ := newTestIndexer(t, nil)
+	dir := writeFiles(t, map[string]string{"calc.go": complexitySource})
+	ctx := context.Background()
+	if _, err := idx.IndexProject(ctx, dir, false, false); err != nil {
+		t.Fatal(err)
+	}
+
+	results, err := idx.Search(ctx, "compute", types.SearchOptions{BasePath: dir, Limit: 10, MinComplexity: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 || results[0].Name != "Branchy" {
+		t.Fatalf("min_complexity 3 returned %+v, want only Branchy", results)
+	}
+
+	ranked, err := idx.ComplexFunctions(ctx, dir, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, sym := range ranked {
+		got = append(got, fmt.Sprintf("%s:%d", sym.Name, sym.Complexity))
+	}
+	if want := "Branchy:8,Straight:1"; strings.Join(got, ",") != want {
+		t.Fatalf("complex functions = %v, want %s", got, want)
+	}
+}
+
+func TestDefinitionsAreRebuiltFromTheDatabaseAfterDeletion(t *testing.T) {
+	var body strings.Builder
+	body.WriteString("func Long(n int) int {\n")
+	for i := 0; i < 40; i++ {
+		fmt.Fprintf(&body, "\tn += %d\n", i)
+	}
+	body.WriteString("\treturn n\n}")
+	long := body.String()
+	short := "func Short() int {\n\treturn 1\n}"
+	src := "package lib\n\n" + long + "\n\n" + short + "\n"
+
+	for _, full := range []bool{false, true} {
+		idx, _ := newTestIndexer(t, func(cfg *config.Config) {
+			cfg.MaxChunkSize = 15
+			cfg.ChunkOverlap = 3
+			cfg.StoreFullFiles = full
+		})
+		dir := writeFiles(t, map[string]string{"lib.go": src})
+		ctx := context.Background()
+		if _, err := idx.IndexProject(ctx, dir, false, false); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Remove(filepath.Join(dir, "lib.go")); err != nil {
+			t.Fatal(err)
+		}
+
+		for name, want := range map[string]string{"Long": long, "Short": short} {
+			defs, err := idx.GotoDefinition(ctx, name, "")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(defs) != 1 || defs[0].Content != want {
+				t.Errorf("StoreFullFiles=%v: %s definitions = %+v, want the complete source", full, name, defs)
+			}
+		}
+	}
+}
+
+func TestSearchFlagsResultsOfFilesChangedSinceIndexing(t *testing.T) {
+	idx, _ := newTestIndexer(t, nil)
+	dir := writeFiles(t, map[string]string{
+		"a.go": "package main\n\nfunc A() int { return 1 }\n",
+		"b.go": "package main\n\nfunc B() int { return 2 }\n",
+	})
+	ctx := context.Background()
+	before := time.Now().Unix()
+	if _, err := idx.IndexProject(ctx, dir, false, false); err != nil {
+		t.Fatal(err)
+	}
+
+	// Edit a.go without reindexing
+	later := time.Now().Add(time.Hour)
+	if err := os.Chtimes(filepath.Join(dir, "a.go"), later, later); err != nil {
+		t.Fatal(err)
+	}
+
+	results, err := idx.Search(ctx, "return", types.SearchOptions{BasePath: dir, Limit: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	stale := make(map[string]bool)
+	for _, r := range results {
+		stale[r.Name] = r.Stale
+		if r.LastIndexed < before || r.LastIndexed > time.Now().Unix() {
+			t.Errorf("%s: last_indexed = %d, want the indexing time", r.Name, r.LastIndexed)
+		}
+	}
+	if want := map[string]bool{"A": true, "B": false}; !reflect.DeepEqual(stale, want) {
+		t.Fatalf("stale = %v, want %v", stale, want)
+	}
+}
+
+func TestIndexTestsFalseOmitsTestFiles(t *testing.T) {
+	files := map[string]string{
+		"calc.go":           "package calc\n\nfunc Add(a, b int) int { return a + b }\n",
+		"calc_test.go":      "package calc\n\nimport \"testing\"\n\nfunc TestAdd(t *testing.T) { Add(1, 2) }\n",
+		"tests/fixtures.go": "package tests\n\nfunc Fixture() int { return 1 }\n",
+	}
+	for _, indexTests := range []bool{true, false} {
+		idx, st := newTestIndexer(t, func(cfg *config.Config) { cfg.IndexTests = indexTests })
+		dir := writeFiles(t, files)
+		ctx := context.Background()
+		if _, err := idx.IndexProject(ctx, dir, false, false); err != nil {
+			t.Fatal(err)
+		}
+
+		for _, name := range []string{"calc_test.go", "tests/fixtures.go"} {
+			if indexed := st.CountFileChunks(ctx, filepath.Join(dir, name)) > 0; indexed != indexTests {
+				t.Errorf("IndexTests=%v: %s indexed = %v", indexTests, name, indexed)
+			}
+		}
+
+		// Add is untested only by accident when tests are left out
+		resp, err := idx.SearchWithUsage(ctx, "add numbers", types.SearchOptions{BasePath: dir, Limit: 10})
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, r := range resp.Results {
+			if r.Name == "Add" && (r.Usage == nil || r.Usage.NotTested) {
+				t.Errorf("IndexTests=%v: Add usage = %+v, want not_tested unset", indexTests, r.Usage)
+			}
+		}
+	}
+}
+
+func TestSearchPathsResolveAgainstBasePath(t *testing.T) {
+	idx, _ := newTestIndexer(t, nil)
+	ctx := context.Background()
+	projectA := writeFiles(t, map[string]string{"sub/x.go": "package sub\n\nfunc X() int { return 1 }\n"})
+	projectB := writeFiles(t, map[string]string{"y.go": "package main\n\nfunc Y() int { return 2 }\n"})
+	for _, dir := range []string{projectA, projectB} {
+		if _, err := idx.IndexProject(ctx, dir, false, false); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	results, err := idx.Search(ctx, "return", types.SearchOptions{BasePath: projectA, Limit: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 || results[0].FilePath != "./sub/x.go" {
+		t.Fatalf("results = %+v, want only ./sub/x.go relative to the base path", results)
+	}
+
+	_, err = idx.Search(ctx, "return", types.SearchOptions{BasePath: filepath.Join(projectA, "missing"), Limit: 10})
+	if types.ErrorCodeOf(err) != types.ErrCodePathNotFound {
+		t.Fatalf("missing base_path: err = %v, want path_not_found", err)
+	}
+}
+
+func TestClearIndexClearsProjectMetadata(t *testing.T) {
+	idx, _ := newTestIndexer(t, nil)
+	ctx := context.Background()
+
+	meta, err := store.NewMetadata(idx.cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := meta.SetProject(&types.Project{ID: "p", Path: "/p"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := idx.ClearIndex(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	meta, err = store.NewMetadata(idx.cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if projects := meta.ListProjects(); len(projects) != 0 {
+		t.Errorf("projects after ClearIndex = %+v, want none", projects)
+	}
+}
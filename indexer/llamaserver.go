@@ -0,0 +1,158 @@
+package indexer
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// llamaStartTimeout bounds how long llama-server may take to load the model
+const llamaStartTimeout = 2 * time.Minute
+
+// llamaServer is a llama.cpp llama-server child process serving one embedding
+// model on a loopback port. It backs the local embedding backend, so no
+// separate daemon has to be running.
+type llamaServer struct {
+	cmd     *exec.Cmd
+	baseURL string
+	done    chan struct{} // Closed when the process exits
+	stderr  *tailWriter
+}
+
+// NewLocalEmbedder starts llama-server (bin) for the GGUF model at modelPath
+// and returns an Embedder that talks to it through its OpenAI-compatible
+// embeddings endpoint. The model name is the file name without extension.
+func NewLocalEmbedder(bin, modelPath string, maxConcurrent int) (*Embedder, error) {
+	if modelPath == "" {
+		return nil, fmt.Errorf("local embedding backend needs a model file (MCP_LOCAL_MODEL_PATH)")
+	}
+	if maxConcurrent < 1 {
+		maxConcurrent = 1
+	}
+
+	server, err := startLlamaServer(bin, modelPath, maxConcurrent)
+	if err != nil {
+		return nil, err
+	}
+
+	model := strings.TrimSuffix(filepath.Base(modelPath), filepath.Ext(modelPath))
+	e := NewEmbedder(server.baseURL, model, maxConcurrent)
+	e.server = server
+	return e, nil
+}
+
+// startLlamaServer launches llama-server on a free loopback port with one
+// slot per concurrent request and waits until it has loaded the model
+func startLlamaServer(bin, modelPath string, slots int) (*llamaServer, error) {
+	port, err := freePort()
+	if err != nil {
+		return nil, fmt.Errorf("failed to pick a port for llama-server: %w", err)
+	}
+
+	s := &llamaServer{
+		baseURL: fmt.Sprintf("http://127.0.0.1:%d", port),
+		done:    make(chan struct{}),
+		stderr:  &tailWriter{max: 4096},
+	}
+	s.cmd = exec.Command(bin,
+		"-m", modelPath,
+		"--embedding",
+		"--host", "127.0.0.1",
+		"--port", strconv.Itoa(port),
+		"-np", strconv.Itoa(slots),
+	)
+	// stdout is the MCP transport, so the server's output must not reach it
+	s.cmd.Stderr = s.stderr
+
+	if err := s.cmd.Start(); err != nil {
+		return nil, fmt.Errorf("failed to start %s: %w", bin, err)
+	}
+	go func() {
+		_ = s.cmd.Wait()
+		close(s.done)
+	}()
+
+	if err := s.waitReady(llamaStartTimeout); err != nil {
+		s.stop()
+		return nil, err
+	}
+	return s, nil
+}
+
+// waitReady polls /health until the model is loaded, the process exits or
+// the timeout passes
+func (s *llamaServer) waitReady(timeout time.Duration) error {
+	client := &http.Client{Timeout: 2 * time.Second}
+	deadline := time.Now().Add(timeout)
+
+	for time.Now().Before(deadline) {
+		select {
+		case <-s.done:
+			return fmt.Errorf("llama-server exited during startup: %s", strings.TrimSpace(s.stderr.String()))
+		default:
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		req, _ := http.NewRequestWithContext(ctx, "GET", s.baseURL+"/health", nil)
+		resp, err := client.Do(req)
+		if err == nil {
+			resp.Body.Close()
+			if resp.StatusCode == http.StatusOK {
+				cancel()
+				return nil
+			}
+		}
+		cancel()
+		time.Sleep(250 * time.Millisecond)
+	}
+
+	return fmt.Errorf("llama-server did not load the model within %s", timeout)
+}
+
+// stop kills the server and waits for it to exit
+func (s *llamaServer) stop() {
+	if s.cmd.Process != nil {
+		_ = s.cmd.Process.Kill()
+	}
+	<-s.done
+}
+
+// freePort returns a loopback TCP port that is currently unused
+func freePort() (int, error) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return 0, err
+	}
+	defer l.Close()
+	return l.Addr().(*net.TCPAddr).Port, nil
+}
+
+// tailWriter keeps the last max bytes written, for startup error messages
+type tailWriter struct {
+	mu  sync.Mutex
+	buf []byte
+	max int
+}
+
+func (w *tailWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.buf = append(w.buf, p...)
+	if len(w.buf) > w.max {
+		w.buf = w.buf[len(w.buf)-w.max:]
+	}
+	return len(p), nil
+}
+
+func (w *tailWriter) String() string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return string(w.buf)
+}
@@ -3,6 +3,8 @@ package indexer
 import (
 	"context"
 	"strings"
+	"sync"
+	"unicode/utf8"
 
 	"mcp-semantic-search/types"
 
@@ -41,7 +43,9 @@ import (
 
 // Parser uses tree-sitter for multi-language code parsing
 type Parser struct {
-	parsers map[string]*sitter.Parser
+	parsers    map[string]*sitter.Parser
+	mu         sync.Mutex                 // tree-sitter parsers are not safe for concurrent use
+	references func(language string) bool // Whether references are extracted for a language (nil = always)
 }
 
 // NewParser creates a new tree-sitter based parser
@@ -116,16 +120,23 @@ type SymbolInfo struct {
 	EndByte    uint32
 	Content    string
 	IsExported bool
-	Calls      []string // Functions/methods this symbol calls
-	References []string // Types/variables this symbol references
-	Parent     string   // Parent symbol (e.g., class name for methods)
+	Calls      []string       // Functions/methods this symbol calls
+	CallCounts map[string]int // Call sites per callee, for callees called more than once
+	References []string       // Types/variables this symbol references
+	Parent     string         // Parent symbol (e.g., class name for methods)
+	Signature  string         // Declaration before the body, e.g. "func Foo(a int) error"
+	Complexity int            // Estimated cyclomatic complexity (functions and methods, else 0)
 }
 
 // ParseResult contains all extracted information from a file
 type ParseResult struct {
-	Symbols []SymbolInfo
-	Imports []string
-	IsTest  bool
+	Symbols  []SymbolInfo
+	Comments []SymbolInfo // Comment blocks and docstrings; Parent is the documented or enclosing symbol
+	Imports  []string     // Imported module/package paths, as written
+	IsTest   bool
+	HasError bool // Tree contains syntax errors (ERROR or MISSING nodes)
+
+	ErrorRanges [][2]int // Line ranges (1-based, inclusive) of the outermost ERROR nodes
 }
 
 // Parse parses source code and extracts symbols with their references
@@ -136,7 +147,9 @@ func (p *Parser) Parse(ctx context.Context, content []byte, language string) (*P
 		return nil, nil
 	}
 
+	p.mu.Lock()
 	tree, err := parser.ParseCtx(ctx, nil, content)
+	p.mu.Unlock()
 	if err != nil {
 		return nil, err
 	}
@@ -149,11 +162,32 @@ func (p *Parser) Parse(ctx context.Context, content []byte, language string) (*P
 
 	// Extract symbols based on language
 	rootNode := tree.RootNode()
+	result.HasError = rootNode.HasError()
 	p.extractSymbols(rootNode, content, language, result, "")
+	p.extractComments(rootNode, content, language, result)
+	if result.HasError {
+		result.ErrorRanges = errorRanges(rootNode)
+	}
 
 	return result, nil
 }
 
+// errorRanges returns the line ranges of ERROR nodes, not descending into them
+func errorRanges(node *sitter.Node) [][2]int {
+	if node.Type() == "ERROR" {
+		return [][2]int{{int(node.StartPoint().Row) + 1, int(node.EndPoint().Row) + 1}}
+	}
+	if !node.HasError() {
+		return nil
+	}
+
+	var ranges [][2]int
+	for i := 0; i < int(node.ChildCount()); i++ {
+		ranges = append(ranges, errorRanges(node.Child(i))...)
+	}
+	return ranges
+}
+
 // extractSymbols recursively extracts symbols from the AST
 func (p *Parser) extractSymbols(node *sitter.Node, content []byte, language string, result *ParseResult, parent string) {
 	if node == nil {
@@ -170,14 +204,17 @@ func (p *Parser) extractSymbols(node *sitter.Node, content []byte, language stri
 	// Extract imports
 	if p.isImportNode(nodeType, language) {
 		importText := string(content[node.StartByte():node.EndByte()])
-		result.Imports = append(result.Imports, importText)
+		result.Imports = append(result.Imports, importPaths(importText, language)...)
 	}
 
 	// Extract symbols based on node type and language
 	if symbol := p.extractSymbol(node, content, language, parent); symbol != nil {
 		// Extract calls and references from the symbol's body
-		symbol.Calls = p.extractCalls(node, content, language)
-		symbol.References = p.extractReferences(node, content, language)
+		symbol.Calls, symbol.CallCounts = p.extractCalls(node, content, language)
+		if p.references == nil || p.references(language) {
+			symbol.References = p.extractReferences(node, content, language)
+			symbol.References = addRPCReferences(symbol, language)
+		}
 		result.Symbols = append(result.Symbols, *symbol)
 
 		// For classes/structs, set parent for child methods
@@ -251,6 +288,9 @@ func (p *Parser) extractSymbol(node *sitter.Node, content []byte, language, pare
 		case "class_declaration":
 			symbolType = types.ChunkTypeClass
 			nameNode = node.ChildByFieldName("name")
+		case "interface_declaration":
+			symbolType = types.ChunkTypeClass
+			nameNode = node.ChildByFieldName("name")
 		case "arrow_function":
 			// Check if it's assigned to a variable
 			if parent := node.Parent(); parent != nil && parent.Type() == "variable_declarator" {
@@ -491,16 +531,18 @@ func (p *Parser) extractSymbol(node *sitter.Node, content []byte, language, pare
 		}
 
 	case "protobuf":
+		// Names live in message_name/service_name/rpc_name children, not a name field
 		switch nodeType {
-		case "message":
+		case "message", "service":
 			symbolType = types.ChunkTypeClass
-			nameNode = node.ChildByFieldName("name")
-		case "service":
-			symbolType = types.ChunkTypeClass
-			nameNode = node.ChildByFieldName("name")
+			if named := node.NamedChild(0); named != nil {
+				nameNode = p.findIdentifier(named)
+			}
 		case "rpc":
 			symbolType = types.ChunkTypeFunction
-			nameNode = node.ChildByFieldName("name")
+			if named := node.NamedChild(0); named != nil {
+				nameNode = p.findIdentifier(named)
+			}
 		}
 
 	case "css", "html", "svelte", "yaml", "toml", "cue":
@@ -515,6 +557,11 @@ func (p *Parser) extractSymbol(node *sitter.Node, content []byte, language, pare
 
 	name := string(content[nameNode.StartByte():nameNode.EndByte()])
 
+	// HCL blocks are named by type plus labels, e.g. resource.aws_instance.web
+	if language == "hcl" && nodeType == "block" {
+		name = hclBlockName(node, content)
+	}
+
 	// Check if exported (public)
 	isExported = p.isExported(name, node, language)
 
@@ -534,23 +581,178 @@ func (p *Parser) extractSymbol(node *sitter.Node, content []byte, language, pare
 		Content:    string(content[node.StartByte():node.EndByte()]),
 		IsExported: isExported,
 		Parent:     parent,
+		Signature:  p.extractSignature(node, content, language, symbolType),
+		Complexity: complexityOf(node, symbolType),
+	}
+}
+
+// complexityOf returns the cyclomatic complexity estimate for functions and
+// methods, and 0 for other symbols
+func complexityOf(node *sitter.Node, symbolType types.ChunkType) int {
+	if symbolType != types.ChunkTypeFunction && symbolType != types.ChunkTypeMethod {
+		return 0
+	}
+	return cyclomaticComplexity(node)
+}
+
+// maxSignatureLength caps stored signatures, e.g. for declarations with huge
+// default values or generated parameter lists
+const maxSignatureLength = 300
+
+// extractSignature returns a symbol's declaration: the source before its body,
+// with whitespace collapsed, e.g. Go "func (s *Store) Get(id string) (*User, error)",
+// Python "def load(self, path: str) -> bool", TS "async fetch(url: string): Promise<Response>".
+// Functions and methods without a body field use their first line; other
+// symbols without a body get no signature.
+func (p *Parser) extractSignature(node *sitter.Node, content []byte, language string, symbolType types.ChunkType) string {
+	var signature string
+	if body := node.ChildByFieldName("body"); body != nil && body.StartByte() > node.StartByte() {
+		signature = string(content[node.StartByte():body.StartByte()])
+	} else if symbolType == types.ChunkTypeFunction || symbolType == types.ChunkTypeMethod {
+		signature, _, _ = strings.Cut(string(content[node.StartByte():node.EndByte()]), "\n")
+	} else {
+		return ""
+	}
+
+	signature = strings.Join(strings.Fields(signature), " ")
+	// Drop the body opener left before the body node (Python's ":", a brace)
+	signature = strings.TrimSpace(strings.TrimSuffix(strings.TrimSuffix(signature, "{"), ":"))
+	if len(signature) > maxSignatureLength {
+		cut := maxSignatureLength
+		for cut > 0 && !utf8.RuneStart(signature[cut]) {
+			cut--
+		}
+		signature = signature[:cut] + "..."
+	}
+	return signature
+}
+
+// minCommentLength skips trivial comments like "// TODO" or "# noqa"
+const minCommentLength = 20
+
+// extractComments collects comment blocks (adjacent comment lines merged) and
+// Python docstrings, attaching each to the symbol it documents or sits in
+func (p *Parser) extractComments(root *sitter.Node, content []byte, language string, result *ParseResult) {
+	var groups []SymbolInfo
+	var collect func(node *sitter.Node)
+	collect = func(node *sitter.Node) {
+		if node == nil {
+			return
+		}
+		if isCommentNode(node, language) {
+			startLine := int(node.StartPoint().Row) + 1
+			endLine := int(node.EndPoint().Row) + 1
+			text := string(content[node.StartByte():node.EndByte()])
+
+			// Merge with the previous comment if it ends on the line above
+			if n := len(groups); n > 0 && groups[n-1].EndLine == startLine-1 && groups[n-1].EndByte <= node.StartByte() {
+				groups[n-1].Content += "\n" + text
+				groups[n-1].EndLine = endLine
+				groups[n-1].EndByte = node.EndByte()
+				return
+			}
+			groups = append(groups, SymbolInfo{
+				Type:      types.ChunkTypeBlock,
+				Name:      types.DocChunkName,
+				StartLine: startLine,
+				EndLine:   endLine,
+				StartByte: node.StartByte(),
+				EndByte:   node.EndByte(),
+				Content:   text,
+			})
+			return
+		}
+		for i := 0; i < int(node.ChildCount()); i++ {
+			collect(node.Child(i))
+		}
+	}
+	collect(root)
+
+	for _, g := range groups {
+		if len(strings.TrimSpace(g.Content)) < minCommentLength {
+			continue
+		}
+		g.Parent = commentOwner(g, result.Symbols)
+		result.Comments = append(result.Comments, g)
+	}
+}
+
+// isCommentNode reports whether a node is a comment or a Python docstring
+func isCommentNode(node *sitter.Node, language string) bool {
+	nodeType := node.Type()
+	if nodeType == "comment" || nodeType == "line_comment" || nodeType == "block_comment" || nodeType == "doc_comment" {
+		return true
+	}
+
+	// Python docstrings: a string expression as the first statement of a module/class/function body
+	if language == "python" && nodeType == "expression_statement" &&
+		node.NamedChildCount() == 1 && node.NamedChild(0).Type() == "string" {
+		if parent := node.Parent(); parent != nil && parent.NamedChild(0) == node {
+			return true
+		}
+	}
+	return false
+}
+
+// commentOwner returns the symbol a comment documents (starting on the next line)
+// or, failing that, the innermost symbol that contains it
+func commentOwner(comment SymbolInfo, symbols []SymbolInfo) string {
+	owner := ""
+	ownerSize := -1
+	for _, sym := range symbols {
+		if sym.StartLine == comment.EndLine+1 {
+			return sym.Name
+		}
+		if sym.StartLine <= comment.StartLine && sym.EndLine >= comment.EndLine {
+			if size := sym.EndLine - sym.StartLine; ownerSize < 0 || size < ownerSize {
+				owner = sym.Name
+				ownerSize = size
+			}
+		}
 	}
+	return owner
+}
+
+// hclBlockName joins an HCL block's type and labels with dots
+// (e.g. `resource "aws_instance" "web"` -> resource.aws_instance.web)
+func hclBlockName(node *sitter.Node, content []byte) string {
+	parts := make([]string, 0, 3)
+	for i := 0; i < int(node.ChildCount()); i++ {
+		child := node.Child(i)
+		switch child.Type() {
+		case "identifier", "string_lit":
+			label := string(content[child.StartByte():child.EndByte()])
+			parts = append(parts, strings.Trim(label, `"`))
+			continue
+		}
+		// Labels end where the block body starts
+		break
+	}
+	return strings.Join(parts, ".")
 }
 
 // extractCalls extracts function/method calls from a node
-func (p *Parser) extractCalls(node *sitter.Node, content []byte, language string) []string {
-	calls := make(map[string]bool)
+// along with the number of call sites of each callee called more than once
+func (p *Parser) extractCalls(node *sitter.Node, content []byte, language string) ([]string, map[string]int) {
+	calls := make(map[string]int)
 	p.findCalls(node, content, language, calls)
 
 	result := make([]string, 0, len(calls))
-	for call := range calls {
+	var repeated map[string]int
+	for call, count := range calls {
 		result = append(result, call)
+		if count > 1 {
+			if repeated == nil {
+				repeated = make(map[string]int)
+			}
+			repeated[call] = count
+		}
 	}
-	return result
+	return result, repeated
 }
 
 // findCalls recursively finds all function calls
-func (p *Parser) findCalls(node *sitter.Node, content []byte, language string, calls map[string]bool) {
+func (p *Parser) findCalls(node *sitter.Node, content []byte, language string, calls map[string]int) {
 	if node == nil {
 		return
 	}
@@ -641,7 +843,7 @@ func (p *Parser) findCalls(node *sitter.Node, content []byte, language string, c
 	if isCall && nameNode != nil {
 		callName := p.extractCallName(nameNode, content)
 		if callName != "" && !isKeyword(callName, language) {
-			calls[callName] = true
+			calls[callName]++
 		}
 	}
 
@@ -730,6 +932,20 @@ func (p *Parser) findReferences(node *sitter.Node, content []byte, language stri
 				}
 			}
 		}
+	case "protobuf":
+		// rpc request/response types and message field types; scalar field
+		// types are keywords with no message_or_enum_type node
+		if nodeType == "message_or_enum_type" {
+			name := string(content[node.StartByte():node.EndByte()])
+			// Qualified types (google.protobuf.Timestamp) are indexed by their last segment
+			if idx := strings.LastIndex(name, "."); idx >= 0 {
+				name = name[idx+1:]
+			}
+			if name != "" {
+				refs[name] = true
+			}
+			return
+		}
 	}
 
 	// Recurse into children
@@ -738,6 +954,46 @@ func (p *Parser) findReferences(node *sitter.Node, content []byte, language stri
 	}
 }
 
+// rpcMessageSuffixes are the conventional protobuf request/response message suffixes
+var rpcMessageSuffixes = []string{"Request", "Response", "Reply"}
+
+// addRPCReferences links protobuf RPCs with the code implementing them.
+// A proto rpc records its service name; a function or method named M that
+// references an MRequest/MResponse/MReply message records M, so FindReferencers
+// on the RPC name returns its handlers.
+func addRPCReferences(symbol *SymbolInfo, language string) []string {
+	refs := symbol.References
+
+	if language == "protobuf" {
+		if symbol.Type == types.ChunkTypeFunction && symbol.Parent != "" {
+			refs = append(refs, symbol.Parent)
+		}
+		return refs
+	}
+
+	if symbol.Type != types.ChunkTypeFunction && symbol.Type != types.ChunkTypeMethod {
+		return refs
+	}
+
+	// Method names carry their parent prefix (e.g. server.GetUser)
+	name := symbol.Name
+	if idx := strings.LastIndex(name, "."); idx >= 0 {
+		name = name[idx+1:]
+	}
+	if name == "" {
+		return refs
+	}
+
+	for _, ref := range refs {
+		for _, suffix := range rpcMessageSuffixes {
+			if ref == name+suffix {
+				return append(refs, name)
+			}
+		}
+	}
+	return refs
+}
+
 // Helper functions
 
 func (p *Parser) isImportNode(nodeType, language string) bool {
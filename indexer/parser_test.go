@@ -0,0 +1,131 @@
+package indexer
+
+import (
+	"context"
+	"reflect"
+	"slices"
+	"testing"
+)
+
+const rpcHandlerSource = `package server
+
+func (s *Server) GetUser(ctx context.Context, req *GetUserRequest) (*GetUserResponse, error) {
+	return nil, nil
+}
+`
+
+const rpcServiceSource = `syntax = "proto3";
+
+service Users {
+  rpc GetUser(GetUserRequest) returns (GetUserResponse);
+}
+`
+
+func handlerReferences(t *testing.T, p *Parser) []string {
+	t.Helper()
+	result, err := p.Parse(context.Background(), []byte(rpcHandlerSource), "go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, sym := range result.Symbols {
+		if sym.Name == "Server.GetUser" || sym.Name == "GetUser" {
+			return sym.References
+		}
+	}
+	t.Fatalf("GetUser not found in %+v", result.Symbols)
+	return nil
+}
+
+func TestParserLinksRPCHandlersToTheirRPC(t *testing.T) {
+	refs := handlerReferences(t, NewParser())
+	if !slices.Contains(refs, "GetUserRequest") || !slices.Contains(refs, "GetUser") {
+		t.Fatalf("References = %v, want the request type and the RPC name", refs)
+	}
+}
+
+func TestParserSkipsRPCReferencesWhenReferencesAreDisabled(t *testing.T) {
+	p := NewParser()
+	p.references = func(language string) bool { return false }
+	if refs := handlerReferences(t, p); len(refs) != 0 {
+		t.Fatalf("References = %v, want none with references disabled", refs)
+	}
+
+	result, err := p.Parse(context.Background(), []byte(rpcServiceSource), "protobuf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, sym := range result.Symbols {
+		if len(sym.References) != 0 {
+			t.Fatalf("%s References = %v, want none with references disabled for protobuf", sym.Name, sym.References)
+		}
+	}
+}
+
+func TestParserExtractsGoAndPythonSignatures(t *testing.T) {
+	tests := []struct {
+		language, source, name, want string
+	}{
+		{"go", "package s\n\nfunc (s *Store) Get(id string,\n\tlimit int) (*User, error) {\n\treturn nil, nil\n}\n",
+			"Store.Get", "func (s *Store) Get(id string, limit int) (*User, error)"},
+		{"python", "def load(self, path: str) -> bool:\n    return True\n",
+			"load", "def load(self, path: str) -> bool"},
+	}
+	for _, tt := range tests {
+		result, err := NewParser().Parse(context.Background(), []byte(tt.source), tt.language)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got string
+		for _, sym := range result.Symbols {
+			if sym.Name == tt.name {
+				got = sym.Signature
+			}
+		}
+		if got != tt.want {
+			t.Errorf("%s %s: signature %q, want %q", tt.language, tt.name, got, tt.want)
+		}
+	}
+}
+
+const complexitySource = `package calc
+
+type Point struct{ X, Y int }
+
+func Straight(a, b int) int {
+	sum := a + b
+	return sum * 2
+}
+
+func Branchy(items []int, limit int) int {
+	total := 0
+	for _, v := range items {
+		if v > limit && v%2 == 0 {
+			total += v
+		} else if v < 0 || v == limit {
+			continue
+		}
+		switch {
+		case v == 1:
+			total++
+		case v == 2:
+			total--
+		}
+	}
+	return total
+}
+`
+
+func TestParserEstimatesCyclomaticComplexity(t *testing.T) {
+	result, err := NewParser().Parse(context.Background(), []byte(complexitySource), "go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := make(map[string]int)
+	for _, sym := range result.Symbols {
+		got[sym.Name] = sym.Complexity
+	}
+	// Branchy: 1 + for + if + && + else-if + || + two cases
+	if want := map[string]int{"Point": 0, "Straight": 1, "Branchy": 8}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("complexity = %v, want %v", got, want)
+	}
+}
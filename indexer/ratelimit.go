@@ -0,0 +1,61 @@
+package indexer
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// rateLimiter spaces out events to at most one per interval. Waiters reserve
+// consecutive slots, so the rate holds however many goroutines call wait.
+type rateLimiter struct {
+	mu       sync.Mutex
+	interval time.Duration // Minimum spacing between events
+	next     time.Time     // Earliest start of the next unreserved slot
+}
+
+// newRateLimiter returns a limiter allowing perSec events per second, or nil
+// (no limit) if perSec is not positive
+func newRateLimiter(perSec float64) *rateLimiter {
+	if perSec <= 0 {
+		return nil
+	}
+	return &rateLimiter{interval: time.Duration(float64(time.Second) / perSec)}
+}
+
+// wait blocks until the caller's slot starts or ctx is done. A caller whose
+// ctx ends first gives its slot back if no later slot was reserved. A nil
+// limiter never blocks.
+func (l *rateLimiter) wait(ctx context.Context) error {
+	if l == nil {
+		return nil
+	}
+
+	l.mu.Lock()
+	now := time.Now()
+	slot := l.next
+	if slot.Before(now) {
+		// Idle time does not accumulate into a burst
+		slot = now
+	}
+	l.next = slot.Add(l.interval)
+	l.mu.Unlock()
+
+	delay := slot.Sub(now)
+	if delay <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		l.mu.Lock()
+		if l.next.Equal(slot.Add(l.interval)) {
+			l.next = slot
+		}
+		l.mu.Unlock()
+		return ctx.Err()
+	}
+}
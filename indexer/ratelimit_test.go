@@ -0,0 +1,49 @@
+package indexer
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestRateLimiterSpacesWaiters(t *testing.T) {
+	limiter := newRateLimiter(20) // One slot per 50ms
+	ctx := context.Background()
+
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		if err := limiter.wait(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed < 100*time.Millisecond {
+		t.Errorf("3 waits took %s, want at least 100ms", elapsed)
+	}
+}
+
+func TestRateLimiterRefundsCancelledSlot(t *testing.T) {
+	limiter := newRateLimiter(1)
+	if err := limiter.wait(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	reserved := limiter.next
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := limiter.wait(ctx); err == nil {
+		t.Fatal("wait returned before its slot despite the cancelled context")
+	}
+	if !limiter.next.Equal(reserved) {
+		t.Errorf("next = %s, want the cancelled slot %s given back", limiter.next, reserved)
+	}
+}
+
+func TestNilRateLimiterNeverBlocks(t *testing.T) {
+	if newRateLimiter(0) != nil {
+		t.Fatal("newRateLimiter(0) should disable limiting")
+	}
+	var limiter *rateLimiter
+	if err := limiter.wait(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -1,12 +1,17 @@
 package indexer
 
 import (
+	"bytes"
+	"compress/gzip"
 	"crypto/sha256"
 	"encoding/hex"
+	"fmt"
 	"io"
+	"log"
 	"os"
 	"path/filepath"
 	"strings"
+	"unicode/utf8"
 
 	"mcp-semantic-search/config"
 	"mcp-semantic-search/types"
@@ -19,6 +24,7 @@ type Scanner struct {
 	cfg      *config.Config
 	ignorers map[string]*ignore.GitIgnore // Map of directory path -> gitignore
 	rootPath string
+	only     map[string]bool // If set, only these absolute paths are hashed and returned
 }
 
 // NewScanner creates a new Scanner for a project directory
@@ -40,6 +46,11 @@ func NewScanner(cfg *config.Config, rootPath string) (*Scanner, error) {
 	return scanner, nil
 }
 
+// Restrict limits Scan to the given absolute file paths; exclusion rules still apply
+func (s *Scanner) Restrict(paths map[string]bool) {
+	s.only = paths
+}
+
 // loadGitignore loads .gitignore from a directory if it exists
 func (s *Scanner) loadGitignore(dirPath string) {
 	gitignorePath := filepath.Join(dirPath, ".gitignore")
@@ -50,12 +61,13 @@ func (s *Scanner) loadGitignore(dirPath string) {
 	}
 }
 
-// isIgnoredByGitignore checks if a path is ignored by any applicable .gitignore
-func (s *Scanner) isIgnoredByGitignore(absPath string, isDir bool) bool {
+// gitignoreRule returns the first .gitignore rule that ignores a path, as
+// "<.gitignore path relative to the root>:<line>: <rule>", or "" if none does
+func (s *Scanner) gitignoreRule(absPath string, isDir bool) string {
 	// Get relative path from root
 	relPath, err := filepath.Rel(s.rootPath, absPath)
 	if err != nil {
-		return false
+		return ""
 	}
 
 	// For directories, append "/" for proper gitignore matching
@@ -70,8 +82,8 @@ func (s *Scanner) isIgnoredByGitignore(absPath string, isDir bool) bool {
 
 	// Check root gitignore first
 	if ignorer, ok := s.ignorers[s.rootPath]; ok {
-		if ignorer.MatchesPath(matchPath) {
-			return true
+		if matched, pattern := ignorer.MatchesPathHow(matchPath); matched {
+			return s.describeRule(s.rootPath, pattern)
 		}
 	}
 
@@ -88,13 +100,25 @@ func (s *Scanner) isIgnoredByGitignore(absPath string, isDir bool) bool {
 			if isDir {
 				subMatchPath += "/"
 			}
-			if ignorer.MatchesPath(subMatchPath) {
-				return true
+			if matched, pattern := ignorer.MatchesPathHow(subMatchPath); matched {
+				return s.describeRule(currentDir, pattern)
 			}
 		}
 	}
 
-	return false
+	return ""
+}
+
+// describeRule formats a matched rule of the .gitignore in dir
+func (s *Scanner) describeRule(dir string, pattern *ignore.IgnorePattern) string {
+	file := ".gitignore"
+	if rel, err := filepath.Rel(s.rootPath, dir); err == nil && rel != "." {
+		file = filepath.ToSlash(rel) + "/.gitignore"
+	}
+	if pattern == nil {
+		return file
+	}
+	return fmt.Sprintf("%s:%d: %s", file, pattern.LineNo, strings.TrimSpace(pattern.Line))
 }
 
 // Scan walks the directory tree and returns all indexable files
@@ -126,6 +150,9 @@ func (s *Scanner) Scan() ([]types.FileInfo, error) {
 		}
 
 		// Check if file should be indexed
+		if s.only != nil && !s.only[path] {
+			return nil
+		}
 		if !s.shouldIncludeFile(info, path) {
 			return nil
 		}
@@ -157,49 +184,171 @@ func (s *Scanner) Scan() ([]types.FileInfo, error) {
 // shouldExcludeDir checks if a directory should be excluded
 // absPath is the absolute path to the directory
 func (s *Scanner) shouldExcludeDir(name, absPath string) bool {
+	return s.dirExcludeReason(name, absPath) != ""
+}
+
+// dirExcludeReason explains why a directory is excluded, or returns "" if it is scanned
+func (s *Scanner) dirExcludeReason(name, absPath string) string {
+	return failedCheck(s.dirChecks(name, absPath, false))
+}
+
+// dirChecks evaluates the rules deciding whether a directory is scanned, in
+// order. Unless all is set it stops at the first failed check.
+func (s *Scanner) dirChecks(name, absPath string, all bool) []types.IndexCheck {
+	var checks []types.IndexCheck
+	check := func(name string, failed bool, detail string) bool {
+		checks = append(checks, indexCheck(name, failed, detail))
+		return failed && !all
+	}
+
 	// Always exclude configured directories
-	if s.cfg.IsExcludedDir(name) {
-		return true
+	if check("excluded_dir", s.cfg.IsExcludedDir(name), fmt.Sprintf("directory %s is always excluded", name)) {
+		return checks
+	}
+	if check("excluded_home_dir", s.cfg.IsExcludedHomeDir(absPath), fmt.Sprintf("directory ~/%s is always excluded", name)) {
+		return checks
+	}
+
+	// Dotfile overrides take precedence over .gitignore
+	if check("hidden", s.cfg.SkipDotfile(name), fmt.Sprintf("hidden directory %s is skipped (MCP_SKIP_DOTFILES)", name)) {
+		return checks
+	}
+	if s.cfg.ForceDotfilePath(s.rootPath, absPath) {
+		return checks
 	}
 
 	// Check all applicable .gitignore files
-	if s.isIgnoredByGitignore(absPath, true) {
-		return true
+	rule := s.gitignoreRule(absPath, true)
+	check("gitignore", rule != "", fmt.Sprintf("directory %s is ignored by .gitignore (%s)", name, rule))
+	return checks
+}
+
+// indexCheck returns a check result, with detail only if it failed
+func indexCheck(name string, failed bool, detail string) types.IndexCheck {
+	if !failed {
+		return types.IndexCheck{Check: name, Passed: true}
 	}
+	return types.IndexCheck{Check: name, Detail: detail}
+}
 
-	return false
+// failedCheck returns the detail of the first failed check, or "" if all passed
+func failedCheck(checks []types.IndexCheck) string {
+	for _, c := range checks {
+		if !c.Passed {
+			return c.Detail
+		}
+	}
+	return ""
 }
 
 // shouldIncludeFile checks if a file should be indexed
 // absPath is the absolute path to the file
 func (s *Scanner) shouldIncludeFile(info os.FileInfo, absPath string) bool {
+	return s.fileExcludeReason(info, absPath) == ""
+}
+
+// fileExcludeReason explains why a file is not indexed, or returns "" if it is
+func (s *Scanner) fileExcludeReason(info os.FileInfo, absPath string) string {
+	return failedCheck(s.fileChecks(info, absPath, false))
+}
+
+// fileChecks evaluates the rules deciding whether a file is scanned, in
+// order. Unless all is set it stops at the first failed check. Binary
+// content is checked when the file is read.
+func (s *Scanner) fileChecks(info os.FileInfo, absPath string, all bool) []types.IndexCheck {
+	var checks []types.IndexCheck
+	check := func(name string, failed bool, detail string) bool {
+		checks = append(checks, indexCheck(name, failed, detail))
+		return failed && !all
+	}
+
 	// Check file size
-	if info.Size() > s.cfg.MaxFileSize {
-		return false
+	if check("max_file_size", s.cfg.ExceedsMaxFileSize(info.Size()),
+		fmt.Sprintf("larger than MCP_MAX_FILE_SIZE (%d > %d bytes)", info.Size(), s.cfg.MaxFileSize)) {
+		return checks
 	}
 
 	// Check file size is not zero
-	if info.Size() == 0 {
-		return false
+	if check("empty", info.Size() == 0, "empty file") {
+		return checks
 	}
 
 	// Check extension
-	ext := strings.ToLower(filepath.Ext(info.Name()))
-	if s.cfg.IsExcludedExt(ext) {
-		return false
+	ext := s.cfg.FilterExt(info.Name())
+	if check("excluded_ext", s.cfg.IsExcludedExt(ext), fmt.Sprintf("extension %s is excluded", ext)) {
+		return checks
+	}
+	if check("included_ext", !s.cfg.ShouldIncludeExt(ext), fmt.Sprintf("extension %s is not in the included extensions", ext)) {
+		return checks
+	}
+
+	// Test files by path; content-detected tests are dropped by the chunker
+	if !s.cfg.IndexTests {
+		rel, err := filepath.Rel(s.rootPath, absPath)
+		if check("test_file", err == nil && isTestFilePath(string(filepath.Separator)+rel), "test file (MCP_INDEX_TESTS=false)") {
+			return checks
+		}
 	}
 
-	if !s.cfg.ShouldIncludeExt(ext) {
-		return false
+	// Dotfile overrides take precedence over .gitignore
+	if check("hidden", s.cfg.SkipDotfile(info.Name()), "hidden file is skipped (MCP_SKIP_DOTFILES)") {
+		return checks
 	}
 
 	// Check all applicable .gitignore files
-	if s.isIgnoredByGitignore(absPath, false) {
-		return false
+	if !s.cfg.ForceDotfilePath(s.rootPath, absPath) {
+		rule := s.gitignoreRule(absPath, false)
+		check("gitignore", rule != "", fmt.Sprintf("ignored by .gitignore (%s)", rule))
 	}
+	return checks
+}
 
-	// Check if it's a binary file (will be checked again when reading)
-	return true
+// ExcludeReason explains why Scan would skip a file under the root: an
+// excluded or ignored parent directory, or the file's own size, extension
+// or ignore rules. Returns "" if the file would be scanned.
+func (s *Scanner) ExcludeReason(absPath string, info os.FileInfo) string {
+	rel, err := filepath.Rel(s.rootPath, absPath)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "outside the indexed folder"
+	}
+
+	// Walk the parent directories the way Scan does, loading their .gitignore files
+	dir := s.rootPath
+	parts := strings.Split(filepath.ToSlash(rel), "/")
+	for _, part := range parts[:len(parts)-1] {
+		dir = filepath.Join(dir, part)
+		if reason := s.dirExcludeReason(part, dir); reason != "" {
+			return reason
+		}
+		s.loadGitignore(dir)
+	}
+
+	return s.fileExcludeReason(info, absPath)
+}
+
+// Explain evaluates every rule deciding whether Scan picks up a file under
+// the root, unlike ExcludeReason which stops at the first one that fails.
+// The parent directories are reported as one "directory" check, since Scan
+// does not look inside an excluded directory.
+func (s *Scanner) Explain(absPath string, info os.FileInfo) []types.IndexCheck {
+	rel, err := filepath.Rel(s.rootPath, absPath)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return []types.IndexCheck{{Check: "inside_folder", Detail: "outside the indexed folder"}}
+	}
+
+	dirCheck := types.IndexCheck{Check: "directory", Passed: true}
+	dir := s.rootPath
+	parts := strings.Split(filepath.ToSlash(rel), "/")
+	for _, part := range parts[:len(parts)-1] {
+		dir = filepath.Join(dir, part)
+		if reason := s.dirExcludeReason(part, dir); reason != "" {
+			dirCheck = types.IndexCheck{Check: "directory", Detail: reason}
+			break
+		}
+		s.loadGitignore(dir)
+	}
+
+	return append([]types.IndexCheck{dirCheck}, s.fileChecks(info, absPath, true)...)
 }
 
 // hashFile calculates SHA256 hash of a file's content
@@ -244,34 +393,200 @@ func IsBinaryFile(path string) (bool, error) {
 	return false, nil
 }
 
-// ReadFileContent reads and returns file content, checking for binary
-func ReadFileContent(path string) (string, error) {
+// ReadFileContent reads and returns file content, checking for binary.
+// If cfg.MaxReadBytes > 0, at most that many bytes are read and the content is
+// truncated at the last complete line so that only the head of large files is indexed.
+// Invalid UTF-8 is sanitized or the file skipped according to cfg.InvalidUTF8.
+// With cfg.IndexCompressed, .gz files are decompressed first.
+func ReadFileContent(path string, cfg *config.Config) (string, error) {
+	var content []byte
+	var err error
+	if cfg.IndexCompressed && strings.EqualFold(filepath.Ext(path), ".gz") {
+		content, err = readGzipBytes(path, cfg.MaxFileSize, cfg.MaxReadBytes)
+	} else {
+		content, err = readFileBytes(path, cfg.MaxReadBytes)
+	}
+	if err != nil || content == nil {
+		return "", err
+	}
+
+	if reason := nonTextReason(content, cfg.MaxLineLength); reason != "" {
+		log.Printf("Skipping %s: %s (MCP_MAX_LINE_LENGTH)", path, reason)
+		return "", nil
+	}
+
+	if !utf8.Valid(content) {
+		if cfg.InvalidUTF8 == "skip" {
+			log.Printf("Skipping %s: invalid UTF-8 (MCP_INVALID_UTF8=skip)", path)
+			return "", nil
+		}
+		log.Printf("Sanitized invalid UTF-8 in %s", path)
+		return strings.ToValidUTF8(string(content), "\uFFFD"), nil
+	}
+
+	return string(content), nil
+}
+
+const (
+	// minWhitespaceRatio is the share of whitespace below which text is taken
+	// for encoded or minified data; code is usually 15-30% whitespace
+	minWhitespaceRatio = 0.03
+	// minWhitespaceCheckBytes skips the whitespace check for files with
+	// fewer ASCII bytes than this
+	minWhitespaceCheckBytes = 1024
+)
+
+// nonTextReason explains why content looks like minified or encoded data
+// rather than source code: a line longer than maxLineLength bytes, or almost
+// no whitespace. Returns "" for normal text or when maxLineLength is 0.
+func nonTextReason(content []byte, maxLineLength int) string {
+	if maxLineLength <= 0 {
+		return ""
+	}
+
+	// The whitespace ratio is taken over ASCII bytes only, so prose in
+	// scripts without spaces between words (CJK) is not mistaken for data
+	lineStart, whitespace, ascii := 0, 0, 0
+	for i, b := range content {
+		if b < utf8.RuneSelf {
+			ascii++
+		}
+		switch b {
+		case '\n':
+			if i-lineStart > maxLineLength {
+				return fmt.Sprintf("line %d bytes long", i-lineStart)
+			}
+			lineStart = i + 1
+			whitespace++
+		case ' ', '\t', '\r':
+			whitespace++
+		}
+	}
+	if len(content)-lineStart > maxLineLength {
+		return fmt.Sprintf("line %d bytes long", len(content)-lineStart)
+	}
+
+	if ascii >= minWhitespaceCheckBytes && float64(whitespace) < float64(ascii)*minWhitespaceRatio {
+		return fmt.Sprintf("%.1f%% whitespace", float64(whitespace)*100/float64(ascii))
+	}
+	return ""
+}
+
+// readFileBytes reads a non-binary file, limited to maxBytes if > 0.
+// Returns nil content for binary files.
+func readFileBytes(path string, maxBytes int64) ([]byte, error) {
 	// Check if binary first
 	isBinary, err := IsBinaryFile(path)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 	if isBinary {
-		return "", nil // Return empty for binary files
+		return nil, nil // Return empty for binary files
 	}
 
-	content, err := os.ReadFile(path)
+	if maxBytes <= 0 {
+		return os.ReadFile(path)
+	}
+
+	f, err := os.Open(path)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
+	defer f.Close()
 
-	return string(content), nil
+	// Read one extra byte to detect whether the file exceeds the limit
+	content, err := io.ReadAll(io.LimitReader(f, maxBytes+1))
+	if err != nil {
+		return nil, err
+	}
+
+	return truncateContent(path, content, maxBytes), nil
+}
+
+// readGzipBytes decompresses a .gz file. Files that decompress to more than
+// maxSize bytes or to binary data return nil content; the rest is truncated
+// to maxBytes if > 0.
+func readGzipBytes(path string, maxSize, maxBytes int64) ([]byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	zr, err := gzip.NewReader(f)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decompress: %w", err)
+	}
+	defer zr.Close()
+
+	// Read one extra byte to detect whether the output exceeds the limit
+	content, err := io.ReadAll(io.LimitReader(zr, maxSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decompress: %w", err)
+	}
+	if int64(len(content)) > maxSize {
+		log.Printf("Skipping %s: decompresses to more than %d bytes (MCP_MAX_FILE_SIZE)", path, maxSize)
+		return nil, nil
+	}
+
+	// Same null-byte check as IsBinaryFile, on the decompressed data
+	if bytes.IndexByte(content[:min(len(content), 512)], 0) >= 0 {
+		return nil, nil
+	}
+
+	if maxBytes <= 0 {
+		return content, nil
+	}
+	return truncateContent(path, content, maxBytes), nil
+}
+
+// truncateContent cuts content to at most maxBytes, at a line boundary, or
+// at a character boundary if the head has no complete line
+func truncateContent(path string, content []byte, maxBytes int64) []byte {
+	if int64(len(content)) <= maxBytes {
+		return content
+	}
+
+	content = content[:maxBytes]
+	// Avoid cutting a line (or a multi-byte character) in half
+	if idx := bytes.LastIndexByte(content, '\n'); idx >= 0 {
+		content = content[:idx+1]
+	} else {
+		content = content[:runeBoundary(content)]
+	}
+	log.Printf("Truncated %s to %d bytes (MCP_MAX_READ_BYTES)", path, len(content))
+	return content
+}
+
+// runeBoundary returns the length of content without a trailing partial
+// multi-byte character
+func runeBoundary(content []byte) int {
+	for i := len(content) - 1; i >= 0 && i >= len(content)-utf8.UTFMax; i-- {
+		if utf8.RuneStart(content[i]) {
+			if !utf8.FullRune(content[i:]) {
+				return i
+			}
+			break
+		}
+	}
+	return len(content)
 }
 
 // detectLanguage detects programming language from file extension
 func detectLanguage(path string) string {
 	ext := strings.ToLower(filepath.Ext(path))
 
+	// Compressed sources are detected by the extension under .gz (schema.sql.gz)
+	if ext == ".gz" {
+		return detectLanguage(strings.TrimSuffix(path, filepath.Ext(path)))
+	}
+
 	languageMap := map[string]string{
 		// Go
 		".go": "go",
 		// Python
 		".py": "python", ".pyw": "python", ".pyx": "python",
+		".bzl": "python", // Starlark (Bazel)
 		// JavaScript/TypeScript
 		".js": "javascript", ".jsx": "javascript",
 		".ts": "typescript", ".tsx": "typescript",
@@ -346,6 +661,9 @@ func detectLanguage(path string) string {
 		"Jenkinsfile": "groovy",
 		"BUILD":      "python", // Bazel
 		"WORKSPACE":  "python", // Bazel
+		"BUILD.bazel":     "python",
+		"WORKSPACE.bazel": "python",
+		"MODULE.bazel":    "python",
 		".bashrc":    "bash",
 		".zshrc":     "bash",
 		".gitignore": "text",
@@ -367,9 +685,78 @@ func detectLanguage(path string) string {
 		return lang
 	}
 
+	// Variants of special files (Makefile.local, Dockerfile.dev)
+	if name, _, found := strings.Cut(basename, "."); found {
+		if lang, ok := filenameMap[name]; ok {
+			return lang
+		}
+	}
+
+	// Scripts without an extension, by their shebang line; files with an
+	// unknown extension are not opened
+	if ext == "" {
+		if lang := sniffShebang(path); lang != "" {
+			return lang
+		}
+	}
+
 	return "text"
 }
 
+// shebangInterpreters maps script interpreters (without version suffix) to languages
+var shebangInterpreters = map[string]string{
+	"sh": "bash", "bash": "bash", "zsh": "bash", "dash": "bash", "ksh": "bash",
+	"python": "python", "pypy": "python",
+	"node": "javascript", "nodejs": "javascript", "deno": "javascript", "bun": "javascript",
+	"ts-node": "typescript", "tsx": "typescript",
+	"ruby": "ruby",
+	"perl": "perl",
+	"php":  "php",
+	"lua":  "lua", "luajit": "lua",
+	"rscript": "r",
+	"elixir":  "elixir",
+	"groovy":  "groovy",
+}
+
+// sniffShebang detects a script's language from its "#!" line, e.g.
+// "#!/bin/bash" or "#!/usr/bin/env python3". Returns "" if the file has no
+// shebang, an unknown interpreter, or cannot be read.
+func sniffShebang(path string) string {
+	f, err := os.Open(path)
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+
+	buf := make([]byte, 256)
+	n, _ := io.ReadFull(f, buf)
+	line, _, _ := strings.Cut(string(buf[:n]), "\n")
+	line, ok := strings.CutPrefix(line, "#!")
+	if !ok {
+		return ""
+	}
+
+	fields := strings.Fields(line)
+	if len(fields) == 0 {
+		return ""
+	}
+	interpreter := filepath.Base(fields[0])
+	if interpreter == "env" {
+		// Skip env's options (-S, -i, NAME=value) to the command
+		interpreter = ""
+		for _, field := range fields[1:] {
+			if !strings.HasPrefix(field, "-") && !strings.Contains(field, "=") {
+				interpreter = filepath.Base(field)
+				break
+			}
+		}
+	}
+
+	// python3, python3.12, ruby2.7
+	interpreter = strings.TrimRight(strings.ToLower(interpreter), "0123456789.")
+	return shebangInterpreters[interpreter]
+}
+
 // FindGitRoot finds the nearest .git directory
 func FindGitRoot(path string) (string, bool) {
 	absPath, err := filepath.Abs(path)
@@ -0,0 +1,185 @@
+package indexer
+
+import (
+	"bytes"
+	"compress/gzip"
+	"path/filepath"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"mcp-semantic-search/config"
+)
+
+func TestReadFileContentTruncatesAtLastCompleteLine(t *testing.T) {
+	dir := writeFiles(t, map[string]string{"a.go": "line one\nline two\nline three\n"})
+	cfg := config.DefaultConfig()
+	cfg.MaxReadBytes = 15
+
+	content, err := ReadFileContent(filepath.Join(dir, "a.go"), cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if content != "line one\n" {
+		t.Fatalf("content = %q, want the first complete line", content)
+	}
+}
+
+func TestReadFileContentTruncatesSingleLineAtRuneBoundary(t *testing.T) {
+	dir := writeFiles(t, map[string]string{"a.txt": strings.Repeat("é", 10)})
+	cfg := config.DefaultConfig()
+	cfg.MaxReadBytes = 7 // three 2-byte runes and half of the fourth
+
+	content, err := ReadFileContent(filepath.Join(dir, "a.txt"), cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if content != "ééé" || !utf8.ValidString(content) {
+		t.Fatalf("content = %q, want three whole characters", content)
+	}
+}
+
+func TestReadFileContentBoundsDecompressedSize(t *testing.T) {
+	var gz bytes.Buffer
+	zw := gzip.NewWriter(&gz)
+	zw.Write([]byte(strings.Repeat("SELECT 1;\n", 100)))
+	zw.Close()
+	path := filepath.Join(writeFiles(t, map[string]string{"big.sql.gz": gz.String()}), "big.sql.gz")
+
+	cfg := config.DefaultConfig()
+	cfg.IndexCompressed = true
+	content, err := ReadFileContent(path, cfg)
+	if err != nil || len(content) != 1000 {
+		t.Fatalf("content length %d, err %v, want the 1000 decompressed bytes", len(content), err)
+	}
+
+	// The limit applies to the decompressed size, not the file size
+	cfg.MaxFileSize = 999
+	if content, err := ReadFileContent(path, cfg); err != nil || content != "" {
+		t.Fatalf("content length %d, err %v, want the file skipped", len(content), err)
+	}
+}
+
+func TestReadFileContentSkipsMinifiedAndEncodedFiles(t *testing.T) {
+	minified := "!function(e){" + strings.Repeat("var a=e.b||{};a.c=function(d){return d+1};", 300) + "}(window);\n"
+	encoded := strings.Repeat(strings.Repeat("QUJDREVGR0hJSktMTU5PUFFSU1RVVldYWVo", 2)+"\n", 40)
+	code := strings.Repeat("function add(a, b) {\n  return a + b;\n}\n", 60)
+	dir := writeFiles(t, map[string]string{"bundle.min.js": minified, "data.b64": encoded, "app.js": code})
+
+	for _, tt := range []struct {
+		file          string
+		maxLineLength int
+		kept          bool
+	}{
+		{"bundle.min.js", 10000, false},
+		{"data.b64", 10000, false},
+		{"app.js", 10000, true},
+		{"bundle.min.js", 0, true},
+	} {
+		cfg := config.DefaultConfig()
+		cfg.MaxLineLength = tt.maxLineLength
+		content, err := ReadFileContent(filepath.Join(dir, tt.file), cfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if kept := content != ""; kept != tt.kept {
+			t.Errorf("%s with MaxLineLength %d: kept = %v, want %v", tt.file, tt.maxLineLength, kept, tt.kept)
+		}
+	}
+}
+
+func TestScanIncludesFilesOverMaxFileSizeWhenReadIsBounded(t *testing.T) {
+	dir := writeFiles(t, map[string]string{"big.go": "package main\n\n// " + strings.Repeat("x", 300) + "\n"})
+	cfg := config.DefaultConfig()
+	cfg.MaxFileSize = 100
+
+	scanned := func() int {
+		s, err := NewScanner(cfg, dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		files, err := s.Scan()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return len(files)
+	}
+	if n := scanned(); n != 0 {
+		t.Fatalf("scanned %d files, want big.go excluded without MCP_MAX_READ_BYTES", n)
+	}
+
+	cfg.MaxReadBytes = 50
+	if n := scanned(); n != 1 {
+		t.Fatalf("scanned %d files, want big.go included with MCP_MAX_READ_BYTES", n)
+	}
+	content, err := ReadFileContent(filepath.Join(dir, "big.go"), cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if content != "package main\n\n" {
+		t.Fatalf("content = %q, want the head of big.go", content)
+	}
+}
+
+func TestDetectLanguageSniffsShebangOnlyWithoutExtension(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"deploy":     "#!/usr/bin/env python3\nprint('hi')\n",
+		"deploy.xyz": "#!/usr/bin/env python3\nprint('hi')\n",
+	})
+	if got := detectLanguage(filepath.Join(dir, "deploy")); got != "python" {
+		t.Errorf("deploy: language = %q, want python", got)
+	}
+	if got := detectLanguage(filepath.Join(dir, "deploy.xyz")); got != "text" {
+		t.Errorf("deploy.xyz: language = %q, want text", got)
+	}
+}
+
+func TestScanDotfileControls(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		".gitignore":     ".env.local\n.tools/\n",
+		".env.local":     "TOKEN=x\n",
+		".tools/gen.sh":  "echo gen\n",
+		".github/ci.yml": "on: push\n",
+		".hidden.go":     "package main\n",
+		"main.go":        "package main\n",
+	})
+	scanned := func(configure func(*config.Config)) map[string]bool {
+		cfg := config.DefaultConfig()
+		configure(cfg)
+		s, err := NewScanner(cfg, dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		files, err := s.Scan()
+		if err != nil {
+			t.Fatal(err)
+		}
+		found := make(map[string]bool)
+		for _, f := range files {
+			rel, _ := filepath.Rel(dir, f.Path)
+			found[filepath.ToSlash(rel)] = true
+		}
+		return found
+	}
+
+	tests := []struct {
+		name      string
+		configure func(*config.Config)
+		want      map[string]bool
+	}{
+		{"gitignore decides", func(*config.Config) {},
+			map[string]bool{".env.local": false, ".tools/gen.sh": false, ".github/ci.yml": true, ".hidden.go": true, "main.go": true}},
+		{"index hidden files", func(cfg *config.Config) { cfg.IndexHiddenFiles = true },
+			map[string]bool{".env.local": true, ".tools/gen.sh": true, ".github/ci.yml": true, ".hidden.go": true, "main.go": true}},
+		{"skip dotfiles", func(cfg *config.Config) { cfg.SkipDotfiles = true; cfg.IndexHiddenFiles = true },
+			map[string]bool{".env.local": false, ".tools/gen.sh": false, ".github/ci.yml": true, ".hidden.go": false, "main.go": true}},
+	}
+	for _, tt := range tests {
+		found := scanned(tt.configure)
+		for file, want := range tt.want {
+			if found[file] != want {
+				t.Errorf("%s: %s scanned = %v, want %v", tt.name, file, found[file], want)
+			}
+		}
+	}
+}
@@ -0,0 +1,62 @@
+package indexer
+
+import (
+	"context"
+	"fmt"
+
+	"mcp-semantic-search/types"
+)
+
+// searchDiffOverfetch is how many times the requested limit each SearchDiff
+// query fetches, so overlaps just outside the top results are still detected
+// and "minus" can fill the limit after removing them
+const searchDiffOverfetch = 4
+
+// SearchDiff runs query and otherQuery with the same options and combines their
+// results: "minus" keeps results of query that otherQuery did not return,
+// "intersect" keeps the ones it did. Results are matched by file and line range
+// and keep the ranking and similarity of query.
+func (idx *Indexer) SearchDiff(ctx context.Context, query, otherQuery, operation string, opts types.SearchOptions) ([]types.SearchResult, error) {
+	var keepShared bool
+	switch operation {
+	case "minus":
+	case "intersect":
+		keepShared = true
+	default:
+		return nil, types.NewError(types.ErrCodeInvalidRequest, fmt.Sprintf("unknown operation %q (use minus or intersect)", operation), nil)
+	}
+
+	limit := idx.cfg.ClampSearchLimit(opts.Limit)
+	if limit <= 0 {
+		limit = 5
+	}
+	fetch := opts
+	fetch.Limit = limit * searchDiffOverfetch
+
+	results, err := idx.Search(ctx, query, fetch)
+	if err != nil {
+		return nil, err
+	}
+	other, err := idx.Search(ctx, otherQuery, fetch)
+	if err != nil {
+		return nil, err
+	}
+
+	inOther := make(map[string]bool, len(other))
+	for _, r := range other {
+		inOther[r.AbsolutePath+"\x00"+r.Lines] = true
+	}
+
+	combined := make([]types.SearchResult, 0, limit)
+	for _, r := range results {
+		if inOther[r.AbsolutePath+"\x00"+r.Lines] != keepShared {
+			continue
+		}
+		combined = append(combined, r)
+		if len(combined) >= limit {
+			break
+		}
+	}
+
+	return combined, nil
+}
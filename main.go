@@ -15,6 +15,7 @@ import (
 	"mcp-semantic-search/indexer"
 	"mcp-semantic-search/store"
 	"mcp-semantic-search/tools"
+	"mcp-semantic-search/types"
 	"mcp-semantic-search/updater"
 	"mcp-semantic-search/watcher"
 	"mcp-semantic-search/webui"
@@ -39,12 +40,37 @@ func main() {
 		log.Fatalf("Failed to create database directory: %v", err)
 	}
 
-	// Create embedder
-	embedder := indexer.NewEmbedder(cfg.OllamaURL, cfg.EmbeddingModel)
+	// Validate the embedding template before anything is embedded with it
+	if err := types.SetEmbeddingTemplate(cfg.EmbedTemplate); err != nil {
+		log.Fatalf("Invalid MCP_EMBED_TEMPLATE: %v", err)
+	}
+	if cfg.EmbedTemplate != "" {
+		log.Printf("Using custom embedding template; changing it re-embeds every indexed chunk")
+	}
 
-	// Test Ollama connection, try to start if not running
+	// Create embedder: Ollama, or a llama.cpp server started for a local model file
 	ctx := context.Background()
-	if err := embedder.TestConnection(ctx); err != nil {
+	var embedder *indexer.Embedder
+	if cfg.EmbeddingBackend == "local" {
+		var err error
+		embedder, err = indexer.NewLocalEmbedder(cfg.LlamaServerBin, cfg.LocalModelPath, cfg.EmbeddingWorkers)
+		if err != nil {
+			log.Fatalf("Failed to start local embedding backend: %v", err)
+		}
+	} else {
+		embedder = indexer.NewEmbedder(cfg.OllamaURL, cfg.EmbeddingModel, cfg.EmbeddingWorkers)
+	}
+	embedder.SetNormalize(cfg.NormalizeEmbeddings)
+	embedder.SetRateLimit(cfg.EmbedRatePerSec)
+	embedder.SetBatchSize(cfg.EmbeddingBatchSize)
+
+	// Test the connection; Ollama is started if not running
+	if cfg.EmbeddingBackend == "local" {
+		if err := embedder.TestConnection(ctx); err != nil {
+			embedder.Close()
+			log.Fatalf("Local embedding model is not usable: %v", err)
+		}
+	} else if err := embedder.TestConnection(ctx); err != nil {
 		fmt.Fprintf(os.Stderr, "Ollama not running, attempting to start...\n")
 		if startErr := startOllama(); startErr != nil {
 			fmt.Fprintf(os.Stderr, "Failed to start Ollama: %v\n", startErr)
@@ -60,12 +86,22 @@ func main() {
 		fmt.Fprintf(os.Stderr, "Ollama started successfully\n")
 	}
 
+	// Keep the embedding model resident between indexing bursts
+	if cfg.ModelKeepAlive > 0 {
+		embedder.StartKeepAlive(time.Duration(cfg.ModelKeepAlive) * time.Second)
+	}
+
 	// Create store
 	vectorStore, err := store.NewStore(cfg, embedder.EmbeddingFunc())
 	if err != nil {
 		log.Fatalf("Failed to create vector store: %v", err)
 	}
 
+	// Embed each file's chunks in multi-text requests
+	if cfg.EmbeddingBatchSize > 1 {
+		vectorStore.SetBatchEmbeddingFunc(embedder.BatchEmbeddingFunc())
+	}
+
 	// Create file hash store for incremental indexing (uses SQLite)
 	hashStore := vectorStore.NewFileHashStore()
 
@@ -82,6 +118,10 @@ func main() {
 	if cfg.WatchEnabled {
 		folders := hashStore.ListIndexedFolders()
 		for _, folderPath := range folders {
+			if err := idx.CheckAllowedPath(folderPath); err != nil {
+				log.Printf("Not restoring watcher: %v", err)
+				continue
+			}
 			if err := watcherManager.StartWatching(folderPath); err != nil {
 				log.Printf("Failed to restore watcher for %s: %v", folderPath, err)
 			} else {
@@ -90,6 +130,40 @@ func main() {
 		}
 	}
 
+	// Detect indexes built by an older parser/chunker (e.g. after an auto-update)
+	reindex := false
+	if stored, outdated := idx.IndexOutdated(); outdated {
+		if cfg.ReindexOnUpgrade {
+			log.Printf("Index was built with chunker version %d (current %d), reindexing all folders", stored, indexer.ChunkerVersion)
+			reindex = true
+		} else {
+			log.Printf("Warning: index was built with chunker version %d (current %d); reindex folders or set MCP_REINDEX_ON_UPGRADE=true to refresh chunks", stored, indexer.ChunkerVersion)
+		}
+	}
+
+	// Reindex and/or re-embed stored chunks if the embedding configuration
+	// changed since they were indexed, one after the other so they never
+	// write the same vectors. A complete reindex embeds every chunk anew, so
+	// it makes the re-embed unnecessary.
+	if reindex || vectorStore.NeedsReembed() {
+		go func() {
+			ctx := context.Background()
+			if reindex {
+				err := idx.ReindexAll(ctx)
+				if err == nil {
+					if err := vectorStore.MarkReembedded(); err != nil {
+						log.Printf("Warning: failed to record embedding configuration: %v", err)
+					}
+					return
+				}
+				log.Printf("Warning: reindex after upgrade incomplete, will retry on next start: %v", err)
+			}
+			if err := vectorStore.ReembedIfNeeded(ctx); err != nil {
+				log.Printf("Warning: re-embedding failed, will retry on next start: %v", err)
+			}
+		}()
+	}
+
 	// Create MCP server
 	mcpServer := server.NewMCPServer(
 		serverName,
@@ -140,6 +214,7 @@ func main() {
 			_ = webServer.Stop()
 		}
 		watcherManager.StopAll()
+		embedder.Close()
 		idx.Close()
 		_ = vectorStore.Close()
 		os.Exit(0)
@@ -148,12 +223,20 @@ func main() {
 	// Print startup info to stderr (stdout is for MCP communication)
 	fmt.Fprintf(os.Stderr, "Starting %s v%s\n", serverName, Version)
 	fmt.Fprintf(os.Stderr, "Database path: %s\n", cfg.DBPath)
-	fmt.Fprintf(os.Stderr, "Ollama URL: %s\n", cfg.OllamaURL)
-	fmt.Fprintf(os.Stderr, "Embedding model: %s\n", cfg.EmbeddingModel)
+	if cfg.EmbeddingBackend == "local" {
+		fmt.Fprintf(os.Stderr, "Embedding backend: local (%s)\n", cfg.LocalModelPath)
+	} else {
+		fmt.Fprintf(os.Stderr, "Ollama URL: %s\n", cfg.OllamaURL)
+		fmt.Fprintf(os.Stderr, "Embedding model: %s\n", cfg.EmbeddingModel)
+	}
 	fmt.Fprintf(os.Stderr, "Embedding workers: %d\n", cfg.EmbeddingWorkers)
+	fmt.Fprintf(os.Stderr, "File workers: %d (parse workers: %d)\n", cfg.FileWorkers, cfg.ParseWorkers)
 	fmt.Fprintf(os.Stderr, "File watching: %v\n", cfg.WatchEnabled)
 	fmt.Fprintf(os.Stderr, "Auto-index: %v\n", cfg.AutoIndex)
 	fmt.Fprintf(os.Stderr, "Auto-update: %v (apply: %v)\n", cfg.AutoUpdateEnabled, cfg.AutoUpdateApply)
+	if cfg.SafeMode {
+		fmt.Fprintf(os.Stderr, "Safe mode: on\n")
+	}
 	if cfg.WebUIEnabled && actualWebUIPort > 0 {
 		fmt.Fprintf(os.Stderr, "Web UI: http://localhost:%d\n", actualWebUIPort)
 		if cfg.AutoOpenUI {
@@ -170,11 +253,12 @@ func main() {
 				return
 			}
 			log.Printf("Auto-indexing current folder: %s", cwd)
-			result, err := idx.IndexProject(context.Background(), cwd, cfg.WatchEnabled)
+			result, err := idx.IndexProject(context.Background(), cwd, cfg.WatchEnabled, false)
 			if err != nil {
 				log.Printf("Auto-index failed: %v", err)
 			} else {
-				log.Printf("Auto-index complete: %d files, %d chunks", result.FilesIndexed, result.ChunksStored)
+				log.Printf("Auto-index complete: %d files, %d chunks (by language: %v, by type: %v)",
+					result.FilesIndexed, result.ChunksStored, result.ByLanguage, result.BySymbolType)
 			}
 		}()
 	}
@@ -0,0 +1,71 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"mcp-semantic-search/types"
+)
+
+// CheckEmbeddingDimension re-probes the model's output dimension and compares
+// it with the dimension the vector table was created for. On a mismatch (e.g.
+// Ollama or the model was updated while the server is running) indexing is
+// blocked with a dimension_mismatch error until the dimension matches again;
+// restarting the server rebuilds the index for the new dimension.
+func (s *Store) CheckEmbeddingDimension(ctx context.Context) error {
+	emb, err := s.embeddingFunc(ctx, "test")
+	if err != nil {
+		return fmt.Errorf("failed to generate test embedding: %w", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(emb) == s.embeddingDim {
+		if s.dimensionErr != nil {
+			log.Printf("Embedding dimension matches the index again (%d); indexing unblocked", s.embeddingDim)
+		}
+		s.dimensionErr = nil
+		return nil
+	}
+	return s.setDimensionMismatch(len(emb))
+}
+
+// EmbeddingDimension returns the vector dimension the index was created for
+func (s *Store) EmbeddingDimension() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.embeddingDim
+}
+
+// DimensionError returns the dimension_mismatch error blocking indexing, or nil
+func (s *Store) DimensionError() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.dimensionErr
+}
+
+// verifyDimensions checks freshly generated embeddings before they are stored,
+// so vectors of the wrong size never reach the vec table
+func (s *Store) verifyDimensions(embeddings [][]float32) error {
+	for _, emb := range embeddings {
+		if len(emb) != s.embeddingDim {
+			s.mu.Lock()
+			defer s.mu.Unlock()
+			return s.setDimensionMismatch(len(emb))
+		}
+	}
+	return nil
+}
+
+// setDimensionMismatch records and returns the error for a model that now
+// produces got-dimensional vectors. Caller must hold s.mu.
+func (s *Store) setDimensionMismatch(got int) error {
+	if s.dimensionErr == nil {
+		log.Printf("Error: embedding model now returns %d-dimensional vectors but the index holds %d; indexing blocked until restart", got, s.embeddingDim)
+	}
+	s.dimensionErr = types.NewError(types.ErrCodeDimensionMismatch,
+		fmt.Sprintf("embedding model now returns %d-dimensional vectors but the index was built with %d; restart the server to rebuild the index", got, s.embeddingDim), nil)
+	return s.dimensionErr
+}
@@ -0,0 +1,41 @@
+package store
+
+import (
+	"context"
+	"testing"
+
+	"mcp-semantic-search/types"
+)
+
+func TestDimensionChangeBlocksIndexingUntilItMatchesAgain(t *testing.T) {
+	st := newTestStore(t, nil)
+	ctx := context.Background()
+	addChunks(t, st, testChunk("/p/a.go", 0, "A", "func A() {}"))
+
+	// The model now returns wider vectors
+	st.embeddingFunc = func(ctx context.Context, text string) ([]float32, error) {
+		return make([]float32, 24), nil
+	}
+	err := st.AddChunks(ctx, []types.Chunk{testChunk("/p/b.go", 0, "B", "func B() {}")})
+	if types.ErrorCodeOf(err) != types.ErrCodeDimensionMismatch {
+		t.Fatalf("AddChunks error = %v, want dimension_mismatch", err)
+	}
+	if n := st.CountFileChunks(ctx, "/p/b.go"); n != 0 {
+		t.Fatalf("stored %d mismatched chunks", n)
+	}
+	if types.ErrorCodeOf(st.CheckEmbeddingDimension(ctx)) != types.ErrCodeDimensionMismatch {
+		t.Fatal("CheckEmbeddingDimension did not report the mismatch")
+	}
+	if st.DimensionError() == nil {
+		t.Fatal("DimensionError not set for status")
+	}
+
+	st.embeddingFunc = fakeEmbed
+	if err := st.CheckEmbeddingDimension(ctx); err != nil {
+		t.Fatalf("CheckEmbeddingDimension = %v after the dimension matched again", err)
+	}
+	if st.DimensionError() != nil {
+		t.Fatal("DimensionError still set after the dimension matched again")
+	}
+	addChunks(t, st, testChunk("/p/b.go", 0, "B", "func B() {}"))
+}
@@ -0,0 +1,247 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"mcp-semantic-search/types"
+
+	sqlite_vec "github.com/asg017/sqlite-vec-go-bindings/ncruces"
+)
+
+// duplicateNeighbors is how many duplicates are reported for each seed chunk
+const duplicateNeighbors = 20
+
+// duplicateMaxNeighbors bounds the nearest-neighbor query when most neighbors
+// of a seed are in its own file or outside the path prefix
+const duplicateMaxNeighbors = 1280
+
+// vectorMatch is a chunk returned by a nearest-neighbor query
+type vectorMatch struct {
+	id         string
+	chunk      types.DuplicateChunk
+	similarity float32
+}
+
+// SearchByVector returns the k chunks nearest to embedding, closest first.
+// FilePath and AbsolutePath are both absolute.
+func (s *Store) SearchByVector(ctx context.Context, embedding []float32, k int) ([]types.SearchResult, error) {
+	blob, err := sqlite_vec.SerializeFloat32(embedding)
+	if err != nil {
+		return nil, fmt.Errorf("failed to serialize vector: %w", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	matches, err := s.nearestChunks(blob, k)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]types.SearchResult, 0, len(matches))
+	for _, m := range matches {
+		results = append(results, types.SearchResult{
+			FilePath:     m.chunk.FilePath,
+			AbsolutePath: m.chunk.FilePath,
+			ChunkType:    m.chunk.ChunkType,
+			Name:         m.chunk.Name,
+			Lines:        fmt.Sprintf("%d-%d", m.chunk.StartLine, m.chunk.EndLine),
+			StartLine:    m.chunk.StartLine,
+			EndLine:      m.chunk.EndLine,
+			Similarity:   m.similarity,
+		})
+	}
+	return results, nil
+}
+
+// nearestChunks runs a k-nearest-neighbor query for a serialized vector.
+// Caller must hold s.mu.
+func (s *Store) nearestChunks(blob []byte, k int) ([]vectorMatch, error) {
+	stmt, _, err := s.db.Prepare(`
+		SELECT c.id, c.name, c.chunk_type, c.absolute_path, c.start_line, c.end_line, v.distance
+		FROM vec_chunks v
+		JOIN vec_chunk_map m ON m.vec_rowid = v.rowid
+		JOIN chunks c ON c.id = m.chunk_id
+		WHERE v.embedding MATCH ?
+		  AND k = ?
+		ORDER BY v.distance
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to prepare query: %w", err)
+	}
+	defer stmt.Close()
+
+	stmt.BindBlob(1, blob)
+	stmt.BindInt(2, k)
+
+	var matches []vectorMatch
+	for stmt.Step() {
+		matches = append(matches, vectorMatch{
+			id: stmt.ColumnText(0),
+			chunk: types.DuplicateChunk{
+				Name:      stmt.ColumnText(1),
+				ChunkType: stmt.ColumnText(2),
+				FilePath:  stmt.ColumnText(3),
+				StartLine: stmt.ColumnInt(4),
+				EndLine:   stmt.ColumnInt(5),
+			},
+			similarity: float32(1.0 - stmt.ColumnFloat(6)),
+		})
+	}
+	if err := stmt.Err(); err != nil {
+		return nil, fmt.Errorf("query iteration failed: %w", err)
+	}
+	return matches, nil
+}
+
+// FindDuplicates finds near-identical copies of the chunks of absFile, or of
+// the symbol named symbol when absFile is empty. Each seed chunk's stored
+// vector is matched against the index; neighbors in the seed's own file or
+// outside pathPrefix are skipped. At most maxSeeds chunks are checked; the
+// returned bool reports whether more seeds existed.
+func (s *Store) FindDuplicates(ctx context.Context, absFile, symbol, pathPrefix string, minSimilarity float32, maxSeeds int) ([]types.DuplicatePair, bool, error) {
+	query := `
+		SELECT c.id, c.name, c.chunk_type, c.absolute_path, c.start_line, c.end_line, v.embedding
+		FROM chunks c
+		JOIN vec_chunk_map m ON m.chunk_id = c.id
+		JOIN vec_chunks v ON v.rowid = m.vec_rowid
+		WHERE `
+	var arg string
+	if absFile != "" {
+		query += `c.absolute_path = ?`
+		arg = absFile
+	} else {
+		query += `(c.name = ? OR c.name LIKE ? || ' (part %') AND c.absolute_path LIKE ?`
+		arg = symbol
+	}
+	query += ` ORDER BY c.absolute_path, c.start_line LIMIT ?`
+
+	// The lock is held for the seed query only; each neighbor query takes
+	// it again so indexing is not blocked for the whole request
+	s.mu.Lock()
+	stmt, _, err := s.db.Prepare(query)
+	if err != nil {
+		s.mu.Unlock()
+		return nil, false, fmt.Errorf("query failed: %w", err)
+	}
+	stmt.BindText(1, arg)
+	param := 2
+	if absFile == "" {
+		stmt.BindText(2, symbol)
+		stmt.BindText(3, pathPrefix+"%")
+		param = 4
+	}
+	stmt.BindInt(param, maxSeeds+1)
+
+	type seed struct {
+		id    string
+		chunk types.DuplicateChunk
+		blob  []byte
+	}
+	var seeds []seed
+	for stmt.Step() {
+		seeds = append(seeds, seed{
+			id: stmt.ColumnText(0),
+			chunk: types.DuplicateChunk{
+				Name:      stmt.ColumnText(1),
+				ChunkType: stmt.ColumnText(2),
+				FilePath:  stmt.ColumnText(3),
+				StartLine: stmt.ColumnInt(4),
+				EndLine:   stmt.ColumnInt(5),
+			},
+			blob: stmt.ColumnBlob(6, nil),
+		})
+	}
+	err = stmt.Err()
+	stmt.Close()
+	s.mu.Unlock()
+	if err != nil {
+		return nil, false, fmt.Errorf("query iteration failed: %w", err)
+	}
+
+	truncated := len(seeds) > maxSeeds
+	if truncated {
+		seeds = seeds[:maxSeeds]
+	}
+
+	pairs := make([]types.DuplicatePair, 0)
+	seen := make(map[string]bool)
+	for _, sd := range seeds {
+		if err := ctx.Err(); err != nil {
+			return nil, false, err
+		}
+
+		matches, err := s.similarNeighbors(sd.blob, duplicateNeighbors, minSimilarity, func(m vectorMatch) bool {
+			return m.id != sd.id && m.chunk.FilePath != sd.chunk.FilePath && strings.HasPrefix(m.chunk.FilePath, pathPrefix)
+		})
+		if err != nil {
+			return nil, false, err
+		}
+		for _, m := range matches {
+			// Report each pair once, whichever side was the seed
+			key := sd.id + "\x00" + m.id
+			if m.id < sd.id {
+				key = m.id + "\x00" + sd.id
+			}
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+
+			pairs = append(pairs, types.DuplicatePair{
+				Chunk:      sd.chunk,
+				Duplicate:  m.chunk,
+				Similarity: m.similarity,
+			})
+		}
+	}
+
+	sort.SliceStable(pairs, func(i, j int) bool {
+		if pairs[i].Similarity != pairs[j].Similarity {
+			return pairs[i].Similarity > pairs[j].Similarity
+		}
+		a, b := pairs[i].Chunk, pairs[j].Chunk
+		if a.FilePath != b.FilePath {
+			return a.FilePath < b.FilePath
+		}
+		return a.StartLine < b.StartLine
+	})
+
+	return pairs, truncated, nil
+}
+
+// similarNeighbors returns up to n nearest chunks at or above minSimilarity
+// that pass keep, closest first. The vector query cannot filter, so k grows
+// until n chunks pass, the rest fall below minSimilarity, or k reaches
+// duplicateMaxNeighbors.
+func (s *Store) similarNeighbors(blob []byte, n int, minSimilarity float32, keep func(vectorMatch) bool) ([]vectorMatch, error) {
+	for k := n; ; k = min(k*4, duplicateMaxNeighbors) {
+		s.mu.Lock()
+		matches, err := s.nearestChunks(blob, k)
+		s.mu.Unlock()
+		if err != nil {
+			return nil, err
+		}
+
+		done := len(matches) < k || k >= duplicateMaxNeighbors
+		var kept []vectorMatch
+		for _, m := range matches {
+			if m.similarity < minSimilarity {
+				done = true // Ordered by distance
+				break
+			}
+			if keep(m) {
+				kept = append(kept, m)
+				if len(kept) == n {
+					return kept, nil
+				}
+			}
+		}
+		if done {
+			return kept, nil
+		}
+	}
+}
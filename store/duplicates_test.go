@@ -0,0 +1,59 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"mcp-semantic-search/types"
+)
+
+func TestFindDuplicatesLooksPastSameFileNeighbors(t *testing.T) {
+	st := newTestStore(t, nil)
+	// Same-file chunks are exact copies; the copy in another file differs
+	// slightly, so it ranks behind more neighbors than one query returns
+	st.embeddingFunc = func(ctx context.Context, text string) ([]float32, error) {
+		v := make([]float32, 16)
+		v[0] = 1
+		if strings.Contains(text, "nearly") {
+			v[1] = 0.05
+		}
+		return v, nil
+	}
+
+	var chunks []types.Chunk
+	for i := 0; i < 2*duplicateNeighbors; i++ {
+		chunks = append(chunks, testChunk("/p/big.go", i, fmt.Sprintf("F%d", i), "func body"))
+	}
+	addChunks(t, st, chunks...)
+	addChunks(t, st, testChunk("/p/util.go", 0, "Copy", "func body nearly"))
+	addChunks(t, st, testChunk("/other/copy.go", 0, "Outside", "func body nearly"))
+
+	pairs, truncated, err := st.FindDuplicates(context.Background(), "/p/big.go", "", "/p/", 0.99, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !truncated {
+		t.Error("truncated = false, want true with one seed of many")
+	}
+	if len(pairs) != 1 || pairs[0].Duplicate.Name != "Copy" {
+		t.Fatalf("pairs = %+v, want the copy in util.go only", pairs)
+	}
+}
+
+func TestFindDuplicatesUnlocksStoreOnCancel(t *testing.T) {
+	st := newTestStore(t, nil)
+	addChunks(t, st, testChunk("/p/a.go", 0, "A", "func body"), testChunk("/p/b.go", 0, "B", "func body"))
+
+	// A cancelled context stops the scan before the first neighbor query
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, _, err := st.FindDuplicates(ctx, "/p/a.go", "", "/p/", 0.99, 10); err == nil {
+		t.Fatal("FindDuplicates ignored the cancelled context")
+	}
+	if !st.mu.TryLock() {
+		t.Fatal("FindDuplicates returned with the store locked")
+	}
+	st.mu.Unlock()
+}
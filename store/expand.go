@@ -0,0 +1,183 @@
+package store
+
+import (
+	"sort"
+	"strings"
+)
+
+// maxQueryVariants caps the extra searches run per query with query expansion
+const maxQueryVariants = 3
+
+// abbreviations maps common code abbreviations to the words they stand for.
+// expandQuery uses it in both directions ("auth" <-> "authenticate").
+var abbreviations = map[string][]string{
+	"addr":   {"address"},
+	"arg":    {"argument"},
+	"args":   {"arguments"},
+	"async":  {"asynchronous"},
+	"auth":   {"authenticate", "authentication", "authorization"},
+	"authn":  {"authentication"},
+	"authz":  {"authorization"},
+	"btn":    {"button"},
+	"calc":   {"calculate"},
+	"cfg":    {"config", "configuration"},
+	"cmd":    {"command"},
+	"config": {"configuration"},
+	"conn":   {"connection"},
+	"ctx":    {"context"},
+	"db":     {"database"},
+	"del":    {"delete"},
+	"dir":    {"directory"},
+	"doc":    {"documentation"},
+	"elem":   {"element"},
+	"env":    {"environment"},
+	"err":    {"error"},
+	"fn":     {"function"},
+	"func":   {"function"},
+	"gen":    {"generate"},
+	"idx":    {"index"},
+	"impl":   {"implementation"},
+	"info":   {"information"},
+	"init":   {"initialize"},
+	"len":    {"length"},
+	"lib":    {"library"},
+	"max":    {"maximum"},
+	"mgr":    {"manager"},
+	"min":    {"minimum"},
+	"msg":    {"message"},
+	"num":    {"number"},
+	"obj":    {"object"},
+	"param":  {"parameter"},
+	"params": {"parameters"},
+	"perm":   {"permission"},
+	"pkg":    {"package"},
+	"prev":   {"previous"},
+	"ref":    {"reference"},
+	"repo":   {"repository"},
+	"req":    {"request"},
+	"resp":   {"response"},
+	"spec":   {"specification"},
+	"src":    {"source"},
+	"str":    {"string"},
+	"svc":    {"service"},
+	"sync":   {"synchronize"},
+	"tmp":    {"temporary"},
+	"tx":     {"transaction"},
+	"util":   {"utility"},
+	"val":    {"value"},
+	"var":    {"variable"},
+}
+
+// expansions is abbreviations plus the reverse mapping (full word -> abbreviation)
+var expansions = buildExpansions()
+
+func buildExpansions() map[string][]string {
+	result := make(map[string][]string, len(abbreviations)*2)
+	for abbr, words := range abbreviations {
+		result[abbr] = append(result[abbr], words...)
+	}
+	// Walk abbreviations in sorted order so a word's reverse entries are stable
+	for _, abbr := range sortedKeys(abbreviations) {
+		for _, word := range abbreviations[abbr] {
+			if !containsString(result[word], abbr) {
+				result[word] = append(result[word], abbr)
+			}
+		}
+	}
+	return result
+}
+
+// expandQuery returns up to maxQueryVariants alternative phrasings of query,
+// substituting abbreviations, their expansions, and simple word stems
+// ("handlers" -> "handler", "parsing" -> "pars"). Variant k uses the k-th
+// alternative of every term that has one. The result is deterministic.
+func expandQuery(query string) []string {
+	terms := strings.Fields(strings.ToLower(query))
+
+	alternatives := make([][]string, len(terms))
+	for i, term := range terms {
+		alternatives[i] = termAlternatives(term)
+	}
+
+	var variants []string
+	seen := map[string]bool{strings.Join(terms, " "): true}
+	for k := 0; k < maxQueryVariants; k++ {
+		words := make([]string, len(terms))
+		changed := false
+		for i, term := range terms {
+			words[i] = term
+			if k < len(alternatives[i]) {
+				words[i] = alternatives[i][k]
+				changed = true
+			}
+		}
+		if !changed {
+			break
+		}
+		variant := strings.Join(words, " ")
+		if !seen[variant] {
+			seen[variant] = true
+			variants = append(variants, variant)
+		}
+	}
+
+	return variants
+}
+
+// termAlternatives lists the substitutes for one query term: table entries for
+// the term, then its stem and the stem's table entries
+func termAlternatives(term string) []string {
+	var alts []string
+	add := func(words ...string) {
+		for _, w := range words {
+			if w != term && !containsString(alts, w) {
+				alts = append(alts, w)
+			}
+		}
+	}
+
+	add(expansions[term]...)
+	if stem := stemWord(term); stem != term {
+		add(stem)
+		add(expansions[stem]...)
+	}
+
+	return alts
+}
+
+// stemWord strips a common English inflection, keeping at least 4 letters
+func stemWord(word string) string {
+	for _, suffix := range []struct{ from, to string }{
+		{"ies", "y"},
+		{"sses", "ss"},
+		{"ing", ""},
+		{"ed", ""},
+		{"s", ""},
+	} {
+		if strings.HasSuffix(word, suffix.from) && !strings.HasSuffix(word, "ss") {
+			stem := strings.TrimSuffix(word, suffix.from) + suffix.to
+			if len(stem) >= 4 {
+				return stem
+			}
+		}
+	}
+	return word
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+func sortedKeys(m map[string][]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,72 @@
+package store
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	"mcp-semantic-search/types"
+)
+
+func TestExpandQuerySubstitutesAbbreviationsAndStems(t *testing.T) {
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{"auth handlers", []string{"authenticate handler", "authentication handlers", "authorization handlers"}},
+		{"Database conn", []string{"db connection"}},
+		{"parsing", []string{"pars"}},
+		{"render", nil},
+	}
+	for _, tt := range tests {
+		if got := expandQuery(tt.query); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("expandQuery(%q) = %q, want %q", tt.query, got, tt.want)
+		}
+	}
+}
+
+func TestStemWordKeepsShortWordsAndDoubleS(t *testing.T) {
+	for word, want := range map[string]string{
+		"queries": "query",
+		"classes": "class",
+		"class":   "class",
+		"bus":     "bus",
+		"loaded":  "load",
+		"uses":    "uses",
+	} {
+		if got := stemWord(word); got != want {
+			t.Errorf("stemWord(%q) = %q, want %q", word, got, want)
+		}
+	}
+}
+
+func TestSearchExpandKeepsEachChunksBestVariantScore(t *testing.T) {
+	st := newTestStore(t, nil)
+	// Chunks match the query direction only when their text and the query
+	// share the marker word; "auth" alone points elsewhere
+	st.embeddingFunc = func(ctx context.Context, text string) ([]float32, error) {
+		v := make([]float32, 16)
+		if strings.Contains(text, "authentication") {
+			v[0] = 1
+		} else {
+			v[1] = 1
+		}
+		return v, nil
+	}
+	addChunks(t, st, testChunk("/p/login.go", 0, "Login", "checks authentication"))
+
+	similarity := func(expand bool) float32 {
+		results, err := st.Search(context.Background(), "auth", "", types.SearchOptions{Limit: 5, Expand: expand})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(results) != 1 {
+			t.Fatalf("expand=%v: results %+v, want Login", expand, results)
+		}
+		return results[0].Similarity
+	}
+	if plain, expanded := similarity(false), similarity(true); expanded <= plain || expanded < 0.99 {
+		t.Fatalf("similarity %v plain, %v expanded; want the authentication variant's score", plain, expanded)
+	}
+}
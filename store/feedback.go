@@ -0,0 +1,122 @@
+package store
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+
+	"mcp-semantic-search/types"
+
+	sqlite_vec "github.com/asg017/sqlite-vec-go-bindings/ncruces"
+)
+
+// feedbackQueryHash fingerprints a query so feedback can be grouped by query
+// without storing its text
+func feedbackQueryHash(query string) string {
+	normalized := strings.Join(strings.Fields(strings.ToLower(query)), " ")
+	hash := sha256.Sum256([]byte(normalized))
+	return hex.EncodeToString(hash[:])
+}
+
+// RecordFeedback stores whether the chunk covering line of absolutePath was a
+// useful result for query, together with the query/chunk vector similarity
+func (s *Store) RecordFeedback(ctx context.Context, query, absolutePath string, line int, clicked bool) (*types.FeedbackEntry, error) {
+	queryEmb, err := s.embeddingFunc(types.WithQueryEmbedding(ctx), query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to embed query: %w", err)
+	}
+	queryBlob, err := sqlite_vec.SerializeFloat32(queryEmb)
+	if err != nil {
+		return nil, fmt.Errorf("failed to serialize query vector: %w", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// The innermost chunk covering the line, with its distance to the query
+	stmt, _, err := s.db.Prepare(`
+		SELECT c.id, vec_distance_cosine(v.embedding, ?)
+		FROM chunks c
+		JOIN vec_chunk_map m ON m.chunk_id = c.id
+		JOIN vec_chunks v ON v.rowid = m.vec_rowid
+		WHERE c.absolute_path = ? AND c.start_line <= ? AND c.end_line >= ?
+		ORDER BY c.end_line - c.start_line
+		LIMIT 1
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("query failed: %w", err)
+	}
+	stmt.BindBlob(1, queryBlob)
+	stmt.BindText(2, absolutePath)
+	stmt.BindInt(3, line)
+	stmt.BindInt(4, line)
+	if !stmt.Step() {
+		err := stmt.Err()
+		stmt.Close()
+		if err != nil {
+			return nil, fmt.Errorf("query failed: %w", err)
+		}
+		return nil, types.NewError(types.ErrCodeInvalidRequest, fmt.Sprintf("no indexed chunk at %s:%d", absolutePath, line), nil)
+	}
+	entry := &types.FeedbackEntry{
+		QueryHash:  feedbackQueryHash(query),
+		ChunkID:    stmt.ColumnText(0),
+		Similarity: float32(1.0 - stmt.ColumnFloat(1)),
+		Clicked:    clicked,
+		CreatedAt:  time.Now().Unix(),
+	}
+	stmt.Close()
+
+	insert, _, err := s.db.Prepare(`
+		INSERT INTO search_feedback (query_hash, chunk_id, similarity, clicked, created_at)
+		VALUES (?, ?, ?, ?, ?)
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to prepare insert: %w", err)
+	}
+	defer insert.Close()
+	insert.BindText(1, entry.QueryHash)
+	insert.BindText(2, entry.ChunkID)
+	insert.BindFloat(3, float64(entry.Similarity))
+	insert.BindBool(4, entry.Clicked)
+	insert.BindInt64(5, entry.CreatedAt)
+	if err := insert.Exec(); err != nil {
+		return nil, fmt.Errorf("failed to record feedback: %w", err)
+	}
+
+	return entry, nil
+}
+
+// ExportFeedback returns all recorded feedback, oldest first
+func (s *Store) ExportFeedback(ctx context.Context) ([]types.FeedbackEntry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stmt, _, err := s.db.Prepare(`
+		SELECT query_hash, chunk_id, similarity, clicked, created_at
+		FROM search_feedback
+		ORDER BY id
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("query failed: %w", err)
+	}
+	defer stmt.Close()
+
+	entries := make([]types.FeedbackEntry, 0)
+	for stmt.Step() {
+		entries = append(entries, types.FeedbackEntry{
+			QueryHash:  stmt.ColumnText(0),
+			ChunkID:    stmt.ColumnText(1),
+			Similarity: float32(stmt.ColumnFloat(2)),
+			Clicked:    stmt.ColumnBool(3),
+			CreatedAt:  stmt.ColumnInt64(4),
+		})
+	}
+	if err := stmt.Err(); err != nil {
+		return nil, fmt.Errorf("query iteration failed: %w", err)
+	}
+	return entries, nil
+}
@@ -0,0 +1,84 @@
+package store
+
+import (
+	"strings"
+
+	"mcp-semantic-search/types"
+)
+
+// replaceFileContent replaces the stored full content of a file; empty content
+// removes it. Caller must hold s.mu and an open transaction.
+func (s *Store) replaceFileContent(absolutePath, content string) error {
+	delStmt, _, err := s.db.Prepare("DELETE FROM file_contents WHERE absolute_path = ?")
+	if err != nil {
+		return err
+	}
+	delStmt.BindText(1, absolutePath)
+	err = delStmt.Exec()
+	delStmt.Close()
+	if err != nil || content == "" {
+		return err
+	}
+
+	insStmt, _, err := s.db.Prepare("INSERT INTO file_contents(absolute_path, content) VALUES (?, ?)")
+	if err != nil {
+		return err
+	}
+	defer insStmt.Close()
+
+	insStmt.BindText(1, absolutePath)
+	insStmt.BindText(2, content)
+	return insStmt.Exec()
+}
+
+// fileContent returns the stored full content of a file (MCP_STORE_FULL_FILES).
+// Caller must hold s.mu.
+func (s *Store) fileContent(absolutePath string) (string, bool) {
+	stmt, _, err := s.db.Prepare("SELECT content FROM file_contents WHERE absolute_path = ?")
+	if err != nil {
+		return "", false
+	}
+	defer stmt.Close()
+
+	stmt.BindText(1, absolutePath)
+	if stmt.Step() {
+		return stmt.ColumnText(0), true
+	}
+	return "", false
+}
+
+// symbolPart is one stored chunk of a definition split into parts
+type symbolPart struct {
+	startLine int
+	content   string
+}
+
+// definitionContent rebuilds the full source of a definition from the
+// database: cut from the stored file when available, else by joining the
+// raw content of its parts without their overlapping lines. Caller must hold s.mu.
+func (s *Store) definitionContent(def types.Definition, parts []symbolPart) string {
+	if content, ok := s.fileContent(def.FilePath); ok {
+		lines := strings.Split(content, "\n")
+		if def.Line >= 1 && def.EndLine >= def.Line && def.EndLine <= len(lines) {
+			return strings.Join(lines[def.Line-1:def.EndLine], "\n")
+		}
+	}
+	if len(parts) < 2 {
+		return def.Content
+	}
+
+	var lines []string
+	next := parts[0].startLine // First line not yet emitted
+	for _, part := range parts {
+		partLines := strings.Split(part.content, "\n")
+		if skip := next - part.startLine; skip > 0 {
+			if skip >= len(partLines) {
+				continue
+			}
+			partLines = partLines[skip:]
+		}
+		lines = append(lines, partLines...)
+		next = max(next, part.startLine) + len(partLines)
+	}
+	return strings.Join(lines, "\n")
+}
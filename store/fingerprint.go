@@ -0,0 +1,108 @@
+package store
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+)
+
+// fingerprintKey is the store_config key holding the sentinel vector of the
+// model the index was built with
+const fingerprintKey = "embedding_fingerprint"
+
+// fingerprintSentinel is embedded at startup; its vector fingerprints the
+// model's weights, which can change behind an unchanged model name (e.g. an
+// Ollama tag pulled again)
+const fingerprintSentinel = "func parseConfig(path string) (*Config, error) // load settings from a file"
+
+// fingerprintMinSimilarity is the cosine similarity below which the sentinel
+// vector is considered to come from different weights. Repeated embeddings
+// by the same model differ only by floating-point noise.
+const fingerprintMinSimilarity = 0.99
+
+// checkEmbeddingFingerprint compares the sentinel vector embedded at startup
+// with the one recorded when the index was built. On drift the stored vectors
+// no longer match new ones: they are re-embedded with
+// MCP_REEMBED_ON_MODEL_CHANGE, otherwise the index is flagged (see ModelDrift)
+// and searches keep working with degraded results until it is rebuilt.
+func (s *Store) checkEmbeddingFingerprint() error {
+	s.modelDrift = ""
+	if len(s.fingerprint) == 0 {
+		return nil
+	}
+
+	stored, err := s.getConfigValue(fingerprintKey)
+	if err != nil {
+		return err
+	}
+	// A renamed model is handled by checkEmbeddingModel, and a pending
+	// re-embed records the fingerprint once it completes
+	if s.modelErr != nil || s.reembedNeeded {
+		return nil
+	}
+
+	var reference []float32
+	if stored != "" {
+		if err := json.Unmarshal([]byte(stored), &reference); err != nil {
+			log.Printf("Warning: ignoring unreadable embedding fingerprint: %v", err)
+			reference = nil
+		}
+	}
+	// First run, an index from before fingerprints, a rebuilt table, or nothing to compare
+	if len(reference) != len(s.fingerprint) || !s.hasChunks() {
+		return s.recordFingerprint()
+	}
+
+	similarity := cosineSimilarity(reference, s.fingerprint)
+	if similarity >= fingerprintMinSimilarity {
+		return nil
+	}
+
+	if s.cfg.ReembedOnModelChange {
+		log.Printf("Warning: embedding model %s returns different vectors than when the index was built (sentinel similarity %.4f); stored vectors will be re-embedded", embeddingModelName(s.cfg), similarity)
+		s.reembedNeeded = true
+		return nil
+	}
+
+	log.Printf("Warning: embedding model %s returns different vectors than when the index was built (sentinel similarity %.4f); reindex recommended", embeddingModelName(s.cfg), similarity)
+	s.modelDrift = fmt.Sprintf("embedding model %s changed since the index was built (its weights were updated under the same name; sentinel similarity %.4f), so results may be unreliable; clear the index and reindex, or restart with MCP_REEMBED_ON_MODEL_CHANGE=true", embeddingModelName(s.cfg), similarity)
+	return nil
+}
+
+// ModelDrift describes a change of the embedding model's weights since the
+// index was built, or returns "" if none was detected
+func (s *Store) ModelDrift() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.modelDrift
+}
+
+// recordFingerprint stores the current sentinel vector as the index's
+// reference and clears a detected drift. Caller must hold s.mu (or be
+// initializing the schema).
+func (s *Store) recordFingerprint() error {
+	s.modelDrift = ""
+	if len(s.fingerprint) == 0 {
+		return nil
+	}
+	data, err := json.Marshal(s.fingerprint)
+	if err != nil {
+		return fmt.Errorf("failed to encode embedding fingerprint: %w", err)
+	}
+	return s.setConfigValue(fingerprintKey, string(data))
+}
+
+// cosineSimilarity returns the cosine similarity of two equal-length vectors
+func cosineSimilarity(a, b []float32) float64 {
+	var dot, normA, normB float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		normA += float64(a[i]) * float64(a[i])
+		normB += float64(b[i]) * float64(b[i])
+	}
+	if normA == 0 || normB == 0 {
+		return 0
+	}
+	return dot / (math.Sqrt(normA) * math.Sqrt(normB))
+}
@@ -0,0 +1,61 @@
+package store
+
+import (
+	"context"
+	"testing"
+
+	"mcp-semantic-search/config"
+	"mcp-semantic-search/types"
+)
+
+// driftedEmbed stands in for new weights under the same model name: same
+// dimension, but the sentinel lands elsewhere
+func driftedEmbed(ctx context.Context, text string) ([]float32, error) {
+	v, _ := fakeEmbed(ctx, text)
+	if text == fingerprintSentinel {
+		v[0], v[15] = 0, 1
+	}
+	return v, nil
+}
+
+func TestDriftedSentinelFlagsTheIndexAsStale(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.DBPath = t.TempDir()
+	ctx := context.Background()
+
+	open := func(embed func(context.Context, string) ([]float32, error)) *Store {
+		t.Helper()
+		st, err := NewStore(cfg, embed)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return st
+	}
+
+	st := open(fakeEmbed)
+	addChunks(t, st, testChunk("/p/a.go", 0, "Alpha", "func Alpha() {}"))
+	st.Close()
+
+	st = open(driftedEmbed)
+	if st.ModelDrift() == "" {
+		t.Fatal("drifted sentinel not detected")
+	}
+	if _, err := st.Search(ctx, "alpha", "", types.SearchOptions{Limit: 5}); err != nil {
+		t.Fatalf("search blocked by a drift warning: %v", err)
+	}
+	st.Close()
+
+	// The same weights again: nothing to report
+	st = open(fakeEmbed)
+	if drift := st.ModelDrift(); drift != "" {
+		t.Fatalf("ModelDrift = %q with the original weights", drift)
+	}
+	st.Close()
+
+	cfg.ReembedOnModelChange = true
+	st = open(driftedEmbed)
+	defer st.Close()
+	if st.ModelDrift() != "" || !st.NeedsReembed() {
+		t.Fatalf("ModelDrift = %q, NeedsReembed = %v, want a re-embed instead of a warning", st.ModelDrift(), st.NeedsReembed())
+	}
+}
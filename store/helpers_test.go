@@ -0,0 +1,57 @@
+package store
+
+import (
+	"context"
+	"testing"
+
+	"mcp-semantic-search/config"
+	"mcp-semantic-search/types"
+)
+
+// fakeEmbed is a deterministic embedding: texts of equal length get equal vectors
+func fakeEmbed(ctx context.Context, text string) ([]float32, error) {
+	v := make([]float32, 16)
+	v[0] = 1
+	v[len(text)%16] += 1
+	return v, nil
+}
+
+// newTestStore returns a store in a temp directory, after applying configure
+// to the default config
+func newTestStore(t *testing.T, configure func(*config.Config)) *Store {
+	t.Helper()
+	cfg := config.DefaultConfig()
+	cfg.DBPath = t.TempDir()
+	if configure != nil {
+		configure(cfg)
+	}
+
+	st, err := NewStore(cfg, fakeEmbed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { st.Close() })
+	return st
+}
+
+// testChunk returns a function chunk of absPath, numbered i within the file
+func testChunk(absPath string, i int, name, content string) types.Chunk {
+	return types.Chunk{
+		ID:        GenerateChunkID(absPath, i),
+		FilePath:  absPath,
+		Content:   content,
+		Language:  "go",
+		Type:      types.ChunkTypeFunction,
+		Name:      name,
+		StartLine: i*10 + 1,
+		EndLine:   i*10 + 5,
+	}
+}
+
+// addChunks stores chunks or fails the test
+func addChunks(t *testing.T, st *Store, chunks ...types.Chunk) {
+	t.Helper()
+	if err := st.AddChunks(context.Background(), chunks); err != nil {
+		t.Fatal(err)
+	}
+}
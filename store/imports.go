@@ -0,0 +1,76 @@
+package store
+
+import (
+	"context"
+	"fmt"
+
+	"mcp-semantic-search/types"
+)
+
+// replaceFileImports replaces the stored import paths of a file.
+// Caller must hold s.mu and an open transaction.
+func (s *Store) replaceFileImports(absolutePath string, imports []string) error {
+	delStmt, _, err := s.db.Prepare("DELETE FROM file_imports WHERE absolute_path = ?")
+	if err != nil {
+		return err
+	}
+	delStmt.BindText(1, absolutePath)
+	err = delStmt.Exec()
+	delStmt.Close()
+	if err != nil || len(imports) == 0 {
+		return err
+	}
+
+	insStmt, _, err := s.db.Prepare("INSERT OR IGNORE INTO file_imports(absolute_path, import_path) VALUES (?, ?)")
+	if err != nil {
+		return err
+	}
+	defer insStmt.Close()
+
+	for _, imp := range imports {
+		insStmt.BindText(1, absolutePath)
+		insStmt.BindText(2, imp)
+		if err := insStmt.Exec(); err != nil {
+			return err
+		}
+		insStmt.Reset()
+	}
+	return nil
+}
+
+// FileImports returns every indexed file under pathPrefix with its stored
+// import paths, sorted by path. Node paths are absolute.
+func (s *Store) FileImports(ctx context.Context, pathPrefix string) ([]types.DependencyNode, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stmt, _, err := s.db.Prepare(`
+		SELECT DISTINCT c.absolute_path, c.language, i.import_path
+		FROM chunks c
+		LEFT JOIN file_imports i ON i.absolute_path = c.absolute_path
+		WHERE c.absolute_path LIKE ?
+		ORDER BY c.absolute_path, i.import_path
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to prepare imports query: %w", err)
+	}
+	defer stmt.Close()
+
+	stmt.BindText(1, pathPrefix+"%")
+
+	var nodes []types.DependencyNode
+	for stmt.Step() {
+		path := stmt.ColumnText(0)
+		if len(nodes) == 0 || nodes[len(nodes)-1].Path != path {
+			nodes = append(nodes, types.DependencyNode{Path: path, Language: stmt.ColumnText(1)})
+		}
+		if imp := stmt.ColumnText(2); imp != "" {
+			node := &nodes[len(nodes)-1]
+			node.Imports = append(node.Imports, imp)
+		}
+	}
+	if err := stmt.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read imports: %w", err)
+	}
+	return nodes, nil
+}
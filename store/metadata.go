@@ -365,6 +365,18 @@ func (m *Metadata) RemoveProject(path string) error {
 	return m.Save()
 }
 
+// Clear removes every project and deletes the metadata file
+func (m *Metadata) Clear() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.projects = make(map[string]*types.Project)
+	if err := os.Remove(m.cfg.MetadataPath()); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove metadata: %w", err)
+	}
+	return nil
+}
+
 // ListProjects returns all tracked projects
 func (m *Metadata) ListProjects() []*types.Project {
 	m.mu.RLock()
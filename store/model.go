@@ -0,0 +1,70 @@
+package store
+
+import (
+	"fmt"
+	"log"
+
+	"mcp-semantic-search/config"
+	"mcp-semantic-search/types"
+)
+
+// embeddingModelKey is the store_config key holding the model the vectors were built with
+const embeddingModelKey = "embedding_model"
+
+// embeddingModelName identifies the configured embedding model: the Ollama
+// model name, or the model file of the local backend
+func embeddingModelName(cfg *config.Config) string {
+	if cfg.EmbeddingBackend == "local" {
+		return "local:" + cfg.LocalModelPath
+	}
+	return cfg.EmbeddingModel
+}
+
+// checkEmbeddingModel compares the model the index was built with against the
+// configured one. A dimension change already rebuilt the index, but two models
+// can share a dimension, and their vectors must not be mixed: search and
+// indexing are blocked with a model_mismatch error until the index is cleared,
+// or the stored chunks are re-embedded (MCP_REEMBED_ON_MODEL_CHANGE).
+func (s *Store) checkEmbeddingModel() error {
+	stored, err := s.getConfigValue(embeddingModelKey)
+	if err != nil {
+		return err
+	}
+
+	current := embeddingModelName(s.cfg)
+	if stored == current {
+		return nil
+	}
+	// First run, an index from before the model was recorded, or nothing to mix with
+	if stored == "" || !s.hasChunks() {
+		return s.setConfigValue(embeddingModelKey, current)
+	}
+
+	if s.cfg.ReembedOnModelChange {
+		log.Printf("Warning: embedding model changed from %s to %s; stored vectors will be re-embedded", stored, current)
+		s.reembedNeeded = true
+		return nil
+	}
+
+	log.Printf("Error: embedding model changed from %s to %s with the same dimension; search and indexing blocked until the index is cleared or MCP_REEMBED_ON_MODEL_CHANGE=true", stored, current)
+	s.modelErr = types.NewError(types.ErrCodeModelMismatch,
+		fmt.Sprintf("the index was built with embedding model %s but %s is configured; clear the index, set MCP_REEMBED_ON_MODEL_CHANGE=true, or switch back to %s", stored, current, stored), nil)
+	return nil
+}
+
+// ModelError returns the model_mismatch error blocking search and indexing, or nil
+func (s *Store) ModelError() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.modelErr
+}
+
+// recordEmbeddingModel marks every stored vector as built with the configured
+// model (and its current weights) and lifts a model mismatch. Caller must hold s.mu.
+func (s *Store) recordEmbeddingModel() error {
+	if err := s.setConfigValue(embeddingModelKey, embeddingModelName(s.cfg)); err != nil {
+		return err
+	}
+	s.modelErr = nil
+	return s.recordFingerprint()
+}
@@ -0,0 +1,64 @@
+package store
+
+import (
+	"context"
+	"testing"
+
+	"mcp-semantic-search/config"
+	"mcp-semantic-search/types"
+)
+
+func TestSwappingSameDimensionModelsIsDetected(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.DBPath = t.TempDir()
+	cfg.EmbeddingModel = "model-a"
+	ctx := context.Background()
+
+	open := func() *Store {
+		t.Helper()
+		st, err := NewStore(cfg, fakeEmbed)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return st
+	}
+
+	st := open()
+	addChunks(t, st, testChunk("/p/a.go", 0, "Alpha", "func Alpha() {}"))
+	st.Close()
+
+	// Same dimension (fakeEmbed), different model
+	cfg.EmbeddingModel = "model-b"
+	st = open()
+	if types.ErrorCodeOf(st.ModelError()) != types.ErrCodeModelMismatch {
+		t.Fatalf("ModelError = %v, want model_mismatch", st.ModelError())
+	}
+	err := st.AddChunks(ctx, []types.Chunk{testChunk("/p/b.go", 0, "Beta", "func Beta() {}")})
+	if types.ErrorCodeOf(err) != types.ErrCodeModelMismatch {
+		t.Fatalf("AddChunks error = %v, want model_mismatch", err)
+	}
+	if n := st.CountFileChunks(ctx, "/p/b.go"); n != 0 {
+		t.Fatalf("stored %d chunks embedded by the other model", n)
+	}
+	if _, err := st.Search(ctx, "alpha", "", types.SearchOptions{Limit: 5}); types.ErrorCodeOf(err) != types.ErrCodeModelMismatch {
+		t.Fatalf("Search error = %v, want model_mismatch", err)
+	}
+	st.Close()
+
+	// Re-embedding on a model change lifts the block instead
+	cfg.ReembedOnModelChange = true
+	st = open()
+	if st.ModelError() != nil || !st.NeedsReembed() {
+		t.Fatalf("ModelError = %v, NeedsReembed = %v, want a re-embed instead of a block", st.ModelError(), st.NeedsReembed())
+	}
+	st.Close()
+
+	// Switching back to the original model is fine
+	cfg.ReembedOnModelChange = false
+	cfg.EmbeddingModel = "model-a"
+	st = open()
+	defer st.Close()
+	if err := st.ModelError(); err != nil {
+		t.Fatalf("ModelError = %v after switching back to model-a", err)
+	}
+}
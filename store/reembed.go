@@ -0,0 +1,270 @@
+package store
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log"
+
+	"mcp-semantic-search/types"
+
+	sqlite_vec "github.com/asg017/sqlite-vec-go-bindings/ncruces"
+)
+
+// embeddingConfigKey is the store_config key holding the embedding config hash
+const embeddingConfigKey = "embedding_config_hash"
+
+// reembedBatchSize is the number of chunks re-embedded per write transaction
+const reembedBatchSize = 64
+
+// embeddingConfigHash fingerprints how chunk text is turned into embedding input.
+// Any change to FormatForEmbedding (or a configured template) changes the hash.
+func embeddingConfigHash() string {
+	sample := types.FormatForEmbedding("language", "type", "name", "signature", "content")
+	hash := sha256.Sum256([]byte(sample))
+	return hex.EncodeToString(hash[:])
+}
+
+// checkEmbeddingConfig compares the stored embedding config hash with the current one.
+// On first run the hash is stored; on mismatch a re-embed is flagged (see ReembedIfNeeded).
+func (s *Store) checkEmbeddingConfig() error {
+	stored, err := s.getConfigValue(embeddingConfigKey)
+	if err != nil {
+		return err
+	}
+
+	current := embeddingConfigHash()
+	if stored == "" {
+		return s.setConfigValue(embeddingConfigKey, current)
+	}
+
+	if stored != current {
+		log.Printf("Warning: embedding configuration changed since the index was built; stored vectors will be re-embedded")
+		s.reembedNeeded = true
+	}
+	return nil
+}
+
+// NeedsReembed reports whether stored vectors were built with a different embedding configuration
+func (s *Store) NeedsReembed() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.reembedNeeded
+}
+
+// reembedChunk is the stored data needed to rebuild a chunk's embedding input
+type reembedChunk struct {
+	id        string
+	chunkType string
+	name      string
+	language  string
+	signature string
+	content   string
+	text      string // New embedding text
+}
+
+// ReembedIfNeeded regenerates all vectors from stored chunk content when the
+// embedding configuration changed. The new config hash is only recorded once
+// every chunk has been re-embedded, so an interrupted run resumes on next start.
+func (s *Store) ReembedIfNeeded(ctx context.Context) error {
+	if !s.NeedsReembed() {
+		return nil
+	}
+
+	chunks, err := s.loadReembedChunks()
+	if err != nil {
+		return err
+	}
+
+	log.Printf("Re-embedding %d chunks after embedding configuration change...", len(chunks))
+
+	for start := 0; start < len(chunks); start += reembedBatchSize {
+		end := start + reembedBatchSize
+		if end > len(chunks) {
+			end = len(chunks)
+		}
+		if err := s.reembedBatch(ctx, chunks[start:end]); err != nil {
+			return fmt.Errorf("re-embed failed after %d chunks: %w", start, err)
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.recordReembedded(); err != nil {
+		return err
+	}
+
+	log.Printf("Re-embedding complete: %d chunks", len(chunks))
+	return nil
+}
+
+// MarkReembedded records that every stored vector matches the current
+// embedding configuration because the chunks were embedded again by other
+// means (a full reindex), so ReembedIfNeeded has nothing left to do.
+func (s *Store) MarkReembedded() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.reembedNeeded {
+		return nil
+	}
+	return s.recordReembedded()
+}
+
+// recordReembedded stores the current embedding config hash and model and
+// clears reembedNeeded. Caller must hold s.mu.
+func (s *Store) recordReembedded() error {
+	if err := s.setConfigValue(embeddingConfigKey, embeddingConfigHash()); err != nil {
+		return err
+	}
+	if err := s.recordEmbeddingModel(); err != nil {
+		return err
+	}
+	s.reembedNeeded = false
+	return nil
+}
+
+// loadReembedChunks reads every chunk's content and metadata
+func (s *Store) loadReembedChunks() ([]reembedChunk, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stmt, _, err := s.db.Prepare(`SELECT id, chunk_type, name, language, raw_content, signature FROM chunks`)
+	if err != nil {
+		return nil, fmt.Errorf("query failed: %w", err)
+	}
+	defer stmt.Close()
+
+	var chunks []reembedChunk
+	for stmt.Step() {
+		c := reembedChunk{
+			id:        stmt.ColumnText(0),
+			chunkType: stmt.ColumnText(1),
+			name:      stmt.ColumnText(2),
+			language:  stmt.ColumnText(3),
+			content:   stmt.ColumnText(4),
+			signature: stmt.ColumnText(5),
+		}
+		c.text = types.FormatForEmbedding(c.language, c.chunkType, c.name, c.signature, c.content)
+		chunks = append(chunks, c)
+	}
+
+	if err := stmt.Err(); err != nil {
+		return nil, fmt.Errorf("query iteration failed: %w", err)
+	}
+	return chunks, nil
+}
+
+// reembedBatch embeds a batch of chunks and replaces their vectors and embedding text
+func (s *Store) reembedBatch(ctx context.Context, chunks []reembedChunk) error {
+	texts := make([]string, len(chunks))
+	for i, c := range chunks {
+		texts[i] = c.text
+	}
+	embeddings, embedErrs := s.embedTexts(ctx, texts)
+
+	for i, err := range embedErrs {
+		if err != nil {
+			return fmt.Errorf("embedding failed for chunk %s: %w", chunks[i].id, err)
+		}
+	}
+	if err := s.verifyDimensions(embeddings); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.db.Exec("BEGIN TRANSACTION"); err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+
+	for i, c := range chunks {
+		blob, err := sqlite_vec.SerializeFloat32(embeddings[i])
+		if err != nil {
+			s.db.Exec("ROLLBACK")
+			return fmt.Errorf("failed to serialize vector for %s: %w", c.id, err)
+		}
+		if err := s.replaceVector(c, blob); err != nil {
+			s.db.Exec("ROLLBACK")
+			return err
+		}
+	}
+
+	return s.db.Exec("COMMIT")
+}
+
+// replaceVector swaps a chunk's vector and embedding text. Caller must hold s.mu
+// and an open transaction.
+func (s *Store) replaceVector(c reembedChunk, blob []byte) error {
+	chunkID := c.id
+
+	// Update embedding text; skip chunks removed or re-indexed since they were loaded
+	textStmt, _, err := s.db.Prepare(`UPDATE chunks SET embedding_text = ? WHERE id = ? AND raw_content = ?`)
+	if err != nil {
+		return fmt.Errorf("failed to prepare text update: %w", err)
+	}
+	textStmt.BindText(1, c.text)
+	textStmt.BindText(2, chunkID)
+	textStmt.BindText(3, c.content)
+	err = textStmt.Exec()
+	textStmt.Close()
+	if err != nil {
+		return fmt.Errorf("failed to update embedding text for %s: %w", chunkID, err)
+	}
+	if s.db.Changes() == 0 {
+		return nil
+	}
+
+	// Delete old vector if present
+	getStmt, _, err := s.db.Prepare(`SELECT vec_rowid FROM vec_chunk_map WHERE chunk_id = ?`)
+	if err != nil {
+		return fmt.Errorf("failed to prepare get rowid statement: %w", err)
+	}
+	getStmt.BindText(1, chunkID)
+	oldRowid, hasOld := int64(0), false
+	if getStmt.Step() {
+		oldRowid, hasOld = getStmt.ColumnInt64(0), true
+	}
+	getStmt.Close()
+
+	if hasOld {
+		delStmt, _, err := s.db.Prepare(`DELETE FROM vec_chunks WHERE rowid = ?`)
+		if err != nil {
+			return fmt.Errorf("failed to prepare vec delete statement: %w", err)
+		}
+		delStmt.BindInt64(1, oldRowid)
+		err = delStmt.Exec()
+		delStmt.Close()
+		if err != nil {
+			return fmt.Errorf("failed to delete old vector for %s: %w", chunkID, err)
+		}
+	}
+
+	// Insert new vector and update the mapping
+	vecStmt, _, err := s.db.Prepare(`INSERT INTO vec_chunks(embedding) VALUES (?)`)
+	if err != nil {
+		return fmt.Errorf("failed to prepare vec statement: %w", err)
+	}
+	vecStmt.BindBlob(1, blob)
+	err = vecStmt.Exec()
+	vecStmt.Close()
+	if err != nil {
+		return fmt.Errorf("failed to insert vector for %s: %w", chunkID, err)
+	}
+	newRowid := s.db.LastInsertRowID()
+
+	mapStmt, _, err := s.db.Prepare(`INSERT OR REPLACE INTO vec_chunk_map(chunk_id, vec_rowid) VALUES (?, ?)`)
+	if err != nil {
+		return fmt.Errorf("failed to prepare vec map statement: %w", err)
+	}
+	mapStmt.BindText(1, chunkID)
+	mapStmt.BindInt64(2, newRowid)
+	err = mapStmt.Exec()
+	mapStmt.Close()
+	if err != nil {
+		return fmt.Errorf("failed to update vec map for %s: %w", chunkID, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,93 @@
+package store
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+
+	"mcp-semantic-search/config"
+	"mcp-semantic-search/types"
+)
+
+func TestChangingEmbedTemplateReembedsStoredChunks(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.DBPath = t.TempDir()
+	t.Cleanup(func() { types.SetEmbeddingTemplate("") })
+
+	st, err := NewStore(cfg, fakeEmbed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	addChunks(t, st, testChunk("/p/a.go", 0, "Alpha", "func Alpha() {}"))
+	st.Close()
+
+	if err := types.SetEmbeddingTemplate("{name}\n{content}"); err != nil {
+		t.Fatal(err)
+	}
+	var mu sync.Mutex
+	var embedded []string
+	st, err = NewStore(cfg, func(ctx context.Context, text string) ([]float32, error) {
+		mu.Lock()
+		embedded = append(embedded, text)
+		mu.Unlock()
+		return fakeEmbed(ctx, text)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer st.Close()
+
+	if !st.NeedsReembed() {
+		t.Fatal("a new embedding template did not flag a re-embed")
+	}
+	if err := st.ReembedIfNeeded(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if !strings.Contains(strings.Join(embedded, "|"), "Alpha\nfunc Alpha() {}") {
+		t.Fatalf("embedded %q, want the chunk in the new template", embedded)
+	}
+	if st.NeedsReembed() {
+		t.Error("re-embed still flagged after ReembedIfNeeded")
+	}
+}
+
+func TestMarkReembeddedRecordsConfigWithoutReembedding(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.DBPath = t.TempDir()
+	t.Cleanup(func() { types.SetEmbeddingTemplate("") })
+
+	st, err := NewStore(cfg, fakeEmbed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	addChunks(t, st, testChunk("/p/a.go", 0, "Alpha", "func Alpha() {}"))
+	st.Close()
+
+	if err := types.SetEmbeddingTemplate("{name}\n{content}"); err != nil {
+		t.Fatal(err)
+	}
+	st, err = NewStore(cfg, fakeEmbed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !st.NeedsReembed() {
+		t.Fatal("a new embedding template did not flag a re-embed")
+	}
+	if err := st.MarkReembedded(); err != nil {
+		t.Fatal(err)
+	}
+	st.Close()
+
+	// The recorded hash survives a restart
+	st, err = NewStore(cfg, fakeEmbed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer st.Close()
+	if st.NeedsReembed() {
+		t.Error("re-embed flagged again after MarkReembedded")
+	}
+}
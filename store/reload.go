@@ -0,0 +1,40 @@
+package store
+
+import (
+	"fmt"
+	"log"
+)
+
+// Reload closes and reopens the database connection, so that changes written
+// by another process (a CLI run, a second server) are read from disk rather
+// than through this connection's state. The schema checks done at startup run
+// again on the new connection. On failure the old connection stays in use.
+func (s *Store) Reload() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	db, err := openAndVerifyDB(s.dbPath)
+	if err != nil {
+		return fmt.Errorf("failed to reopen database: %w", err)
+	}
+
+	old, oldModelErr, oldDrift, oldReembed := s.db, s.modelErr, s.modelDrift, s.reembedNeeded
+	s.db = db
+	s.modelErr = nil
+	s.reembedNeeded = false
+	if err := s.initSchema(); err != nil {
+		s.db, s.modelErr, s.modelDrift, s.reembedNeeded = old, oldModelErr, oldDrift, oldReembed
+		db.Close()
+		return fmt.Errorf("failed to initialize reopened database: %w", err)
+	}
+
+	for _, hashStore := range s.hashStores {
+		hashStore.db = db
+	}
+	if err := old.Close(); err != nil {
+		log.Printf("Warning: failed to close previous database connection: %v", err)
+	}
+
+	log.Printf("Reloaded database %s", s.dbPath)
+	return nil
+}
@@ -0,0 +1,84 @@
+package store
+
+import (
+	"context"
+	"testing"
+
+	"mcp-semantic-search/config"
+	"mcp-semantic-search/types"
+)
+
+func TestReloadSurfacesChunksAddedByAnotherProcess(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.DBPath = t.TempDir()
+	ctx := context.Background()
+
+	server, err := NewStore(cfg, fakeEmbed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+	hashes := server.NewFileHashStore()
+	addChunks(t, server, testChunk("/p/a.go", 0, "Alpha", "func Alpha() {}"))
+
+	// A second connection stands in for a CLI run writing the same database
+	cli, err := NewStore(cfg, fakeEmbed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	addChunks(t, cli, testChunk("/p/b.go", 0, "Beta", "func Beta() {}"))
+	cli.Close()
+
+	if err := server.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	results, err := server.Search(ctx, "function", "", types.SearchOptions{Limit: 5})
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := make(map[string]bool)
+	for _, r := range results {
+		names[r.Name] = true
+	}
+	if !names["Alpha"] || !names["Beta"] {
+		t.Fatalf("results after reload = %v, want Alpha and Beta", names)
+	}
+
+	// File hash stores share the reopened connection
+	hashes.SetFileHash("/p", "/p/b.go", "abc")
+	if got := hashes.GetFileHash("/p", "/p/b.go"); got != "abc" {
+		t.Fatalf("hash store after reload: hash = %q, want abc", got)
+	}
+}
+
+func TestFailedReloadKeepsConnectionState(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.DBPath = t.TempDir()
+
+	st, err := NewStore(cfg, fakeEmbed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer st.Close()
+	st.mu.Lock()
+	st.reembedNeeded = true
+	st.mu.Unlock()
+
+	// Another process leaves a schema the checks cannot migrate
+	other, err := openAndVerifyDB(st.dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = other.Exec(`DROP TABLE removed_symbols; CREATE VIEW removed_symbols AS SELECT '' AS name, 0 AS removed_at`)
+	other.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := st.Reload(); err == nil {
+		t.Fatal("Reload succeeded over an unmigratable schema")
+	}
+	if !st.NeedsReembed() {
+		t.Error("failed reload dropped the pending re-embed")
+	}
+}
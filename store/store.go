@@ -5,10 +5,13 @@ import (
 	"crypto/sha256"
 	_ "embed"
 	"encoding/hex"
+	"encoding/json"
 	"fmt"
 	"log"
+	"math"
 	"os"
 	"path/filepath"
+	"regexp"
 	"sort"
 	"strconv"
 	"strings"
@@ -22,14 +25,27 @@ import (
 	"github.com/ncruces/go-sqlite3"
 )
 
+// ErrEmptyQuery is returned by Search when the query is empty or whitespace-only
+var ErrEmptyQuery = types.NewError(types.ErrCodeQueryEmpty, "query cannot be empty", nil)
+
+// ErrNotIndexed is returned by Search when no chunks have been indexed yet
+var ErrNotIndexed = types.NewError(types.ErrCodeNotIndexed, "no projects are indexed yet; index a folder first", nil)
+
 // Store manages the SQLite vector database using ncruces driver
 type Store struct {
 	db             *sqlite3.Conn
 	dbPath         string
 	embeddingFunc  types.EmbeddingFunc
+	batchEmbedFunc types.BatchEmbeddingFunc // Embeds several texts per request when set (see SetBatchEmbeddingFunc)
 	cfg            *config.Config
 	mu             sync.Mutex
-	embeddingDim   int // Detected embedding dimension from model
+	embeddingDim   int  // Detected embedding dimension from model
+	reembedNeeded  bool // Embedding configuration changed since vectors were stored
+	dimensionErr   error // Set when the model's output dimension stopped matching embeddingDim
+	modelErr       error // Set when the configured model differs from the one the index was built with
+	fingerprint    []float32 // Sentinel vector from the model at startup (see checkEmbeddingFingerprint)
+	modelDrift     string    // Set when the model's weights changed since the index was built
+	hashStores     []*FileHashStore // Hash stores sharing db; repointed by Reload
 }
 
 // NewStore creates a new Store instance with SQLite + sqlite-vec
@@ -180,8 +196,9 @@ func (s *Store) detectEmbeddingDimension() (int, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
 
-	// Generate a test embedding with a simple string
-	testEmb, err := s.embeddingFunc(ctx, "test")
+	// Embed the fingerprint sentinel; its vector is also compared with the
+	// index's reference (see checkEmbeddingFingerprint)
+	testEmb, err := s.embeddingFunc(ctx, fingerprintSentinel)
 	if err != nil {
 		return 0, fmt.Errorf("failed to generate test embedding: %w", err)
 	}
@@ -190,6 +207,7 @@ func (s *Store) detectEmbeddingDimension() (int, error) {
 		return 0, fmt.Errorf("embedding model returned empty vector")
 	}
 
+	s.fingerprint = testEmb
 	return len(testEmb), nil
 }
 
@@ -245,6 +263,61 @@ func (s *Store) checkAndUpdateDimension() (bool, error) {
 	return false, nil // No change
 }
 
+// getConfigValue reads a value from store_config ("" if unset)
+func (s *Store) getConfigValue(key string) (string, error) {
+	stmt, _, err := s.db.Prepare("SELECT value FROM store_config WHERE key = ?")
+	if err != nil {
+		return "", fmt.Errorf("failed to prepare query: %w", err)
+	}
+	defer stmt.Close()
+
+	stmt.BindText(1, key)
+	if stmt.Step() {
+		return stmt.ColumnText(0), nil
+	}
+	return "", nil
+}
+
+// setConfigValue writes a value to store_config
+func (s *Store) setConfigValue(key, value string) error {
+	stmt, _, err := s.db.Prepare("INSERT OR REPLACE INTO store_config (key, value) VALUES (?, ?)")
+	if err != nil {
+		return fmt.Errorf("failed to prepare insert: %w", err)
+	}
+	defer stmt.Close()
+
+	stmt.BindText(1, key)
+	stmt.BindText(2, value)
+	if err := stmt.Exec(); err != nil {
+		return fmt.Errorf("failed to store %s: %w", key, err)
+	}
+	return nil
+}
+
+// indexVersionKey is the store_config key holding the chunker version the index was built with
+const indexVersionKey = "chunker_version"
+
+// IndexVersion returns the chunker version recorded for the index (0 if never recorded)
+func (s *Store) IndexVersion() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	value, err := s.getConfigValue(indexVersionKey)
+	if err != nil {
+		log.Printf("Warning: failed to read index version: %v", err)
+		return 0
+	}
+	version, _ := strconv.Atoi(value)
+	return version
+}
+
+// SetIndexVersion records the chunker version the index was built with
+func (s *Store) SetIndexVersion(version int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.setConfigValue(indexVersionKey, strconv.Itoa(version))
+}
+
 // initSchema creates the database tables and indexes
 func (s *Store) initSchema() error {
 	// Create store_config table to track settings like embedding dimension
@@ -270,6 +343,8 @@ func (s *Store) initSchema() error {
 		s.db.Exec("DROP TABLE IF EXISTS vec_chunks")
 		s.db.Exec("DROP TABLE IF EXISTS vec_chunk_map")
 		s.db.Exec("DELETE FROM chunks")
+		s.db.Exec("DELETE FROM file_imports")
+		s.db.Exec("DELETE FROM file_contents")
 	}
 
 	// Create chunks table
@@ -288,19 +363,45 @@ func (s *Store) initSchema() error {
 			refs TEXT,
 			is_exported INTEGER NOT NULL DEFAULT 0,
 			is_test INTEGER NOT NULL DEFAULT 0,
-			parent TEXT
+			parent TEXT,
+			mod_time INTEGER NOT NULL DEFAULT 0,
+			content_hash TEXT NOT NULL DEFAULT '',
+			signature TEXT NOT NULL DEFAULT '',
+			metadata TEXT NOT NULL DEFAULT '',
+			indexed_at INTEGER NOT NULL DEFAULT 0
 		)
 	`)
 	if err != nil {
 		return fmt.Errorf("failed to create chunks table: %w", err)
 	}
 
+	// Migrate chunks tables created by older versions
+	if err := s.addColumnIfMissing("chunks", "mod_time", "INTEGER NOT NULL DEFAULT 0"); err != nil {
+		return err
+	}
+	if err := s.addColumnIfMissing("chunks", "content_hash", "TEXT NOT NULL DEFAULT ''"); err != nil {
+		return err
+	}
+	if err := s.addColumnIfMissing("chunks", "signature", "TEXT NOT NULL DEFAULT ''"); err != nil {
+		return err
+	}
+	if err := s.addColumnIfMissing("chunks", "metadata", "TEXT NOT NULL DEFAULT ''"); err != nil {
+		return err
+	}
+	if err := s.addColumnIfMissing("chunks", "indexed_at", "INTEGER NOT NULL DEFAULT 0"); err != nil {
+		return err
+	}
+	if err := s.addColumnIfMissing("chunks", "call_counts", "TEXT NOT NULL DEFAULT ''"); err != nil {
+		return err
+	}
+
 	// Create indexes
 	indexes := []string{
 		"CREATE INDEX IF NOT EXISTS idx_chunks_path ON chunks(absolute_path)",
 		"CREATE INDEX IF NOT EXISTS idx_chunks_language ON chunks(language)",
 		"CREATE INDEX IF NOT EXISTS idx_chunks_type ON chunks(chunk_type)",
 		"CREATE INDEX IF NOT EXISTS idx_chunks_name ON chunks(name)",
+		"CREATE INDEX IF NOT EXISTS idx_chunks_content_hash ON chunks(content_hash)",
 	}
 	for _, idx := range indexes {
 		if err := s.db.Exec(idx); err != nil {
@@ -309,13 +410,7 @@ func (s *Store) initSchema() error {
 	}
 
 	// Create vec0 virtual table for vector search with dynamic dimension
-	// Note: Using rowid instead of TEXT PRIMARY KEY for better compatibility with ncruces driver
-	createVecSQL := fmt.Sprintf(`
-		CREATE VIRTUAL TABLE IF NOT EXISTS vec_chunks USING vec0(
-			embedding float[%d] distance_metric=cosine
-		)
-	`, s.embeddingDim)
-	err = s.db.Exec(createVecSQL)
+	err = s.db.Exec(s.vecTableSQL())
 	if err != nil {
 		return fmt.Errorf("failed to create vec_chunks table: %w", err)
 	}
@@ -362,38 +457,189 @@ func (s *Store) initSchema() error {
 		return fmt.Errorf("failed to create file_hashes index: %w", err)
 	}
 
+	// Import paths per file, for dependency_graph
+	err = s.db.Exec(`
+		CREATE TABLE IF NOT EXISTS file_imports (
+			absolute_path TEXT NOT NULL,
+			import_path TEXT NOT NULL,
+			PRIMARY KEY (absolute_path, import_path)
+		)
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to create file_imports table: %w", err)
+	}
+
+	// Full file contents (MCP_STORE_FULL_FILES), for exact definitions without disk access
+	err = s.db.Exec(`
+		CREATE TABLE IF NOT EXISTS file_contents (
+			absolute_path TEXT PRIMARY KEY,
+			content TEXT NOT NULL
+		)
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to create file_contents table: %w", err)
+	}
+
+	// Track symbol names whose chunks were deleted, to flag stale call references after renames
+	err = s.db.Exec(`
+		CREATE TABLE IF NOT EXISTS removed_symbols (
+			name TEXT PRIMARY KEY,
+			absolute_path TEXT NOT NULL DEFAULT '',
+			removed_at INTEGER NOT NULL
+		)
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to create removed_symbols table: %w", err)
+	}
+	if err := s.addColumnIfMissing("removed_symbols", "absolute_path", "TEXT NOT NULL DEFAULT ''"); err != nil {
+		return err
+	}
+
+	// Search feedback for relevance analysis (MCP_COLLECT_FEEDBACK)
+	err = s.db.Exec(`
+		CREATE TABLE IF NOT EXISTS search_feedback (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			query_hash TEXT NOT NULL,
+			chunk_id TEXT NOT NULL,
+			similarity REAL NOT NULL,
+			clicked INTEGER NOT NULL,
+			created_at INTEGER NOT NULL
+		)
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to create search_feedback table: %w", err)
+	}
+
+	// Detect embedding configuration changes (text format, template)
+	if err := s.checkEmbeddingConfig(); err != nil {
+		return fmt.Errorf("failed to check embedding config: %w", err)
+	}
+
+	// Detect a switch to another model with the same dimension
+	if err := s.checkEmbeddingModel(); err != nil {
+		return fmt.Errorf("failed to check embedding model: %w", err)
+	}
+
+	// Detect new weights behind the same model name
+	if err := s.checkEmbeddingFingerprint(); err != nil {
+		return fmt.Errorf("failed to check embedding fingerprint: %w", err)
+	}
+
+	// Rebuild the vector table if it was created by an incompatible sqlite-vec
+	if err := s.checkVecVersion(); err != nil {
+		return fmt.Errorf("failed to check sqlite-vec version: %w", err)
+	}
+
+	return nil
+}
+
+// vecTableSQL returns the statement creating the vec_chunks table for the current dimension.
+// Note: Using rowid instead of TEXT PRIMARY KEY for better compatibility with ncruces driver
+func (s *Store) vecTableSQL() string {
+	return fmt.Sprintf(`
+		CREATE VIRTUAL TABLE IF NOT EXISTS vec_chunks USING vec0(
+			embedding float[%d] distance_metric=cosine
+		)
+	`, s.embeddingDim)
+}
+
+// addColumnIfMissing adds a column to an existing table if it is not present yet
+func (s *Store) addColumnIfMissing(table, column, definition string) error {
+	stmt, _, err := s.db.Prepare(fmt.Sprintf("PRAGMA table_info(%s)", table))
+	if err != nil {
+		return fmt.Errorf("failed to read %s schema: %w", table, err)
+	}
+	defer stmt.Close()
+
+	for stmt.Step() {
+		if stmt.ColumnText(1) == column {
+			return nil
+		}
+	}
+
+	if err := s.db.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", table, column, definition)); err != nil {
+		return fmt.Errorf("failed to add %s.%s column: %w", table, column, err)
+	}
 	return nil
 }
 
+// SetBatchEmbeddingFunc makes the store embed the chunks of a file (or a
+// re-embed batch) with fn, which sends several texts per request, instead of
+// one embeddingFunc call per chunk. Call before the store is used for indexing.
+func (s *Store) SetBatchEmbeddingFunc(fn types.BatchEmbeddingFunc) {
+	s.batchEmbedFunc = fn
+}
+
+// embedTexts embeds texts, through the batch embedding function when set,
+// else concurrently one per call. errs[i] is set for each text that failed;
+// a failed batch sets it for all of them.
+func (s *Store) embedTexts(ctx context.Context, texts []string) ([][]float32, []error) {
+	embeddings := make([][]float32, len(texts))
+	errs := make([]error, len(texts))
+
+	if s.batchEmbedFunc != nil {
+		batch, err := s.batchEmbedFunc(ctx, texts)
+		if err == nil && len(batch) != len(texts) {
+			err = fmt.Errorf("requested %d embeddings, got %d", len(texts), len(batch))
+		}
+		if err != nil {
+			for i := range errs {
+				errs[i] = err
+			}
+			return embeddings, errs
+		}
+		return batch, errs
+	}
+
+	var wg sync.WaitGroup
+	for i := range texts {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			embeddings[i], errs[i] = s.embeddingFunc(ctx, texts[i])
+		}(i)
+	}
+	wg.Wait()
+	return embeddings, errs
+}
+
 // AddChunks adds chunks to the database with their embeddings
 func (s *Store) AddChunks(ctx context.Context, chunks []types.Chunk) error {
 	if len(chunks) == 0 {
 		return nil
 	}
 
-	s.mu.Lock()
-	defer s.mu.Unlock()
+	// Never mix vectors of two models with the same dimension
+	if err := s.ModelError(); err != nil {
+		return err
+	}
 
-	// Generate embeddings for all chunks
-	embeddings := make([][]float32, len(chunks))
+	// Generate embeddings for all chunks (outside the lock so other files can
+	// embed concurrently; the embedder enforces the global concurrency cap)
 	embeddingTexts := make([]string, len(chunks))
-
 	for i, chunk := range chunks {
-		embeddingText := types.FormatForEmbedding(
+		embeddingTexts[i] = types.FormatForEmbedding(
 			chunk.Language,
 			string(chunk.Type),
 			chunk.Name,
+			chunk.Signature,
 			chunk.Content,
 		)
-		embeddingTexts[i] = embeddingText
+	}
+	embeddings, embedErrs := s.embedTexts(ctx, embeddingTexts)
 
-		emb, err := s.embeddingFunc(ctx, embeddingText)
+	for i, err := range embedErrs {
 		if err != nil {
-			return fmt.Errorf("embedding failed for chunk %s: %w", chunk.ID, err)
+			return fmt.Errorf("embedding failed for chunk %s: %w", chunks[i].ID, err)
 		}
-		embeddings[i] = emb
+	}
+	if err := s.verifyDimensions(embeddings); err != nil {
+		return err
 	}
 
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
 	// Begin transaction
 	err := s.db.Exec("BEGIN IMMEDIATE TRANSACTION")
 	if err != nil {
@@ -404,8 +650,8 @@ func (s *Store) AddChunks(ctx context.Context, chunks []types.Chunk) error {
 	chunkStmt, _, err := s.db.Prepare(`
 		INSERT OR REPLACE INTO chunks
 		(id, absolute_path, chunk_type, name, language, start_line, end_line,
-		 raw_content, embedding_text, calls, refs, is_exported, is_test, parent)
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		 raw_content, embedding_text, calls, refs, is_exported, is_test, parent, mod_time, content_hash, signature, metadata, indexed_at, call_counts)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
 	`)
 	if err != nil {
 		s.db.Exec("ROLLBACK")
@@ -454,6 +700,7 @@ func (s *Store) AddChunks(ctx context.Context, chunks []types.Chunk) error {
 	defer vecMapStmt.Close()
 
 	// Insert chunks and embeddings
+	indexedAt := time.Now().Unix()
 	for i, chunk := range chunks {
 		chunkStmt.BindText(1, chunk.ID)
 		chunkStmt.BindText(2, chunk.FilePath)
@@ -469,6 +716,12 @@ func (s *Store) AddChunks(ctx context.Context, chunks []types.Chunk) error {
 		chunkStmt.BindInt(12, boolToInt(chunk.IsExported))
 		chunkStmt.BindInt(13, boolToInt(chunk.IsTest))
 		chunkStmt.BindText(14, chunk.Parent)
+		chunkStmt.BindInt64(15, chunk.ModTime)
+		chunkStmt.BindText(16, contentHash(chunk.Content))
+		chunkStmt.BindText(17, chunk.Signature)
+		chunkStmt.BindText(18, encodeMetadata(chunk.Metadata))
+		chunkStmt.BindInt64(19, indexedAt)
+		chunkStmt.BindText(20, encodeCallCounts(chunk.CallCounts))
 
 		err = chunkStmt.Exec()
 		if err != nil {
@@ -522,35 +775,210 @@ func (s *Store) AddChunks(ctx context.Context, chunks []types.Chunk) error {
 		vecMapStmt.Reset()
 	}
 
+	// Record each file's imports and content (every chunk of a file carries the same)
+	seenFiles := make(map[string]bool)
+	for _, chunk := range chunks {
+		if seenFiles[chunk.FilePath] {
+			continue
+		}
+		seenFiles[chunk.FilePath] = true
+		if err := s.replaceFileImports(chunk.FilePath, chunk.Imports); err != nil {
+			s.db.Exec("ROLLBACK")
+			return fmt.Errorf("failed to store imports for %s: %w", chunk.FilePath, err)
+		}
+		if err := s.replaceFileContent(chunk.FilePath, chunk.FileContent); err != nil {
+			s.db.Exec("ROLLBACK")
+			return fmt.Errorf("failed to store content of %s: %w", chunk.FilePath, err)
+		}
+	}
+	if err := s.clearRemovedSymbols(chunks); err != nil {
+		s.db.Exec("ROLLBACK")
+		return fmt.Errorf("failed to clear removed symbols: %w", err)
+	}
+
 	return s.db.Exec("COMMIT")
 }
 
 // Search performs semantic search across the database
 func (s *Store) Search(ctx context.Context, query string, cwd string, opts types.SearchOptions) ([]types.SearchResult, error) {
+	// An empty query embeds to an arbitrary vector; reject it instead
+	// of returning meaningless nearest neighbors
+	query = strings.TrimSpace(query)
+	if query == "" {
+		return nil, ErrEmptyQuery
+	}
+
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
+	if !s.hasChunks() {
+		return nil, ErrNotIndexed
+	}
+	if s.modelErr != nil {
+		return nil, s.modelErr
+	}
+
 	limit := opts.Limit
 	if limit <= 0 {
 		limit = 5
 	}
 
+	// Query more results than needed since we'll filter
+	queryLimit := candidateLimit(limit, s.cfg.SearchCandidateMultiplier, opts)
+
+	// With query expansion, also search abbreviation/stem variants of the
+	// query and keep each chunk's best score
+	queries := []string{query}
+	if opts.Expand || s.cfg.QueryExpansion {
+		queries = append(queries, expandQuery(query)...)
+	}
+
+	var results []types.SearchResult
+	exported := make(map[string]bool)     // absolute path + name -> is exported, for tie-breaking
+	contentHashes := make(map[string]string) // absolute path + lines -> content hash, for dedup
+	resultIndex := make(map[string]int)      // absolute path + lines -> index in results
+	for _, q := range queries {
+		candidates, candidateExported, candidateHashes, err := s.searchCandidates(ctx, q, cwd, opts, queryLimit)
+		if err != nil {
+			return nil, err
+		}
+		for key, v := range candidateExported {
+			exported[key] = v
+		}
+		for key, v := range candidateHashes {
+			contentHashes[key] = v
+		}
+		for _, c := range candidates {
+			key := c.AbsolutePath + "\x00" + c.Lines
+			if i, ok := resultIndex[key]; ok {
+				if c.Similarity > results[i].Similarity {
+					results[i] = c
+				}
+				continue
+			}
+			resultIndex[key] = len(results)
+			results = append(results, c)
+		}
+	}
+
+	// Re-sort by boosted similarity. Tied scores (common for many
+	// identically-named symbols like New or Close) are ordered deterministically:
+	// exported first, then by caller count, then by location, so equal scores
+	// never depend on the order the vector index returned candidates in.
+	names := make([]string, 0, len(results))
+	seenNames := make(map[string]bool)
+	for _, r := range results {
+		if r.Name != "" && !seenNames[r.Name] {
+			seenNames[r.Name] = true
+			names = append(names, r.Name)
+		}
+	}
+	callerCounts := s.countCallers(ctx, names, cwd) // name -> direct callers
+	sort.SliceStable(results, func(i, j int) bool {
+		if !types.SimilarityTied(results[i].Similarity, results[j].Similarity) {
+			return results[i].Similarity > results[j].Similarity
+		}
+		ei := exported[results[i].AbsolutePath+"\x00"+results[i].Name]
+		ej := exported[results[j].AbsolutePath+"\x00"+results[j].Name]
+		if ei != ej {
+			return ei
+		}
+		if ci, cj := callerCounts[results[i].Name], callerCounts[results[j].Name]; ci != cj {
+			return ci > cj
+		}
+		return locationLess(results[i], results[j])
+	})
+
+	// Collapse content-identical chunks, keeping the best-ranked copy
+	if s.cfg.DedupChunks {
+		seen := make(map[string]bool)
+		deduped := results[:0]
+		for _, r := range results {
+			hash := contentHashes[r.AbsolutePath+"\x00"+r.Lines]
+			if hash != "" && seen[hash] {
+				continue
+			}
+			seen[hash] = true
+			deduped = append(deduped, r)
+		}
+		results = deduped
+	}
+
+	// Recency ordering: keep results close to the best match, newest files first
+	if opts.Sort == "recent" {
+		results = sortByRecency(results)
+	}
+
+	// Trim to limit
+	if len(results) > limit {
+		results = results[:limit]
+	}
+
+	// Reorder the selected results for presentation
+	orderResults(results, opts.OrderBy)
+
+	// Give nameless line-based blocks some context
+	for i := range results {
+		if results[i].Name == "" && results[i].ChunkType == string(types.ChunkTypeBlock) {
+			results[i].Enclosing = s.enclosingSymbol(results[i].AbsolutePath, results[i].StartLine)
+		}
+	}
+
+	// Count copies across the whole index, not just the candidate pool
+	if s.cfg.DedupChunks {
+		hashes := make([]string, 0, len(results))
+		for _, r := range results {
+			if hash := contentHashes[r.AbsolutePath+"\x00"+r.Lines]; hash != "" {
+				hashes = append(hashes, hash)
+			}
+		}
+		counts := s.countContentHashes(hashes)
+		for i := range results {
+			if hash := contentHashes[results[i].AbsolutePath+"\x00"+results[i].Lines]; hash != "" && counts[hash] > 0 {
+				results[i].Duplicates = counts[hash] - 1
+			}
+		}
+	}
+
+	markStale(results)
+
+	return results, nil
+}
+
+// markStale flags results whose file changed on disk since it was indexed
+// (modification time differs from the indexed one). Files that no longer
+// exist, such as content indexed from memory, are not flagged.
+func markStale(results []types.SearchResult) {
+	modTimes := make(map[string]int64)
+	for i := range results {
+		if results[i].ModTime == 0 {
+			continue // Indexed by a version that did not record it
+		}
+		modTime, ok := modTimes[results[i].AbsolutePath]
+		if !ok {
+			if info, err := os.Stat(results[i].AbsolutePath); err == nil {
+				modTime = info.ModTime().Unix()
+			}
+			modTimes[results[i].AbsolutePath] = modTime
+		}
+		results[i].Stale = modTime != 0 && modTime != results[i].ModTime
+	}
+}
+
+// searchCandidates runs one vector search for query and returns the filtered,
+// keyword-boosted candidates (unsorted), with exported flags keyed by absolute
+// path + name and content hashes keyed by absolute path + lines. Caller must hold s.mu.
+func (s *Store) searchCandidates(ctx context.Context, query string, cwd string, opts types.SearchOptions, queryLimit int) ([]types.SearchResult, map[string]bool, map[string]string, error) {
 	// Generate query embedding
-	queryEmb, err := s.embeddingFunc(ctx, query)
+	queryEmb, err := s.embeddingFunc(types.WithQueryEmbedding(ctx), query)
 	if err != nil {
-		return nil, fmt.Errorf("failed to embed query: %w", err)
+		return nil, nil, nil, fmt.Errorf("failed to embed query: %w", err)
 	}
 
 	// Serialize query vector
 	queryBlob, err := sqlite_vec.SerializeFloat32(queryEmb)
 	if err != nil {
-		return nil, fmt.Errorf("failed to serialize query vector: %w", err)
-	}
-
-	// Query more results than needed since we'll filter
-	queryLimit := limit * 5
-	if queryLimit < 50 {
-		queryLimit = 50
+		return nil, nil, nil, fmt.Errorf("failed to serialize query vector: %w", err)
 	}
 
 	// Prepare query terms for keyword boosting
@@ -580,6 +1008,18 @@ func (s *Store) Search(ctx context.Context, query string, cwd string, opts types
 		}
 	}
 
+	// Resolve explicit file list to absolute paths
+	var pathSet map[string]bool
+	if len(opts.Paths) > 0 {
+		pathSet = make(map[string]bool, len(opts.Paths))
+		for _, p := range opts.Paths {
+			if !filepath.IsAbs(p) {
+				p = filepath.Join(cwd, p)
+			}
+			pathSet[filepath.Clean(p)] = true
+		}
+	}
+
 	// Normalize filters
 	languageFilter := strings.ToLower(opts.Language)
 	chunkTypeFilter := strings.ToLower(opts.ChunkType)
@@ -590,7 +1030,7 @@ func (s *Store) Search(ctx context.Context, query string, cwd string, opts types
 			c.id, c.absolute_path, c.chunk_type, c.name, c.language,
 			c.start_line, c.end_line, c.raw_content, c.calls, c.refs,
 			c.is_exported, c.is_test, c.parent,
-			v.distance
+			v.distance, c.mod_time, c.content_hash, c.signature, c.metadata, c.indexed_at
 		FROM vec_chunks v
 		JOIN vec_chunk_map m ON m.vec_rowid = v.rowid
 		JOIN chunks c ON c.id = m.chunk_id
@@ -599,14 +1039,18 @@ func (s *Store) Search(ctx context.Context, query string, cwd string, opts types
 		ORDER BY v.distance
 	`)
 	if err != nil {
-		return nil, fmt.Errorf("failed to prepare query: %w", err)
+		return nil, nil, nil, fmt.Errorf("failed to prepare query: %w", err)
 	}
 	defer stmt.Close()
 
 	stmt.BindBlob(1, queryBlob)
 	stmt.BindInt(2, queryLimit)
 
-	results := make([]types.SearchResult, 0, limit)
+	results := make([]types.SearchResult, 0, queryLimit)
+	exported := make(map[string]bool)     // absolute path + name -> is exported, for tie-breaking
+	contentHashes := make(map[string]string) // absolute path + lines -> content hash, for dedup
+	boosts := make([]float32, 0, queryLimit) // keyword boost per result, parallel to results
+	var topSimilarity float32                // best unboosted similarity among candidates
 
 	for stmt.Step() {
 		id := stmt.ColumnText(0)
@@ -623,12 +1067,15 @@ func (s *Store) Search(ctx context.Context, query string, cwd string, opts types
 		isTest := stmt.ColumnInt(11)
 		parent := stmt.ColumnText(12)
 		distance := stmt.ColumnFloat(13)
+		modTime := stmt.ColumnInt64(14)
+		hash := stmt.ColumnText(15)
+		signature := stmt.ColumnText(16)
+		metadata := decodeMetadata(stmt.ColumnText(17))
+		indexedAt := stmt.ColumnInt64(18)
 
 		// Suppress unused variable warnings
 		_ = id
-		_ = calls
 		_ = refs
-		_ = isExported
 		_ = isTest
 		_ = parent
 
@@ -650,6 +1097,11 @@ func (s *Store) Search(ctx context.Context, query string, cwd string, opts types
 			continue
 		}
 
+		// Apply comments_only filter
+		if opts.CommentsOnly && (chunkType != string(types.ChunkTypeBlock) || name != types.DocChunkName) {
+			continue
+		}
+
 		// Apply chunk type filter
 		if chunkTypeFilter != "" && chunkTypeFilter != "all" {
 			if strings.ToLower(chunkType) != chunkTypeFilter {
@@ -657,6 +1109,18 @@ func (s *Store) Search(ctx context.Context, query string, cwd string, opts types
 			}
 		}
 
+		// Apply complexity filter; chunks without a complexity (non-functions) never match
+		if opts.MinComplexity > 0 {
+			if complexity, _ := strconv.Atoi(metadata[types.MetadataComplexity]); complexity < opts.MinComplexity {
+				continue
+			}
+		}
+
+		// Apply calls filter: only chunks calling the given symbol
+		if opts.Calls != "" && !s.callsInclude(calls, opts.Calls, language) {
+			continue
+		}
+
 		// Apply path filter
 		if absFilterPath != "" || isGlobPattern {
 			cleanAbsPath := filepath.Clean(absolutePath)
@@ -675,6 +1139,11 @@ func (s *Store) Search(ctx context.Context, query string, cwd string, opts types
 			}
 		}
 
+		// Apply explicit file list filter
+		if pathSet != nil && !pathSet[filepath.Clean(absolutePath)] {
+			continue
+		}
+
 		// Convert to relative path from cwd
 		relativePath := absolutePath
 		if cwd != "" {
@@ -684,15 +1153,15 @@ func (s *Store) Search(ctx context.Context, query string, cwd string, opts types
 			}
 
 			// Skip files outside cwd unless filter specified
-			if absFilterPath == "" && !isGlobPattern && strings.HasPrefix(rel, "..") {
+			if absFilterPath == "" && !isGlobPattern && pathSet == nil && strings.HasPrefix(rel, "..") {
 				continue
 			}
 
 			relativePath = "./" + filepath.ToSlash(rel)
 		}
 
-		// Apply keyword boosting
-		boostedSimilarity := similarity
+		// Compute keyword boost; applied after the loop once the top similarity is known
+		var boost float32
 		if len(queryTerms) > 0 && name != "" {
 			nameLower := strings.ToLower(name)
 			matchCount := 0
@@ -702,12 +1171,25 @@ func (s *Store) Search(ctx context.Context, query string, cwd string, opts types
 				}
 			}
 			if matchCount > 0 {
-				boost := float32(matchCount) / float32(len(queryTerms)) * 0.3
-				boostedSimilarity = similarity + boost
-				if boostedSimilarity > 1.0 {
-					boostedSimilarity = 1.0
+				boost = float32(matchCount) / float32(len(queryTerms)) * 0.3
+			}
+		}
+
+		// Smaller boost for query terms found in parameter/return types ("takes a context")
+		if len(queryTerms) > 0 && signature != "" {
+			signatureLower := strings.ToLower(signature)
+			nameLower := strings.ToLower(name)
+			matchCount := 0
+			for _, term := range queryTerms {
+				// Skip short words ("a", "to") that appear in almost any signature,
+				// and the symbol's own name, which the signature also contains
+				if len(term) >= 3 && strings.Contains(signatureLower, term) && !strings.Contains(nameLower, term) {
+					matchCount++
 				}
 			}
+			if matchCount > 0 {
+				boost += float32(matchCount) / float32(len(queryTerms)) * 0.1
+			}
 		}
 
 		result := types.SearchResult{
@@ -716,48 +1198,303 @@ func (s *Store) Search(ctx context.Context, query string, cwd string, opts types
 			ChunkType:    chunkType,
 			Name:         name,
 			Lines:        fmt.Sprintf("%d-%d", startLine, endLine),
+			StartLine:    startLine,
+			EndLine:      endLine,
 			Content:      rawContent,
-			Similarity:   boostedSimilarity,
+			Similarity:   similarity,
 			Language:     language,
+			Signature:    signature,
+			ModTime:      modTime,
+			LastIndexed:  indexedAt,
+			Metadata:     metadata,
+		}
+		if opts.Debug {
+			result.RawDistance = &distance
 		}
 		results = append(results, result)
+		boosts = append(boosts, boost)
+		if similarity > topSimilarity {
+			topSimilarity = similarity
+		}
+		if isExported == 1 {
+			exported[result.AbsolutePath+"\x00"+result.Name] = true
+		}
+		if hash != "" {
+			contentHashes[result.AbsolutePath+"\x00"+result.Lines] = hash
+		}
 	}
 
 	if err := stmt.Err(); err != nil {
-		return nil, fmt.Errorf("query iteration failed: %w", err)
+		return nil, nil, nil, fmt.Errorf("query iteration failed: %w", err)
 	}
 
-	// Re-sort by boosted similarity
-	sort.Slice(results, func(i, j int) bool {
-		return results[i].Similarity > results[j].Similarity
-	})
-
-	// Trim to limit
-	if len(results) > limit {
-		results = results[:limit]
+	// Apply keyword boosts only to candidates within the band of the top
+	// semantic match, so a name hit reorders near-ties but can't pull a far
+	// weaker match above a strong one
+	for i := range results {
+		if boosts[i] == 0 {
+			continue
+		}
+		if s.cfg.BoostBand > 0 && results[i].Similarity < topSimilarity-s.cfg.BoostBand {
+			continue
+		}
+		results[i].Similarity = min(results[i].Similarity+boosts[i], 1.0)
 	}
 
-	return results, nil
+	return results, exported, contentHashes, nil
 }
 
-// DeleteFileChunks removes all chunks for a specific file
-func (s *Store) DeleteFileChunks(ctx context.Context, absolutePath string) error {
-	s.mu.Lock()
-	defer s.mu.Unlock()
-
-	err := s.db.Exec("BEGIN TRANSACTION")
+// enclosingSymbol returns the name of the nearest named, non-block chunk that
+// starts at or before line in the same file ("" if none). Caller must hold s.mu.
+func (s *Store) enclosingSymbol(absolutePath string, line int) string {
+	stmt, _, err := s.db.Prepare(`
+		SELECT name, parent FROM chunks
+		WHERE absolute_path = ? AND start_line <= ? AND name != '' AND chunk_type != ?
+		ORDER BY start_line DESC
+		LIMIT 1
+	`)
 	if err != nil {
-		return err
+		return ""
 	}
+	defer stmt.Close()
 
-	// Get chunk IDs for this file
-	stmt, _, err := s.db.Prepare("SELECT id FROM chunks WHERE absolute_path = ?")
-	if err != nil {
-		s.db.Exec("ROLLBACK")
-		return err
+	stmt.BindText(1, absolutePath)
+	stmt.BindInt(2, line)
+	stmt.BindText(3, string(types.ChunkTypeBlock))
+	if !stmt.Step() {
+		return ""
 	}
 
-	stmt.BindText(1, absolutePath)
+	if parent := stmt.ColumnText(1); parent != "" {
+		return parent + "." + stmt.ColumnText(0)
+	}
+	return stmt.ColumnText(0)
+}
+
+// countContentHashes returns the number of chunks with each of the given
+// content hashes, in one query. Caller must hold s.mu.
+func (s *Store) countContentHashes(hashes []string) map[string]int {
+	counts := make(map[string]int)
+	if len(hashes) == 0 {
+		return counts
+	}
+	list, err := json.Marshal(hashes)
+	if err != nil {
+		return counts
+	}
+
+	stmt, _, err := s.db.Prepare(`
+		SELECT content_hash, COUNT(*)
+		FROM chunks
+		WHERE content_hash IN (SELECT value FROM json_each(?))
+		GROUP BY content_hash
+	`)
+	if err != nil {
+		return counts
+	}
+	defer stmt.Close()
+
+	stmt.BindText(1, string(list))
+	for stmt.Step() {
+		counts[stmt.ColumnText(0)] = stmt.ColumnInt(1)
+	}
+	return counts
+}
+
+// contentHash returns the hex SHA256 of chunk content, used to detect identical chunks
+func contentHash(content string) string {
+	hash := sha256.Sum256([]byte(content))
+	return hex.EncodeToString(hash[:])
+}
+
+// encodeMetadata serializes chunk metadata as JSON for the metadata column
+// ("" when empty)
+func encodeMetadata(metadata map[string]string) string {
+	if len(metadata) == 0 {
+		return ""
+	}
+	data, err := json.Marshal(metadata)
+	if err != nil {
+		return ""
+	}
+	return string(data)
+}
+
+// encodeCallCounts serializes a chunk's repeated call counts for the call_counts column
+func encodeCallCounts(counts map[string]int) string {
+	if len(counts) == 0 {
+		return ""
+	}
+	data, err := json.Marshal(counts)
+	if err != nil {
+		return ""
+	}
+	return string(data)
+}
+
+// decodeCallCounts parses the call_counts column; nil when empty or malformed
+func decodeCallCounts(data string) map[string]int {
+	if data == "" {
+		return nil
+	}
+	var counts map[string]int
+	if err := json.Unmarshal([]byte(data), &counts); err != nil {
+		return nil
+	}
+	return counts
+}
+
+// decodeMetadata parses the metadata column; nil when empty or malformed
+func decodeMetadata(data string) map[string]string {
+	if data == "" {
+		return nil
+	}
+	var metadata map[string]string
+	if err := json.Unmarshal([]byte(data), &metadata); err != nil {
+		return nil
+	}
+	return metadata
+}
+
+// orderResults sorts results by name, path or line; "similarity" or "" keeps ranking order
+func orderResults(results []types.SearchResult, orderBy string) {
+	var less func(a, b types.SearchResult) bool
+	switch orderBy {
+	case "name":
+		less = func(a, b types.SearchResult) bool { return a.Name < b.Name }
+	case "path":
+		less = func(a, b types.SearchResult) bool {
+			if a.FilePath != b.FilePath {
+				return a.FilePath < b.FilePath
+			}
+			return a.StartLine < b.StartLine
+		}
+	case "line":
+		less = func(a, b types.SearchResult) bool {
+			return a.StartLine < b.StartLine
+		}
+	default:
+		return
+	}
+
+	sort.SliceStable(results, func(i, j int) bool {
+		return less(results[i], results[j])
+	})
+}
+
+// locationLess orders results by absolute path, then line range and name,
+// the final tiebreaker for results with equal scores
+func locationLess(a, b types.SearchResult) bool {
+	if a.AbsolutePath != b.AbsolutePath {
+		return a.AbsolutePath < b.AbsolutePath
+	}
+	if a.StartLine != b.StartLine {
+		return a.StartLine < b.StartLine
+	}
+	if a.EndLine != b.EndLine {
+		return a.EndLine < b.EndLine
+	}
+	return a.Name < b.Name
+}
+
+// maxSearchCandidates bounds the vector query size regardless of filters
+const maxSearchCandidates = 2000
+
+// vecMaxK is the largest k sqlite-vec accepts in a KNN query
+const vecMaxK = 4096
+
+// candidateLimit returns how many vector candidates to fetch for a search.
+// Filters are applied after the vector query, so each active filter widens the
+// candidate pool to keep the limit fillable.
+func candidateLimit(limit, multiplier int, opts types.SearchOptions) int {
+	if multiplier < 1 {
+		multiplier = 5
+	}
+
+	activeFilters := 0
+	if opts.Path != "" {
+		activeFilters++
+	}
+	if len(opts.Paths) > 0 {
+		activeFilters++
+	}
+	if opts.Language != "" {
+		activeFilters++
+	}
+	if opts.ChunkType != "" && strings.ToLower(opts.ChunkType) != "all" {
+		activeFilters++
+	}
+	if opts.CodeOnly {
+		activeFilters++
+	}
+	if opts.MinSimilarity > 0 {
+		activeFilters++
+	}
+	if opts.CommentsOnly {
+		activeFilters++
+	}
+	if opts.MinComplexity > 0 {
+		activeFilters++
+	}
+	if opts.Calls != "" {
+		activeFilters++
+	}
+
+	queryLimit := limit * multiplier * (1 + activeFilters)
+	if queryLimit < 50 {
+		queryLimit = 50
+	}
+	if queryLimit > maxSearchCandidates {
+		// Large limits (MCP_MAX_SEARCH_LIMIT) keep at least the unfiltered over-fetch
+		queryLimit = min(max(maxSearchCandidates, limit*multiplier), vecMaxK)
+	}
+	return queryLimit
+}
+
+// recencySimilarityWindow is how far below the best match a result may score
+// and still be considered for recency ordering
+const recencySimilarityWindow = 0.15
+
+// sortByRecency drops results far below the best similarity and orders the
+// rest by file modification time (newest first). Results must be sorted by
+// similarity on input.
+func sortByRecency(results []types.SearchResult) []types.SearchResult {
+	if len(results) == 0 {
+		return results
+	}
+
+	floor := results[0].Similarity - recencySimilarityWindow
+	kept := results[:0]
+	for _, r := range results {
+		if r.Similarity >= floor {
+			kept = append(kept, r)
+		}
+	}
+
+	sort.SliceStable(kept, func(i, j int) bool {
+		return kept[i].ModTime > kept[j].ModTime
+	})
+	return kept
+}
+
+// DeleteFileChunks removes all chunks for a specific file
+func (s *Store) DeleteFileChunks(ctx context.Context, absolutePath string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	err := s.db.Exec("BEGIN TRANSACTION")
+	if err != nil {
+		return err
+	}
+
+	// Get chunk IDs for this file
+	stmt, _, err := s.db.Prepare("SELECT id FROM chunks WHERE absolute_path = ?")
+	if err != nil {
+		s.db.Exec("ROLLBACK")
+		return err
+	}
+
+	stmt.BindText(1, absolutePath)
 
 	var ids []string
 	for stmt.Step() {
@@ -811,6 +1548,22 @@ func (s *Store) DeleteFileChunks(ctx context.Context, absolutePath string) error
 	delVecStmt.Close()
 	delMapStmt.Close()
 
+	// Remember the names defined in this file; if they are not re-added,
+	// callers still referencing them are flagged as stale
+	if err := s.recordRemovedSymbols(absolutePath); err != nil {
+		s.db.Exec("ROLLBACK")
+		return err
+	}
+
+	if err := s.replaceFileImports(absolutePath, nil); err != nil {
+		s.db.Exec("ROLLBACK")
+		return err
+	}
+	if err := s.replaceFileContent(absolutePath, ""); err != nil {
+		s.db.Exec("ROLLBACK")
+		return err
+	}
+
 	// Delete from chunks
 	delChunkStmt, _, err := s.db.Prepare("DELETE FROM chunks WHERE absolute_path = ?")
 	if err != nil {
@@ -828,6 +1581,34 @@ func (s *Store) DeleteFileChunks(ctx context.Context, absolutePath string) error
 	return s.db.Exec("COMMIT")
 }
 
+// CountFileChunks returns the number of chunks stored for a specific file
+func (s *Store) CountFileChunks(ctx context.Context, absolutePath string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stmt, _, err := s.db.Prepare("SELECT COUNT(*) FROM chunks WHERE absolute_path = ?")
+	if err != nil {
+		return 0
+	}
+	defer stmt.Close()
+
+	stmt.BindText(1, absolutePath)
+	if stmt.Step() {
+		return stmt.ColumnInt(0)
+	}
+	return 0
+}
+
+// hasChunks reports whether any chunk is stored. Caller must hold s.mu.
+func (s *Store) hasChunks() bool {
+	stmt, _, err := s.db.Prepare("SELECT 1 FROM chunks LIMIT 1")
+	if err != nil {
+		return true // Let the search itself surface the database error
+	}
+	defer stmt.Close()
+	return stmt.Step()
+}
+
 // GetTotalChunkCount returns the total number of chunks in the database
 func (s *Store) GetTotalChunkCount() int {
 	if s == nil || s.db == nil {
@@ -850,12 +1631,124 @@ func (s *Store) GetTotalChunkCount() int {
 	return 0
 }
 
+// CountChunksBy returns chunk counts grouped by a chunks column ("language" or "chunk_type")
+func (s *Store) CountChunksBy(column string) map[string]int {
+	if column != "language" && column != "chunk_type" {
+		return nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stmt, _, err := s.db.Prepare("SELECT " + column + ", COUNT(*) FROM chunks GROUP BY " + column)
+	if err != nil {
+		log.Printf("CountChunksBy error: %v", err)
+		return nil
+	}
+	defer stmt.Close()
+
+	counts := make(map[string]int)
+	for stmt.Step() {
+		counts[stmt.ColumnText(0)] = stmt.ColumnInt(1)
+	}
+	return counts
+}
+
+// caseInsensitiveLanguages lists languages whose function and method names are
+// case-insensitive, so calls written in another case still resolve there
+var caseInsensitiveLanguages = map[string]bool{
+	"php": true,
+	"sql": true,
+}
+
+// qualifierSeparators rewrites the qualified-name separators of supported
+// languages ("ns::f", "$obj->f", "Ns\f", "obj:f") to "."
+var qualifierSeparators = strings.NewReplacer("::", ".", "->", ".", "\\", ".", ":", ".")
+
+// terminalSegment returns the last segment of a possibly qualified name ("a.b.Method" -> "Method")
+func terminalSegment(name string) string {
+	name = qualifierSeparators.Replace(name)
+	return name[strings.LastIndexByte(name, '.')+1:]
+}
+
+// symbolFilter returns a SQL condition that prefilters rows whose column
+// contains the terminal segment of symbolName, and the value to bind for it
+// (as parameter 1). The comparison is case-sensitive per
+// cfg.SymbolCaseSensitive, except in caseInsensitiveLanguages, to agree with
+// symbolMatches; the table must have a language column.
+func (s *Store) symbolFilter(column, symbolName string) (string, string) {
+	insensitive := "instr(lower(" + column + "), lower(?1)) > 0"
+	if !s.cfg.SymbolCaseSensitive {
+		return insensitive, terminalSegment(symbolName)
+	}
+
+	languages := make([]string, 0, len(caseInsensitiveLanguages))
+	for language := range caseInsensitiveLanguages {
+		languages = append(languages, "'"+language+"'")
+	}
+	sort.Strings(languages)
+	return "(instr(" + column + ", ?1) > 0 OR (language IN (" + strings.Join(languages, ", ") + ") AND " + insensitive + "))",
+		terminalSegment(symbolName)
+}
+
+// symbolEqual compares two symbol names per cfg.SymbolCaseSensitive, ignoring
+// case for languages in caseInsensitiveLanguages
+func (s *Store) symbolEqual(a, b, language string) bool {
+	if s.cfg.SymbolCaseSensitive && !caseInsensitiveLanguages[language] {
+		return a == b
+	}
+	return strings.EqualFold(a, b)
+}
+
+// symbolMatches reports whether name, found in code of the given language, is
+// symbolName or a qualified form of it: "pkg.Name", "pkg.Sub.Name", "ns::Name"
+// and "$obj->Name" all match "Name", and "a.Store.Search" matches "Store.Search"
+func (s *Store) symbolMatches(name, symbolName, language string) bool {
+	if s.symbolEqual(name, symbolName, language) {
+		return true
+	}
+	name, symbolName = qualifierSeparators.Replace(name), qualifierSeparators.Replace(symbolName)
+	if len(name) <= len(symbolName) || name[len(name)-len(symbolName)-1] != '.' {
+		return false
+	}
+	return s.symbolEqual(name[len(name)-len(symbolName):], symbolName, language)
+}
+
+// callsInclude reports whether a comma-separated calls column contains a call
+// matching symbolName (see symbolMatches)
+func (s *Store) callsInclude(calls, symbolName, language string) bool {
+	for _, call := range strings.Split(calls, ",") {
+		if s.symbolMatches(strings.TrimSpace(call), symbolName, language) {
+			return true
+		}
+	}
+	return false
+}
+
+// callSites counts the calls to symbolName among a calls column, using the
+// chunk's repeated call counts; 0 if symbolName is not called
+func (s *Store) callSites(calls string, counts map[string]int, symbolName, language string) int {
+	sites := 0
+	for _, call := range strings.Split(calls, ",") {
+		call = strings.TrimSpace(call)
+		if s.symbolMatches(call, symbolName, language) {
+			sites += max(counts[call], 1)
+		}
+	}
+	return sites
+}
+
 // FindCallers finds all chunks that call a specific symbol
 // If pathPrefix is not empty, only returns callers from files within that path (project scoping)
 func (s *Store) FindCallers(ctx context.Context, symbolName string, maxResults int, pathPrefix string) ([]types.CallerInfo, error) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
+	return s.findCallers(ctx, symbolName, maxResults, pathPrefix, "")
+}
 
+// findCallers is FindCallers for callers holding s.mu. A non-empty where
+// further filters the calling chunks, e.g. "is_test = 1".
+func (s *Store) findCallers(ctx context.Context, symbolName string, maxResults int, pathPrefix, where string) ([]types.CallerInfo, error) {
 	if maxResults <= 0 {
 		maxResults = 50
 	}
@@ -863,32 +1756,36 @@ func (s *Store) FindCallers(ctx context.Context, symbolName string, maxResults i
 	var stmt *sqlite3.Stmt
 	var err error
 
+	filter, pattern := s.symbolFilter("calls", symbolName)
+	if where != "" {
+		filter += " AND " + where
+	}
 	if pathPrefix != "" {
 		// Scope to specific project/folder
 		stmt, _, err = s.db.Prepare(`
-			SELECT name, absolute_path, start_line, language, is_test, parent, calls
+			SELECT name, absolute_path, start_line, language, is_test, parent, calls, call_counts
 			FROM chunks
-			WHERE calls LIKE ? AND absolute_path LIKE ?
+			WHERE ` + filter + ` AND absolute_path LIKE ?
 			LIMIT ?
 		`)
 		if err != nil {
 			return nil, fmt.Errorf("query failed: %w", err)
 		}
-		stmt.BindText(1, "%"+symbolName+"%")
+		stmt.BindText(1, pattern)
 		stmt.BindText(2, pathPrefix+"%")
 		stmt.BindInt(3, maxResults*3)
 	} else {
 		// Search all indexed code
 		stmt, _, err = s.db.Prepare(`
-			SELECT name, absolute_path, start_line, language, is_test, parent, calls
+			SELECT name, absolute_path, start_line, language, is_test, parent, calls, call_counts
 			FROM chunks
-			WHERE calls LIKE ?
+			WHERE ` + filter + `
 			LIMIT ?
 		`)
 		if err != nil {
 			return nil, fmt.Errorf("query failed: %w", err)
 		}
-		stmt.BindText(1, "%"+symbolName+"%")
+		stmt.BindText(1, pattern)
 		stmt.BindInt(2, maxResults*3)
 	}
 	defer stmt.Close()
@@ -910,20 +1807,14 @@ func (s *Store) FindCallers(ctx context.Context, symbolName string, maxResults i
 			continue
 		}
 
-		callList := strings.Split(calls, ",")
-		found := false
-		for _, call := range callList {
-			call = strings.TrimSpace(call)
-			if call == symbolName || strings.HasSuffix(call, "."+symbolName) {
-				found = true
-				break
-			}
-		}
-
-		if !found {
+		sites := s.callSites(calls, decodeCallCounts(stmt.ColumnText(7)), symbolName, language)
+		if sites == 0 {
 			continue
 		}
 
+		// Every part of a split symbol carries the whole symbol's calls and
+		// call counts, so the parts are reported once, as the symbol
+		name, _, _ = strings.Cut(name, " (part ")
 		if seen[name] {
 			continue
 		}
@@ -936,6 +1827,7 @@ func (s *Store) FindCallers(ctx context.Context, symbolName string, maxResults i
 			Language: language,
 			IsTest:   isTest == 1,
 			Parent:   parent,
+			Calls:    sites,
 		})
 
 		if len(callers) >= maxResults {
@@ -946,9 +1838,73 @@ func (s *Store) FindCallers(ctx context.Context, symbolName string, maxResults i
 	return callers, nil
 }
 
-// FindCallersDeep finds callers up to N levels deep using the chunks table
+// countCallers returns the number of distinct direct callers of each of names
+// within pathPrefix (all indexed code if empty), reading the chunks once.
+// Caller must hold s.mu.
+func (s *Store) countCallers(ctx context.Context, names []string, pathPrefix string) map[string]int {
+	counts := make(map[string]int, len(names))
+	if len(names) == 0 {
+		return counts
+	}
+
+	// Prefilter on each name's terminal segment; callSites does the exact match
+	filters := make([]string, len(names))
+	for i := range names {
+		filters[i] = fmt.Sprintf("instr(lower(calls), lower(?%d)) > 0", i+1)
+	}
+	query := `SELECT name, language, calls, call_counts FROM chunks WHERE (` + strings.Join(filters, " OR ") + `)`
+	if pathPrefix != "" {
+		query += fmt.Sprintf(" AND absolute_path LIKE ?%d", len(names)+1)
+	}
+	stmt, _, err := s.db.Prepare(query)
+	if err != nil {
+		return counts
+	}
+	defer stmt.Close()
+	for i, name := range names {
+		stmt.BindText(i+1, terminalSegment(name))
+	}
+	if pathPrefix != "" {
+		stmt.BindText(len(names)+1, pathPrefix+"%")
+	}
+
+	// Every part of a split symbol carries the whole symbol's calls, so the
+	// parts count once, as the symbol
+	seen := make(map[string]bool)
+	for stmt.Step() {
+		caller, _, _ := strings.Cut(stmt.ColumnText(0), " (part ")
+		language := stmt.ColumnText(1)
+		calls := stmt.ColumnText(2)
+		callCounts := decodeCallCounts(stmt.ColumnText(3))
+		for _, name := range names {
+			key := name + "\x00" + caller
+			if seen[key] || s.callSites(calls, callCounts, name, language) == 0 {
+				continue
+			}
+			seen[key] = true
+			counts[name]++
+		}
+	}
+	return counts
+}
+
+// FindCallersDeep finds callers up to N levels deep using the chunks table.
+// The second return value reports whether traversal stopped at cfg.MaxCallerNodes.
 // If pathPrefix is not empty, only returns callers from files within that path (project scoping)
-func (s *Store) FindCallersDeep(ctx context.Context, symbolName string, depth int, maxPerLevel int, pathPrefix string) map[int][]types.CallerInfo {
+func (s *Store) FindCallersDeep(ctx context.Context, symbolName string, depth int, maxPerLevel int, pathPrefix string) (map[int][]types.CallerInfo, bool) {
+	return s.findCallersDeep(ctx, symbolName, depth, maxPerLevel, pathPrefix, "")
+}
+
+// FindCallersDeepSplitTests is FindCallersDeep with separate per-level caps
+// for test and non-test callers, so that many non-test callers cannot crowd
+// tests out of a level (see find_tests)
+func (s *Store) FindCallersDeepSplitTests(ctx context.Context, symbolName string, depth int, maxPerLevel int, pathPrefix string) (map[int][]types.CallerInfo, bool) {
+	return s.findCallersDeep(ctx, symbolName, depth, maxPerLevel, pathPrefix, "is_test = 1", "is_test = 0")
+}
+
+// findCallersDeep walks callers level by level, querying each symbol's
+// callers once per filter (see findCallers), each with its own maxPerLevel cap
+func (s *Store) findCallersDeep(ctx context.Context, symbolName string, depth int, maxPerLevel int, pathPrefix string, filters ...string) (map[int][]types.CallerInfo, bool) {
 	result := make(map[int][]types.CallerInfo)
 
 	if depth <= 0 {
@@ -962,25 +1918,43 @@ func (s *Store) FindCallersDeep(ctx context.Context, symbolName string, depth in
 	seenSymbols := make(map[string]bool)
 	seenSymbols[symbolName] = true
 
-	for level := 1; level <= depth; level++ {
+	// Global cap on collected nodes so hot symbols can't explode the traversal
+	maxNodes := s.cfg.MaxCallerNodes
+	visited := 0
+	truncated := false
+
+	for level := 1; level <= depth && !truncated; level++ {
 		levelCallers := make([]types.CallerInfo, 0)
 		nextSymbols := make([]string, 0)
 
 		for _, sym := range currentSymbols {
-			callers, err := s.FindCallers(ctx, sym, maxPerLevel, pathPrefix)
-			if err != nil {
-				continue
+			var callers []types.CallerInfo
+			for _, where := range filters {
+				s.mu.Lock()
+				found, err := s.findCallers(ctx, sym, maxPerLevel, pathPrefix, where)
+				s.mu.Unlock()
+				if err == nil {
+					callers = append(callers, found...)
+				}
 			}
 
 			for _, caller := range callers {
 				if seenSymbols[caller.Name] {
 					continue
 				}
+				if maxNodes > 0 && visited >= maxNodes {
+					truncated = true
+					break
+				}
 				seenSymbols[caller.Name] = true
+				visited++
 
 				levelCallers = append(levelCallers, caller)
 				nextSymbols = append(nextSymbols, caller.Name)
 			}
+			if truncated {
+				break
+			}
 		}
 
 		if len(levelCallers) > 0 {
@@ -993,7 +1967,7 @@ func (s *Store) FindCallersDeep(ctx context.Context, symbolName string, depth in
 		}
 	}
 
-	return result
+	return result, truncated
 }
 
 // HasCallers returns true if the symbol has any callers (using chunks table)
@@ -1016,18 +1990,19 @@ func (s *Store) FindReferencers(ctx context.Context, symbolName string, maxResul
 	var stmt *sqlite3.Stmt
 	var err error
 
+	filter, pattern := s.symbolFilter("refs", symbolName)
 	if pathPrefix != "" {
 		// Scope to specific project/folder
 		stmt, _, err = s.db.Prepare(`
 			SELECT name, absolute_path, start_line, language, is_test, parent, refs, chunk_type
 			FROM chunks
-			WHERE refs LIKE ? AND absolute_path LIKE ?
+			WHERE ` + filter + ` AND absolute_path LIKE ?
 			LIMIT ?
 		`)
 		if err != nil {
 			return nil, fmt.Errorf("query failed: %w", err)
 		}
-		stmt.BindText(1, "%"+symbolName+"%")
+		stmt.BindText(1, pattern)
 		stmt.BindText(2, pathPrefix+"%")
 		stmt.BindInt(3, maxResults*3)
 	} else {
@@ -1035,13 +2010,13 @@ func (s *Store) FindReferencers(ctx context.Context, symbolName string, maxResul
 		stmt, _, err = s.db.Prepare(`
 			SELECT name, absolute_path, start_line, language, is_test, parent, refs, chunk_type
 			FROM chunks
-			WHERE refs LIKE ?
+			WHERE ` + filter + `
 			LIMIT ?
 		`)
 		if err != nil {
 			return nil, fmt.Errorf("query failed: %w", err)
 		}
-		stmt.BindText(1, "%"+symbolName+"%")
+		stmt.BindText(1, pattern)
 		stmt.BindInt(2, maxResults*3)
 	}
 	defer stmt.Close()
@@ -1060,7 +2035,7 @@ func (s *Store) FindReferencers(ctx context.Context, symbolName string, maxResul
 		chunkType := stmt.ColumnText(7)
 
 		// Don't include the symbol itself
-		if name == symbolName {
+		if s.symbolEqual(name, symbolName, language) {
 			continue
 		}
 
@@ -1073,7 +2048,7 @@ func (s *Store) FindReferencers(ctx context.Context, symbolName string, maxResul
 		found := false
 		for _, ref := range refList {
 			ref = strings.TrimSpace(ref)
-			if ref == symbolName || strings.HasSuffix(ref, "."+symbolName) {
+			if s.symbolMatches(ref, symbolName, language) {
 				found = true
 				break
 			}
@@ -1106,9 +2081,10 @@ func (s *Store) FindReferencers(ctx context.Context, symbolName string, maxResul
 	return referencers, nil
 }
 
-// FindReferencersDeep finds referencers up to N levels deep
+// FindReferencersDeep finds referencers up to N levels deep.
+// The second return value reports whether traversal stopped at cfg.MaxCallerNodes.
 // If pathPrefix is not empty, only returns referencers from files within that path (project scoping)
-func (s *Store) FindReferencersDeep(ctx context.Context, symbolName string, depth int, maxPerLevel int, pathPrefix string) map[int][]types.CallerInfo {
+func (s *Store) FindReferencersDeep(ctx context.Context, symbolName string, depth int, maxPerLevel int, pathPrefix string) (map[int][]types.CallerInfo, bool) {
 	result := make(map[int][]types.CallerInfo)
 
 	if depth <= 0 {
@@ -1122,7 +2098,12 @@ func (s *Store) FindReferencersDeep(ctx context.Context, symbolName string, dept
 	seenSymbols := make(map[string]bool)
 	seenSymbols[symbolName] = true
 
-	for level := 1; level <= depth; level++ {
+	// Global cap on collected nodes so hot symbols can't explode the traversal
+	maxNodes := s.cfg.MaxCallerNodes
+	visited := 0
+	truncated := false
+
+	for level := 1; level <= depth && !truncated; level++ {
 		levelReferencers := make([]types.CallerInfo, 0)
 		nextSymbols := make([]string, 0)
 
@@ -1136,11 +2117,19 @@ func (s *Store) FindReferencersDeep(ctx context.Context, symbolName string, dept
 				if seenSymbols[ref.Name] {
 					continue
 				}
+				if maxNodes > 0 && visited >= maxNodes {
+					truncated = true
+					break
+				}
 				seenSymbols[ref.Name] = true
+				visited++
 
 				levelReferencers = append(levelReferencers, ref)
 				nextSymbols = append(nextSymbols, ref.Name)
 			}
+			if truncated {
+				break
+			}
 		}
 
 		if len(levelReferencers) > 0 {
@@ -1153,53 +2142,673 @@ func (s *Store) FindReferencersDeep(ctx context.Context, symbolName string, dept
 		}
 	}
 
-	return result
-}
-
-// HasTestCaller returns true if any caller is a test
-func (s *Store) HasTestCaller(ctx context.Context, symbolName string, pathPrefix string) bool {
-	callers, err := s.FindCallers(ctx, symbolName, 50, pathPrefix)
-	if err != nil {
-		return false
-	}
-	for _, c := range callers {
-		if c.IsTest {
-			return true
-		}
-	}
-	return false
+	return result, truncated
 }
 
-// GetChunkMetadata retrieves metadata for a specific symbol
-func (s *Store) GetChunkMetadata(ctx context.Context, symbolName string) (map[string]string, error) {
+// exportedSymbolsFilter selects exported, non-test symbol chunks under a path
+// prefix, skipping the continuation parts of split symbols
+const exportedSymbolsFilter = `
+	FROM chunks
+	WHERE is_exported = 1 AND is_test = 0
+	  AND chunk_type IN ('function', 'method', 'class')
+	  AND absolute_path LIKE ?
+	  AND (name NOT LIKE '% (part %' OR name LIKE '% (part 1)')
+	GROUP BY absolute_path, name
+`
+
+// ListComplexFunctions returns functions and methods within pathPrefix ordered
+// by estimated cyclomatic complexity, highest first, at most limit (<= 0 = all).
+// Split symbols are listed once.
+func (s *Store) ListComplexFunctions(ctx context.Context, pathPrefix string, limit int) ([]types.ComplexSymbol, error) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
+	if limit <= 0 {
+		limit = -1 // SQLite: no limit
+	}
+
 	stmt, _, err := s.db.Prepare(`
-		SELECT absolute_path, chunk_type, name, language, start_line, end_line,
-		       calls, refs, is_exported, is_test, parent
+		SELECT name, chunk_type, absolute_path, start_line, end_line, language,
+		       CAST(json_extract(metadata, '$.` + types.MetadataComplexity + `') AS INTEGER) AS complexity
 		FROM chunks
-		WHERE name = ?
-		LIMIT 1
+		WHERE metadata != ''
+		  AND chunk_type IN ('function', 'method')
+		  AND absolute_path LIKE ?
+		  AND (name NOT LIKE '% (part %' OR name LIKE '% (part 1)')
+		  AND complexity > 0
+		ORDER BY complexity DESC, absolute_path, start_line
+		LIMIT ?
 	`)
 	if err != nil {
 		return nil, fmt.Errorf("query failed: %w", err)
 	}
 	defer stmt.Close()
 
-	stmt.BindText(1, symbolName)
+	stmt.BindText(1, pathPrefix+"%")
+	stmt.BindInt(2, limit)
 
-	if !stmt.Step() {
-		return nil, nil
+	symbols := make([]types.ComplexSymbol, 0)
+	for stmt.Step() {
+		symbols = append(symbols, types.ComplexSymbol{
+			Name:       strings.TrimSuffix(stmt.ColumnText(0), " (part 1)"),
+			ChunkType:  stmt.ColumnText(1),
+			FilePath:   stmt.ColumnText(2),
+			StartLine:  stmt.ColumnInt(3),
+			EndLine:    stmt.ColumnInt(4),
+			Language:   stmt.ColumnText(5),
+			Complexity: stmt.ColumnInt(6),
+		})
 	}
 
-	metadata := map[string]string{
-		"absolute_path": stmt.ColumnText(0),
-		"chunk_type":    stmt.ColumnText(1),
-		"name":          stmt.ColumnText(2),
-		"language":      stmt.ColumnText(3),
-		"start_line":    strconv.Itoa(stmt.ColumnInt(4)),
-		"end_line":      strconv.Itoa(stmt.ColumnInt(5)),
+	if err := stmt.Err(); err != nil {
+		return nil, fmt.Errorf("query iteration failed: %w", err)
+	}
+
+	return symbols, nil
+}
+
+// ListExportedSymbols returns exported, non-test functions, methods and classes
+// within pathPrefix, ordered by file and line, paged by limit (<= 0 = all) and
+// offset. total is the number of symbols before paging.
+func (s *Store) ListExportedSymbols(ctx context.Context, pathPrefix string, limit, offset int) (symbols []types.APISymbol, total int, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	countStmt, _, err := s.db.Prepare(`SELECT COUNT(*) FROM (SELECT 1 ` + exportedSymbolsFilter + `)`)
+	if err != nil {
+		return nil, 0, fmt.Errorf("query failed: %w", err)
+	}
+	defer countStmt.Close()
+
+	countStmt.BindText(1, pathPrefix+"%")
+	if countStmt.Step() {
+		total = countStmt.ColumnInt(0)
+	}
+
+	if limit <= 0 {
+		limit = -1 // SQLite: no limit
+	}
+	if offset < 0 {
+		offset = 0
+	}
+
+	// One row per file and name (overloads, split symbols); MIN picks the first declaration
+	stmt, _, err := s.db.Prepare(`
+		SELECT name, chunk_type, absolute_path, MIN(start_line), raw_content
+		` + exportedSymbolsFilter + `
+		ORDER BY absolute_path, MIN(start_line)
+		LIMIT ? OFFSET ?
+	`)
+	if err != nil {
+		return nil, 0, fmt.Errorf("query failed: %w", err)
+	}
+	defer stmt.Close()
+
+	stmt.BindText(1, pathPrefix+"%")
+	stmt.BindInt(2, limit)
+	stmt.BindInt(3, offset)
+
+	symbols = make([]types.APISymbol, 0)
+	for stmt.Step() {
+		name := stmt.ColumnText(0)
+		name = strings.TrimSuffix(name, " (part 1)")
+
+		symbols = append(symbols, types.APISymbol{
+			Name:      name,
+			ChunkType: stmt.ColumnText(1),
+			FilePath:  stmt.ColumnText(2),
+			Line:      stmt.ColumnInt(3),
+			Signature: firstLine(stmt.ColumnText(4)),
+		})
+	}
+
+	if err := stmt.Err(); err != nil {
+		return nil, 0, fmt.Errorf("query iteration failed: %w", err)
+	}
+
+	return symbols, total, nil
+}
+
+// interfaceMethodPattern matches a method signature line inside an interface
+// body: Go "Name(...)", Java "Type name(...);", TypeScript "name?(...)" / "name<T>(...)"
+var interfaceMethodPattern = regexp.MustCompile(`^\s*(?:(?:public|abstract|default|static|readonly)\s+)*(?:[\w<>\[\],.?]+\s+)?(\w+)\??\s*(?:<[^>]*>)?\s*\(`)
+
+// interfaceHeaderPattern matches an interface declaration header (Go, Java, TypeScript, C#)
+var interfaceHeaderPattern = regexp.MustCompile(`\binterface\b`)
+
+// FindImplementors finds types implementing the named interface: classes that
+// declare it in an implements/extends clause, and types whose methods cover
+// the interface's method set (Go-style structural implementation).
+// If pathPrefix is not empty, only types in files within that path are returned.
+func (s *Store) FindImplementors(ctx context.Context, interfaceName string, pathPrefix string) ([]types.Implementor, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Load the interface declaration(s) to get the method set
+	stmt, _, err := s.db.Prepare(`
+		SELECT raw_content FROM chunks
+		WHERE name = ? AND chunk_type = 'class'
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("query failed: %w", err)
+	}
+	stmt.BindText(1, interfaceName)
+
+	found := false
+	methodSet := make(map[string]bool)
+	for stmt.Step() {
+		content := stmt.ColumnText(0)
+		header, body, ok := strings.Cut(content, "{")
+		if !ok || !interfaceHeaderPattern.MatchString(header) {
+			continue
+		}
+		found = true
+		for _, line := range strings.Split(body, "\n") {
+			if m := interfaceMethodPattern.FindStringSubmatch(line); m != nil {
+				methodSet[m[1]] = true
+			}
+		}
+	}
+	stmt.Close()
+
+	if !found {
+		return nil, fmt.Errorf("no interface named %q in the index", interfaceName)
+	}
+
+	implementors := make([]types.Implementor, 0)
+	seen := make(map[string]bool)
+
+	// Nominal: classes whose header names the interface in an implements/extends clause
+	declaredPattern := regexp.MustCompile(`\b(?:implements|extends)\b[^{]*\b` + regexp.QuoteMeta(interfaceName) + `\b`)
+	stmt, _, err = s.db.Prepare(`
+		SELECT name, absolute_path, start_line, language, raw_content
+		FROM chunks
+		WHERE chunk_type = 'class' AND name != ? AND raw_content LIKE ? AND absolute_path LIKE ?
+		ORDER BY absolute_path, start_line
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("query failed: %w", err)
+	}
+	stmt.BindText(1, interfaceName)
+	stmt.BindText(2, "%"+interfaceName+"%")
+	stmt.BindText(3, pathPrefix+"%")
+	for stmt.Step() {
+		header, _, _ := strings.Cut(stmt.ColumnText(4), "{")
+		name := stmt.ColumnText(0)
+		if !declaredPattern.MatchString(header) || seen[name] {
+			continue
+		}
+		seen[name] = true
+		implementors = append(implementors, types.Implementor{
+			Name:     name,
+			FilePath: stmt.ColumnText(1),
+			Line:     stmt.ColumnInt(2),
+			Language: stmt.ColumnText(3),
+			Match:    "declared",
+		})
+	}
+	stmt.Close()
+
+	if len(methodSet) == 0 {
+		return implementors, nil
+	}
+
+	// Structural: group methods by their parent type and compare with the method set
+	type typeMethods struct {
+		methods  map[string]bool
+		path     string
+		line     int
+		language string
+	}
+	byType := make(map[string]*typeMethods)
+	var order []string
+
+	stmt, _, err = s.db.Prepare(`
+		SELECT parent, name, absolute_path, start_line, language
+		FROM chunks
+		WHERE chunk_type = 'method' AND parent != '' AND parent != ? AND absolute_path LIKE ?
+		ORDER BY absolute_path, start_line
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("query failed: %w", err)
+	}
+	stmt.BindText(1, interfaceName)
+	stmt.BindText(2, pathPrefix+"%")
+	for stmt.Step() {
+		// Generic receivers (Go "Stack[T]") group under the bare type name
+		parent, _, _ := strings.Cut(stmt.ColumnText(0), "[")
+		// Method chunks are named "Type.method", possibly with a " (part N)" suffix
+		method, _, _ := strings.Cut(stmt.ColumnText(1), " (part ")
+		if i := strings.LastIndex(method, "."); i >= 0 {
+			method = method[i+1:]
+		}
+		tm, ok := byType[parent]
+		if !ok {
+			tm = &typeMethods{
+				methods:  make(map[string]bool),
+				path:     stmt.ColumnText(2),
+				line:     stmt.ColumnInt(3),
+				language: stmt.ColumnText(4),
+			}
+			byType[parent] = tm
+			order = append(order, parent)
+		}
+		tm.methods[method] = true
+	}
+	stmt.Close()
+
+	for _, typeName := range order {
+		tm := byType[typeName]
+		if seen[typeName] || len(tm.methods) < len(methodSet) {
+			continue
+		}
+		covers := true
+		for method := range methodSet {
+			if !tm.methods[method] {
+				covers = false
+				break
+			}
+		}
+		if !covers {
+			continue
+		}
+		seen[typeName] = true
+
+		impl := types.Implementor{
+			Name:     typeName,
+			FilePath: tm.path,
+			Line:     tm.line,
+			Language: tm.language,
+			Match:    "method set",
+		}
+		// Point at the type declaration rather than its first method when indexed
+		if path, line, ok := s.typeLocation(typeName, tm.language); ok {
+			impl.FilePath, impl.Line = path, line
+		}
+		implementors = append(implementors, impl)
+	}
+
+	return implementors, nil
+}
+
+// typeLocation returns where a class/type chunk is declared. Caller must hold s.mu.
+func (s *Store) typeLocation(name, language string) (string, int, bool) {
+	stmt, _, err := s.db.Prepare(`
+		SELECT absolute_path, start_line FROM chunks
+		WHERE name = ? AND language = ? AND chunk_type = 'class'
+		LIMIT 1
+	`)
+	if err != nil {
+		return "", 0, false
+	}
+	defer stmt.Close()
+
+	stmt.BindText(1, name)
+	stmt.BindText(2, language)
+	if !stmt.Step() {
+		return "", 0, false
+	}
+	return stmt.ColumnText(0), stmt.ColumnInt(1), true
+}
+
+// FindSymbolLocation returns the function, method and class chunks defining
+// symbolName. A qualified name ("Store.Search") matches that method exactly;
+// a bare name also matches methods of any type. Definitions are ordered by
+// closeness to callerPath (same file, same directory, then longest shared
+// path), so the caller's own package comes first. callerPath may be empty.
+func (s *Store) FindSymbolLocation(ctx context.Context, symbolName string, callerPath string) ([]types.Definition, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	filter, pattern := s.symbolFilter("name", symbolName)
+	stmt, _, err := s.db.Prepare(`
+		SELECT name, absolute_path, start_line, end_line, chunk_type, language, raw_content
+		FROM chunks
+		WHERE ` + filter + ` AND chunk_type IN ('function', 'method', 'class')
+		ORDER BY absolute_path, start_line
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("query failed: %w", err)
+	}
+	defer stmt.Close()
+
+	stmt.BindText(1, pattern)
+
+	definitions := make([]types.Definition, 0)
+	seen := make(map[string]int)
+	parts := make(map[int][]symbolPart)
+	for stmt.Step() {
+		name := stmt.ColumnText(0)
+		// Split symbols are named "Name (part N)"; their parts are merged into one definition
+		isPart := false
+		if i := strings.Index(name, " (part "); i >= 0 {
+			name = name[:i]
+			isPart = true
+		}
+		if !s.symbolMatches(name, symbolName, stmt.ColumnText(5)) {
+			continue
+		}
+
+		key := stmt.ColumnText(1) + "\x00" + name
+		part := symbolPart{startLine: stmt.ColumnInt(2), content: stmt.ColumnText(6)}
+		if i, ok := seen[key]; ok {
+			if isPart && len(parts[i]) > 0 {
+				parts[i] = append(parts[i], part)
+				definitions[i].EndLine = max(definitions[i].EndLine, stmt.ColumnInt(3))
+			}
+			continue
+		}
+		seen[key] = len(definitions)
+		if isPart {
+			parts[len(definitions)] = []symbolPart{part}
+		}
+
+		definitions = append(definitions, types.Definition{
+			Name:      name,
+			FilePath:  stmt.ColumnText(1),
+			Line:      stmt.ColumnInt(2),
+			EndLine:   stmt.ColumnInt(3),
+			ChunkType: stmt.ColumnText(4),
+			Language:  stmt.ColumnText(5),
+			Content:   stmt.ColumnText(6),
+		})
+	}
+
+	if err := stmt.Err(); err != nil {
+		return nil, fmt.Errorf("query iteration failed: %w", err)
+	}
+
+	for i := range definitions {
+		definitions[i].Content = s.definitionContent(definitions[i], parts[i])
+	}
+
+	if callerPath != "" {
+		callerDir := filepath.Dir(callerPath)
+		sort.SliceStable(definitions, func(i, j int) bool {
+			return definitionCloseness(definitions[i].FilePath, callerPath, callerDir) >
+				definitionCloseness(definitions[j].FilePath, callerPath, callerDir)
+		})
+	}
+
+	return definitions, nil
+}
+
+// definitionCloseness scores how close a definition's file is to the caller:
+// same file beats same directory, which beats the longest shared parent path
+func definitionCloseness(defPath, callerPath, callerDir string) int {
+	if defPath == callerPath {
+		return math.MaxInt
+	}
+	defDir := filepath.Dir(defPath)
+	if defDir == callerDir {
+		return math.MaxInt - 1
+	}
+
+	shared := 0
+	defParts := strings.Split(defDir, string(filepath.Separator))
+	callerParts := strings.Split(callerDir, string(filepath.Separator))
+	for shared < len(defParts) && shared < len(callerParts) && defParts[shared] == callerParts[shared] {
+		shared++
+	}
+	return shared
+}
+
+// firstLine returns the first non-empty line of content without a trailing opening brace
+func firstLine(content string) string {
+	for _, line := range strings.Split(content, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		return strings.TrimSpace(strings.TrimSuffix(line, "{"))
+	}
+	return ""
+}
+
+// NameInStringLiteral returns true if the symbol name appears quoted in any indexed
+// chunk, which suggests reflection, route registration, or config-driven dispatch.
+// The match is case-sensitive and literal. If pathPrefix is not empty, only
+// chunks within that path are considered.
+func (s *Store) NameInStringLiteral(ctx context.Context, symbolName string, pathPrefix string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// The path range uses the path index, unlike LIKE (whose _ and % would
+	// also need escaping); "\xff" sorts after every UTF-8 continuation
+	stmt, _, err := s.db.Prepare(`
+		SELECT 1 FROM chunks
+		WHERE absolute_path >= ? AND absolute_path < ?
+		  AND (instr(raw_content, ?) > 0 OR instr(raw_content, ?) > 0 OR instr(raw_content, ?) > 0)
+		LIMIT 1
+	`)
+	if err != nil {
+		return false
+	}
+	defer stmt.Close()
+
+	stmt.BindText(1, pathPrefix)
+	stmt.BindText(2, pathPrefix+"\xff")
+	stmt.BindText(3, "\""+symbolName+"\"")
+	stmt.BindText(4, "'"+symbolName+"'")
+	stmt.BindText(5, "`"+symbolName+"`")
+
+	return stmt.Step()
+}
+
+// HasTestCaller returns true if any caller is a test
+func (s *Store) HasTestCaller(ctx context.Context, symbolName string, pathPrefix string) bool {
+	callers, err := s.FindCallers(ctx, symbolName, 50, pathPrefix)
+	if err != nil {
+		return false
+	}
+	for _, c := range callers {
+		if c.IsTest {
+			return true
+		}
+	}
+	return false
+}
+
+// recordRemovedSymbols adds the names of absolutePath's chunks to
+// removed_symbols as stored ("Name", or "Parent.Name" for methods). Names
+// that come back with the file's new chunks are cleared again by
+// clearRemovedSymbols. Caller must hold s.mu.
+func (s *Store) recordRemovedSymbols(absolutePath string) error {
+	namesStmt, _, err := s.db.Prepare(`SELECT DISTINCT name FROM chunks WHERE absolute_path = ? AND name != ''`)
+	if err != nil {
+		return err
+	}
+	namesStmt.BindText(1, absolutePath)
+	names := make(map[string]bool)
+	for namesStmt.Step() {
+		name, _, _ := strings.Cut(namesStmt.ColumnText(0), " (part ")
+		names[name] = true
+	}
+	err = namesStmt.Err()
+	namesStmt.Close()
+	if err != nil {
+		return err
+	}
+
+	insertStmt, _, err := s.db.Prepare(`INSERT OR REPLACE INTO removed_symbols(name, absolute_path, removed_at) VALUES (?, ?, ?)`)
+	if err != nil {
+		return err
+	}
+	defer insertStmt.Close()
+
+	now := time.Now().Unix()
+	for name := range names {
+		insertStmt.BindText(1, name)
+		insertStmt.BindText(2, absolutePath)
+		insertStmt.BindInt64(3, now)
+		if err := insertStmt.Exec(); err != nil {
+			return err
+		}
+		insertStmt.Reset()
+	}
+	return nil
+}
+
+// clearRemovedSymbols drops the names of chunks from removed_symbols, so a
+// symbol that is indexed again (a re-saved file) is not reported as removed.
+// Caller must hold s.mu.
+func (s *Store) clearRemovedSymbols(chunks []types.Chunk) error {
+	stmt, _, err := s.db.Prepare(`DELETE FROM removed_symbols WHERE name = ?`)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	for _, chunk := range chunks {
+		name, _, _ := strings.Cut(chunk.Name, " (part ")
+		if name == "" {
+			continue
+		}
+		stmt.BindText(1, name)
+		if err := stmt.Exec(); err != nil {
+			return err
+		}
+		stmt.Reset()
+	}
+	return nil
+}
+
+// StaleSymbols returns the names a call may use for symbols that were removed
+// from the index and are no longer defined anywhere (e.g. the callee was
+// renamed). A method is known as "Type.Name"; a function as "Name" and,
+// qualified by its package or module, as "dir.Name" and "file.Name". Compute
+// it once per search and match calls against it with StaleCalls.
+func (s *Store) StaleSymbols(ctx context.Context) map[string]bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// A removed name is stale unless a symbol of that name, possibly nested
+	// ("Outer.Name") or split ("Name (part N)"), is still indexed
+	stmt, _, err := s.db.Prepare(`
+		SELECT r.name, r.absolute_path FROM removed_symbols r
+		WHERE NOT EXISTS (
+			SELECT 1 FROM (
+				SELECT CASE WHEN instr(name, ' (part ') > 0
+					THEN substr(name, 1, instr(name, ' (part ') - 1) ELSE name END AS base
+				FROM chunks
+			) c
+			WHERE c.base = r.name OR substr(c.base, -length(r.name) - 1) = '.' || r.name
+		)
+	`)
+	if err != nil {
+		return nil
+	}
+	defer stmt.Close()
+
+	var stale map[string]bool
+	for stmt.Step() {
+		if stale == nil {
+			stale = make(map[string]bool)
+		}
+		name := qualifierSeparators.Replace(stmt.ColumnText(0))
+		stale[name] = true
+
+		if i := strings.LastIndexByte(name, '.'); i >= 0 {
+			// Nested methods are also called through their innermost type
+			if j := strings.LastIndexByte(name[:i], '.'); j >= 0 {
+				stale[name[j+1:]] = true
+			}
+			continue
+		}
+		if path := stmt.ColumnText(1); path != "" {
+			stale[filepath.Base(filepath.Dir(path))+"."+name] = true
+			file := filepath.Base(path)
+			stale[strings.TrimSuffix(file, filepath.Ext(file))+"."+name] = true
+		}
+	}
+	return stale
+}
+
+// selfQualifiers are the receivers through which a method calls its siblings
+var selfQualifiers = map[string]bool{"self": true, "this": true, "cls": true, "static": true, "parent": true}
+
+// StaleCalls returns the calls that point at symbols in stale (see
+// StaleSymbols). A call matches by its receiver or qualifier: "obj.Close"
+// only matches a removed "obj.Close", never an unrelated method of that name.
+// parent is the calling chunk's enclosing type, which resolves unqualified
+// and self/this calls to its methods.
+func StaleCalls(calls []string, parent string, stale map[string]bool) []string {
+	if len(stale) == 0 || len(calls) == 0 {
+		return nil
+	}
+	parent = qualifierSeparators.Replace(parent)
+
+	var result []string
+	seen := make(map[string]bool)
+	for _, call := range calls {
+		if call == "" || seen[call] {
+			continue
+		}
+		seen[call] = true
+
+		name := strings.TrimPrefix(qualifierSeparators.Replace(call), "$")
+		qualifier, method := "", name
+		if i := strings.LastIndexByte(name, '.'); i >= 0 {
+			qualifier, method = name[:i], name[i+1:]
+			qualifier = qualifier[strings.LastIndexByte(qualifier, '.')+1:]
+		}
+
+		switch {
+		case qualifier == "":
+			if !stale[method] && (parent == "" || !stale[parent+"."+method]) {
+				continue
+			}
+		case selfQualifiers[qualifier]:
+			if parent == "" || !stale[parent+"."+method] {
+				continue
+			}
+		default:
+			if !stale[name] && !stale[qualifier+"."+method] {
+				continue
+			}
+		}
+		result = append(result, call)
+	}
+	return result
+}
+
+// GetChunkMetadata retrieves metadata for a specific symbol
+func (s *Store) GetChunkMetadata(ctx context.Context, symbolName string) (map[string]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// With case-insensitive matching an exact-case match still wins
+	collate := ""
+	if !s.cfg.SymbolCaseSensitive {
+		collate = " COLLATE NOCASE"
+	}
+	stmt, _, err := s.db.Prepare(`
+		SELECT absolute_path, chunk_type, name, language, start_line, end_line,
+		       calls, refs, is_exported, is_test, parent, metadata
+		FROM chunks
+		WHERE name = ?` + collate + `
+		ORDER BY name = ? DESC
+		LIMIT 1
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("query failed: %w", err)
+	}
+	defer stmt.Close()
+
+	stmt.BindText(1, symbolName)
+	stmt.BindText(2, symbolName)
+
+	if !stmt.Step() {
+		return nil, nil
+	}
+
+	metadata := map[string]string{
+		"absolute_path": stmt.ColumnText(0),
+		"chunk_type":    stmt.ColumnText(1),
+		"name":          stmt.ColumnText(2),
+		"language":      stmt.ColumnText(3),
+		"start_line":    strconv.Itoa(stmt.ColumnInt(4)),
+		"end_line":      strconv.Itoa(stmt.ColumnInt(5)),
 		"is_exported":   strconv.FormatBool(stmt.ColumnInt(8) == 1),
 		"is_test":       strconv.FormatBool(stmt.ColumnInt(9) == 1),
 	}
@@ -1213,11 +2822,20 @@ func (s *Store) GetChunkMetadata(ctx context.Context, symbolName string) (map[st
 	if parent := stmt.ColumnText(10); parent != "" {
 		metadata["parent"] = parent
 	}
+	// Parser-supplied metadata never overrides the columns above
+	for k, v := range decodeMetadata(stmt.ColumnText(11)) {
+		if _, ok := metadata[k]; !ok {
+			metadata[k] = v
+		}
+	}
 
 	return metadata, nil
 }
 
-// ClearAll removes all chunks from the database
+// ClearAll removes all chunks from the database in one transaction; on error
+// nothing is removed. The project metadata file is deleted afterwards.
+// Caller and reference lookups are answered from the chunks table, so no
+// separate caller index needs to be cleared.
 func (s *Store) ClearAll(ctx context.Context) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
@@ -1251,7 +2869,40 @@ func (s *Store) ClearAll(ctx context.Context) error {
 		return fmt.Errorf("failed to clear file_hashes: %w", err)
 	}
 
-	return s.db.Exec("COMMIT")
+	err = s.db.Exec("DELETE FROM file_imports")
+	if err != nil {
+		s.db.Exec("ROLLBACK")
+		return fmt.Errorf("failed to clear file_imports: %w", err)
+	}
+
+	err = s.db.Exec("DELETE FROM file_contents")
+	if err != nil {
+		s.db.Exec("ROLLBACK")
+		return fmt.Errorf("failed to clear file_contents: %w", err)
+	}
+
+	err = s.db.Exec("DELETE FROM removed_symbols")
+	if err != nil {
+		s.db.Exec("ROLLBACK")
+		return fmt.Errorf("failed to clear removed_symbols: %w", err)
+	}
+
+	// Nothing is left to re-embed, so the index now matches the current config
+	if err := s.setConfigValue(embeddingConfigKey, embeddingConfigHash()); err != nil {
+		s.db.Exec("ROLLBACK")
+		return fmt.Errorf("failed to record embedding config: %w", err)
+	}
+	if err := s.recordEmbeddingModel(); err != nil {
+		s.db.Exec("ROLLBACK")
+		return fmt.Errorf("failed to record embedding model: %w", err)
+	}
+
+	if err := s.db.Exec("COMMIT"); err != nil {
+		s.db.Exec("ROLLBACK")
+		return fmt.Errorf("failed to commit clear: %w", err)
+	}
+	s.reembedNeeded = false
+	return nil
 }
 
 // Close closes the database connection
@@ -1261,7 +2912,12 @@ func (s *Store) Close() error {
 
 // NewFileHashStore creates a FileHashStore using this store's database connection and shared mutex
 func (s *Store) NewFileHashStore() *FileHashStore {
-	return NewFileHashStore(s.db, &s.mu)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	hashStore := NewFileHashStore(s.db, &s.mu)
+	s.hashStores = append(s.hashStores, hashStore)
+	return hashStore
 }
 
 // Helper functions
@@ -0,0 +1,439 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"mcp-semantic-search/config"
+	"mcp-semantic-search/types"
+)
+
+func TestStaleCallsMatchQualifiedRemovedSymbols(t *testing.T) {
+	st := newTestStore(t, nil)
+	ctx := context.Background()
+
+	addChunks(t, st, testChunk("/p/greeter.py", 0, "Greeter.hello", "def hello(self): pass"))
+	if err := st.DeleteFileChunks(ctx, "/p/greeter.py"); err != nil {
+		t.Fatal(err)
+	}
+
+	calls := []string{"self.hello", "greeter.hello", "print"}
+	if got := StaleCalls(calls, "Greeter", st.StaleSymbols(ctx)); !reflect.DeepEqual(got, []string{"self.hello"}) {
+		t.Fatalf("StaleCalls = %v, want [self.hello]", got)
+	}
+	// Another class's self.hello is a different method
+	if got := StaleCalls(calls, "Other", st.StaleSymbols(ctx)); len(got) != 0 {
+		t.Fatalf("StaleCalls from Other = %v, want none", got)
+	}
+
+	// The method moved to another file (here split in parts) is not stale
+	addChunks(t, st, testChunk("/p/greeting.py", 0, "Greeter.hello (part 1)", "def hello(self): pass"))
+	if got := StaleCalls(calls, "Greeter", st.StaleSymbols(ctx)); len(got) != 0 {
+		t.Fatalf("StaleCalls = %v, want none once Greeter.hello is defined again", got)
+	}
+}
+
+func TestStaleCallsIgnoreUnrelatedReceivers(t *testing.T) {
+	st := newTestStore(t, nil)
+	ctx := context.Background()
+
+	addChunks(t, st, testChunk("/p/x.go", 0, "X.Close", "func (x *X) Close() {}"))
+	if err := st.DeleteFileChunks(ctx, "/p/x.go"); err != nil {
+		t.Fatal(err)
+	}
+
+	calls := []string{"f.Close", "Close", "X.Close", "pkg.X.Close"}
+	if got, want := StaleCalls(calls, "", st.StaleSymbols(ctx)), []string{"X.Close", "pkg.X.Close"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("StaleCalls = %v, want %v", got, want)
+	}
+}
+
+func TestStaleCallsAfterRename(t *testing.T) {
+	st := newTestStore(t, nil)
+	ctx := context.Background()
+
+	addChunks(t, st, testChunk("/p/greet/greet.go", 0, "Hello", "func Hello() {}"))
+	if err := st.DeleteFileChunks(ctx, "/p/greet/greet.go"); err != nil {
+		t.Fatal(err)
+	}
+	addChunks(t, st, testChunk("/p/greet/greet.go", 0, "Greet", "func Greet() {}"))
+
+	calls := []string{"Hello", "greet.Hello", "http.Hello", "Greet"}
+	if got, want := StaleCalls(calls, "", st.StaleSymbols(ctx)), []string{"Hello", "greet.Hello"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("StaleCalls = %v, want %v", got, want)
+	}
+}
+
+func TestReindexedSymbolsAreNotRecordedAsRemoved(t *testing.T) {
+	st := newTestStore(t, nil)
+	ctx := context.Background()
+
+	// Re-saving a file deletes and re-adds its chunks; only names that are gone stay removed
+	addChunks(t, st, testChunk("/p/a.go", 0, "Keep", "func Keep() {}"), testChunk("/p/a.go", 1, "Drop", "func Drop() {}"))
+	if err := st.DeleteFileChunks(ctx, "/p/a.go"); err != nil {
+		t.Fatal(err)
+	}
+	addChunks(t, st, testChunk("/p/a.go", 0, "Keep", "func Keep() {}"))
+
+	var names []string
+	for name := range st.StaleSymbols(ctx) {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	if want := []string{"Drop", "a.Drop", "p.Drop"}; !reflect.DeepEqual(names, want) {
+		t.Fatalf("stale symbols = %v, want %v", names, want)
+	}
+
+	// The table itself no longer lists the re-added name
+	st.mu.Lock()
+	stmt, _, err := st.db.Prepare(`SELECT COUNT(*) FROM removed_symbols WHERE name = 'Keep'`)
+	if err != nil {
+		st.mu.Unlock()
+		t.Fatal(err)
+	}
+	stmt.Step()
+	count := stmt.ColumnInt(0)
+	stmt.Close()
+	st.mu.Unlock()
+	if count != 0 {
+		t.Fatalf("removed_symbols lists Keep %d times after it was re-added", count)
+	}
+}
+
+func TestNameInStringLiteralIsLiteralAndCaseSensitive(t *testing.T) {
+	st := newTestStore(t, nil)
+	ctx := context.Background()
+	addChunks(t, st, testChunk("/p/app/routes.go", 0, "routes", `func routes() { register("handleUser") }`))
+
+	tests := []struct {
+		name, prefix string
+		want         bool
+	}{
+		{"handleUser", "", true},
+		{"handleUser", "/p/app", true},
+		{"handleUser", "/p/other", false},
+		{"HandleUser", "", false}, // Case-sensitive
+		{"handle_ser", "", false}, // _ is not a wildcard
+		{"handle%", "", false},    // Nor is %
+	}
+	for _, tt := range tests {
+		if got := st.NameInStringLiteral(ctx, tt.name, tt.prefix); got != tt.want {
+			t.Errorf("NameInStringLiteral(%q, %q) = %v, want %v", tt.name, tt.prefix, got, tt.want)
+		}
+	}
+}
+
+func TestSearchBreaksTiesByExportedThenCallers(t *testing.T) {
+	st := newTestStore(t, nil)
+	ctx := context.Background()
+
+	// Equal-length embedding texts, so the three constructors score the same
+	newA := testChunk("/p/a/new.go", 0, "NewA", "func NewA() *A { return &A{} }")
+	newB := testChunk("/p/b/new.go", 0, "NewB", "func NewB() *B { return &B{} }")
+	newC := testChunk("/p/c/new.go", 0, "NewC", "func NewC() *C { return &C{} }")
+	newB.IsExported = true
+	newC.IsExported = true
+	caller := testChunk("/p/d/use.go", 0, "Use", "func Use() { c.NewC() }")
+	caller.Calls = []string{"c.NewC"}
+	addChunks(t, st, newA, newB, newC, caller)
+
+	results, err := st.Search(ctx, "New", "", types.SearchOptions{Limit: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var order []string
+	for _, r := range results {
+		if strings.HasPrefix(r.Name, "New") {
+			order = append(order, r.AbsolutePath)
+			if r.Similarity != results[0].Similarity {
+				t.Fatalf("%s scored %v, want a tie at %v", r.Name, r.Similarity, results[0].Similarity)
+			}
+		}
+	}
+	want := []string{"/p/c/new.go", "/p/b/new.go", "/p/a/new.go"}
+	if !reflect.DeepEqual(order, want) {
+		t.Errorf("constructors ordered %v, want exported with callers, exported, unexported: %v", order, want)
+	}
+}
+
+func TestSimilarityTiedOnlyForCloseScores(t *testing.T) {
+	if !types.SimilarityTied(0.8, 0.8) || !types.SimilarityTied(0.80001, 0.80005) {
+		t.Error("equal or epsilon-close scores should tie")
+	}
+	if types.SimilarityTied(0.8001, 0.8024) {
+		t.Error("scores 0.0023 apart should keep their order")
+	}
+}
+
+func TestFindCallersCountsSplitSymbolOnce(t *testing.T) {
+	st := newTestStore(t, nil)
+
+	// Both parts of a split symbol carry the whole symbol's call counts
+	var chunks []types.Chunk
+	for i, name := range []string{"Big (part 1)", "Big (part 2)"} {
+		ch := testChunk("/p/big.go", i, name, "target()")
+		ch.Calls = []string{"target"}
+		ch.CallCounts = map[string]int{"target": 3}
+		chunks = append(chunks, ch)
+	}
+	small := testChunk("/p/small.go", 0, "Small", "target()")
+	small.Calls = []string{"target"}
+	addChunks(t, st, append(chunks, small)...)
+
+	callers, err := st.FindCallers(context.Background(), "target", 10, "/p/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sites := make(map[string]int)
+	for _, c := range callers {
+		sites[c.Name] += c.Calls
+	}
+	if want := map[string]int{"Big": 3, "Small": 1}; !reflect.DeepEqual(sites, want) {
+		t.Fatalf("call sites = %v, want %v", sites, want)
+	}
+}
+
+func TestClearAllRemovesChunks(t *testing.T) {
+	st := newTestStore(t, nil)
+	ctx := context.Background()
+
+	caller := testChunk("/p/main.go", 0, "main", "run()")
+	caller.Calls = []string{"run"}
+	addChunks(t, st, caller)
+
+	if err := st.ClearAll(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	callers, err := st.FindCallers(ctx, "run", 10, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(callers) != 0 {
+		t.Errorf("FindCallers after ClearAll = %+v, want none", callers)
+	}
+}
+
+func TestSearchDedupCountsCopiesPerResult(t *testing.T) {
+	st := newTestStore(t, func(cfg *config.Config) { cfg.DedupChunks = true })
+	ctx := context.Background()
+
+	addChunks(t, st,
+		testChunk("/p/a/util.go", 0, "Clamp", "func Clamp(v int) int { return v }"),
+		testChunk("/p/b/util.go", 0, "Clamp", "func Clamp(v int) int { return v }"),
+		testChunk("/p/c/util.go", 0, "Clamp", "func Clamp(v int) int { return v }"),
+		testChunk("/p/a/other.go", 0, "Other", "func Other() {}"),
+	)
+
+	results, err := st.Search(ctx, "Clamp", "", types.SearchOptions{Limit: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	duplicates := make(map[string]int)
+	for _, r := range results {
+		duplicates[r.Name] += r.Duplicates
+		if r.Name == "Clamp" && r.AbsolutePath != "/p/a/util.go" {
+			t.Errorf("kept copy %s, want the first one", r.AbsolutePath)
+		}
+	}
+	if want := map[string]int{"Clamp": 2, "Other": 0}; !reflect.DeepEqual(duplicates, want) {
+		t.Fatalf("duplicates = %v, want %v", duplicates, want)
+	}
+}
+
+func TestSearchReturnsStoredSignature(t *testing.T) {
+	st := newTestStore(t, nil)
+	get := testChunk("/p/store.go", 0, "Get", "func Get(id string) (*User, error) { return nil, nil }")
+	get.Signature = "func Get(id string) (*User, error)"
+	addChunks(t, st, get)
+
+	results, err := st.Search(context.Background(), "Get user", "", types.SearchOptions{Limit: 5})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 || results[0].Signature != get.Signature {
+		t.Fatalf("results = %+v, want Get with its signature", results)
+	}
+}
+
+func TestChunkMetadataRoundTrips(t *testing.T) {
+	st := newTestStore(t, nil)
+	ctx := context.Background()
+	handler := testChunk("/p/api.py", 0, "handler", "def handler(): pass")
+	handler.Metadata = map[string]string{
+		"decorators": "@app.route(\"/x\"),@cached",
+		"note":       "multi\nline \u00e9",
+		"name":       "spoofed",
+	}
+	addChunks(t, st, handler, testChunk("/p/plain.py", 0, "plain", "def plain(): pass"))
+
+	results, err := st.Search(ctx, "handler", "", types.SearchOptions{Limit: 5})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := make(map[string]map[string]string)
+	for _, r := range results {
+		got[r.Name] = r.Metadata
+	}
+	if !reflect.DeepEqual(got["handler"], handler.Metadata) || got["plain"] != nil {
+		t.Fatalf("search metadata = %v, want handler's map and none for plain", got)
+	}
+
+	meta, err := st.GetChunkMetadata(ctx, "handler")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if meta["decorators"] != handler.Metadata["decorators"] || meta["note"] != handler.Metadata["note"] {
+		t.Errorf("GetChunkMetadata = %v, want the stored metadata", meta)
+	}
+	if meta["name"] != "handler" {
+		t.Errorf("name = %q, stored metadata must not override the name column", meta["name"])
+	}
+}
+
+func TestEqualScoresAreOrderedByPathThenLine(t *testing.T) {
+	st := newTestStore(t, nil)
+	// Equal-length names, paths and contents embed identically
+	addChunks(t, st,
+		testChunk("/p/c.go", 0, "fc", "return 3"),
+		testChunk("/p/a.go", 1, "fe", "return 5"),
+		testChunk("/p/b.go", 0, "fb", "return 2"),
+		testChunk("/p/a.go", 0, "fa", "return 1"),
+	)
+
+	want := []string{"./p/a.go:1", "./p/a.go:11", "./p/b.go:1", "./p/c.go:1"}
+	for run := 0; run < 5; run++ {
+		results, err := st.Search(context.Background(), "return", "/", types.SearchOptions{Limit: 10})
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, r := range results {
+			got = append(got, fmt.Sprintf("%s:%d", r.FilePath, r.StartLine))
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Fatalf("run %d: order = %v, want %v", run, got, want)
+		}
+	}
+}
+
+func TestFindCallersMatchesQualifiedAndCaseInsensitiveLanguages(t *testing.T) {
+	st := newTestStore(t, nil)
+
+	qualified := testChunk("/p/main.go", 0, "run", "a.b.Method()")
+	qualified.Calls = []string{"a.b.Method"}
+	static := testChunk("/p/util.php", 0, "boot", "App\\Util::method()")
+	static.Language = "php"
+	static.Calls = []string{"App\\Util::method"}
+	other := testChunk("/p/other.go", 0, "other", "x.method()")
+	other.Calls = []string{"x.method"}
+	suffix := testChunk("/p/suffix.go", 0, "suffix", "x.OtherMethod()")
+	suffix.Calls = []string{"x.OtherMethod"}
+	addChunks(t, st, qualified, static, other, suffix)
+
+	callers, err := st.FindCallers(context.Background(), "Method", 10, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, c := range callers {
+		got = append(got, c.Name)
+	}
+	sort.Strings(got)
+	// Go's x.method differs in case; PHP names are case-insensitive
+	if want := []string{"boot", "run"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("callers of Method = %v, want %v", got, want)
+	}
+
+	callers, err = st.FindCallers(context.Background(), "b.Method", 10, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(callers) != 1 || callers[0].Name != "run" {
+		t.Fatalf("callers of b.Method = %+v, want run", callers)
+	}
+}
+
+// markerEmbed embeds texts containing "strongmatch" at similarity 0.71 to a
+// plain query, texts containing "weakmatch" at 0.5, and anything else as the
+// query direction
+func markerEmbed(ctx context.Context, text string) ([]float32, error) {
+	v := make([]float32, 16)
+	v[0] = 1
+	switch {
+	case strings.Contains(text, "strongmatch"):
+		v[1] = 1
+	case strings.Contains(text, "weakmatch"):
+		v[1] = 1.7321
+	}
+	return v, nil
+}
+
+func TestKeywordBoostOnlyReordersNearTies(t *testing.T) {
+	for _, tt := range []struct {
+		band  float32
+		first string
+	}{
+		{0.1, "Loader"},    // ParseConfig is 0.2 below the top match: not boosted
+		{0, "ParseConfig"}, // Boosting every candidate lifts the name match
+	} {
+		st := newTestStore(t, func(cfg *config.Config) { cfg.BoostBand = tt.band })
+		st.embeddingFunc = markerEmbed
+		addChunks(t, st,
+			testChunk("/p/load.go", 0, "Loader", "strongmatch"),
+			testChunk("/p/parse.go", 0, "ParseConfig", "weakmatch"),
+		)
+
+		results, err := st.Search(context.Background(), "parse config", "", types.SearchOptions{Limit: 5})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(results) != 2 || results[0].Name != tt.first {
+			t.Errorf("band %v: results %+v, want %s first", tt.band, results, tt.first)
+		}
+	}
+}
+
+func TestSymbolLookupsFollowCaseSensitivity(t *testing.T) {
+	for _, sensitive := range []bool{true, false} {
+		st := newTestStore(t, func(cfg *config.Config) { cfg.SymbolCaseSensitive = sensitive })
+		ctx := context.Background()
+
+		def := testChunk("/p/foo.go", 0, "Foo", "func Foo() {}")
+		caller := testChunk("/p/main.go", 0, "run", "pkg.Foo()")
+		caller.Calls = []string{"pkg.Foo"}
+		user := testChunk("/p/use.go", 0, "use", "var x Foo")
+		user.References = []string{"Foo"}
+		addChunks(t, st, def, caller, user)
+
+		callers, err := st.FindCallers(ctx, "foo", 10, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		refs, err := st.FindReferencers(ctx, "foo", 10, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		meta, err := st.GetChunkMetadata(ctx, "foo")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if sensitive {
+			if len(callers) != 0 || len(refs) != 0 || meta != nil {
+				t.Errorf("case-sensitive lookup of foo matched Foo: callers=%v refs=%v meta=%v", callers, refs, meta)
+			}
+		} else if len(callers) != 1 || len(refs) != 1 || meta["name"] != "Foo" {
+			t.Errorf("case-insensitive lookup of foo: callers=%v refs=%v meta=%v", callers, refs, meta)
+		}
+
+		// The exact name matches either way
+		if callers, _ := st.FindCallers(ctx, "Foo", 10, ""); len(callers) != 1 {
+			t.Errorf("sensitive=%v: FindCallers(Foo) = %v, want run", sensitive, callers)
+		}
+	}
+}
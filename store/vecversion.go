@@ -0,0 +1,101 @@
+package store
+
+import (
+	"fmt"
+	"log"
+	"strings"
+)
+
+// vecVersionKey is the store_config key holding the sqlite-vec version that created vec_chunks
+const vecVersionKey = "vec_version"
+
+// vecVersion returns the version of the loaded sqlite-vec extension (e.g. "v0.1.6")
+func (s *Store) vecVersion() (string, error) {
+	stmt, _, err := s.db.Prepare(`SELECT vec_version()`)
+	if err != nil {
+		return "", fmt.Errorf("failed to query vec_version: %w", err)
+	}
+	defer stmt.Close()
+
+	if stmt.Step() {
+		return stmt.ColumnText(0), nil
+	}
+	return "", stmt.Err()
+}
+
+// vecVersionsCompatible reports whether two sqlite-vec versions share a major and
+// minor version. Patch releases keep the vec0 storage format; sqlite-vec is
+// pre-1.0, so minor releases may change it.
+func vecVersionsCompatible(a, b string) bool {
+	majorMinor := func(v string) string {
+		parts := strings.SplitN(strings.TrimPrefix(v, "v"), ".", 3)
+		if len(parts) < 2 {
+			return v
+		}
+		return parts[0] + "." + parts[1]
+	}
+	return majorMinor(a) == majorMinor(b)
+}
+
+// checkVecVersion compares the sqlite-vec version that created vec_chunks with
+// the loaded one. On first run the version is stored; on an incompatible
+// version the vector table is rebuilt (see rebuildVecTable).
+func (s *Store) checkVecVersion() error {
+	current, err := s.vecVersion()
+	if err != nil {
+		return err
+	}
+
+	stored, err := s.getConfigValue(vecVersionKey)
+	if err != nil {
+		return err
+	}
+	if stored == current {
+		return nil
+	}
+
+	if stored != "" && !vecVersionsCompatible(stored, current) {
+		log.Printf("Warning: vector table was created by sqlite-vec %s but %s is loaded; rebuilding it", stored, current)
+		if err := s.rebuildVecTable(); err != nil {
+			return err
+		}
+	}
+	return s.setConfigValue(vecVersionKey, current)
+}
+
+// rebuildVecTable recreates vec_chunks with the loaded sqlite-vec, keeping
+// rowids so vec_chunk_map stays valid. Stored vectors are copied over when the
+// old table can still be read; otherwise the table is recreated empty and every
+// chunk is flagged for re-embedding (see ReembedIfNeeded).
+func (s *Store) rebuildVecTable() error {
+	if err := s.db.Exec("BEGIN TRANSACTION"); err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+
+	copied := s.db.Exec(`CREATE TEMP TABLE vec_chunks_rebuild AS SELECT rowid AS id, embedding FROM vec_chunks`) == nil
+
+	steps := []string{"DROP TABLE IF EXISTS vec_chunks", s.vecTableSQL()}
+	if copied {
+		steps = append(steps,
+			`INSERT INTO vec_chunks(rowid, embedding) SELECT id, embedding FROM vec_chunks_rebuild`,
+			"DROP TABLE vec_chunks_rebuild")
+	}
+	for _, step := range steps {
+		if err := s.db.Exec(step); err != nil {
+			s.db.Exec("ROLLBACK")
+			return fmt.Errorf("failed to rebuild vec_chunks: %w", err)
+		}
+	}
+
+	if err := s.db.Exec("COMMIT"); err != nil {
+		return fmt.Errorf("failed to commit vec_chunks rebuild: %w", err)
+	}
+
+	if copied {
+		log.Printf("Rebuilt vector table with stored vectors")
+	} else {
+		log.Printf("Warning: stored vectors could not be read; all chunks will be re-embedded")
+		s.reembedNeeded = true
+	}
+	return nil
+}
@@ -0,0 +1,75 @@
+package store
+
+import (
+	"bytes"
+	"context"
+	"log"
+	"os"
+	"strings"
+	"testing"
+
+	"mcp-semantic-search/config"
+	"mcp-semantic-search/types"
+)
+
+func TestVecVersionsCompatible(t *testing.T) {
+	for _, tt := range []struct {
+		a, b string
+		want bool
+	}{
+		{"v0.1.6", "v0.1.7", true},
+		{"v0.1.6", "v0.2.0", false},
+		{"v1.0.0", "v0.1.0", false},
+		{"v0.1.6", "0.1.6", true},
+	} {
+		if got := vecVersionsCompatible(tt.a, tt.b); got != tt.want {
+			t.Errorf("vecVersionsCompatible(%s, %s) = %v, want %v", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestVecTableRebuiltOnIncompatibleVersionKeepsVectors(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.DBPath = t.TempDir()
+	ctx := context.Background()
+
+	st, err := NewStore(cfg, fakeEmbed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	addChunks(t, st, testChunk("/p/a.go", 0, "Alpha", "func Alpha() {}"), testChunk("/p/b.go", 0, "Beta", "func Beta(n int) int { return n }"))
+	// Pretend the vector table was created by an older, incompatible sqlite-vec
+	if err := st.setConfigValue(vecVersionKey, "v0.0.1"); err != nil {
+		t.Fatal(err)
+	}
+	st.Close()
+
+	var logs bytes.Buffer
+	log.SetOutput(&logs)
+	defer log.SetOutput(os.Stderr)
+
+	st, err = NewStore(cfg, fakeEmbed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer st.Close()
+
+	if !strings.Contains(logs.String(), "created by sqlite-vec v0.0.1") || !strings.Contains(logs.String(), "Rebuilt vector table with stored vectors") {
+		t.Fatalf("logs = %q, want a rebuild that kept the vectors", logs.String())
+	}
+	current, _ := st.vecVersion()
+	if stored, _ := st.getConfigValue(vecVersionKey); stored != current {
+		t.Errorf("stored vec_version = %q, want the loaded %q", stored, current)
+	}
+	if st.reembedNeeded {
+		t.Error("vectors were copied, yet chunks were flagged for re-embedding")
+	}
+
+	results, err := st.Search(ctx, "function", "", types.SearchOptions{Limit: 5})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 2 {
+		t.Fatalf("search after rebuild returned %d results, want 2", len(results))
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"sort"
 	"strings"
 
 	"mcp-semantic-search/indexer"
@@ -16,9 +17,27 @@ import (
 // RegisterTools registers all MCP tools with the server
 func RegisterTools(s *server.MCPServer, idx *indexer.Indexer) {
 	registerSearch(s, idx)
+	registerSymbolHistory(s, idx)
+	registerAPISurface(s, idx)
+	registerPruneProjects(s, idx)
+	registerFindImplementations(s, idx)
+	registerGotoDefinition(s, idx)
+	if idx.TestsIndexed() {
+		registerFindTests(s, idx)
+	}
+	registerFindDuplicates(s, idx)
+	registerComplexFunctions(s, idx)
+	registerDependencyGraph(s, idx)
+	registerFileStatus(s, idx)
+	registerExplainIndex(s, idx)
+	registerSearchDiff(s, idx)
+	registerTestModel(s, idx)
+	if idx.FeedbackEnabled() {
+		registerFeedback(s, idx)
+	}
 }
 
-// registerSearch registers the search tool - the main (and only) tool
+// registerSearch registers the search tool - the main tool
 func registerSearch(s *server.MCPServer, idx *indexer.Indexer) {
 	tool := mcp.NewTool("search",
 		mcp.WithDescription(`Semantic code search with usage analysis.
@@ -52,6 +71,13 @@ Use natural language queries like:
 		mcp.WithString("path",
 			mcp.Description("Filter results to this subdirectory path (e.g., 'src/components' or './lib'). Only returns results from files within this path."),
 		),
+		mcp.WithArray("paths",
+			mcp.Description("Restrict results to these files (relative to the current directory or absolute), e.g. files found by a prior grep."),
+			mcp.WithStringItems(),
+		),
+		mcp.WithString("base_path",
+			mcp.Description("Absolute directory that relative paths (path, paths, result paths) resolve against, and outside of which results are skipped unless filtered; pass your working directory when it differs from the server's (default: the server's working directory)."),
+		),
 		mcp.WithString("language",
 			mcp.Description("Filter by programming language (e.g., 'go', 'python', 'javascript', 'typescript'). Case-insensitive."),
 		),
@@ -61,26 +87,75 @@ Use natural language queries like:
 		mcp.WithBoolean("code_only",
 			mcp.Description("Exclude non-code files like JSON, YAML, Markdown, HTML, CSS (default: true)."),
 		),
+		mcp.WithBoolean("comments_only",
+			mcp.Description("Only search comments and docstrings (requires MCP_INDEX_COMMENTS=true at index time) (default: false)."),
+		),
 		mcp.WithNumber("min_similarity",
 			mcp.Description("Minimum similarity score threshold (0.0-1.0). Results below this score are filtered out."),
 		),
+		mcp.WithNumber("min_complexity",
+			mcp.Description("Only return functions and methods with at least this estimated cyclomatic complexity (1 + branches, loops, cases, && and ||)."),
+		),
+		mcp.WithString("calls",
+			mcp.Description("Only return chunks that call this symbol, e.g. 'Exec' or 'db.Exec' (qualified calls match by their last segment). Combine with the query to find definitions that call X."),
+		),
 		mcp.WithNumber("limit",
-			mcp.Description("Maximum number of results to return (default: 5, max: 50)"),
+			mcp.Description(fmt.Sprintf("Maximum number of results to return (default: 5, max: %d)", idx.MaxSearchLimit())),
+		),
+		mcp.WithString("sort",
+			mcp.Description("Result order: 'relevance' (default) or 'recent' (most recently modified files first, among results close to the best match)."),
+		),
+		mcp.WithString("order_by",
+			mcp.Description("Order of the returned results: 'similarity' (default), 'name', 'path', or 'line'. Results are still selected by relevance."),
+		),
+		mcp.WithBoolean("expand",
+			mcp.Description("Also search abbreviation and word-stem variants of the query (e.g. 'auth' also finds 'authenticate'). Default: false, or true when MCP_QUERY_EXPANSION is set."),
+		),
+		mcp.WithBoolean("debug",
+			mcp.Description("Include each result's raw cosine distance from the vector index (raw_distance in JSON, a score line in text) to correlate scores. Default: false."),
+		),
+		mcp.WithNumber("precision",
+			mcp.Description("Decimal places of the similarity and distance on debug score lines (default: 4)."),
+		),
+		mcp.WithString("group_by",
+			mcp.Description("Group results in the output: 'file' (all results from one file together), 'type' (functions, then classes, etc.), or 'none' (default: ranked by similarity)."),
+		),
+		mcp.WithBoolean("dedent",
+			mcp.Description("Strip the indentation common to all lines of each result's code (e.g. a method nested in a class), keeping relative indentation. Display only; default: false."),
+		),
+		mcp.WithString("format",
+			mcp.Description("Output format: 'text' (default) or 'json' (the full response with usage data and graph)."),
+		),
+		mcp.WithNumber("max_bytes",
+			mcp.Description("With format 'json', cap the response size in bytes; trailing results are dropped whole and \"truncated\" is set (default: 0, no cap)."),
 		),
 	)
 
 	s.AddTool(tool, func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
 		query, err := req.RequireString("query")
 		if err != nil {
-			return mcp.NewToolResultError("query parameter is required"), nil
+			return toolError("Search", types.NewError(types.ErrCodeInvalidRequest, "query parameter is required", nil)), nil
+		}
+		query = strings.TrimSpace(query)
+		if query == "" {
+			return toolError("Search", types.NewError(types.ErrCodeQueryEmpty, "query cannot be empty", nil)), nil
 		}
 
 		// Build search options from parameters
 		opts := types.SearchOptions{
-			Path:      req.GetString("path", ""),
-			Language:  req.GetString("language", ""),
-			ChunkType: req.GetString("type", ""),
-			CodeOnly:  req.GetBool("code_only", true),
+			Path:          req.GetString("path", ""),
+			Paths:         req.GetStringSlice("paths", nil),
+			BasePath:      strings.TrimSpace(req.GetString("base_path", "")),
+			Language:      req.GetString("language", ""),
+			ChunkType:     req.GetString("type", ""),
+			CodeOnly:      req.GetBool("code_only", true),
+			CommentsOnly:  req.GetBool("comments_only", false),
+			MinComplexity: req.GetInt("min_complexity", 0),
+			Calls:         strings.TrimSpace(req.GetString("calls", "")),
+			Sort:          strings.ToLower(req.GetString("sort", "relevance")),
+			OrderBy:       strings.ToLower(req.GetString("order_by", "similarity")),
+			Expand:        req.GetBool("expand", false),
+			Debug:         req.GetBool("debug", false),
 		}
 
 		// Get min_similarity (0.0-1.0)
@@ -90,9 +165,6 @@ Use natural language queries like:
 
 		// Get limit with new default of 5
 		opts.Limit = req.GetInt("limit", 5)
-		if opts.Limit > 50 {
-			opts.Limit = 50
-		}
 		if opts.Limit < 1 {
 			opts.Limit = 1
 		}
@@ -100,29 +172,990 @@ Use natural language queries like:
 		// Search with usage analysis
 		response, err := idx.SearchWithUsage(ctx, query, opts)
 		if err != nil {
-			return mcp.NewToolResultError(fmt.Sprintf("Search failed: %v", err)), nil
+			return toolError("Search", err), nil
 		}
 
 		if response.Count == 0 {
 			return mcp.NewToolResultText("No matching results found. Make sure you have indexed projects first."), nil
 		}
 
+		// Group results for presentation (ranking within each group is preserved)
+		groupBy := strings.ToLower(req.GetString("group_by", ""))
+		response.Results = groupResults(response.Results, groupBy)
+
+		if req.GetBool("dedent", false) {
+			for i := range response.Results {
+				response.Results[i].Content = dedent(response.Results[i].Content)
+			}
+		}
+
+		if strings.ToLower(req.GetString("format", "text")) == "json" {
+			data, err := marshalSearchResponse(response, req.GetInt("max_bytes", 0))
+			if err != nil {
+				return toolError("Search", err), nil
+			}
+			return mcp.NewToolResultText(data), nil
+		}
+
 		// Return plain text response for AI consumption
-		return mcp.NewToolResultText(formatTextResponse(response)), nil
+		return mcp.NewToolResultText(formatTextResponse(response, groupBy, req.GetInt("precision", 4))), nil
+	})
+}
+
+// registerSearchDiff registers the search_diff tool
+func registerSearchDiff(s *server.MCPServer, idx *indexer.Indexer) {
+	tool := mcp.NewTool("search_diff",
+		mcp.WithDescription(`Combine two semantic searches with a set operation.
+
+Runs both queries with the same filters and compares results by file and line range:
+- minus: code matching the query but not the other query (e.g. "database access" minus "tests")
+- intersect: code matching both queries
+
+Results keep the ranking of the first query.`),
+		mcp.WithString("query",
+			mcp.Required(),
+			mcp.Description("Natural language search query whose results are returned"),
+		),
+		mcp.WithString("other_query",
+			mcp.Required(),
+			mcp.Description("Second query, removed from (minus) or required in (intersect) the results"),
+		),
+		mcp.WithString("operation",
+			mcp.Description("'minus' (default) or 'intersect'"),
+		),
+		mcp.WithString("path",
+			mcp.Description("Filter results to this subdirectory path (e.g., 'src/components' or './lib')."),
+		),
+		mcp.WithString("language",
+			mcp.Description("Filter by programming language (e.g., 'go', 'python'). Case-insensitive."),
+		),
+		mcp.WithString("type",
+			mcp.Description("Filter by chunk type: 'function', 'class', 'method', or 'all' (default: 'all')."),
+		),
+		mcp.WithBoolean("code_only",
+			mcp.Description("Exclude non-code files like JSON, YAML, Markdown, HTML, CSS (default: true)."),
+		),
+		mcp.WithNumber("min_similarity",
+			mcp.Description("Minimum similarity (0.0-1.0) for a result of either query to count. Set it so 'minus' only removes code that really matches the other query."),
+		),
+		mcp.WithNumber("limit",
+			mcp.Description(fmt.Sprintf("Maximum number of results to return (default: 5, max: %d)", idx.MaxSearchLimit())),
+		),
+	)
+
+	s.AddTool(tool, func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		query := strings.TrimSpace(req.GetString("query", ""))
+		otherQuery := strings.TrimSpace(req.GetString("other_query", ""))
+		if query == "" || otherQuery == "" {
+			return toolError("Search diff", types.NewError(types.ErrCodeQueryEmpty, "query and other_query cannot be empty", nil)), nil
+		}
+
+		opts := types.SearchOptions{
+			Path:      req.GetString("path", ""),
+			Language:  req.GetString("language", ""),
+			ChunkType: req.GetString("type", ""),
+			CodeOnly:  req.GetBool("code_only", true),
+			Limit:     req.GetInt("limit", 5),
+		}
+		if minSim := req.GetFloat("min_similarity", 0.0); minSim > 0 && minSim <= 1.0 {
+			opts.MinSimilarity = float32(minSim)
+		}
+		if opts.Limit < 1 {
+			opts.Limit = 1
+		}
+
+		operation := strings.ToLower(req.GetString("operation", "minus"))
+		results, err := idx.SearchDiff(ctx, query, otherQuery, operation, opts)
+		if err != nil {
+			return toolError("Search diff", err), nil
+		}
+
+		if len(results) == 0 {
+			return mcp.NewToolResultText(fmt.Sprintf("No results left after %s.", operation)), nil
+		}
+
+		return mcp.NewToolResultText(formatTextResponse(&types.SearchResponse{Count: len(results), Results: results}, "", 4)), nil
+	})
+}
+
+// registerFeedback registers the feedback tool (only with MCP_COLLECT_FEEDBACK)
+func registerFeedback(s *server.MCPServer, idx *indexer.Indexer) {
+	tool := mcp.NewTool("feedback",
+		mcp.WithDescription(`Report whether a search result was useful for a query.
+
+Call this after using (or rejecting) a result from the search tool. Each report is stored with the query's hash and the result's similarity to build a relevance dataset; the query text itself is not stored.`),
+		mcp.WithString("query",
+			mcp.Required(),
+			mcp.Description("The search query that returned the result"),
+		),
+		mcp.WithString("file",
+			mcp.Required(),
+			mcp.Description("File of the result, as shown in the search output (e.g. './indexer/indexer.go')"),
+		),
+		mcp.WithNumber("line",
+			mcp.Required(),
+			mcp.Description("A line inside the result, e.g. its first line"),
+		),
+		mcp.WithBoolean("useful",
+			mcp.Description("Whether the result was useful (default: true)"),
+		),
+	)
+
+	s.AddTool(tool, func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		query := strings.TrimSpace(req.GetString("query", ""))
+		if query == "" {
+			return toolError("Feedback", types.NewError(types.ErrCodeQueryEmpty, "query cannot be empty", nil)), nil
+		}
+		file := req.GetString("file", "")
+		line := req.GetInt("line", 0)
+		if file == "" || line < 1 {
+			return toolError("Feedback", types.NewError(types.ErrCodeInvalidRequest, "file and line parameters are required", nil)), nil
+		}
+
+		entry, err := idx.RecordFeedback(ctx, query, file, line, req.GetBool("useful", true))
+		if err != nil {
+			return toolError("Feedback", err), nil
+		}
+
+		return mcp.NewToolResultText(fmt.Sprintf("Recorded feedback for %s (similarity %.2f).", entry.ChunkID, entry.Similarity)), nil
+	})
+}
+
+// registerSymbolHistory registers the symbol_history tool
+func registerSymbolHistory(s *server.MCPServer, idx *indexer.Indexer) {
+	tool := mcp.NewTool("symbol_history",
+		mcp.WithDescription(`Show how an indexed symbol changed across recent git commits.
+
+Uses 'git log -L' on the symbol's current line range. Useful to understand how a function evolved before modifying it. Best-effort: only works for files inside a git repository.`),
+		mcp.WithString("symbol",
+			mcp.Required(),
+			mcp.Description("Exact symbol name (function, method, class) as indexed"),
+		),
+		mcp.WithNumber("max_commits",
+			mcp.Description("Maximum number of commits to show (default: 5, max: 20)"),
+		),
+	)
+
+	s.AddTool(tool, func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		symbol, err := req.RequireString("symbol")
+		if err != nil || strings.TrimSpace(symbol) == "" {
+			return toolError("Symbol history", types.NewError(types.ErrCodeInvalidRequest, "symbol parameter is required", nil)), nil
+		}
+
+		maxCommits := req.GetInt("max_commits", 5)
+		if maxCommits > 20 {
+			maxCommits = 20
+		}
+		if maxCommits < 1 {
+			maxCommits = 1
+		}
+
+		history, err := idx.SymbolHistory(ctx, strings.TrimSpace(symbol), maxCommits)
+		if err != nil {
+			return toolError("Symbol history", err), nil
+		}
+
+		return mcp.NewToolResultText(formatSymbolHistory(history)), nil
+	})
+}
+
+// registerAPISurface registers the api_surface tool
+func registerAPISurface(s *server.MCPServer, idx *indexer.Indexer) {
+	tool := mcp.NewTool("api_surface",
+		mcp.WithDescription(`List the exported (public) functions, methods and classes of an indexed project, grouped by file.
+
+Useful for generating docs or reviewing the public API. This is an enumeration of the index, not a semantic search. Test files are excluded.`),
+		mcp.WithString("path",
+			mcp.Description("Project or subdirectory path (default: current directory)"),
+		),
+		mcp.WithNumber("limit",
+			mcp.Description("Max symbols to return (default and cap: MCP_MAX_LIST_RESULTS, 200)"),
+		),
+		mcp.WithNumber("offset",
+			mcp.Description("Number of symbols to skip, for paging through large projects (default: 0)"),
+		),
+	)
+
+	s.AddTool(tool, func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		path := req.GetString("path", ".")
+		if strings.TrimSpace(path) == "" {
+			path = "."
+		}
+
+		result, err := idx.APISurface(ctx, path, req.GetInt("limit", 0), req.GetInt("offset", 0))
+		if err != nil {
+			return toolError("API surface", err), nil
+		}
+
+		if result.Total == 0 {
+			return mcp.NewToolResultText("No exported symbols found. Make sure the project is indexed."), nil
+		}
+
+		return mcp.NewToolResultText(formatAPISurface(result)), nil
+	})
+}
+
+// registerComplexFunctions registers the complex_functions tool
+func registerComplexFunctions(s *server.MCPServer, idx *indexer.Indexer) {
+	tool := mcp.NewTool("complex_functions",
+		mcp.WithDescription(`List the most complex functions and methods of an indexed project.
+
+Ranks by an estimated cyclomatic complexity computed at index time: 1 plus the number of branches, loops, case arms, catch clauses, ternaries and && / || operators. Useful for picking refactoring or review targets. This is an enumeration of the index, not a semantic search.`),
+		mcp.WithString("path",
+			mcp.Description("Project or subdirectory path (default: current directory)"),
+		),
+		mcp.WithNumber("limit",
+			mcp.Description("Max functions to return (default: 20, capped at MCP_MAX_LIST_RESULTS)"),
+		),
+	)
+
+	s.AddTool(tool, func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		path := req.GetString("path", ".")
+		if strings.TrimSpace(path) == "" {
+			path = "."
+		}
+
+		symbols, err := idx.ComplexFunctions(ctx, path, req.GetInt("limit", 20))
+		if err != nil {
+			return toolError("Complex functions", err), nil
+		}
+
+		if len(symbols) == 0 {
+			return mcp.NewToolResultText("No functions with complexity data found. Make sure the project is indexed (reindex if it was indexed by an older version)."), nil
+		}
+
+		var sb strings.Builder
+		sb.WriteString(fmt.Sprintf("Top %d functions by complexity:\n\n", len(symbols)))
+		for _, sym := range symbols {
+			sb.WriteString(fmt.Sprintf("%4d  %s (%s) %s:%d-%d\n", sym.Complexity, sym.Name, sym.ChunkType, sym.FilePath, sym.StartLine, sym.EndLine))
+		}
+
+		return mcp.NewToolResultText(sb.String()), nil
+	})
+}
+
+// registerDependencyGraph registers the dependency_graph tool
+func registerDependencyGraph(s *server.MCPServer, idx *indexer.Indexer) {
+	tool := mcp.NewTool("dependency_graph",
+		mcp.WithDescription(`Show the import graph of an indexed project.
+
+Returns each file's imports and the internal dependencies between project files: an edge A -> B means A imports B (or B's package). Imports are resolved relative to the importing file, by Go module path, or by matching the project path they name; third-party and standard library imports are listed but produce no edges. Useful for understanding module coupling. This is an enumeration of the index, not a semantic search.`),
+		mcp.WithString("path",
+			mcp.Description("Project or subdirectory path (default: current directory)"),
+		),
+		mcp.WithString("format",
+			mcp.Description("Output format: 'text' (default) or 'json' (nodes and edges)."),
+		),
+	)
+
+	s.AddTool(tool, func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		path := req.GetString("path", ".")
+		if strings.TrimSpace(path) == "" {
+			path = "."
+		}
+
+		graph, err := idx.DependencyGraph(ctx, path)
+		if err != nil {
+			return toolError("Dependency graph", err), nil
+		}
+
+		if len(graph.Nodes) == 0 {
+			return mcp.NewToolResultText("No indexed files found. Make sure the project is indexed (reindex if it was indexed by an older version)."), nil
+		}
+
+		if strings.ToLower(req.GetString("format", "text")) == "json" {
+			data, err := json.Marshal(graph)
+			if err != nil {
+				return toolError("Dependency graph", err), nil
+			}
+			return mcp.NewToolResultText(string(data)), nil
+		}
+
+		return mcp.NewToolResultText(formatDependencyGraph(graph)), nil
+	})
+}
+
+// formatDependencyGraph renders a dependency graph as text: the internal
+// dependencies and external imports of each file, then the most imported files
+func formatDependencyGraph(graph *types.DependencyGraph) string {
+	deps := make(map[string][]string)
+	internal := make(map[string]map[string]bool)
+	importedBy := make(map[string]int)
+	for _, e := range graph.Edges {
+		deps[e.From] = append(deps[e.From], e.To)
+		if internal[e.From] == nil {
+			internal[e.From] = make(map[string]bool)
+		}
+		internal[e.From][e.Import] = true
+		importedBy[e.To]++
+	}
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("Dependency graph of %s: %d files, %d internal dependencies\n\n", graph.Root, len(graph.Nodes), len(graph.Edges)))
+
+	for _, node := range graph.Nodes {
+		var external []string
+		for _, imp := range node.Imports {
+			if !internal[node.Path][imp] {
+				external = append(external, imp)
+			}
+		}
+		if len(deps[node.Path]) == 0 && len(external) == 0 {
+			continue
+		}
+
+		sb.WriteString(node.Path + "\n")
+		for _, to := range deps[node.Path] {
+			sb.WriteString(fmt.Sprintf("   -> %s\n", to))
+		}
+		if len(external) > 0 {
+			sb.WriteString(fmt.Sprintf("   external: %s\n", strings.Join(external, ", ")))
+		}
+	}
+
+	if len(importedBy) > 0 {
+		files := make([]string, 0, len(importedBy))
+		for file := range importedBy {
+			files = append(files, file)
+		}
+		sort.Slice(files, func(i, j int) bool {
+			if importedBy[files[i]] != importedBy[files[j]] {
+				return importedBy[files[i]] > importedBy[files[j]]
+			}
+			return files[i] < files[j]
+		})
+		if len(files) > 10 {
+			files = files[:10]
+		}
+
+		sb.WriteString("\nMost imported:\n")
+		for _, file := range files {
+			sb.WriteString(fmt.Sprintf("%4d  %s\n", importedBy[file], file))
+		}
+	}
+
+	return sb.String()
+}
+
+// registerFileStatus registers the file_status tool
+func registerFileStatus(s *server.MCPServer, idx *indexer.Indexer) {
+	tool := mcp.NewTool("file_status",
+		mcp.WithDescription(`Explain the indexing status of one file.
+
+Reports whether the file is indexed (and with how many chunks), whether it changed since it was indexed, its detected language, and why indexing skips it if it does (.gitignore with the matching file and rule, excluded directory, size limit, extension, binary or minified content, not inside an indexed folder). Use when a file does not show up in search results.`),
+		mcp.WithString("path",
+			mcp.Required(),
+			mcp.Description("File path (absolute or relative to the current directory)"),
+		),
+		mcp.WithString("format",
+			mcp.Description("Output format: 'text' (default) or 'json'."),
+		),
+	)
+
+	s.AddTool(tool, func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		path, err := req.RequireString("path")
+		if err != nil || strings.TrimSpace(path) == "" {
+			return toolError("File status", types.NewError(types.ErrCodeInvalidRequest, "path parameter is required", nil)), nil
+		}
+
+		status, err := idx.FileStatus(ctx, strings.TrimSpace(path))
+		if err != nil {
+			return toolError("File status", err), nil
+		}
+
+		if strings.ToLower(req.GetString("format", "text")) == "json" {
+			data, err := json.Marshal(status)
+			if err != nil {
+				return toolError("File status", err), nil
+			}
+			return mcp.NewToolResultText(string(data)), nil
+		}
+
+		return mcp.NewToolResultText(formatFileStatus(status)), nil
+	})
+}
+
+// formatFileStatus renders a file's indexing diagnosis as text
+func formatFileStatus(status *types.FileStatus) string {
+	var sb strings.Builder
+
+	sb.WriteString(fmt.Sprintf("File: %s\n", status.Path))
+	sb.WriteString(fmt.Sprintf("Language: %s\n", status.Language))
+	if status.Project != "" {
+		sb.WriteString(fmt.Sprintf("Indexed folder: %s\n", status.Project))
+	}
+	if status.Exists {
+		sb.WriteString(fmt.Sprintf("Size: %d bytes\n", status.Size))
+	}
+
+	if status.Indexed {
+		sb.WriteString(fmt.Sprintf("Indexed: yes (%d chunks)\n", status.Chunks))
+	} else {
+		sb.WriteString("Indexed: no\n")
+	}
+
+	switch {
+	case status.StoredHash == "":
+		sb.WriteString("Hash: no stored hash\n")
+	case !status.Exists:
+		sb.WriteString(fmt.Sprintf("Hash: stored %s, file deleted since indexing\n", shortHash(status.StoredHash)))
+	case status.Stale:
+		sb.WriteString(fmt.Sprintf("Hash: stored %s, current %s (changed since indexing, reindex needed)\n",
+			shortHash(status.StoredHash), shortHash(status.CurrentHash)))
+	default:
+		sb.WriteString(fmt.Sprintf("Hash: %s (up to date)\n", shortHash(status.CurrentHash)))
+	}
+
+	if status.Excluded != "" {
+		sb.WriteString(fmt.Sprintf("Skipped by indexing: %s\n", status.Excluded))
+	} else if !status.Indexed {
+		sb.WriteString("Skipped by indexing: no; the file should be indexed on the next run (reindex the folder)\n")
+	}
+
+	return sb.String()
+}
+
+// registerExplainIndex registers the explain_index tool
+func registerExplainIndex(s *server.MCPServer, idx *indexer.Indexer) {
+	tool := mcp.NewTool("explain_index",
+		mcp.WithDescription(`Explain every indexing rule for one file.
+
+Lists each rule indexing applies to the file and whether it passes: excluded or ignored parent directory, size limit (MCP_MAX_FILE_SIZE), empty file, excluded or not included extension, test file, hidden file, .gitignore (with the matching file and rule), binary or minified content, invalid UTF-8, and being inside an indexed folder. Also reports the detected language and whether the file is in the index now. Use when a file does not show up in search results; file_status reports only the first failing rule plus hash staleness.`),
+		mcp.WithString("path",
+			mcp.Required(),
+			mcp.Description("File path (absolute or relative to the current directory)"),
+		),
+		mcp.WithString("format",
+			mcp.Description("Output format: 'text' (default) or 'json'."),
+		),
+	)
+
+	s.AddTool(tool, func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		path, err := req.RequireString("path")
+		if err != nil || strings.TrimSpace(path) == "" {
+			return toolError("Explain index", types.NewError(types.ErrCodeInvalidRequest, "path parameter is required", nil)), nil
+		}
+
+		explanation, err := idx.ExplainIndex(ctx, strings.TrimSpace(path))
+		if err != nil {
+			return toolError("Explain index", err), nil
+		}
+
+		if strings.ToLower(req.GetString("format", "text")) == "json" {
+			data, err := json.Marshal(explanation)
+			if err != nil {
+				return toolError("Explain index", err), nil
+			}
+			return mcp.NewToolResultText(string(data)), nil
+		}
+
+		return mcp.NewToolResultText(formatIndexExplanation(explanation)), nil
 	})
 }
 
+// formatIndexExplanation renders a file's indexing rules as text
+func formatIndexExplanation(explanation *types.IndexExplanation) string {
+	var sb strings.Builder
+
+	sb.WriteString(fmt.Sprintf("File: %s\n", explanation.Path))
+	sb.WriteString(fmt.Sprintf("Language: %s\n", explanation.Language))
+	if explanation.Project != "" {
+		sb.WriteString(fmt.Sprintf("Indexed folder: %s\n", explanation.Project))
+	}
+	if explanation.Exists {
+		sb.WriteString(fmt.Sprintf("Size: %d bytes\n", explanation.Size))
+	}
+	if explanation.Indexed {
+		sb.WriteString(fmt.Sprintf("In index: yes (%d chunks)\n", explanation.Chunks))
+	} else {
+		sb.WriteString("In index: no\n")
+	}
+
+	sb.WriteString("\nRules:\n")
+	for _, check := range explanation.Checks {
+		if check.Passed {
+			sb.WriteString(fmt.Sprintf("  pass  %s\n", check.Check))
+		} else {
+			sb.WriteString(fmt.Sprintf("  FAIL  %s: %s\n", check.Check, check.Detail))
+		}
+	}
+
+	switch {
+	case explanation.Excluded != "":
+		sb.WriteString(fmt.Sprintf("\nSkipped by indexing: %s\n", explanation.Excluded))
+	case !explanation.Indexed:
+		sb.WriteString("\nSkipped by indexing: no; the file should be indexed on the next run (reindex the folder)\n")
+	}
+
+	return sb.String()
+}
+
+// shortHash abbreviates a content hash for display
+func shortHash(hash string) string {
+	if len(hash) > 12 {
+		return hash[:12]
+	}
+	return hash
+}
+
+// registerTestModel registers the test_model tool
+func registerTestModel(s *server.MCPServer, idx *indexer.Indexer) {
+	tool := mcp.NewTool("test_model",
+		mcp.WithDescription(`Try an Ollama embedding model without changing the index.
+
+Embeds a sample text with the given model and reports its vector dimension and latency next to the model and dimension the index uses. Use it to compare models before switching MCP_EMBEDDING_MODEL and reindexing. The index itself always uses the configured model.`),
+		mcp.WithString("model",
+			mcp.Required(),
+			mcp.Description("Ollama model name, e.g. 'nomic-embed-text' or 'mxbai-embed-large' (must be pulled)"),
+		),
+		mcp.WithString("text",
+			mcp.Description("Sample text to embed (default: a short code snippet)"),
+		),
+	)
+
+	s.AddTool(tool, func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		model, err := req.RequireString("model")
+		if err != nil || strings.TrimSpace(model) == "" {
+			return toolError("Test model", types.NewError(types.ErrCodeInvalidRequest, "model parameter is required", nil)), nil
+		}
+
+		result, err := idx.TestModel(ctx, model, req.GetString("text", ""))
+		if err != nil {
+			return toolError("Test model", err), nil
+		}
+
+		var sb strings.Builder
+		sb.WriteString(fmt.Sprintf("Model %s: %d dimensions, %dms\n", result.Model, result.Dimension, result.LatencyMs))
+		sb.WriteString(fmt.Sprintf("Index: %s, %d dimensions\n", result.IndexModel, result.IndexDimension))
+		if result.Model == result.IndexModel {
+			sb.WriteString("\nThis is the model the index already uses.\n")
+		} else if result.SameDimension {
+			sb.WriteString("\nSwitching to this model requires reindexing: vectors from different models are not comparable.\n")
+		} else {
+			sb.WriteString("\nSwitching to this model rebuilds the vector table (different dimension) and requires reindexing.\n")
+		}
+
+		return mcp.NewToolResultText(sb.String()), nil
+	})
+}
+
+// registerFindImplementations registers the find_implementations tool
+func registerFindImplementations(s *server.MCPServer, idx *indexer.Indexer) {
+	tool := mcp.NewTool("find_implementations",
+		mcp.WithDescription(`Find the types that implement an interface (Go, Java, TypeScript).
+
+Matches classes that name the interface in an implements/extends clause, and types whose methods cover all of the interface's methods (Go-style implicit implementation). More precise than a reference search for "what implements X".`),
+		mcp.WithString("interface",
+			mcp.Required(),
+			mcp.Description("Exact interface name as indexed, e.g. 'Store' or 'Handler'"),
+		),
+		mcp.WithString("path",
+			mcp.Description("Only return implementations within this project or subdirectory (default: current directory)"),
+		),
+	)
+
+	s.AddTool(tool, func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		name, err := req.RequireString("interface")
+		if err != nil || strings.TrimSpace(name) == "" {
+			return toolError("Find implementations", types.NewError(types.ErrCodeInvalidRequest, "interface parameter is required", nil)), nil
+		}
+		name = strings.TrimSpace(name)
+
+		path := req.GetString("path", ".")
+		if strings.TrimSpace(path) == "" {
+			path = "."
+		}
+
+		implementors, err := idx.FindImplementations(ctx, name, path)
+		if err != nil {
+			return toolError("Find implementations", err), nil
+		}
+
+		if len(implementors) == 0 {
+			return mcp.NewToolResultText(fmt.Sprintf("No implementations of %s found.", name)), nil
+		}
+
+		var sb strings.Builder
+		sb.WriteString(fmt.Sprintf("Found %d implementations of %s:\n", len(implementors), name))
+		for _, impl := range implementors {
+			sb.WriteString(fmt.Sprintf("\n- %s (%s) %s:%d [%s]", impl.Name, impl.Language, impl.FilePath, impl.Line, impl.Match))
+		}
+		sb.WriteString("\n")
+
+		return mcp.NewToolResultText(sb.String()), nil
+	})
+}
+
+// registerFindTests registers the find_tests tool
+func registerFindTests(s *server.MCPServer, idx *indexer.Indexer) {
+	tool := mcp.NewTool("find_tests",
+		mcp.WithDescription(`Find the tests that exercise a function, directly or through other functions.
+
+Walks the call graph upward from the symbol (up to 5 levels) and returns every caller that is a test, with its file:line and how many calls away it is. Use it to find where to add or update coverage for a change.`),
+		mcp.WithString("symbol",
+			mcp.Required(),
+			mcp.Description("Function or method name, e.g. 'ChunkFile'"),
+		),
+		mcp.WithString("path",
+			mcp.Description("Only return tests within this project or subdirectory (default: current directory)"),
+		),
+	)
+
+	s.AddTool(tool, func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		symbol, err := req.RequireString("symbol")
+		if err != nil || strings.TrimSpace(symbol) == "" {
+			return toolError("Find tests", types.NewError(types.ErrCodeInvalidRequest, "symbol parameter is required", nil)), nil
+		}
+
+		path := req.GetString("path", ".")
+		if strings.TrimSpace(path) == "" {
+			path = "."
+		}
+
+		tests, truncated, err := idx.FindTests(ctx, symbol, path)
+		if err != nil {
+			return toolError("Find tests", err), nil
+		}
+
+		if len(tests) == 0 {
+			return mcp.NewToolResultText(fmt.Sprintf("No tests reach %s.", symbol)), nil
+		}
+
+		var sb strings.Builder
+		sb.WriteString(fmt.Sprintf("Found %d tests reaching %s:\n", len(tests), symbol))
+		for _, t := range tests {
+			via := "direct"
+			if t.Depth > 1 {
+				via = fmt.Sprintf("%d calls away", t.Depth)
+			}
+			sb.WriteString(fmt.Sprintf("\n- %s %s:%d (%s)", t.Name, t.FilePath, t.Line, via))
+		}
+		sb.WriteString("\n")
+		if truncated {
+			sb.WriteString("\n(caller graph truncated at MCP_MAX_CALLER_NODES; more tests may exist)\n")
+		}
+
+		return mcp.NewToolResultText(sb.String()), nil
+	})
+}
+
+// registerFindDuplicates registers the find_duplicates tool
+func registerFindDuplicates(s *server.MCPServer, idx *indexer.Indexer) {
+	tool := mcp.NewTool("find_duplicates",
+		mcp.WithDescription(`Find copy-pasted or near-identical code elsewhere in the index.
+
+For each chunk of a file (or the chunks of a symbol), looks up the chunks whose embeddings are almost the same and reports the pairs with their similarity. Chunks in the same file are not reported. Use it to spot duplication before refactoring or when fixing a bug that may have been copied.`),
+		mcp.WithString("file",
+			mcp.Description("File whose chunks to check, e.g. './indexer/indexer.go'"),
+		),
+		mcp.WithString("symbol",
+			mcp.Description("Function, method or class to check, when no file is given"),
+		),
+		mcp.WithString("path",
+			mcp.Description("Only report duplicates within this project or subdirectory (default: current directory)"),
+		),
+		mcp.WithNumber("min_similarity",
+			mcp.Description(fmt.Sprintf("Similarity threshold (0.0-1.0) for a pair to count as duplicates (default: %.2f)", indexer.DefaultDuplicateSimilarity)),
+		),
+	)
+
+	s.AddTool(tool, func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		file := req.GetString("file", "")
+		symbol := req.GetString("symbol", "")
+		if strings.TrimSpace(file) == "" && strings.TrimSpace(symbol) == "" {
+			return toolError("Find duplicates", types.NewError(types.ErrCodeInvalidRequest, "file or symbol parameter is required", nil)), nil
+		}
+
+		path := req.GetString("path", ".")
+		if strings.TrimSpace(path) == "" {
+			path = "."
+		}
+
+		minSimilarity := float32(req.GetFloat("min_similarity", indexer.DefaultDuplicateSimilarity))
+		pairs, truncated, err := idx.FindDuplicates(ctx, file, symbol, path, minSimilarity)
+		if err != nil {
+			return toolError("Find duplicates", err), nil
+		}
+
+		target := file
+		if target == "" {
+			target = symbol
+		}
+		if len(pairs) == 0 {
+			return mcp.NewToolResultText(fmt.Sprintf("No duplicates of %s found.", target)), nil
+		}
+
+		var sb strings.Builder
+		sb.WriteString(fmt.Sprintf("Found %d duplicate pairs for %s:\n", len(pairs), target))
+		for _, p := range pairs {
+			sb.WriteString(fmt.Sprintf("\n- %.1f%%  %s  <->  %s", p.Similarity*100, describeDuplicate(p.Chunk), describeDuplicate(p.Duplicate)))
+		}
+		sb.WriteString("\n")
+		if truncated {
+			sb.WriteString("\n(only the first chunks were checked; narrow the request to see more)\n")
+		}
+
+		return mcp.NewToolResultText(sb.String()), nil
+	})
+}
+
+// describeDuplicate formats one side of a duplicate pair as "name path:start-end"
+func describeDuplicate(c types.DuplicateChunk) string {
+	location := fmt.Sprintf("%s:%d-%d", c.FilePath, c.StartLine, c.EndLine)
+	if c.Name == "" {
+		return location
+	}
+	return c.Name + " " + location
+}
+
+// registerGotoDefinition registers the goto_definition tool
+func registerGotoDefinition(s *server.MCPServer, idx *indexer.Indexer) {
+	tool := mcp.NewTool("goto_definition",
+		mcp.WithDescription(`Jump to the definition of a function, method or class called in a snippet.
+
+Returns the defining file:line and its code. Accepts names as written at the call site ("doThing", "doThing()", "client.Fetch"). When several definitions exist, pass the calling file to prefer the one in the same package/project.`),
+		mcp.WithString("symbol",
+			mcp.Required(),
+			mcp.Description("Name of the called symbol, e.g. 'doThing' or 'Store.Search'"),
+		),
+		mcp.WithString("from",
+			mcp.Description("File containing the call, used to pick the closest definition (e.g. './indexer/indexer.go')"),
+		),
+	)
+
+	s.AddTool(tool, func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		symbol, err := req.RequireString("symbol")
+		if err != nil || strings.TrimSpace(symbol) == "" {
+			return toolError("Goto definition", types.NewError(types.ErrCodeInvalidRequest, "symbol parameter is required", nil)), nil
+		}
+
+		definitions, err := idx.GotoDefinition(ctx, symbol, req.GetString("from", ""))
+		if err != nil {
+			return toolError("Goto definition", err), nil
+		}
+
+		if len(definitions) == 0 {
+			return mcp.NewToolResultText(fmt.Sprintf("No definition of %s found in the index.", symbol)), nil
+		}
+
+		return mcp.NewToolResultText(formatDefinitions(definitions)), nil
+	})
+}
+
+// formatDefinitions shows the best definition with its code, then any others as locations
+func formatDefinitions(definitions []types.Definition) string {
+	var sb strings.Builder
+	best := definitions[0]
+	sb.WriteString(fmt.Sprintf("%s (%s %s) %s:%d-%d\n\n", best.Name, best.Language, best.ChunkType, best.FilePath, best.Line, best.EndLine))
+	sb.WriteString("```" + best.Language + "\n")
+	sb.WriteString(strings.TrimRight(best.Content, "\n"))
+	sb.WriteString("\n```\n")
+
+	if len(definitions) > 1 {
+		sb.WriteString(fmt.Sprintf("\nOther definitions (%d):\n", len(definitions)-1))
+		for _, d := range definitions[1:] {
+			sb.WriteString(fmt.Sprintf("- %s (%s) %s:%d\n", d.Name, d.ChunkType, d.FilePath, d.Line))
+		}
+	}
+
+	return sb.String()
+}
+
+// registerPruneProjects registers the prune_projects tool
+func registerPruneProjects(s *server.MCPServer, idx *indexer.Indexer) {
+	tool := mcp.NewTool("prune_projects",
+		mcp.WithDescription(`Check that every indexed or watched project folder still exists on disk.
+
+Stops file watchers of folders that were deleted or moved. With remove_chunks, also removes their chunks from the index.`),
+		mcp.WithBoolean("remove_chunks",
+			mcp.Description("Also remove indexed chunks of missing folders (default: false)."),
+		),
+	)
+
+	s.AddTool(tool, func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		result, err := idx.PruneProjects(ctx, req.GetBool("remove_chunks", false))
+		if err != nil {
+			return toolError("Prune", err), nil
+		}
+
+		return mcp.NewToolResultText(formatPruneResult(result)), nil
+	})
+}
+
+// toolErrorHints are follow-up suggestions shown for some error codes
+var toolErrorHints = map[types.ErrorCode]string{
+	types.ErrCodeOllamaDown:           "Start Ollama (ollama serve) and try again.",
+	types.ErrCodeEmbeddingUnavailable: "Check that the embedding model is pulled and Ollama is not overloaded.",
+	types.ErrCodeNotIndexed:           "Index a folder first (it is indexed automatically on startup when MCP_AUTO_INDEX is on).",
+	types.ErrCodeBusy:                 "Wait for the running indexing to finish.",
+	types.ErrCodeDimensionMismatch:    "Restart the server to rebuild the index for the model's new embedding dimension.",
+	types.ErrCodeModelMismatch:        "Clear the index, or restart with MCP_REEMBED_ON_MODEL_CHANGE=true to re-embed it with the new model.",
+}
+
+// toolError formats every failed tool call the same way, with its error code
+// so clients can branch on it: "Search failed [ollama_down]: ...", plus a hint
+func toolError(action string, err error) *mcp.CallToolResult {
+	code := types.ErrorCodeOf(err)
+	msg := fmt.Sprintf("%s failed [%s]: %v", action, code, err)
+	if hint := toolErrorHints[code]; hint != "" {
+		msg += "\n" + hint
+	}
+	return mcp.NewToolResultError(msg)
+}
+
+// formatPruneResult formats a prune result as plain text
+func formatPruneResult(r *types.PruneResult) string {
+	var sb strings.Builder
+
+	sb.WriteString(fmt.Sprintf("Checked %d projects, %d missing on disk\n", r.Checked, len(r.Pruned)))
+	for _, p := range r.Pruned {
+		sb.WriteString(fmt.Sprintf("\n- %s (gone)", p.Path))
+		if p.WatcherStopped {
+			sb.WriteString(", watcher stopped")
+		}
+		if r.ChunksRemoved {
+			sb.WriteString(fmt.Sprintf(", removed %d files / %d chunks", p.FilesRemoved, p.ChunksRemoved))
+		}
+	}
+	if len(r.Pruned) > 0 && !r.ChunksRemoved {
+		sb.WriteString("\n\nChunks were kept; call again with remove_chunks=true to delete them.")
+	}
+	sb.WriteString("\n")
+
+	return sb.String()
+}
+
+// formatAPISurface formats a page of exported symbols grouped by file as plain text
+func formatAPISurface(result *types.APISurfaceResult) string {
+	var sb strings.Builder
+
+	if len(result.Symbols) == result.Total {
+		sb.WriteString(fmt.Sprintf("Found %d exported symbols:\n", result.Total))
+	} else {
+		sb.WriteString(fmt.Sprintf("Found %d exported symbols, showing %d-%d:\n",
+			result.Total, result.Offset+1, result.Offset+len(result.Symbols)))
+	}
+
+	currentFile := ""
+	for _, sym := range result.Symbols {
+		if sym.FilePath != currentFile {
+			currentFile = sym.FilePath
+			sb.WriteString(fmt.Sprintf("\n== %s ==\n", currentFile))
+		}
+		sb.WriteString(fmt.Sprintf("  %d: %s (%s)  %s\n", sym.Line, sym.Name, sym.ChunkType, sym.Signature))
+	}
+
+	if next := result.Offset + len(result.Symbols); next < result.Total {
+		sb.WriteString(fmt.Sprintf("\n(truncated, %d more; call again with offset=%d)\n", result.Total-next, next))
+	}
+
+	return sb.String()
+}
+
+// formatSymbolHistory formats symbol history as plain text for AI consumption
+func formatSymbolHistory(h *types.SymbolHistory) string {
+	var sb strings.Builder
+
+	sb.WriteString(fmt.Sprintf("History of %s (%s:%s): %d commits\n", h.Symbol, h.FilePath, h.Lines, len(h.Commits)))
+	if len(h.Commits) == 0 {
+		sb.WriteString("\nNo commits touched this line range.\n")
+		return sb.String()
+	}
+
+	for _, c := range h.Commits {
+		sha := c.SHA
+		if len(sha) > 12 {
+			sha = sha[:12]
+		}
+		sb.WriteString(fmt.Sprintf("\n%s %s %s: %s\n", sha, c.Date, c.Author, c.Subject))
+		if c.Diff != "" {
+			sb.WriteString("   ```diff\n")
+			for _, line := range strings.Split(c.Diff, "\n") {
+				sb.WriteString("   " + line + "\n")
+			}
+			sb.WriteString("   ```\n")
+		}
+	}
+
+	return sb.String()
+}
+
+// groupResults reorders results so that results sharing a group key are adjacent.
+// Groups are ordered by their best-ranked result; order within a group is preserved.
+func groupResults(results []types.SearchResult, groupBy string) []types.SearchResult {
+	if groupBy != "file" && groupBy != "type" {
+		return results
+	}
+
+	order := make([]string, 0)
+	groups := make(map[string][]types.SearchResult)
+	for _, r := range results {
+		key := groupKey(r, groupBy)
+		if _, ok := groups[key]; !ok {
+			order = append(order, key)
+		}
+		groups[key] = append(groups[key], r)
+	}
+
+	grouped := make([]types.SearchResult, 0, len(results))
+	for _, key := range order {
+		grouped = append(grouped, groups[key]...)
+	}
+	return grouped
+}
+
+// groupKey returns the group a result belongs to, or "" when grouping is disabled
+func groupKey(r types.SearchResult, groupBy string) string {
+	switch groupBy {
+	case "file":
+		return r.FilePath
+	case "type":
+		return r.ChunkType
+	default:
+		return ""
+	}
+}
+
 // formatTextResponse formats search results as plain text for AI consumption
-func formatTextResponse(resp *types.SearchResponse) string {
+func formatTextResponse(resp *types.SearchResponse, groupBy string, precision int) string {
+	precision = min(max(precision, 0), 8)
+
 	var sb strings.Builder
 
+	if resp.Warning != "" {
+		sb.WriteString(fmt.Sprintf("Warning: %s\n\n", resp.Warning))
+	}
 	sb.WriteString(fmt.Sprintf("Found %d results:\n", resp.Count))
 
+	currentGroup := ""
 	for i, r := range resp.Results {
+		// Group header when the group changes
+		if groupBy == "file" || groupBy == "type" {
+			if key := groupKey(r, groupBy); i == 0 || key != currentGroup {
+				currentGroup = key
+				sb.WriteString(fmt.Sprintf("\n== %s: %s ==\n", groupBy, key))
+			}
+		}
+
 		// Header: name (type) file:lines [flags]
 		flags := formatFlags(r.Usage)
+		if r.Duplicates > 0 {
+			flags += fmt.Sprintf(" (%d duplicates)", r.Duplicates)
+		}
+		if r.Stale {
+			flags += " [STALE: file changed since indexed, reindex needed]"
+		}
+		name := r.Name
+		if name == "" && r.Enclosing != "" {
+			name = "within " + r.Enclosing
+		}
 		sb.WriteString(fmt.Sprintf("\n%d. %s (%s) %s:%s%s\n",
-			i+1, r.Name, r.ChunkType, r.FilePath, r.Lines, flags))
+			i+1, name, r.ChunkType, r.FilePath, r.Lines, flags))
+
+		// Scores, with debug
+		if r.RawDistance != nil {
+			sb.WriteString(fmt.Sprintf("   Score: similarity %.*f, raw distance %.*f\n", precision, r.Similarity, precision, *r.RawDistance))
+		}
 
 		// Called by (for functions)
 		if r.Usage != nil && len(r.Usage.CalledBy) > 0 {
@@ -142,6 +1175,16 @@ func formatTextResponse(resp *types.SearchResponse) string {
 			sb.WriteString(fmt.Sprintf("   Used by: %s\n", strings.Join(items, ", ")))
 		}
 
+		// Why a symbol without callers is or isn't flagged unused
+		if r.Usage != nil && r.Usage.UnusedReason != "" {
+			sb.WriteString(fmt.Sprintf("   Unused check: %s\n", r.Usage.UnusedReason))
+		}
+
+		// Calls whose target no longer exists in the index
+		if r.Usage != nil && len(r.Usage.StaleCalls) > 0 {
+			sb.WriteString(fmt.Sprintf("   Possibly stale references: %s (no longer defined; renamed or removed?)\n", strings.Join(r.Usage.StaleCalls, ", ")))
+		}
+
 		// Code content (indented)
 		sb.WriteString("   ```\n")
 		for _, line := range strings.Split(r.Content, "\n") {
@@ -153,7 +1196,86 @@ func formatTextResponse(resp *types.SearchResponse) string {
 	return sb.String()
 }
 
-// formatCallerCompact formats a caller/referencer as "Name (type, file:line)"
+// dedent removes the leading whitespace shared by the non-blank lines of
+// content, so nested code reads from the left margin with its relative
+// indentation intact. Tabs and spaces are compared literally: lines indented
+// with a mix only lose their common prefix. Blank lines become empty.
+//
+// Symbol chunks start at the symbol itself, so their first line has lost its
+// indentation and is left out of the common prefix. The body of a block opened
+// by a trailing ':' (Python) keeps one indentation step below it.
+func dedent(content string) string {
+	lines := strings.Split(content, "\n")
+
+	body := lines
+	if len(lines) > 1 && leadingWhitespace(lines[0]) == "" {
+		body = lines[1:]
+	}
+
+	prefix, found := "", false
+	for _, line := range body {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		indent := leadingWhitespace(line)
+		if !found {
+			prefix, found = indent, true
+			continue
+		}
+		n := 0
+		for n < len(prefix) && n < len(indent) && prefix[n] == indent[n] {
+			n++
+		}
+		prefix = prefix[:n]
+	}
+
+	if len(body) < len(lines) && strings.HasSuffix(strings.TrimSpace(lines[0]), ":") {
+		prefix = strings.TrimSuffix(prefix, indentStep(body, prefix))
+	}
+	if prefix == "" {
+		return content
+	}
+
+	for i, line := range lines {
+		switch {
+		case strings.TrimSpace(line) == "":
+			lines[i] = ""
+		case strings.HasPrefix(line, prefix):
+			lines[i] = line[len(prefix):]
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// leadingWhitespace returns the spaces and tabs line starts with
+func leadingWhitespace(line string) string {
+	return line[:len(line)-len(strings.TrimLeft(line, " \t"))]
+}
+
+// indentStep guesses one indentation level of lines, all indented by at least
+// prefix: a tab for tab-indented code, else the smallest deeper indentation
+// found, else four spaces (or the whole prefix if shorter)
+func indentStep(lines []string, prefix string) string {
+	if strings.HasSuffix(prefix, "\t") {
+		return "\t"
+	}
+	step := 0
+	for _, line := range lines {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		if n := len(leadingWhitespace(line)) - len(prefix); n > 0 && (step == 0 || n < step) {
+			step = n
+		}
+	}
+	if step == 0 || step > len(prefix) {
+		step = min(4, len(prefix))
+	}
+	return prefix[len(prefix)-step:]
+}
+
+// formatCallerCompact formats a caller/referencer as "Name (type, file:line)",
+// with the number of call sites when the caller calls more than once
 func formatCallerCompact(c types.CallerInfo) string {
 	// Extract just filename from path
 	file := c.FilePath
@@ -169,6 +1291,9 @@ func formatCallerCompact(c types.CallerInfo) string {
 	if chunkType == "" {
 		chunkType = "func"
 	}
+	if c.Calls > 1 {
+		return fmt.Sprintf("%s (%s, %s:%d, %d calls)", c.Name, chunkType, file, c.Line, c.Calls)
+	}
 	return fmt.Sprintf("%s (%s, %s:%d)", c.Name, chunkType, file, c.Line)
 }
 
@@ -191,6 +1316,12 @@ func formatFlags(usage *types.UsageInfo) string {
 	if usage.IsTest {
 		flags = append(flags, "test")
 	}
+	if usage.Truncated {
+		flags = append(flags, "callers truncated")
+	}
+	if len(usage.StaleCalls) > 0 {
+		flags = append(flags, "stale references")
+	}
 
 	if len(flags) == 0 {
 		return ""
@@ -198,5 +1329,78 @@ func formatFlags(usage *types.UsageInfo) string {
 	return " [" + strings.Join(flags, ", ") + "]"
 }
 
-// Suppress unused import warning
-var _ = json.Marshal
+// marshalSearchResponse encodes resp as JSON of at most maxBytes (0 = no cap).
+// The usage graph is trimmed first, halving its edges until it fits; then
+// whole results are dropped from the end, along with their graph nodes and
+// edges. The output stays valid JSON; Truncated and Count then describe what
+// was kept.
+func marshalSearchResponse(resp *types.SearchResponse, maxBytes int) (string, error) {
+	data, err := json.Marshal(resp)
+	if err != nil || maxBytes <= 0 || len(data) <= maxBytes {
+		return string(data), err
+	}
+	resp.Truncated = true
+
+	over := func() (bool, error) {
+		data, err = json.Marshal(resp)
+		return err == nil && len(data) > maxBytes, err
+	}
+
+	if resp.Graph != nil {
+		pruneGraph(resp.Graph, resp.Results, -1)
+		for {
+			tooLarge, err := over()
+			if err != nil {
+				return "", err
+			}
+			if !tooLarge || len(resp.Graph.Edges) == 0 {
+				break
+			}
+			pruneGraph(resp.Graph, resp.Results, len(resp.Graph.Edges)/2)
+		}
+	}
+
+	for len(data) > maxBytes && len(resp.Results) > 0 {
+		resp.Results = resp.Results[:len(resp.Results)-1]
+		resp.Count = len(resp.Results)
+		if resp.Graph != nil {
+			pruneGraph(resp.Graph, resp.Results, -1)
+		}
+		if _, err := over(); err != nil {
+			return "", err
+		}
+	}
+
+	return string(data), nil
+}
+
+// pruneGraph keeps the first maxEdges (-1 = all) graph edges touching one of
+// results, and the nodes of results or of kept edges
+func pruneGraph(graph *types.UsageGraph, results []types.SearchResult, maxEdges int) {
+	names := make(map[string]bool, len(results))
+	for _, r := range results {
+		names[r.Name] = true
+	}
+
+	edges := graph.Edges[:0]
+	linked := make(map[string]bool)
+	for _, edge := range graph.Edges {
+		if maxEdges >= 0 && len(edges) >= maxEdges {
+			break
+		}
+		if names[edge.From] || names[edge.To] {
+			edges = append(edges, edge)
+			linked[edge.From] = true
+			linked[edge.To] = true
+		}
+	}
+	graph.Edges = edges
+
+	nodes := graph.Nodes[:0]
+	for _, node := range graph.Nodes {
+		if names[node.ID] || linked[node.ID] {
+			nodes = append(nodes, node)
+		}
+	}
+	graph.Nodes = nodes
+}
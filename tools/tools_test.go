@@ -0,0 +1,144 @@
+package tools
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+
+	"mcp-semantic-search/types"
+)
+
+// graphResponse returns a search response of n results, each called by
+// callersPer graph nodes
+func graphResponse(n, callersPer int) *types.SearchResponse {
+	resp := &types.SearchResponse{Graph: &types.UsageGraph{}}
+	for i := 0; i < n; i++ {
+		name := fmt.Sprintf("Result%d", i)
+		resp.Results = append(resp.Results, types.SearchResult{Name: name, Content: strings.Repeat("x", 200)})
+		resp.Graph.Nodes = append(resp.Graph.Nodes, types.GraphNode{ID: name})
+		for j := 0; j < callersPer; j++ {
+			caller := fmt.Sprintf("Caller%d_%d", i, j)
+			resp.Graph.Nodes = append(resp.Graph.Nodes, types.GraphNode{ID: caller})
+			resp.Graph.Edges = append(resp.Graph.Edges, types.GraphEdge{From: caller, To: name, Count: 1})
+		}
+	}
+	resp.Count = n
+	return resp
+}
+
+func TestMarshalSearchResponseTrimsGraphBeforeResults(t *testing.T) {
+	full, _ := json.Marshal(graphResponse(3, 20))
+	noGraph, _ := json.Marshal(&types.SearchResponse{Count: 3, Results: graphResponse(3, 0).Results, Graph: &types.UsageGraph{}})
+	maxBytes := (len(full) + len(noGraph)) / 2
+
+	data, err := marshalSearchResponse(graphResponse(3, 20), maxBytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var resp types.SearchResponse
+	if err := json.Unmarshal([]byte(data), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if len(data) > maxBytes || !resp.Truncated {
+		t.Fatalf("%d bytes (cap %d), truncated=%v", len(data), maxBytes, resp.Truncated)
+	}
+	if len(resp.Results) != 3 {
+		t.Errorf("kept %d results, want all 3 with a trimmed graph", len(resp.Results))
+	}
+	if len(resp.Graph.Edges) == 0 || len(resp.Graph.Edges) >= 60 {
+		t.Errorf("kept %d of 60 edges, want a trimmed graph", len(resp.Graph.Edges))
+	}
+}
+
+func TestMarshalSearchResponseDropsGraphOfDroppedResults(t *testing.T) {
+	data, err := marshalSearchResponse(graphResponse(3, 1), 700)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var resp types.SearchResponse
+	if err := json.Unmarshal([]byte(data), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Results) == 0 || len(resp.Results) == 3 {
+		t.Fatalf("kept %d results, want some dropped", len(resp.Results))
+	}
+
+	kept := make(map[string]bool)
+	for _, r := range resp.Results {
+		kept[r.Name] = true
+	}
+	for _, edge := range resp.Graph.Edges {
+		if !kept[edge.To] {
+			t.Errorf("edge %+v of a dropped result was kept", edge)
+		}
+	}
+	for _, node := range resp.Graph.Nodes {
+		if strings.HasPrefix(node.ID, "Result") && !kept[node.ID] {
+			t.Errorf("node %s of a dropped result was kept", node.ID)
+		}
+	}
+}
+
+func TestGroupResultsKeepsGroupsInBestRankOrder(t *testing.T) {
+	results := []types.SearchResult{
+		{Name: "a1", FilePath: "a.go", ChunkType: "function"},
+		{Name: "b1", FilePath: "b.go", ChunkType: "class"},
+		{Name: "a2", FilePath: "a.go", ChunkType: "class"},
+		{Name: "b2", FilePath: "b.go", ChunkType: "function"},
+	}
+	names := func(rs []types.SearchResult) string {
+		var out []string
+		for _, r := range rs {
+			out = append(out, r.Name)
+		}
+		return strings.Join(out, ",")
+	}
+
+	if got := names(groupResults(results, "file")); got != "a1,a2,b1,b2" {
+		t.Errorf("group_by=file: %s, want a1,a2,b1,b2", got)
+	}
+	if got := names(groupResults(results, "type")); got != "a1,b2,b1,a2" {
+		t.Errorf("group_by=type: %s, want a1,b2,b1,a2", got)
+	}
+	if got := names(groupResults(results, "")); got != "a1,b1,a2,b2" {
+		t.Errorf("no grouping: %s, want the ranked order", got)
+	}
+
+	text := formatTextResponse(&types.SearchResponse{Count: 4, Results: groupResults(results, "file")}, "file", 2)
+	if strings.Count(text, "== file: ") != 2 {
+		t.Errorf("want one header per file, got:\n%s", text)
+	}
+}
+
+func TestDedentKeepsRelativeIndentation(t *testing.T) {
+	tests := []struct {
+		name, content, want string
+	}{
+		{
+			"nested method",
+			"public void run() {\n        if (ready) {\n            start();\n        }\n\n    }",
+			"public void run() {\n    if (ready) {\n        start();\n    }\n\n}",
+		},
+		{
+			"python block",
+			"def run(self):\n        if self.ready:\n            self.start()\n        return True",
+			"def run(self):\n    if self.ready:\n        self.start()\n    return True",
+		},
+		{
+			"already at the margin",
+			"func Run() {\n\tstart()\n}",
+			"func Run() {\n\tstart()\n}",
+		},
+		{
+			"indented block",
+			"    x = 1\n      y = 2\n    z = 3",
+			"x = 1\n  y = 2\nz = 3",
+		},
+	}
+	for _, tt := range tests {
+		if got := dedent(tt.content); got != tt.want {
+			t.Errorf("%s: dedent =\n%s\nwant\n%s", tt.name, got, tt.want)
+		}
+	}
+}
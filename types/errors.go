@@ -0,0 +1,58 @@
+package types
+
+import "errors"
+
+// ErrorCode categorizes search/index failures so callers can tell them apart
+type ErrorCode string
+
+const (
+	ErrCodeInvalidRequest       ErrorCode = "invalid_request"       // Malformed or missing parameters
+	ErrCodeQueryEmpty           ErrorCode = "query_empty"           // Search query is empty
+	ErrCodePathNotFound         ErrorCode = "path_not_found"        // Folder does not exist
+	ErrCodePathNotAllowed       ErrorCode = "path_not_allowed"      // Folder is outside MCP_ALLOWED_ROOTS
+	ErrCodeNotIndexed           ErrorCode = "not_indexed"           // Nothing has been indexed yet
+	ErrCodeBusy                 ErrorCode = "busy"                  // Folder is already being indexed
+	ErrCodeOllamaDown           ErrorCode = "ollama_down"           // Ollama unreachable (connection failed)
+	ErrCodeEmbeddingUnavailable ErrorCode = "embedding_unavailable" // Ollama reachable but returned an error (overloaded, model missing)
+	ErrCodeDimensionMismatch    ErrorCode = "dimension_mismatch"    // Model's embedding dimension no longer matches the index
+	ErrCodeModelMismatch        ErrorCode = "model_mismatch"        // Configured model differs from the one the index was built with
+	ErrCodeInternal             ErrorCode = "internal"              // Anything else (database, I/O)
+)
+
+// Error is a search/index failure carrying an ErrorCode.
+// It may wrap an underlying error, which is included in the message.
+type Error struct {
+	Code    ErrorCode
+	Message string
+	Err     error
+}
+
+// NewError creates an Error with the given code, message and optional cause
+func NewError(code ErrorCode, message string, err error) *Error {
+	return &Error{Code: code, Message: message, Err: err}
+}
+
+func (e *Error) Error() string {
+	switch {
+	case e.Err == nil:
+		return e.Message
+	case e.Message == "":
+		return e.Err.Error()
+	default:
+		return e.Message + ": " + e.Err.Error()
+	}
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// ErrorCodeOf returns the code of the first Error in err's chain,
+// or ErrCodeInternal if there is none
+func ErrorCodeOf(err error) ErrorCode {
+	var e *Error
+	if errors.As(err, &e) {
+		return e.Code
+	}
+	return ErrCodeInternal
+}
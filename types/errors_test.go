@@ -0,0 +1,25 @@
+package types
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestErrorCodeOfFindsWrappedErrors(t *testing.T) {
+	cause := errors.New("connection refused")
+	err := fmt.Errorf("search failed: %w", NewError(ErrCodeEmbeddingUnavailable, "request error", cause))
+
+	if code := ErrorCodeOf(err); code != ErrCodeEmbeddingUnavailable {
+		t.Errorf("ErrorCodeOf = %s, want %s", code, ErrCodeEmbeddingUnavailable)
+	}
+	if !errors.Is(err, cause) {
+		t.Error("the cause is not reachable through Unwrap")
+	}
+	if got := err.Error(); got != "search failed: request error: connection refused" {
+		t.Errorf("Error() = %q", got)
+	}
+	if code := ErrorCodeOf(cause); code != ErrCodeInternal {
+		t.Errorf("ErrorCodeOf(uncoded) = %s, want %s", code, ErrCodeInternal)
+	}
+}
@@ -3,15 +3,95 @@ package types
 import (
 	"context"
 	"fmt"
+	"math"
+	"regexp"
+	"slices"
+	"strings"
 	"time"
 )
 
+// SimilarityTieEpsilon is how close two similarity scores must be to compare
+// as a tie, ordered by secondary keys instead
+const SimilarityTieEpsilon = 1e-4
+
+// SimilarityTied reports whether two similarity scores are equal or close
+// enough to be ordered by secondary keys; other scores keep their order
+func SimilarityTied(a, b float32) bool {
+	return math.Abs(float64(a)-float64(b)) <= SimilarityTieEpsilon
+}
+
 // EmbeddingFunc is the function signature for generating embeddings
 type EmbeddingFunc func(ctx context.Context, text string) ([]float32, error)
 
-// FormatForEmbedding prepares text for embedding with context prefix
-func FormatForEmbedding(language, chunkType, name, content string) string {
+// BatchEmbeddingFunc generates embeddings for several texts, in their order
+type BatchEmbeddingFunc func(ctx context.Context, texts []string) ([][]float32, error)
+
+// queryEmbeddingKey marks contexts of search query embeddings
+type queryEmbeddingKey struct{}
+
+// WithQueryEmbedding marks ctx as embedding a search query, which an
+// EmbeddingFunc serves ahead of indexing (e.g. outside MCP_EMBED_RATE_PER_SEC)
+func WithQueryEmbedding(ctx context.Context) context.Context {
+	return context.WithValue(ctx, queryEmbeddingKey{}, true)
+}
+
+// IsQueryEmbedding reports whether ctx was marked by WithQueryEmbedding
+func IsQueryEmbedding(ctx context.Context) bool {
+	query, _ := ctx.Value(queryEmbeddingKey{}).(bool)
+	return query
+}
+
+// embeddingPlaceholders are the fields an embedding template may reference
+var embeddingPlaceholders = []string{"{language}", "{type}", "{name}", "{signature}", "{content}"}
+
+var embeddingPlaceholderPattern = regexp.MustCompile(`\{[a-z_]+\}`)
+
+// embeddingTemplate is the configured embedding template (empty = built-in format)
+var embeddingTemplate string
+
+// ValidateEmbeddingTemplate checks that an embedding template only uses known
+// placeholders and includes {content}
+func ValidateEmbeddingTemplate(tmpl string) error {
+	for _, p := range embeddingPlaceholderPattern.FindAllString(tmpl, -1) {
+		if !slices.Contains(embeddingPlaceholders, p) {
+			return fmt.Errorf("unknown placeholder %s (supported: %s)", p, strings.Join(embeddingPlaceholders, ", "))
+		}
+	}
+	if !strings.Contains(tmpl, "{content}") {
+		return fmt.Errorf("template must include {content}")
+	}
+	return nil
+}
+
+// SetEmbeddingTemplate replaces the built-in embedding format with tmpl
+// (empty restores it). Call once at startup, before any chunk is embedded.
+func SetEmbeddingTemplate(tmpl string) error {
+	if tmpl != "" {
+		if err := ValidateEmbeddingTemplate(tmpl); err != nil {
+			return err
+		}
+	}
+	embeddingTemplate = tmpl
+	return nil
+}
+
+// FormatForEmbedding prepares text for embedding with context prefix.
+// signature (the declaration line) may be empty.
+func FormatForEmbedding(language, chunkType, name, signature, content string) string {
+	if embeddingTemplate != "" {
+		return strings.NewReplacer(
+			"{language}", language,
+			"{type}", chunkType,
+			"{name}", name,
+			"{signature}", signature,
+			"{content}", content,
+		).Replace(embeddingTemplate)
+	}
+
 	// Add context to help the embedding model understand the content
+	if name != "" && signature != "" {
+		return fmt.Sprintf("%s %s: %s\nsignature: %s\n%s", language, chunkType, name, signature, content)
+	}
 	if name != "" {
 		return fmt.Sprintf("%s %s: %s\n%s", language, chunkType, name, content)
 	}
@@ -28,14 +108,21 @@ type Chunk struct {
 	FilePath  string            // Relative path within project
 	StartLine int               // Starting line number
 	EndLine   int               // Ending line number
-	Metadata  map[string]string // Additional metadata for filtering
+	Metadata  map[string]string // Additional parser metadata (decorators, scores), stored as JSON
 
 	// Reference tracking for usage maps
-	Calls      []string // Functions/methods this chunk calls
-	References []string // Types/variables this chunk references
-	IsExported bool     // Whether this symbol is public/exported
-	IsTest     bool     // Whether this is in a test file
-	Parent     string   // Parent symbol (e.g., class name for methods)
+	Calls      []string       // Functions/methods this chunk calls
+	CallCounts map[string]int // Call sites per callee, for callees called more than once
+	References []string       // Types/variables this chunk references
+	IsExported bool           // Whether this symbol is public/exported
+	IsTest     bool           // Whether this is in a test file
+	Parent     string         // Parent symbol (e.g., class name for methods)
+	Signature  string         // Declaration before the body, e.g. "func Load(ctx context.Context) error"
+
+	ModTime int64    // Source file modification time (Unix seconds)
+	Imports []string // Import paths of the chunk's file (file-level, stored once per file)
+
+	FileContent string // Full content of the chunk's file, set with MCP_STORE_FULL_FILES (stored once per file)
 }
 
 // ChunkType represents the type of code chunk
@@ -47,6 +134,21 @@ const (
 	ChunkTypeMethod   ChunkType = "method"
 	ChunkTypeBlock    ChunkType = "block"
 	ChunkTypeFile     ChunkType = "file"
+
+	// DocChunkName is the name of comment/docstring chunks (type block)
+	DocChunkName = "doc"
+
+	// MetadataComplexity is the chunk metadata key holding a function's
+	// estimated cyclomatic complexity
+	MetadataComplexity = "complexity"
+
+	// MetadataTruncatedLines is the chunk metadata key holding how many
+	// trailing lines of a symbol were left out of its chunk (MCP_MAX_SYMBOL_PARTS)
+	MetadataTruncatedLines = "truncated_lines"
+
+	// MetadataMerged is the chunk metadata key listing the names of the
+	// symbols merged into one chunk (MCP_MERGE_SMALL_CHUNKS), comma separated
+	MetadataMerged = "merged"
 )
 
 // FileInfo represents a file to be indexed
@@ -79,9 +181,19 @@ type SearchResult struct {
 	ChunkType    string  `json:"chunk_type"`     // function, class, etc.
 	Name         string  `json:"name"`           // Function/class name
 	Lines        string  `json:"lines"`          // e.g., "45-78"
+	StartLine    int     `json:"start_line"`     // First line of the chunk (1-based)
+	EndLine      int     `json:"end_line"`       // Last line of the chunk (inclusive)
 	Content      string  `json:"content"`        // The matching code
 	Similarity   float32 `json:"similarity"`     // Cosine similarity score
 	Language     string  `json:"language"`       // Programming language
+	Signature    string  `json:"signature,omitempty"` // Declaration before the body, e.g. "func Foo(a int) error"
+	ModTime      int64   `json:"mod_time,omitempty"` // Source file modification time (Unix seconds)
+	LastIndexed  int64   `json:"last_indexed,omitempty"` // When the chunk was indexed (Unix seconds)
+	Stale        bool    `json:"stale,omitempty"` // File changed on disk since indexing; reindex needed
+	RawDistance  *float64 `json:"raw_distance,omitempty"` // Cosine distance from the vector index, before keyword boosts (SearchOptions.Debug)
+	Duplicates   int     `json:"duplicates,omitempty"` // Other chunks with identical content (MCP_DEDUP_CHUNKS)
+	Enclosing    string  `json:"enclosing,omitempty"` // Nearest preceding named symbol, for nameless block chunks
+	Metadata     map[string]string `json:"metadata,omitempty"` // Parser-supplied chunk metadata (Chunk.Metadata)
 
 	// Usage map information
 	Usage *UsageInfo `json:"usage,omitempty"` // Usage information (callers, calls, etc.)
@@ -96,6 +208,9 @@ type UsageInfo struct {
 	IsTest       bool         `json:"is_test"`                 // Whether in test file
 	IsUnused     bool         `json:"is_unused"`               // Never called (and exported)
 	NotTested    bool         `json:"not_tested"`              // Not called from any test
+	Truncated    bool         `json:"truncated,omitempty"`     // Caller/referencer traversal hit MCP_MAX_CALLER_NODES
+	UnusedReason string       `json:"unused_reason,omitempty"` // Why a symbol with no callers is (or is not) flagged unused
+	StaleCalls   []string     `json:"stale_calls,omitempty"`   // Calls to symbols no longer defined (possibly stale after a rename)
 }
 
 // CallerInfo represents a caller/referencer of a function or type
@@ -107,6 +222,7 @@ type CallerInfo struct {
 	IsTest   bool   `json:"is_test"`             // Whether caller is a test
 	Parent   string `json:"parent,omitempty"`    // Parent class/struct (for methods)
 	Type     string `json:"type,omitempty"`      // Chunk type (function, class, method)
+	Calls    int    `json:"calls,omitempty"`     // Call sites of the symbol in the caller (callers only)
 }
 
 // SearchResponse is the full response for a search query
@@ -114,6 +230,8 @@ type SearchResponse struct {
 	Count   int             `json:"count"`             // Number of results
 	Results []SearchResult  `json:"results"`           // Search results
 	Graph   *UsageGraph     `json:"graph,omitempty"`   // Optional usage graph
+	Truncated bool          `json:"truncated,omitempty"` // Results were dropped to fit a size cap (JSON max_bytes)
+	Warning   string        `json:"warning,omitempty"`   // Index-wide problem affecting result quality (e.g. embedding model drift)
 }
 
 // UsageGraph represents the call graph for search results
@@ -148,7 +266,13 @@ type IndexResult struct {
 	TimeTakenMs  int64  `json:"time_taken_ms"`
 	Skipped      int    `json:"skipped,omitempty"`  // Files skipped (unchanged)
 	Deleted      int    `json:"deleted,omitempty"`  // Files deleted
+	ParseFallbacks int  `json:"parse_fallbacks,omitempty"` // Files tree-sitter could not chunk (fallback chunking used)
+	Failed       []string `json:"failed,omitempty"`        // Files that could not be indexed, as "path: reason" (status is "partial")
+	SinceRef     string `json:"since_ref,omitempty"`      // Git ref the run was limited to changes since (IndexProjectSince)
 	Error        string `json:"error,omitempty"`
+
+	ByLanguage   map[string]int `json:"by_language,omitempty"`    // Chunks stored per language
+	BySymbolType map[string]int `json:"by_symbol_type,omitempty"` // Chunks stored per chunk type (function, class, ...)
 }
 
 // StatusResult represents the overall status of the server
@@ -156,10 +280,162 @@ type StatusResult struct {
 	Version        string `json:"version"`                  // Application version
 	TotalChunks    int    `json:"total_chunks"`
 	OllamaStatus   string `json:"ollama_status"`            // connected, disconnected
+	EmbeddingError string `json:"embedding_error,omitempty"` // Set when indexing is blocked (e.g. embedding dimension changed)
+	EmbeddingWarning string `json:"embedding_warning,omitempty"` // Set when the index is stale (e.g. model weights changed); reindex recommended
 	DBPath         string `json:"db_path"`
 	CurrentFolder  string `json:"current_folder,omitempty"` // Current working directory
 	CallerSymbols  int    `json:"caller_symbols,omitempty"` // Number of distinct called symbols
 	CallerEntries  int    `json:"caller_entries,omitempty"` // Total caller entries
+	ByLanguage     map[string]int `json:"by_language,omitempty"` // Chunk count per language
+	ByType         map[string]int `json:"by_type,omitempty"`     // Chunk count per chunk type
+	Watchers       []WatcherStats `json:"watchers,omitempty"`    // Active file watchers
+}
+
+// ModelTestResult is the outcome of embedding a sample with a candidate model (test_model)
+type ModelTestResult struct {
+	Model          string `json:"model"`           // Model that was tried
+	Dimension      int    `json:"dimension"`       // Length of the vectors it returns
+	LatencyMs      int64  `json:"latency_ms"`      // Time to embed the sample
+	IndexModel     string `json:"index_model"`     // Model the index is built with
+	IndexDimension int    `json:"index_dimension"` // Vector dimension of the index
+	SameDimension  bool   `json:"same_dimension"`  // Whether the model's vectors fit the existing vector table
+}
+
+// WatcherStats describes the file watcher of one project
+type WatcherStats struct {
+	Path          string `json:"path"`           // Watched project folder
+	WatchedDirs   int    `json:"watched_dirs"`   // Directories registered with the OS watcher
+	PendingEvents int    `json:"pending_events"` // Changes waiting for the debounce to flush
+	Idle          bool   `json:"idle,omitempty"`       // Stopped for inactivity (MCP_WATCH_IDLE_MIN); restarts on the next search
+	Restarts      int    `json:"restarts,omitempty"`   // Times the watcher was restarted after its event loop died
+	LastError     string `json:"last_error,omitempty"` // Why the watcher last died or failed to restart
+}
+
+// APISymbol is an exported symbol in a project's public API surface
+type APISymbol struct {
+	Name      string `json:"name"`
+	ChunkType string `json:"chunk_type"`
+	FilePath  string `json:"file_path"` // Relative to cwd (absolute when stored)
+	Line      int    `json:"line"`
+	Signature string `json:"signature"` // First line of the declaration
+}
+
+// APISurfaceResult is one page of a project's exported symbols
+type APISurfaceResult struct {
+	Symbols []APISymbol `json:"symbols"`
+	Total   int         `json:"total"`  // Exported symbols in the project, before paging
+	Offset  int         `json:"offset"` // Index of the first returned symbol
+}
+
+// ComplexSymbol is a function or method ranked by estimated cyclomatic complexity
+type ComplexSymbol struct {
+	Name       string `json:"name"`
+	ChunkType  string `json:"chunk_type"`
+	FilePath   string `json:"file_path"` // Relative to cwd (absolute when stored)
+	StartLine  int    `json:"start_line"`
+	EndLine    int    `json:"end_line"`
+	Language   string `json:"language"`
+	Complexity int    `json:"complexity"`
+}
+
+// DuplicateChunk identifies one side of a DuplicatePair
+type DuplicateChunk struct {
+	Name      string `json:"name,omitempty"`
+	ChunkType string `json:"chunk_type"`
+	FilePath  string `json:"file_path"` // Relative to cwd (absolute when stored)
+	StartLine int    `json:"start_line"`
+	EndLine   int    `json:"end_line"`
+}
+
+// DuplicatePair is a chunk and a near-identical chunk in another file, as
+// returned by find_duplicates
+type DuplicatePair struct {
+	Chunk      DuplicateChunk `json:"chunk"`
+	Duplicate  DuplicateChunk `json:"duplicate"`
+	Similarity float32        `json:"similarity"`
+}
+
+// DependencyNode is an indexed file and the import paths it declares
+type DependencyNode struct {
+	Path     string   `json:"path"` // Relative to the graph root
+	Language string   `json:"language"`
+	Imports  []string `json:"imports,omitempty"`
+}
+
+// DependencyEdge is an import of one project file by another
+type DependencyEdge struct {
+	From   string `json:"from"`
+	To     string `json:"to"`
+	Import string `json:"import"` // Import path as written in From
+}
+
+// DependencyGraph is the import graph of a project, as returned by
+// dependency_graph. Edges only cover imports that resolve to project files.
+type DependencyGraph struct {
+	Root  string           `json:"root"`
+	Nodes []DependencyNode `json:"nodes"`
+	Edges []DependencyEdge `json:"edges"`
+}
+
+// Implementor is a type that implements an interface
+type Implementor struct {
+	Name     string `json:"name"`
+	FilePath string `json:"file_path"` // Relative to cwd (absolute when stored)
+	Line     int    `json:"line"`
+	Language string `json:"language"`
+	Match    string `json:"match"` // "declared" (implements/extends clause) or "method set"
+}
+
+// TestCaller is a test that reaches a symbol through the call graph
+type TestCaller struct {
+	Name     string `json:"name"`
+	FilePath string `json:"file_path"` // Relative to cwd
+	Line     int    `json:"line"`
+	Language string `json:"language"`
+	Depth    int    `json:"depth"` // 1 = calls the symbol directly
+}
+
+// Definition is where a symbol is defined, as returned by goto_definition
+type Definition struct {
+	Name      string `json:"name"`
+	FilePath  string `json:"file_path"` // Relative to cwd (absolute when stored)
+	Line      int    `json:"line"`
+	EndLine   int    `json:"end_line"`
+	ChunkType string `json:"chunk_type"`
+	Language  string `json:"language"`
+	Content   string `json:"content"`
+}
+
+// RemoveResult represents the result of removing (or previewing removal of) a project
+type RemoveResult struct {
+	Path          string `json:"path"`           // Absolute path of the removed folder
+	FilesRemoved  int    `json:"files_removed"`  // Indexed files removed (or that would be)
+	ChunksRemoved int    `json:"chunks_removed"` // Chunks removed (or that would be)
+	DryRun        bool   `json:"dry_run"`        // True if nothing was deleted
+}
+
+// FeedbackEntry is one recorded judgement of a search result
+type FeedbackEntry struct {
+	QueryHash  string  `json:"query_hash"` // SHA-256 of the normalized query (the query text is not stored)
+	ChunkID    string  `json:"chunk_id"`   // Judged chunk
+	Similarity float32 `json:"similarity"` // Vector similarity between query and chunk, before ranking boosts
+	Clicked    bool    `json:"clicked"`    // True if the result was useful
+	CreatedAt  int64   `json:"created_at"` // Unix seconds
+}
+
+// PruneResult represents the result of reconciling indexed/watched folders with the disk
+type PruneResult struct {
+	Checked       int             `json:"checked"`        // Folders checked
+	Pruned        []PrunedProject `json:"pruned"`         // Folders that no longer exist
+	ChunksRemoved bool            `json:"chunks_removed"` // True if their chunks were deleted
+}
+
+// PrunedProject describes a folder found missing on disk
+type PrunedProject struct {
+	Path           string `json:"path"`            // Absolute path of the missing folder
+	WatcherStopped bool   `json:"watcher_stopped"` // True if a running watcher was stopped
+	FilesRemoved   int    `json:"files_removed"`   // Indexed files removed (0 unless chunks were removed)
+	ChunksRemoved  int    `json:"chunks_removed"`  // Chunks removed (0 unless chunks were removed)
 }
 
 // ScanResult represents the result of scanning a folder (before indexing)
@@ -174,14 +450,76 @@ type ScanResult struct {
 	ByLanguage   map[string]int `json:"by_language"` // File count by language
 }
 
+// FileStatus explains whether and how a single file is indexed
+type FileStatus struct {
+	Path        string `json:"path"`                   // Absolute file path
+	Project     string `json:"project,omitempty"`      // Indexed folder containing the file, if any
+	Language    string `json:"language"`               // Detected language
+	Exists      bool   `json:"exists"`                 // File exists on disk
+	Size        int64  `json:"size,omitempty"`         // Size on disk in bytes
+	Indexed     bool   `json:"indexed"`                // Chunks are stored for the file
+	Chunks      int    `json:"chunks"`                 // Number of stored chunks
+	StoredHash  string `json:"stored_hash,omitempty"`  // Content hash recorded at indexing
+	CurrentHash string `json:"current_hash,omitempty"` // Content hash on disk now
+	Stale       bool   `json:"stale"`                  // File changed (or was deleted) since indexing
+	Excluded    string `json:"excluded,omitempty"`     // Why indexing skips the file (gitignore, size, extension, binary, ...)
+}
+
+// IndexCheck is one rule deciding whether a file is indexed (see IndexExplanation)
+type IndexCheck struct {
+	Check  string `json:"check"`            // Rule name, e.g. "gitignore", "max_file_size", "binary"
+	Passed bool   `json:"passed"`           // The rule lets the file be indexed
+	Detail string `json:"detail,omitempty"` // Why the rule excludes the file
+}
+
+// IndexExplanation reports every indexing rule for one file (explain_index),
+// where FileStatus stops at the first rule that excludes it
+type IndexExplanation struct {
+	Path     string       `json:"path"`               // Absolute file path
+	Project  string       `json:"project,omitempty"`  // Indexed folder containing the file, if any
+	Language string       `json:"language"`           // Detected language
+	Exists   bool         `json:"exists"`             // File exists on disk
+	Size     int64        `json:"size,omitempty"`     // Size on disk in bytes
+	Indexed  bool         `json:"indexed"`            // Chunks are stored for the file
+	Chunks   int          `json:"chunks"`             // Number of stored chunks
+	Checks   []IndexCheck `json:"checks"`             // Rules in the order indexing applies them
+	Excluded string       `json:"excluded,omitempty"` // The first failed rule's detail; "" if the file is indexable
+}
+
+// SymbolHistory describes how a symbol's line range changed across commits
+type SymbolHistory struct {
+	Symbol   string         `json:"symbol"`
+	FilePath string         `json:"file_path"` // Path relative to the git root
+	Lines    string         `json:"lines"`     // Line range as currently indexed, e.g. "10-25"
+	Commits  []SymbolCommit `json:"commits"`   // Most recent first
+}
+
+// SymbolCommit is a single commit that touched a symbol's line range
+type SymbolCommit struct {
+	SHA     string `json:"sha"`
+	Author  string `json:"author"`
+	Date    string `json:"date"`
+	Subject string `json:"subject"`
+	Diff    string `json:"diff"` // Diff limited to the symbol's line range
+}
+
 // SearchOptions contains optional filters for search
 type SearchOptions struct {
-	Path          string  // Filter to subdirectory path
-	Language      string  // Filter by programming language (e.g., "go", "python")
-	ChunkType     string  // Filter by chunk type: "function", "class", "method", "all"
-	CodeOnly      bool    // Exclude non-code files (JSON, YAML, MD, etc.)
-	MinSimilarity float32 // Minimum similarity threshold (0.0-1.0)
-	Limit         int     // Maximum results to return
+	Path          string   // Filter to subdirectory path
+	Paths         []string // Restrict to these files (relative to cwd or absolute)
+	BasePath      string   // Directory that stands in for the server's cwd: result paths, Path/Paths and the outside-cwd skip (e.g. the client's cwd)
+	Language      string   // Filter by programming language (e.g., "go", "python")
+	ChunkType     string   // Filter by chunk type: "function", "class", "method", "all"
+	CodeOnly      bool     // Exclude non-code files (JSON, YAML, MD, etc.)
+	CommentsOnly  bool     // Only return comment/docstring chunks (requires MCP_INDEX_COMMENTS)
+	MinSimilarity float32  // Minimum similarity threshold (0.0-1.0)
+	MinComplexity int      // Only functions/methods with at least this estimated cyclomatic complexity (0 = off)
+	Calls         string   // Only chunks whose indexed calls include this symbol (e.g. "Exec" or "db.Exec")
+	Limit         int      // Maximum results to return
+	Sort          string   // Result order: "relevance" (default) or "recent" (by file modification time)
+	OrderBy       string   // Final ordering of selected results: "similarity" (default), "name", "path", "line"
+	Expand        bool     // Also search abbreviation/stem variants of the query (always on with MCP_QUERY_EXPANSION)
+	Debug         bool     // Report each result's raw vector distance (SearchResult.RawDistance)
 }
 
 // NonCodeLanguages lists languages that are typically config/docs, not code
@@ -199,7 +537,7 @@ var NonCodeLanguages = map[string]bool{
 
 // ProgressEvent represents a progress update during indexing
 type ProgressEvent struct {
-	Type       string  `json:"type"`        // scanning, embedding, complete, error
+	Type       string  `json:"type"`        // scanning, embedding, parse_fallback, file_error, complete, error
 	Project    string  `json:"project"`     // Project name
 	Message    string  `json:"message"`     // Human readable message
 	Current    int     `json:"current"`     // Current item number
@@ -207,4 +545,5 @@ type ProgressEvent struct {
 	Percent    float64 `json:"percent"`     // Percentage complete
 	File       string  `json:"file"`        // Current file being processed
 	Error      string  `json:"error,omitempty"` // Error message if any
+	Result     *IndexResult `json:"result,omitempty"` // Final result (on complete)
 }
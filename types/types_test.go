@@ -0,0 +1,30 @@
+package types
+
+import "testing"
+
+func TestEmbeddingTemplateChangesEmbeddedText(t *testing.T) {
+	t.Cleanup(func() { SetEmbeddingTemplate("") })
+	embed := func() string {
+		return FormatForEmbedding("go", "function", "Add", "func Add(a, b int) int", "return a + b")
+	}
+
+	if got, want := embed(), "go function: Add\nsignature: func Add(a, b int) int\nreturn a + b"; got != want {
+		t.Fatalf("built-in format = %q, want %q", got, want)
+	}
+
+	if err := SetEmbeddingTemplate("{name} ({type})\n{content}"); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := embed(), "Add (function)\nreturn a + b"; got != want {
+		t.Fatalf("templated = %q, want %q", got, want)
+	}
+
+	for _, tmpl := range []string{"{name}", "{lang}: {content}"} {
+		if err := SetEmbeddingTemplate(tmpl); err == nil {
+			t.Errorf("SetEmbeddingTemplate(%q) accepted an invalid template", tmpl)
+		}
+	}
+	if got := embed(); got != "Add (function)\nreturn a + b" {
+		t.Errorf("an invalid template replaced the valid one: %q", got)
+	}
+}
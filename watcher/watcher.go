@@ -2,14 +2,17 @@ package watcher
 
 import (
 	"context"
+	"fmt"
 	"log"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 	"sync"
 	"time"
 
 	"mcp-semantic-search/config"
+	"mcp-semantic-search/types"
 
 	"github.com/bep/debounce"
 	"github.com/fsnotify/fsnotify"
@@ -21,6 +24,8 @@ type FileHandler interface {
 	UpdateFile(ctx context.Context, folderPath, filePath string) error
 	DeleteFile(ctx context.Context, filePath string) error
 	DeleteFolder(ctx context.Context, folderPath string) error
+	// RescanFolder indexes the changes made while folderPath was not watched
+	RescanFolder(ctx context.Context, folderPath string) error
 }
 
 // Watcher monitors a project directory for file changes
@@ -32,10 +37,14 @@ type Watcher struct {
 	ignorer       *ignore.GitIgnore
 	debouncer     func(func())
 	stopChan      chan struct{}
+	done          chan struct{} // Closed when processEvents returns
+	exitReason    string        // Why processEvents returned, guarded by mu
+	startedAt     time.Time
 	mu            sync.Mutex
 	pending       map[string]fsnotify.Op
 	watchedDirs   map[string]bool // Track watched directories to detect folder deletions
 	watchedDirsMu sync.RWMutex
+	lastActivity  time.Time // Last queued file change or search, guarded by mu
 }
 
 // NewWatcher creates a new file watcher for a project
@@ -46,13 +55,15 @@ func NewWatcher(projectPath string, cfg *config.Config, handler FileHandler) (*W
 	}
 
 	w := &Watcher{
-		projectPath: projectPath,
-		cfg:         cfg,
-		handler:     handler,
-		watcher:     fsWatcher,
-		stopChan:    make(chan struct{}),
-		pending:     make(map[string]fsnotify.Op),
-		watchedDirs: make(map[string]bool),
+		projectPath:  projectPath,
+		cfg:          cfg,
+		handler:      handler,
+		watcher:      fsWatcher,
+		stopChan:     make(chan struct{}),
+		done:         make(chan struct{}),
+		pending:      make(map[string]fsnotify.Op),
+		watchedDirs:  make(map[string]bool),
+		lastActivity: time.Now(),
 	}
 
 	// Load .gitignore
@@ -76,6 +87,7 @@ func (w *Watcher) Start() error {
 	}
 
 	// Start event processing goroutine
+	w.startedAt = time.Now()
 	go w.processEvents()
 
 	return nil
@@ -87,6 +99,54 @@ func (w *Watcher) Stop() error {
 	return w.watcher.Close()
 }
 
+// stopped reports whether Stop was called, as opposed to the event loop dying
+func (w *Watcher) stopped() bool {
+	select {
+	case <-w.stopChan:
+		return true
+	default:
+		return false
+	}
+}
+
+// exit records why processEvents returned
+func (w *Watcher) exit(reason string) {
+	w.mu.Lock()
+	w.exitReason = reason
+	w.mu.Unlock()
+}
+
+// Stats returns the number of watched directories and queued events
+func (w *Watcher) Stats() types.WatcherStats {
+	w.watchedDirsMu.RLock()
+	dirs := len(w.watchedDirs)
+	w.watchedDirsMu.RUnlock()
+
+	w.mu.Lock()
+	pending := len(w.pending)
+	w.mu.Unlock()
+
+	return types.WatcherStats{
+		Path:          w.projectPath,
+		WatchedDirs:   dirs,
+		PendingEvents: pending,
+	}
+}
+
+// markActive records activity in the project, postponing an idle stop
+func (w *Watcher) markActive() {
+	w.mu.Lock()
+	w.lastActivity = time.Now()
+	w.mu.Unlock()
+}
+
+// idleSince returns when the project last had a file change or search
+func (w *Watcher) idleSince() time.Time {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.lastActivity
+}
+
 // addWatchRecursive adds a directory and all subdirectories to the watcher
 func (w *Watcher) addWatchRecursive(path string) error {
 	return filepath.Walk(path, func(p string, info os.FileInfo, err error) error {
@@ -121,9 +181,17 @@ func (w *Watcher) addWatchRecursive(path string) error {
 // shouldExcludeDir checks if a directory should be excluded from watching
 func (w *Watcher) shouldExcludeDir(name, path string) bool {
 	// Always exclude certain directories
-	if w.cfg.IsExcludedDir(name) {
+	if w.cfg.IsExcludedDir(name) || w.cfg.IsExcludedHomeDir(path) {
+		return true
+	}
+
+	// Dotfile overrides take precedence over .gitignore
+	if w.cfg.SkipDotfile(name) {
 		return true
 	}
+	if w.cfg.ForceDotfilePath(w.projectPath, path) {
+		return false
+	}
 
 	// Check .gitignore
 	if w.ignorer != nil {
@@ -148,18 +216,24 @@ func (w *Watcher) shouldProcessFile(path string) bool {
 	}
 
 	// Check file size
-	if info.Size() > w.cfg.MaxFileSize {
+	if w.cfg.ExceedsMaxFileSize(info.Size()) {
 		return false
 	}
 
 	// Check extension
-	ext := strings.ToLower(filepath.Ext(path))
+	ext := w.cfg.FilterExt(path)
 	if w.cfg.IsExcludedExt(ext) {
 		return false
 	}
 
+	// Dotfile overrides take precedence over .gitignore
+	name := filepath.Base(path)
+	if w.cfg.SkipDotfile(name) {
+		return false
+	}
+
 	// Check .gitignore
-	if w.ignorer != nil {
+	if w.ignorer != nil && !w.cfg.ForceDotfilePath(w.projectPath, path) {
 		relPath, err := filepath.Rel(w.projectPath, path)
 		if err == nil && w.ignorer.MatchesPath(relPath) {
 			return false
@@ -169,8 +243,16 @@ func (w *Watcher) shouldProcessFile(path string) bool {
 	return true
 }
 
-// processEvents handles file system events
+// processEvents handles file system events until Stop. It closes w.done on
+// return, so WatcherManager can restart a loop that died for another reason.
 func (w *Watcher) processEvents() {
+	defer close(w.done)
+	defer func() {
+		if r := recover(); r != nil {
+			w.exit(fmt.Sprintf("panic: %v", r))
+		}
+	}()
+
 	for {
 		select {
 		case <-w.stopChan:
@@ -178,12 +260,14 @@ func (w *Watcher) processEvents() {
 
 		case event, ok := <-w.watcher.Events:
 			if !ok {
+				w.exit("event channel closed")
 				return
 			}
 			w.handleEvent(event)
 
 		case err, ok := <-w.watcher.Errors:
 			if !ok {
+				w.exit("error channel closed")
 				return
 			}
 			log.Printf("Watcher error: %v", err)
@@ -200,9 +284,7 @@ func (w *Watcher) handleEvent(event fsnotify.Event) {
 				if err := w.watcher.Add(event.Name); err != nil {
 					log.Printf("Failed to watch new directory %s: %v", event.Name, err)
 				} else {
-					w.watchedDirsMu.Lock()
-					w.watchedDirs[event.Name] = true
-					w.watchedDirsMu.Unlock()
+					w.trackDir(event.Name, true)
 				}
 			}
 			return
@@ -211,25 +293,16 @@ func (w *Watcher) handleEvent(event fsnotify.Event) {
 
 	// Handle removal - check if it was a watched directory
 	if event.Has(fsnotify.Remove) || event.Has(fsnotify.Rename) {
-		w.watchedDirsMu.RLock()
-		isDir := w.watchedDirs[event.Name]
-		w.watchedDirsMu.RUnlock()
-
-		if isDir {
-			// Directory was deleted - untrack it
-			w.watchedDirsMu.Lock()
-			delete(w.watchedDirs, event.Name)
-			w.watchedDirsMu.Unlock()
-		}
+		isDir := w.isWatchedDir(event.Name)
 
 		// Queue the event for processing (file or folder)
-		w.mu.Lock()
 		if isDir {
-			w.pending[event.Name] = event.Op | 0x100 // Mark as directory with high bit
+			// Directory was deleted - untrack it
+			w.trackDir(event.Name, false)
+			w.queue(event.Name, event.Op|0x100) // Mark as directory with high bit
 		} else {
-			w.pending[event.Name] = event.Op
+			w.queue(event.Name, event.Op)
 		}
-		w.mu.Unlock()
 
 		w.debouncer(w.flushPending)
 		return
@@ -243,14 +316,41 @@ func (w *Watcher) handleEvent(event fsnotify.Event) {
 	}
 
 	// Queue the event for debounced processing
-	w.mu.Lock()
-	w.pending[event.Name] = event.Op
-	w.mu.Unlock()
+	w.queue(event.Name, event.Op)
 
 	// Debounce the flush
 	w.debouncer(w.flushPending)
 }
 
+// The helpers below unlock with defer: handleEvent runs under the recover in
+// processEvents, whose exit takes w.mu again
+
+// queue records a pending event for the next flush
+func (w *Watcher) queue(path string, op fsnotify.Op) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.pending[path] = op
+	w.lastActivity = time.Now()
+}
+
+// isWatchedDir reports whether path is a watched directory
+func (w *Watcher) isWatchedDir(path string) bool {
+	w.watchedDirsMu.RLock()
+	defer w.watchedDirsMu.RUnlock()
+	return w.watchedDirs[path]
+}
+
+// trackDir records whether path is a watched directory
+func (w *Watcher) trackDir(path string, watched bool) {
+	w.watchedDirsMu.Lock()
+	defer w.watchedDirsMu.Unlock()
+	if watched {
+		w.watchedDirs[path] = true
+	} else {
+		delete(w.watchedDirs, path)
+	}
+}
+
 // flushPending processes all pending events
 func (w *Watcher) flushPending() {
 	w.mu.Lock()
@@ -289,11 +389,32 @@ func (w *Watcher) flushPending() {
 	}
 }
 
+const (
+	// restartBaseBackoff is the delay before restarting a watcher that died;
+	// it doubles with each consecutive failure up to restartMaxBackoff
+	restartBaseBackoff = time.Second
+	restartMaxBackoff  = 5 * time.Minute
+	// restartStableAfter is how long a restarted watcher must run for its
+	// failures to no longer count as consecutive
+	restartStableAfter = 10 * time.Minute
+)
+
+// restartState tracks the failures of a project's watcher
+type restartState struct {
+	restarts    int // Restart attempts in total
+	consecutive int // Failures since the watcher last ran stably
+	lastError   string
+}
+
 // WatcherManager manages multiple project watchers
 type WatcherManager struct {
 	cfg      *config.Config
 	handler  FileHandler
 	watchers map[string]*Watcher
+	idle     map[string]bool // Projects whose watcher was stopped for inactivity (MCP_WATCH_IDLE_MIN)
+	resuming map[string]bool // Idle projects whose watcher is being restarted
+	failures map[string]*restartState
+	stopIdle chan struct{} // Ends the running monitorIdle when closed; nil if none runs
 	mu       sync.RWMutex
 }
 
@@ -303,7 +424,138 @@ func NewWatcherManager(cfg *config.Config, handler FileHandler) *WatcherManager
 		cfg:      cfg,
 		handler:  handler,
 		watchers: make(map[string]*Watcher),
+		idle:     make(map[string]bool),
+		resuming: make(map[string]bool),
+		failures: make(map[string]*restartState),
+	}
+}
+
+// monitorIdleLocked starts monitorIdle unless it is disabled or already
+// running. Caller must hold wm.mu.
+func (wm *WatcherManager) monitorIdleLocked() {
+	if wm.cfg.WatchIdleMin <= 0 || wm.stopIdle != nil {
+		return
+	}
+	wm.stopIdle = make(chan struct{})
+	go wm.monitorIdle(time.Duration(wm.cfg.WatchIdleMin)*time.Minute, wm.stopIdle)
+}
+
+// monitorIdle periodically stops watchers of projects idle for longer than
+// timeout, until stop is closed
+func (wm *WatcherManager) monitorIdle(timeout time.Duration, stop <-chan struct{}) {
+	interval := timeout / 4
+	if interval > time.Minute {
+		interval = time.Minute
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			wm.StopIdle(timeout)
+		}
+	}
+}
+
+// StopIdle stops the watchers of projects without file changes or searches in
+// the last timeout, keeping them registered so Touch can restart them.
+// Returns the stopped projects.
+func (wm *WatcherManager) StopIdle(timeout time.Duration) []string {
+	wm.mu.Lock()
+	defer wm.mu.Unlock()
+
+	cutoff := time.Now().Add(-timeout)
+	var stopped []string
+	for path, w := range wm.watchers {
+		if w.idleSince().After(cutoff) {
+			continue
+		}
+		_ = w.Stop() // The index is kept; only the OS watches are released
+		delete(wm.watchers, path)
+		wm.idle[path] = true
+		stopped = append(stopped, path)
+		log.Printf("Stopped idle watcher for %s (no activity for %s)", path, timeout)
 	}
+	sort.Strings(stopped)
+	return stopped
+}
+
+// Touch records a search in path: watched projects containing path or under
+// it count as active, and watchers stopped for inactivity are restarted in
+// the background so the search does not wait for them
+func (wm *WatcherManager) Touch(path string) {
+	wm.mu.Lock()
+	defer wm.mu.Unlock()
+
+	for project, w := range wm.watchers {
+		if related(project, path) {
+			w.markActive()
+		}
+	}
+	for project := range wm.idle {
+		if related(project, path) && !wm.resuming[project] {
+			wm.resuming[project] = true
+			go wm.resume(project)
+		}
+	}
+}
+
+// resume restarts the watcher of an idle project, then rescans the project
+// for the changes made while it was not watched
+func (wm *WatcherManager) resume(projectPath string) {
+	w, err := wm.launch(projectPath)
+
+	wm.mu.Lock()
+	delete(wm.resuming, projectPath)
+	if !wm.idle[projectPath] {
+		// Stopped or started again while the watcher was being created
+		wm.mu.Unlock()
+		if err == nil {
+			_ = w.Stop()
+		}
+		return
+	}
+	delete(wm.idle, projectPath)
+	if err == nil {
+		wm.watchers[projectPath] = w
+		go wm.supervise(projectPath, w)
+		wm.monitorIdleLocked()
+	}
+	wm.mu.Unlock()
+
+	if err != nil {
+		log.Printf("Failed to restart idle watcher for %s: %v", projectPath, err)
+		return
+	}
+	log.Printf("Restarted watcher for %s", projectPath)
+	wm.rescan(projectPath)
+}
+
+// rescan indexes the changes made in projectPath while it was not watched
+func (wm *WatcherManager) rescan(projectPath string) {
+	if err := wm.handler.RescanFolder(context.Background(), projectPath); err != nil {
+		log.Printf("Failed to rescan %s: %v", projectPath, err)
+	}
+}
+
+// related reports whether one of two paths is the other or lies under it
+func related(a, b string) bool {
+	return within(a, b) || within(b, a)
+}
+
+// within reports whether path is root or lies under it
+func within(path, root string) bool {
+	path, root = filepath.Clean(path), filepath.Clean(root)
+	if path == root {
+		return true
+	}
+	if !strings.HasSuffix(root, string(filepath.Separator)) {
+		root += string(filepath.Separator)
+	}
+	return strings.HasPrefix(path, root)
 }
 
 // StartWatching starts watching a project
@@ -316,25 +568,126 @@ func (wm *WatcherManager) StartWatching(projectPath string) error {
 		_ = w.Stop() // Ignore error when replacing watcher
 	}
 
-	// Create new watcher
-	w, err := NewWatcher(projectPath, wm.cfg, wm.handler)
-	if err != nil {
+	if err := wm.startLocked(projectPath); err != nil {
 		return err
 	}
+	delete(wm.idle, projectPath)
+	return nil
+}
 
-	if err := w.Start(); err != nil {
+// startLocked creates, starts and supervises a watcher for projectPath.
+// Caller must hold wm.mu.
+func (wm *WatcherManager) startLocked(projectPath string) error {
+	w, err := wm.launch(projectPath)
+	if err != nil {
 		return err
 	}
 
 	wm.watchers[projectPath] = w
+	go wm.supervise(projectPath, w)
+	wm.monitorIdleLocked()
 	return nil
 }
 
+// launch creates and starts a watcher for projectPath without registering it
+func (wm *WatcherManager) launch(projectPath string) (*Watcher, error) {
+	w, err := NewWatcher(projectPath, wm.cfg, wm.handler)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := w.Start(); err != nil {
+		_ = w.watcher.Close()
+		return nil, err
+	}
+	return w, nil
+}
+
+// supervise waits for w's event loop to end and, unless it was stopped,
+// restarts the project's watcher with exponential backoff
+func (wm *WatcherManager) supervise(projectPath string, w *Watcher) {
+	<-w.done
+	if w.stopped() {
+		return
+	}
+
+	// Release the dead loop's OS watches before creating new ones
+	_ = w.watcher.Close()
+
+	w.mu.Lock()
+	reason := w.exitReason
+	w.mu.Unlock()
+	log.Printf("Watcher for %s died (%s), restarting", projectPath, reason)
+
+	stable := time.Since(w.startedAt) >= restartStableAfter
+	for {
+		delay, ok := wm.recordFailure(projectPath, w, reason, stable)
+		if !ok {
+			return
+		}
+		stable = false
+		time.Sleep(delay)
+
+		wm.mu.Lock()
+		if wm.watchers[projectPath] != w {
+			wm.mu.Unlock()
+			return // Stopped or replaced while waiting
+		}
+		if _, err := os.Stat(projectPath); err != nil {
+			delete(wm.watchers, projectPath)
+			wm.mu.Unlock()
+			log.Printf("Not restarting watcher for %s: %v", projectPath, err)
+			return
+		}
+		err := wm.startLocked(projectPath)
+		wm.mu.Unlock()
+
+		if err == nil {
+			log.Printf("Restarted watcher for %s", projectPath)
+			wm.rescan(projectPath) // Events were lost while the watcher was down
+			return
+		}
+		reason = fmt.Sprintf("restart failed: %v", err)
+		log.Printf("Failed to restart watcher for %s: %v", projectPath, err)
+	}
+}
+
+// recordFailure counts a watcher failure and returns the delay before the next
+// restart attempt. ok is false when w is no longer the project's watcher.
+func (wm *WatcherManager) recordFailure(projectPath string, w *Watcher, reason string, stable bool) (delay time.Duration, ok bool) {
+	wm.mu.Lock()
+	defer wm.mu.Unlock()
+
+	if wm.watchers[projectPath] != w {
+		return 0, false
+	}
+
+	state := wm.failures[projectPath]
+	if state == nil {
+		state = &restartState{}
+		wm.failures[projectPath] = state
+	}
+	if stable {
+		state.consecutive = 0
+	}
+	state.restarts++
+	state.consecutive++
+	state.lastError = reason
+
+	delay = restartBaseBackoff
+	for i := 1; i < state.consecutive && delay < restartMaxBackoff; i++ {
+		delay *= 2
+	}
+	return min(delay, restartMaxBackoff), true
+}
+
 // StopWatching stops watching a project
 func (wm *WatcherManager) StopWatching(projectPath string) error {
 	wm.mu.Lock()
 	defer wm.mu.Unlock()
 
+	delete(wm.idle, projectPath)
+	delete(wm.failures, projectPath)
 	if w, ok := wm.watchers[projectPath]; ok {
 		err := w.Stop()
 		delete(wm.watchers, projectPath)
@@ -343,21 +696,66 @@ func (wm *WatcherManager) StopWatching(projectPath string) error {
 	return nil
 }
 
-// StopAll stops all watchers
+// StopAll stops all watchers and the idle monitor
 func (wm *WatcherManager) StopAll() {
 	wm.mu.Lock()
 	defer wm.mu.Unlock()
 
+	if wm.stopIdle != nil {
+		close(wm.stopIdle)
+		wm.stopIdle = nil
+	}
+
 	for path, w := range wm.watchers {
 		_ = w.Stop() // Ignore errors during shutdown
 		delete(wm.watchers, path)
 	}
+	wm.idle = make(map[string]bool)
+	wm.failures = make(map[string]*restartState)
 }
 
-// IsWatching checks if a project is being watched
+// IsWatching checks if a project is being watched, including a watcher
+// stopped for inactivity that will restart on the next search
 func (wm *WatcherManager) IsWatching(projectPath string) bool {
 	wm.mu.RLock()
 	defer wm.mu.RUnlock()
 	_, ok := wm.watchers[projectPath]
-	return ok
+	return ok || wm.idle[projectPath]
+}
+
+// ListWatched returns the paths of all watched projects (including idle ones), sorted
+func (wm *WatcherManager) ListWatched() []string {
+	wm.mu.RLock()
+	defer wm.mu.RUnlock()
+
+	paths := make([]string, 0, len(wm.watchers)+len(wm.idle))
+	for path := range wm.watchers {
+		paths = append(paths, path)
+	}
+	for path := range wm.idle {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// Stats returns per-project watcher statistics, sorted by path
+func (wm *WatcherManager) Stats() []types.WatcherStats {
+	wm.mu.RLock()
+	defer wm.mu.RUnlock()
+
+	stats := make([]types.WatcherStats, 0, len(wm.watchers)+len(wm.idle))
+	for path, w := range wm.watchers {
+		stat := w.Stats()
+		if state := wm.failures[path]; state != nil {
+			stat.Restarts = state.restarts
+			stat.LastError = state.lastError
+		}
+		stats = append(stats, stat)
+	}
+	for path := range wm.idle {
+		stats = append(stats, types.WatcherStats{Path: path, Idle: true})
+	}
+	sort.Slice(stats, func(i, j int) bool { return stats[i].Path < stats[j].Path })
+	return stats
 }
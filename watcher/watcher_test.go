@@ -0,0 +1,173 @@
+package watcher
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"mcp-semantic-search/config"
+)
+
+// recordingHandler records the calls made by watchers
+type recordingHandler struct {
+	updated  chan string
+	rescans  chan string
+	rescanFn func()
+}
+
+func newRecordingHandler() *recordingHandler {
+	return &recordingHandler{
+		updated: make(chan string, 16),
+		rescans: make(chan string, 16),
+	}
+}
+
+func (h *recordingHandler) UpdateFile(ctx context.Context, folderPath, filePath string) error {
+	h.updated <- filePath
+	return nil
+}
+
+func (h *recordingHandler) DeleteFile(ctx context.Context, filePath string) error { return nil }
+
+func (h *recordingHandler) DeleteFolder(ctx context.Context, folderPath string) error { return nil }
+
+func (h *recordingHandler) RescanFolder(ctx context.Context, folderPath string) error {
+	if h.rescanFn != nil {
+		h.rescanFn()
+	}
+	h.rescans <- folderPath
+	return nil
+}
+
+func testConfig() *config.Config {
+	cfg := config.DefaultConfig()
+	cfg.DebounceMs = 10
+	return cfg
+}
+
+func waitFor(t *testing.T, ch <-chan string, what string) string {
+	t.Helper()
+	select {
+	case v := <-ch:
+		return v
+	case <-time.After(5 * time.Second):
+		t.Fatalf("timed out waiting for %s", what)
+		return ""
+	}
+}
+
+func TestTouchResumesIdleWatcherInBackgroundAndRescans(t *testing.T) {
+	dir := t.TempDir()
+	handler := newRecordingHandler()
+	release := make(chan struct{})
+	handler.rescanFn = func() { <-release }
+
+	wm := NewWatcherManager(testConfig(), handler)
+	defer wm.StopAll()
+
+	if err := wm.StartWatching(dir); err != nil {
+		t.Fatal(err)
+	}
+	if stopped := wm.StopIdle(0); len(stopped) != 1 || stopped[0] != dir {
+		t.Fatalf("StopIdle = %v, want [%s]", stopped, dir)
+	}
+	if !wm.IsWatching(dir) {
+		t.Fatal("an idle project should still count as watched")
+	}
+
+	// Touch must return while the rescan is still blocked
+	touched := make(chan struct{})
+	go func() {
+		wm.Touch(dir)
+		close(touched)
+	}()
+	select {
+	case <-touched:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Touch blocked on the watcher restart")
+	}
+
+	close(release)
+	if got := waitFor(t, handler.rescans, "rescan"); got != dir {
+		t.Fatalf("rescanned %s, want %s", got, dir)
+	}
+
+	stats := wm.Stats()
+	if len(stats) != 1 || stats[0].Idle {
+		t.Fatalf("watcher not resumed: %+v", stats)
+	}
+}
+
+func TestStopAllEndsIdleMonitor(t *testing.T) {
+	cfg := testConfig()
+	cfg.WatchIdleMin = 1
+	wm := NewWatcherManager(cfg, newRecordingHandler())
+
+	if err := wm.StartWatching(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	wm.mu.RLock()
+	stop := wm.stopIdle
+	wm.mu.RUnlock()
+	if stop == nil {
+		t.Fatal("idle monitor not started with the first watcher")
+	}
+
+	wm.StopAll()
+	select {
+	case <-stop:
+	default:
+		t.Fatal("StopAll did not stop the idle monitor")
+	}
+
+	done := make(chan struct{})
+	go func() {
+		wm.monitorIdle(time.Minute, stop)
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("monitorIdle kept running after stop was closed")
+	}
+}
+
+func TestSuperviseRestartsDeadWatcherAndRescans(t *testing.T) {
+	dir := t.TempDir()
+	handler := newRecordingHandler()
+	wm := NewWatcherManager(testConfig(), handler)
+	defer wm.StopAll()
+
+	if err := wm.StartWatching(dir); err != nil {
+		t.Fatal(err)
+	}
+	wm.mu.RLock()
+	w := wm.watchers[dir]
+	wm.mu.RUnlock()
+
+	// A panic while queueing an event must not deadlock the recover
+	w.mu.Lock()
+	w.pending = nil
+	w.mu.Unlock()
+	if err := os.WriteFile(filepath.Join(dir, "main.go"), []byte("package main\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := waitFor(t, handler.rescans, "rescan after restart"); got != dir {
+		t.Fatalf("rescanned %s, want %s", got, dir)
+	}
+
+	stats := wm.Stats()
+	if len(stats) != 1 || stats[0].Restarts != 1 || !strings.HasPrefix(stats[0].LastError, "panic:") {
+		t.Fatalf("stats = %+v, want one restart after a panic", stats)
+	}
+	wm.mu.RLock()
+	restarted := wm.watchers[dir]
+	wm.mu.RUnlock()
+	if restarted == w {
+		t.Fatal("dead watcher was not replaced")
+	}
+}
@@ -6,8 +6,14 @@ import (
 	"encoding/json"
 	"fmt"
 	"log"
+	"mime"
 	"net"
 	"net/http"
+	"os"
+	pathpkg "path"
+	"path/filepath"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 
@@ -19,6 +25,23 @@ import (
 //go:embed static/*
 var staticFiles embed.FS
 
+// Static asset types that are missing from Go's built-in MIME table
+// and may be absent from the system one
+var extraMimeTypes = map[string]string{
+	".ico":   "image/x-icon",
+	".woff":  "font/woff",
+	".woff2": "font/woff2",
+	".map":   "application/json",
+}
+
+func init() {
+	for ext, typ := range extraMimeTypes {
+		if mime.TypeByExtension(ext) == "" {
+			_ = mime.AddExtensionType(ext, typ)
+		}
+	}
+}
+
 // Server represents the web UI HTTP server
 type Server struct {
 	cfg        *config.Config
@@ -69,11 +92,19 @@ func (s *Server) Start() error {
 
 	// API endpoints
 	mux.HandleFunc("/api/status", s.handleStatus)
+	mux.HandleFunc("/api/watchers", s.handleWatchers)
 	mux.HandleFunc("/api/search", s.handleSearch)
+	mux.HandleFunc("/api/search/stream", s.handleSearchStream)
 	mux.HandleFunc("/api/scan", s.handleScan)
 	mux.HandleFunc("/api/index", s.handleIndex)
 	mux.HandleFunc("/api/reindex", s.handleReindex)
+	mux.HandleFunc("/api/index-content", s.handleIndexContent)
+	mux.HandleFunc("/api/remove-content", s.handleRemoveContent)
 	mux.HandleFunc("/api/remove", s.handleRemove)
+	mux.HandleFunc("/api/clear", s.handleClear)
+	mux.HandleFunc("/api/reload", s.handleReload)
+	mux.HandleFunc("/api/feedback", s.handleFeedback)
+	mux.HandleFunc("/api/feedback/export", s.handleFeedbackExport)
 	mux.HandleFunc("/api/progress", s.handleSSE)
 
 	// Static files (embedded)
@@ -91,7 +122,7 @@ func (s *Server) Start() error {
 
 	for i := 0; i <= maxRetry; i++ {
 		testPort := s.port + i
-		listener, err = net.Listen("tcp", fmt.Sprintf(":%d", testPort))
+		listener, err = net.Listen("tcp", net.JoinHostPort(s.cfg.WebUIHost, strconv.Itoa(testPort)))
 		if err == nil {
 			selectedPort = testPort
 			break
@@ -165,27 +196,40 @@ func (s *Server) handleStatic(w http.ResponseWriter, r *http.Request) {
 		path = "/index.html"
 	}
 
-	data, err := staticFiles.ReadFile("static" + path)
+	data, err := s.readStatic(path)
 	if err != nil {
 		http.Error(w, "Not found", http.StatusNotFound)
 		return
 	}
 
-	// Set content type based on extension
-	switch {
-	case len(path) > 5 && path[len(path)-5:] == ".html":
-		w.Header().Set("Content-Type", "text/html; charset=utf-8")
-	case len(path) > 3 && path[len(path)-3:] == ".js":
-		w.Header().Set("Content-Type", "application/javascript")
-	case len(path) > 4 && path[len(path)-4:] == ".css":
-		w.Header().Set("Content-Type", "text/css")
+	// Set content type based on extension, sniffing content for unknown types
+	contentType := mime.TypeByExtension(filepath.Ext(path))
+	if contentType == "" {
+		contentType = http.DetectContentType(data)
 	}
+	w.Header().Set("Content-Type", contentType)
 
 	if _, err := w.Write(data); err != nil {
 		log.Printf("Failed to write response: %v", err)
 	}
 }
 
+// readStatic reads a static file, preferring the configured override directory
+// and falling back to the embedded files
+func (s *Server) readStatic(urlPath string) ([]byte, error) {
+	// Clean against root so the path cannot escape the static directory
+	cleanPath := pathpkg.Clean("/" + urlPath)
+
+	if s.cfg.WebUIStaticDir != "" {
+		data, err := os.ReadFile(filepath.Join(s.cfg.WebUIStaticDir, filepath.FromSlash(cleanPath)))
+		if err == nil {
+			return data, nil
+		}
+	}
+
+	return staticFiles.ReadFile("static" + cleanPath)
+}
+
 // handleSSE handles Server-Sent Events for real-time progress updates
 func (s *Server) handleSSE(w http.ResponseWriter, r *http.Request) {
 	// Set SSE headers
@@ -242,7 +286,7 @@ func (s *Server) handleStatus(w http.ResponseWriter, r *http.Request) {
 
 	status, err := s.idx.GetStatus(r.Context())
 	if err != nil {
-		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		writeError(w, err)
 		return
 	}
 
@@ -252,6 +296,22 @@ func (s *Server) handleStatus(w http.ResponseWriter, r *http.Request) {
 	writeJSON(w, http.StatusOK, status)
 }
 
+// handleWatchers lists the active file watchers with their statistics
+func (s *Server) handleWatchers(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	watchers := s.idx.WatcherStats()
+	if watchers == nil {
+		watchers = []types.WatcherStats{}
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"watchers": watchers,
+	})
+}
+
 // handleScan scans a folder without indexing
 func (s *Server) handleScan(w http.ResponseWriter, r *http.Request) {
 	if r.Method != "POST" {
@@ -264,18 +324,18 @@ func (s *Server) handleScan(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid JSON"})
+		writeError(w, types.NewError(types.ErrCodeInvalidRequest, "invalid JSON", err))
 		return
 	}
 
 	if req.Path == "" {
-		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "Path is required"})
+		writeError(w, types.NewError(types.ErrCodeInvalidRequest, "path is required", nil))
 		return
 	}
 
 	result, err := s.idx.ScanProject(r.Context(), req.Path)
 	if err != nil {
-		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		writeError(w, err)
 		return
 	}
 
@@ -284,56 +344,131 @@ func (s *Server) handleScan(w http.ResponseWriter, r *http.Request) {
 
 // handleSearch performs semantic search with usage analysis
 func (s *Server) handleSearch(w http.ResponseWriter, r *http.Request) {
+	query, opts, ok := parseSearchRequest(w, r)
+	if !ok {
+		return
+	}
+
+	// Use SearchWithUsage to get usage maps and call graphs
+	response, err := s.idx.SearchWithUsage(r.Context(), query, opts)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, response)
+}
+
+// handleSearchStream performs a semantic search and streams each result as
+// Server-Sent Events once its usage information is ready, followed by a
+// "done" event carrying the count, the final ranking (as "path:lines" keys of
+// the streamed results) and the call graph, or by an "error" event.
+func (s *Server) handleSearchStream(w http.ResponseWriter, r *http.Request) {
+	query, opts, ok := parseSearchRequest(w, r)
+	if !ok {
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "SSE not supported", http.StatusInternalServerError)
+		return
+	}
+
+	// Set SSE headers
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	response, err := s.idx.SearchWithUsageStream(r.Context(), query, opts, func(result types.SearchResult) {
+		data, _ := json.Marshal(result)
+		fmt.Fprintf(w, "event: result\ndata: %s\n\n", data)
+		flusher.Flush()
+	})
+	if err != nil {
+		data, _ := json.Marshal(map[string]string{"error": err.Error(), "code": string(types.ErrorCodeOf(err))})
+		fmt.Fprintf(w, "event: error\ndata: %s\n\n", data)
+		flusher.Flush()
+		return
+	}
+
+	order := make([]string, len(response.Results))
+	for i, result := range response.Results {
+		order[i] = result.AbsolutePath + ":" + result.Lines
+	}
+	data, _ := json.Marshal(struct {
+		Count int               `json:"count"`
+		Order []string          `json:"order"`
+		Graph *types.UsageGraph `json:"graph,omitempty"`
+	}{response.Count, order, response.Graph})
+	fmt.Fprintf(w, "event: done\ndata: %s\n\n", data)
+	flusher.Flush()
+}
+
+// parseSearchRequest decodes a search request body into a query and search
+// options, writing an error response and returning false if it is invalid.
+func parseSearchRequest(w http.ResponseWriter, r *http.Request) (string, types.SearchOptions, bool) {
 	if r.Method != "POST" {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-		return
+		return "", types.SearchOptions{}, false
 	}
 
 	var req struct {
-		Query         string  `json:"query"`
-		Project       string  `json:"project"`
-		Limit         int     `json:"limit"`
-		Language      string  `json:"language"`
-		ChunkType     string  `json:"type"`
-		CodeOnly      bool    `json:"code_only"`
-		MinSimilarity float32 `json:"min_similarity"`
+		Query         string   `json:"query"`
+		Project       string   `json:"project"`
+		Paths         []string `json:"paths"`
+		BasePath      string   `json:"base_path"`
+		Limit         int      `json:"limit"`
+		Language      string   `json:"language"`
+		ChunkType     string   `json:"type"`
+		CodeOnly      bool     `json:"code_only"`
+		CommentsOnly  bool     `json:"comments_only"`
+		MinSimilarity float32  `json:"min_similarity"`
+		MinComplexity int      `json:"min_complexity"`
+		Calls         string   `json:"calls"`
+		Sort          string   `json:"sort"`
+		OrderBy       string   `json:"order_by"`
+		Expand        bool     `json:"expand"`
+		Debug         bool     `json:"debug"`
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid JSON"})
-		return
+		writeError(w, types.NewError(types.ErrCodeInvalidRequest, "invalid JSON", err))
+		return "", types.SearchOptions{}, false
 	}
 
+	req.Query = strings.TrimSpace(req.Query)
 	if req.Query == "" {
-		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "Query is required"})
-		return
+		writeError(w, types.NewError(types.ErrCodeQueryEmpty, "query cannot be empty", nil))
+		return "", types.SearchOptions{}, false
 	}
 
 	if req.Limit <= 0 {
 		req.Limit = 5
 	}
-	if req.Limit > 50 {
-		req.Limit = 50
-	}
 
 	// Build search options
 	opts := types.SearchOptions{
 		Path:          req.Project,
+		Paths:         req.Paths,
+		BasePath:      strings.TrimSpace(req.BasePath),
 		Language:      req.Language,
 		ChunkType:     req.ChunkType,
 		CodeOnly:      req.CodeOnly,
+		CommentsOnly:  req.CommentsOnly,
 		MinSimilarity: req.MinSimilarity,
+		MinComplexity: req.MinComplexity,
+		Calls:         strings.TrimSpace(req.Calls),
 		Limit:         req.Limit,
+		Sort:          strings.ToLower(req.Sort),
+		OrderBy:       strings.ToLower(req.OrderBy),
+		Expand:        req.Expand,
+		Debug:         req.Debug,
 	}
 
-	// Use SearchWithUsage to get usage maps and call graphs
-	response, err := s.idx.SearchWithUsage(r.Context(), req.Query, opts)
-	if err != nil {
-		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
-		return
-	}
-
-	writeJSON(w, http.StatusOK, response)
+	return req.Query, opts, true
 }
 
 // handleIndex starts indexing a project
@@ -344,24 +479,36 @@ func (s *Server) handleIndex(w http.ResponseWriter, r *http.Request) {
 	}
 
 	var req struct {
-		Path  string `json:"path"`
-		Watch bool   `json:"watch"`
+		Path     string `json:"path"`
+		Watch    bool   `json:"watch"`
+		Force    bool   `json:"force"`     // Allow indexing the filesystem root or home directory
+		SinceRef string `json:"since_ref"` // Only index files changed since this git ref
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid JSON"})
+		writeError(w, types.NewError(types.ErrCodeInvalidRequest, "invalid JSON", err))
 		return
 	}
 
 	if req.Path == "" {
-		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "Path is required"})
+		writeError(w, types.NewError(types.ErrCodeInvalidRequest, "path is required", nil))
+		return
+	}
+
+	if s.idx.IsIndexing(req.Path) {
+		writeError(w, types.NewError(types.ErrCodeBusy, req.Path+" is already being indexed", nil))
+		return
+	}
+
+	if err := checkBroadRoot(req.Path, req.Force); err != nil {
+		writeError(w, err)
 		return
 	}
 
 	// Index in background
 	go func() {
 		ctx := context.Background()
-		result, err := s.idx.IndexProject(ctx, req.Path, req.Watch)
+		result, err := s.idx.IndexProjectSince(ctx, req.Path, req.SinceRef, req.Watch, req.Force)
 		if err != nil {
 			log.Printf("Indexing failed for %s: %v", req.Path, err)
 			s.broadcastProgress(types.ProgressEvent{
@@ -371,7 +518,8 @@ func (s *Server) handleIndex(w http.ResponseWriter, r *http.Request) {
 				Error:   err.Error(),
 			})
 		} else {
-			log.Printf("Indexing complete for %s: %d files, %d chunks", req.Path, result.FilesIndexed, result.ChunksStored)
+			log.Printf("Indexing complete for %s: %d files, %d chunks (by language: %v, by type: %v)",
+				req.Path, result.FilesIndexed, result.ChunksStored, result.ByLanguage, result.BySymbolType)
 		}
 	}()
 
@@ -389,23 +537,34 @@ func (s *Server) handleReindex(w http.ResponseWriter, r *http.Request) {
 	}
 
 	var req struct {
-		Path string `json:"path"`
+		Path  string `json:"path"`
+		Force bool   `json:"force"` // Allow reindexing the filesystem root or home directory
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid JSON"})
+		writeError(w, types.NewError(types.ErrCodeInvalidRequest, "invalid JSON", err))
 		return
 	}
 
 	if req.Path == "" {
-		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "Path is required"})
+		writeError(w, types.NewError(types.ErrCodeInvalidRequest, "path is required", nil))
+		return
+	}
+
+	if s.idx.IsIndexing(req.Path) {
+		writeError(w, types.NewError(types.ErrCodeBusy, req.Path+" is already being indexed", nil))
+		return
+	}
+
+	if err := checkBroadRoot(req.Path, req.Force); err != nil {
+		writeError(w, err)
 		return
 	}
 
 	// Reindex in background
 	go func() {
 		ctx := context.Background()
-		result, err := s.idx.ReindexProject(ctx, req.Path)
+		result, err := s.idx.ReindexProject(ctx, req.Path, req.Force)
 		if err != nil {
 			log.Printf("Reindexing failed for %s: %v", req.Path, err)
 			s.broadcastProgress(types.ProgressEvent{
@@ -425,6 +584,76 @@ func (s *Server) handleReindex(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+// handleIndexContent indexes content that is not on disk under a virtual path
+func (s *Server) handleIndexContent(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req struct {
+		Path     string `json:"path"`
+		Content  string `json:"content"`
+		Language string `json:"language"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, types.NewError(types.ErrCodeInvalidRequest, "invalid JSON", err))
+		return
+	}
+
+	if req.Path == "" {
+		writeError(w, types.NewError(types.ErrCodeInvalidRequest, "path is required", nil))
+		return
+	}
+
+	result, err := s.idx.IndexContent(r.Context(), req.Path, req.Content, req.Language)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"status":  "indexed",
+		"message": fmt.Sprintf("Indexed %s (%d chunks)", result.Project, result.ChunksStored),
+		"result":  result,
+	})
+}
+
+// handleRemoveContent removes content indexed under a virtual path
+func (s *Server) handleRemoveContent(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req struct {
+		Path string `json:"path"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, types.NewError(types.ErrCodeInvalidRequest, "invalid JSON", err))
+		return
+	}
+
+	if req.Path == "" {
+		writeError(w, types.NewError(types.ErrCodeInvalidRequest, "path is required", nil))
+		return
+	}
+
+	result, err := s.idx.RemoveContent(r.Context(), req.Path)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"status":  "removed",
+		"message": fmt.Sprintf("Removed %s (%d chunks)", result.Path, result.ChunksRemoved),
+		"result":  result,
+	})
+}
+
 // handleRemove removes a project from the index
 func (s *Server) handleRemove(w http.ResponseWriter, r *http.Request) {
 	if r.Method != "POST" {
@@ -433,30 +662,174 @@ func (s *Server) handleRemove(w http.ResponseWriter, r *http.Request) {
 	}
 
 	var req struct {
-		Path string `json:"path"`
+		Path   string `json:"path"`
+		DryRun bool   `json:"dry_run"`
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid JSON"})
+		writeError(w, types.NewError(types.ErrCodeInvalidRequest, "invalid JSON", err))
 		return
 	}
 
 	if req.Path == "" {
-		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "Path is required"})
+		writeError(w, types.NewError(types.ErrCodeInvalidRequest, "path is required", nil))
 		return
 	}
 
-	if err := s.idx.RemoveProject(r.Context(), req.Path); err != nil {
-		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	result, err := s.idx.RemoveProject(r.Context(), req.Path, req.DryRun)
+	if err != nil {
+		writeError(w, err)
 		return
 	}
 
-	writeJSON(w, http.StatusOK, map[string]string{
+	if req.DryRun {
+		writeJSON(w, http.StatusOK, map[string]interface{}{
+			"status":  "dry_run",
+			"message": fmt.Sprintf("Would remove %d files (%d chunks) from %s", result.FilesRemoved, result.ChunksRemoved, req.Path),
+			"result":  result,
+		})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
 		"status":  "removed",
-		"message": fmt.Sprintf("Project removed: %s", req.Path),
+		"message": fmt.Sprintf("Project removed: %s (%d files, %d chunks)", req.Path, result.FilesRemoved, result.ChunksRemoved),
+		"result":  result,
+	})
+}
+
+// handleClear removes all projects from the index
+func (s *Server) handleClear(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if err := s.idx.ClearIndex(r.Context()); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]string{
+		"status":  "cleared",
+		"message": "All projects removed from the index",
+	})
+}
+
+// handleReload reopens the database to pick up changes made by another process
+func (s *Server) handleReload(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if err := s.idx.ReloadIndex(); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]string{
+		"status":  "reloaded",
+		"message": "Database reopened; changes made by other processes are now visible",
+	})
+}
+
+// handleFeedback records whether a search result was useful (MCP_COLLECT_FEEDBACK)
+func (s *Server) handleFeedback(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req struct {
+		Query  string `json:"query"`
+		Path   string `json:"path"`
+		Line   int    `json:"line"`
+		Useful *bool  `json:"useful"` // Defaults to true
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, types.NewError(types.ErrCodeInvalidRequest, "invalid JSON", err))
+		return
+	}
+
+	req.Query = strings.TrimSpace(req.Query)
+	if req.Query == "" {
+		writeError(w, types.NewError(types.ErrCodeQueryEmpty, "query cannot be empty", nil))
+		return
+	}
+	if req.Path == "" || req.Line < 1 {
+		writeError(w, types.NewError(types.ErrCodeInvalidRequest, "path and line are required", nil))
+		return
+	}
+
+	useful := req.Useful == nil || *req.Useful
+	entry, err := s.idx.RecordFeedback(r.Context(), req.Query, req.Path, req.Line, useful)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, entry)
+}
+
+// handleFeedbackExport returns all recorded search feedback as JSON
+func (s *Server) handleFeedbackExport(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	entries, err := s.idx.ExportFeedback(r.Context())
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"count":    len(entries),
+		"feedback": entries,
 	})
 }
 
+// writeError writes an error response with an HTTP status and "code" derived
+// from the error's types.ErrorCode
+func writeError(w http.ResponseWriter, err error) {
+	code := types.ErrorCodeOf(err)
+	writeJSON(w, errorStatus(code), map[string]string{"error": err.Error(), "code": string(code)})
+}
+
+// checkBroadRoot rejects an unforced request to index the filesystem root or
+// home directory up front, instead of failing later in the background
+func checkBroadRoot(path string, force bool) error {
+	if force {
+		return nil
+	}
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return nil // IndexProject reports it
+	}
+	return indexer.CheckBroadRoot(absPath)
+}
+
+// errorStatus maps an error code to an HTTP status
+func errorStatus(code types.ErrorCode) int {
+	switch code {
+	case types.ErrCodeInvalidRequest, types.ErrCodeQueryEmpty:
+		return http.StatusBadRequest
+	case types.ErrCodePathNotFound, types.ErrCodeNotIndexed:
+		return http.StatusNotFound
+	case types.ErrCodePathNotAllowed:
+		return http.StatusForbidden
+	case types.ErrCodeBusy, types.ErrCodeDimensionMismatch, types.ErrCodeModelMismatch:
+		return http.StatusConflict
+	case types.ErrCodeOllamaDown, types.ErrCodeEmbeddingUnavailable:
+		return http.StatusServiceUnavailable
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
 // writeJSON writes a JSON response
 func writeJSON(w http.ResponseWriter, status int, data interface{}) {
 	w.Header().Set("Content-Type", "application/json")
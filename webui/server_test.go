@@ -0,0 +1,192 @@
+package webui
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"mcp-semantic-search/config"
+	"mcp-semantic-search/indexer"
+	"mcp-semantic-search/store"
+	"mcp-semantic-search/types"
+)
+
+// fakeEmbed is a deterministic embedding: texts of equal length get equal vectors
+func fakeEmbed(ctx context.Context, text string) ([]float32, error) {
+	v := make([]float32, 16)
+	v[0] = 1
+	v[len(text)%16] += 1
+	return v, nil
+}
+
+// newTestServer returns a server over an index of a small Go project, and the project directory
+func newTestServer(t *testing.T) (*Server, string) {
+	t.Helper()
+	cfg := config.DefaultConfig()
+	cfg.DBPath = t.TempDir()
+	cfg.WatchEnabled = false
+
+	st, err := store.NewStore(cfg, fakeEmbed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { st.Close() })
+	idx := indexer.NewIndexer(cfg, st, st.NewFileHashStore(), nil)
+
+	dir := t.TempDir()
+	src := "package main\n\n" +
+		"func Alpha() int { return 1 }\n\n" +
+		"func Beta(n int) int {\n\treturn n * 2\n}\n\n" +
+		"func Gamma(a, b string) string {\n\tif a == \"\" {\n\t\treturn b\n\t}\n\treturn a + b\n}\n"
+	if err := os.WriteFile(filepath.Join(dir, "main.go"), []byte(src), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := idx.IndexProject(context.Background(), dir, false, false); err != nil {
+		t.Fatal(err)
+	}
+	return NewServer(cfg, idx, 0, "test"), dir
+}
+
+func TestSearchStreamDoneCarriesServerOrder(t *testing.T) {
+	s, dir := newTestServer(t)
+	body, _ := json.Marshal(map[string]any{"query": "functions", "base_path": dir, "limit": 10})
+
+	rec := httptest.NewRecorder()
+	s.handleSearchStream(rec, httptest.NewRequest(http.MethodPost, "/api/search/stream", strings.NewReader(string(body))))
+
+	var streamed []string
+	var done struct {
+		Count int      `json:"count"`
+		Order []string `json:"order"`
+	}
+	event := ""
+	scanner := bufio.NewScanner(rec.Body)
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case strings.HasPrefix(line, "event: "):
+			event = strings.TrimPrefix(line, "event: ")
+		case strings.HasPrefix(line, "data: ") && event == "result":
+			var r types.SearchResult
+			if err := json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &r); err != nil {
+				t.Fatal(err)
+			}
+			streamed = append(streamed, r.AbsolutePath+":"+r.Lines)
+		case strings.HasPrefix(line, "data: ") && event == "done":
+			if err := json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &done); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	resp, err := s.idx.SearchWithUsage(context.Background(), "functions", types.SearchOptions{Limit: 10, BasePath: dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := make([]string, len(resp.Results))
+	for i, r := range resp.Results {
+		want[i] = r.AbsolutePath + ":" + r.Lines
+	}
+	if len(want) < 2 {
+		t.Fatalf("want several results, got %v", want)
+	}
+	if !reflect.DeepEqual(done.Order, want) {
+		t.Errorf("done order = %v, want the search order %v", done.Order, want)
+	}
+
+	sort.Strings(streamed)
+	sort.Strings(want)
+	if !reflect.DeepEqual(streamed, want) {
+		t.Errorf("streamed %v, want the same results as the order %v", streamed, want)
+	}
+}
+
+func TestErrorsCarryCodeAndHTTPStatus(t *testing.T) {
+	s, dir := newTestServer(t)
+
+	tests := []struct {
+		handler http.HandlerFunc
+		body    map[string]any
+		status  int
+		code    types.ErrorCode
+	}{
+		{s.handleScan, map[string]any{"path": filepath.Join(dir, "missing")}, http.StatusNotFound, types.ErrCodePathNotFound},
+		{s.handleSearch, map[string]any{"query": "  ", "base_path": dir}, http.StatusBadRequest, types.ErrCodeQueryEmpty},
+	}
+	for _, tt := range tests {
+		body, _ := json.Marshal(tt.body)
+		rec := httptest.NewRecorder()
+		tt.handler(rec, httptest.NewRequest(http.MethodPost, "/", strings.NewReader(string(body))))
+
+		var resp struct {
+			Error string `json:"error"`
+			Code  string `json:"code"`
+		}
+		if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+			t.Fatal(err)
+		}
+		if rec.Code != tt.status || resp.Code != string(tt.code) || resp.Error == "" {
+			t.Errorf("%v: status %d, body %+v, want %d with code %s", tt.body, rec.Code, resp, tt.status, tt.code)
+		}
+	}
+}
+
+func TestFeedbackIsRecordedAndExported(t *testing.T) {
+	s, dir := newTestServer(t)
+	post := func(body map[string]any) *httptest.ResponseRecorder {
+		data, _ := json.Marshal(body)
+		rec := httptest.NewRecorder()
+		s.handleFeedback(rec, httptest.NewRequest(http.MethodPost, "/api/feedback", strings.NewReader(string(data))))
+		return rec
+	}
+	mainGo := filepath.Join(dir, "main.go")
+
+	if rec := post(map[string]any{"query": "double a number", "path": mainGo, "line": 6}); rec.Code != http.StatusBadRequest {
+		t.Fatalf("feedback with collection disabled: status %d, want 400", rec.Code)
+	}
+
+	s.cfg.CollectFeedback = true
+	if rec := post(map[string]any{"query": "double a number", "path": mainGo, "line": 6}); rec.Code != http.StatusOK {
+		t.Fatalf("feedback: status %d: %s", rec.Code, rec.Body)
+	}
+	if rec := post(map[string]any{"query": "Double  a NUMBER", "path": mainGo, "line": 3, "useful": false}); rec.Code != http.StatusOK {
+		t.Fatalf("feedback: status %d: %s", rec.Code, rec.Body)
+	}
+
+	rec := httptest.NewRecorder()
+	s.handleFeedbackExport(rec, httptest.NewRequest(http.MethodGet, "/api/feedback/export", nil))
+	if strings.Contains(rec.Body.String(), "number") {
+		t.Fatal("export contains the query text")
+	}
+	var resp struct {
+		Count    int                   `json:"count"`
+		Feedback []types.FeedbackEntry `json:"feedback"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.Count != 2 || len(resp.Feedback) != 2 {
+		t.Fatalf("exported %+v, want 2 rows", resp)
+	}
+	first, second := resp.Feedback[0], resp.Feedback[1]
+	if !first.Clicked || second.Clicked {
+		t.Errorf("clicked = %v, %v, want true, false", first.Clicked, second.Clicked)
+	}
+	if first.QueryHash == "" || first.QueryHash != second.QueryHash {
+		t.Errorf("query hashes %q and %q should match for the same normalized query", first.QueryHash, second.QueryHash)
+	}
+	if first.ChunkID == second.ChunkID {
+		t.Errorf("both rows recorded chunk %s, want Beta then Alpha", first.ChunkID)
+	}
+	if first.Similarity <= 0 || first.Similarity > 1.0001 {
+		t.Errorf("similarity = %v, want a cosine similarity", first.Similarity)
+	}
+}